@@ -19,6 +19,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/consts"
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/library"
 	"github.com/bililive-go/bililive-go/src/listeners"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/livestate"
@@ -27,6 +28,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/pipeline"
 	"github.com/bililive-go/bililive-go/src/pipeline/stages"
 	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
 	"github.com/bililive-go/bililive-go/src/pkg/iostats"
 	"github.com/bililive-go/bililive-go/src/pkg/kliveproxy"
 	"github.com/bililive-go/bililive-go/src/pkg/launcher"
@@ -60,10 +62,18 @@ func getConfig() (*configs.Config, error) {
 		// if config is invalid, try using the config.yml file besides the executable file.
 		config, err := getConfigBesidesExecutable()
 		if err == nil {
-			return config, config.Verify()
+			return config, verifyConfig(config)
 		}
 	}
-	return config, config.Verify()
+	return config, verifyConfig(config)
+}
+
+// verifyConfig 校验配置，包括录制完成动作对应的 Pipeline 阶段选项
+func verifyConfig(config *configs.Config) error {
+	if err := config.Verify(); err != nil {
+		return err
+	}
+	return stages.ValidateRecordFinishedConfig(config)
 }
 
 func getConfigBesidesExecutable() (*configs.Config, error) {
@@ -195,6 +205,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	// 如果提供了 --verify-integrity，则按完整性清单校验录制文件后退出
+	if flag.VerifyIntegrity != nil && len(*flag.VerifyIntegrity) > 0 {
+		os.Exit(verifyIntegrity(*flag.VerifyIntegrity))
+	}
+
 	// 如果已经是由 launcher 模式启动的，或者指定了 --no-launcher 参数，跳过 launcher 检查
 	// BILILIVE_LAUNCHER=1 环境变量由 launcher 自动设置
 	// --no-launcher 参数用于开发者手动跳过（等同于设置环境变量，但无需污染当前窗口）
@@ -344,9 +359,10 @@ func main() {
 
 	ed := inst.EventDispatcher.(events.Dispatcher)
 
-	// 如果启用了云上传功能，初始化 OpenList 管理器
+	// 如果启用了云上传功能（或自定义管道中有 cloud_upload 阶段），初始化 OpenList 管理器
 	var openlistManager *openlist.Manager
-	if config.OnRecordFinished.CloudUpload.Enable {
+	if config.OnRecordFinished.CloudUpload.Enable ||
+		pipeline.GetEffectivePipelineConfig(&config.OnRecordFinished).HasStage(pipeline.StageNameCloudUpload) {
 		// 获取 OpenList 数据目录
 		openlistDataPath := config.OpenList.DataPath
 		if openlistDataPath == "" {
@@ -395,11 +411,28 @@ func main() {
 	} else {
 		inst.LiveStateManager = liveStateManager
 		inst.LiveStateStore = liveStateManager.GetStore() // 保存 store 引用供其他模块使用
+		pipelineManager.SetSessionResolver(liveStateManager.FindSessionAt)
 		if err := liveStateManager.Start(); err != nil {
 			logger.WithError(err).Warn("启动直播间状态管理器失败")
 		}
 	}
 
+	// 初始化录播资料库
+	libraryDbPath := filepath.Join(config.AppDataPath, "db", "library.db")
+	libraryManager, err := library.NewManager(libraryDbPath)
+	if err != nil {
+		logger.WithError(err).Warn("初始化录播资料库失败，资料库功能将不可用")
+	} else {
+		if liveStateManager != nil {
+			libraryManager.SetSessionResolver(liveStateManager.FindSessionAt)
+		}
+		library.RegisterEventListeners(ed, libraryManager, pipelineManager)
+		inst.LibraryManager = libraryManager
+		if err := libraryManager.Start(ctx); err != nil {
+			logger.WithError(err).Warn("启动录播资料库失败")
+		}
+	}
+
 	// 先初始化 manager（不启动），因为 server 依赖它们
 	lm := listeners.NewManager(ctx)
 	rm := recorders.NewManager(ctx)
@@ -716,6 +749,10 @@ func main() {
 		if liveStateManager != nil {
 			liveStateManager.Close()
 		}
+		// 关闭录播资料库
+		if inst.LibraryManager != nil {
+			inst.LibraryManager.Close(ctx)
+		}
 		// 停止内存监控器
 		memWatcher.Stop()
 		// 关闭 IO 统计模块
@@ -767,3 +804,32 @@ func main() {
 
 	logger.Info("Bye~")
 }
+
+// verifyIntegrity 校验目录下的录制文件并输出有问题的文件，返回进程退出码
+func verifyIntegrity(roots []string) int {
+	ok, noManifest, problems := 0, 0, 0
+	err := integrity.VerifyTree(context.Background(), roots, func(result integrity.Result) {
+		switch {
+		case result.Status == integrity.StatusOK:
+			ok++
+		case result.Status == integrity.StatusNoManifest:
+			noManifest++
+		case result.IsProblem():
+			problems++
+			if result.Message != "" {
+				fmt.Printf("%s\t%s\t%s\n", result.Status, result.Path, result.Message)
+			} else {
+				fmt.Printf("%s\t%s\n", result.Status, result.Path)
+			}
+		}
+	})
+	fmt.Fprintf(os.Stderr, "完整性校验完成: 正常 %d, 无清单 %d, 异常 %d\n", ok, noManifest, problems)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if problems > 0 {
+		return 1
+	}
+	return 0
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -60,6 +61,31 @@ type Feature struct {
 	// 当检测到视频编码参数变化（新的 SPS/PPS）时，会主动断开连接触发 FFmpeg 分段
 	// 这可以避免因编码参数变化导致的花屏问题
 	EnableFlvProxySegment bool `yaml:"enable_flv_proxy_segment,omitempty" json:"enable_flv_proxy_segment,omitempty"`
+
+	// NativeOutputFormat 原生 FLV 解析器的输出格式（仅对 native 下载器生效）
+	// 可选值: "flv" (默认，原样保存), "fmp4" (直接写入分片 MP4，无需录制后转封装)
+	// 只录音频时默认写为 ADTS（.aac），设为 "fmp4" 时写为 .m4a
+	NativeOutputFormat NativeOutputFormat `yaml:"native_output_format,omitempty" json:"native_output_format,omitempty"`
+}
+
+// NativeOutputFormat 原生 FLV 解析器的输出格式
+type NativeOutputFormat string
+
+const (
+	// NativeOutputFLV 原样保存 FLV
+	NativeOutputFLV NativeOutputFormat = "flv"
+	// NativeOutputFMP4 直接写入分片 MP4（每个 GOP 一个 moof/mdat）
+	NativeOutputFMP4 NativeOutputFormat = "fmp4"
+)
+
+// IsValid 检查输出格式是否有效，空值表示默认的 FLV
+func (f NativeOutputFormat) IsValid() bool {
+	switch f {
+	case "", NativeOutputFLV, NativeOutputFMP4:
+		return true
+	default:
+		return false
+	}
 }
 
 // GetEffectiveDownloaderType 获取实际生效的下载器类型
@@ -110,6 +136,32 @@ type OnRecordFinished struct {
 	SaveCover             bool         `yaml:"save_cover" json:"save_cover"`       // 保存视频第一帧作为封面图（.jpg）
 	CloudUpload           CloudUpload  `yaml:"cloud_upload" json:"cloud_upload"`   // 云上传配置
 	UploadTiming          UploadTiming `yaml:"upload_timing" json:"upload_timing"` // 上传时机
+	SessionMerge          SessionMerge `yaml:"session_merge" json:"session_merge"` // 按开播会话合并分段
+
+	// Pipeline 自定义后处理管道，设置后取代上面的各项开关，按阶段列表执行
+	Pipeline []PipelineStage `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+}
+
+// PipelineStage 自定义后处理管道中的一个阶段，字段与 pipeline.StageConfig 一一对应
+type PipelineStage struct {
+	ID        string          `yaml:"id,omitempty" json:"id,omitempty"`                 // 阶段标识，供 depends_on 引用
+	DependsOn []string        `yaml:"depends_on,omitempty" json:"depends_on,omitempty"` // 依赖的阶段 ID，设置后管道按 DAG 执行
+	Name      string          `yaml:"name" json:"name"`                                 // 阶段名称
+	Enabled   *bool           `yaml:"enabled,omitempty" json:"enabled,omitempty"`       // 是否启用（不填表示启用）
+	Parallel  []PipelineStage `yaml:"parallel,omitempty" json:"parallel,omitempty"`     // 并行执行的子阶段
+	Options   map[string]any  `yaml:"options,omitempty" json:"options,omitempty"`       // 阶段特定选项
+	When      string          `yaml:"when,omitempty" json:"when,omitempty"`             // 执行条件，为空表示总是执行
+
+	Retries      int    `yaml:"retries,omitempty" json:"retries,omitempty"`             // 失败后自动重试的次数
+	RetryBackoff string `yaml:"retry_backoff,omitempty" json:"retry_backoff,omitempty"` // 首次重试前的等待时间，之后每次翻倍
+	Timeout      string `yaml:"timeout,omitempty" json:"timeout,omitempty"`             // 单次执行的超时时间，为空表示不限制
+}
+
+// SessionMerge 按开播会话合并分段
+// 启用后每个分段不再单独后处理，关播后把本场的所有分段无损拼接为一个文件，再对合并结果执行其余后处理
+type SessionMerge struct {
+	Enable       bool `yaml:"enable" json:"enable"`
+	DeleteSource bool `yaml:"delete_source" json:"delete_source"` // 合并成功后删除分段文件
 }
 
 type Log struct {
@@ -215,6 +267,41 @@ var defaultUpdateConfig = UpdateConfig{
 	IncludePrerelease:  false,
 }
 
+// StallAction 录制停滞时的处理方式
+type StallAction string
+
+const (
+	// StallActionRestart 停止下载器，重新获取直播流地址后继续录制（默认）
+	StallActionRestart StallAction = "restart"
+	// StallActionLog 只记录日志，不中断录制
+	StallActionLog StallAction = "log"
+)
+
+// StallWatchdog 录制停滞检测：写入的字节数长时间没有增长时按 Action 处理
+type StallWatchdog struct {
+	// TimeoutSec 写入字节数持续多少秒没有增长视为停滞，0 表示关闭检测（默认）
+	TimeoutSec int         `yaml:"timeout_sec" json:"timeout_sec"`
+	Action     StallAction `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// minStallTimeoutSec 停滞检测的最小超时，避免网络抖动导致频繁重启
+const minStallTimeoutSec = 10
+
+func (w *StallWatchdog) verify() error {
+	if w.TimeoutSec < 0 {
+		return fmt.Errorf("录制停滞检测超时不能为负数")
+	}
+	if w.TimeoutSec > 0 && w.TimeoutSec < minStallTimeoutSec {
+		return fmt.Errorf("录制停滞检测超时最小值为 %d 秒", minStallTimeoutSec)
+	}
+	switch w.Action {
+	case "", StallActionRestart, StallActionLog:
+		return nil
+	default:
+		return fmt.Errorf("无效的录制停滞处理方式: %s", w.Action)
+	}
+}
+
 // StreamPreference 流偏好配置
 // 采用指针模式以区分"未设置"和"设置为零值"
 type StreamPreference struct {
@@ -231,6 +318,7 @@ type ResolvedStreamPreference struct {
 type OverridableConfig struct {
 	Interval             *int                  `yaml:"interval,omitempty" json:"interval,omitempty"`                             // 检测间隔(秒)
 	OutPutPath           *string               `yaml:"out_put_path,omitempty" json:"out_put_path,omitempty"`                     // 输出路径
+	ScratchPath          *string               `yaml:"scratch_path,omitempty" json:"scratch_path,omitempty"`                     // 录制临时目录，分段关闭后移动到输出路径
 	FfmpegPath           *string               `yaml:"ffmpeg_path,omitempty" json:"ffmpeg_path,omitempty"`                       // FFmpeg可执行文件路径
 	Log                  *Log                  `yaml:"log,omitempty" json:"log,omitempty"`                                       // 日志配置
 	Feature              *Feature              `yaml:"feature,omitempty" json:"feature,omitempty"`                               // 功能特性配置
@@ -239,6 +327,8 @@ type OverridableConfig struct {
 	OnRecordFinished     *OnRecordFinished     `yaml:"on_record_finished,omitempty" json:"on_record_finished,omitempty"`         // 录制完成后的动作
 	TimeoutInUs          *int                  `yaml:"timeout_in_us,omitempty" json:"timeout_in_us,omitempty"`                   // 超时设置(微秒)
 	StreamPreference     *StreamPreference     `yaml:"stream_preference,omitempty" json:"stream_preference,omitempty"`           // 流偏好配置
+	StoragePlacement     *StoragePlacement     `yaml:"storage_placement,omitempty" json:"storage_placement,omitempty"`           // 存储池放置策略
+	StallWatchdog        *StallWatchdog        `yaml:"stall_watchdog,omitempty" json:"stall_watchdog,omitempty"`                 // 录制停滞检测
 }
 
 // PlatformConfig 包含平台特定的设置
@@ -276,6 +366,7 @@ type Config struct {
 	// 全局默认配置（非指针，提供默认值）
 	Interval             int                  `yaml:"interval" json:"interval"`
 	OutPutPath           string               `yaml:"out_put_path" json:"out_put_path"`
+	ScratchPath          string               `yaml:"scratch_path,omitempty" json:"scratch_path,omitempty"`
 	FfmpegPath           string               `yaml:"ffmpeg_path" json:"ffmpeg_path"`
 	Log                  Log                  `yaml:"log" json:"log"`
 	Feature              Feature              `yaml:"feature" json:"feature"`
@@ -283,10 +374,14 @@ type Config struct {
 	VideoSplitStrategies VideoSplitStrategies `yaml:"video_split_strategies" json:"video_split_strategies"`
 	OnRecordFinished     OnRecordFinished     `yaml:"on_record_finished" json:"on_record_finished"`
 	TimeoutInUs          int                  `yaml:"timeout_in_us" json:"timeout_in_us"`
+	StallWatchdog        StallWatchdog        `yaml:"stall_watchdog" json:"stall_watchdog"`
 
 	// 流偏好配置 - 两套系统并存
 	StreamPreference StreamPreference `yaml:"stream_preference,omitempty" json:"stream_preference,omitempty"` // 新版（渐进迁移中）
 
+	// 多磁盘存储池配置
+	StoragePool StoragePool `yaml:"storage_pool,omitempty" json:"storage_pool,omitempty"`
+
 	// 直播间列表
 	LiveRooms []LiveRoom `yaml:"live_rooms" json:"live_rooms"`
 
@@ -304,6 +399,12 @@ type Config struct {
 	// 任务队列配置
 	TaskQueue TaskQueue `yaml:"task_queue" json:"task_queue"`
 
+	// 最大同时录制数，0 表示不限制
+	MaxConcurrentRecordings int `yaml:"max_concurrent_recordings,omitempty" json:"max_concurrent_recordings"`
+
+	// 录制文件落盘后生成完整性附属文件（.sha256 和 .manifest.json），在后台计算，默认开启
+	IntegritySidecar bool `yaml:"integrity_sidecar" json:"integrity_sidecar"`
+
 	// 代理配置
 	Proxy Proxy `yaml:"proxy" json:"proxy"`
 
@@ -559,6 +660,9 @@ type LiveRoom struct {
 	AudioOnly   bool         `yaml:"audio_only,omitempty" json:"audio_only,omitempty"`
 	NickName    string       `yaml:"nick_name,omitempty" json:"nick_name,omitempty"`
 	SchemeUrl   string       `yaml:"scheme" json:"scheme,omitempty"`
+	Priority    int          `yaml:"priority,omitempty" json:"priority,omitempty"` // 录制优先级，数值越大越优先，默认 0
+	// RelayTargets 录制的同时将直播流转推到这些 RTMP 地址（如 rtmp://127.0.0.1/live/room1），仅 FLV 流生效
+	RelayTargets []string `yaml:"relay_targets,omitempty" json:"relay_targets,omitempty"`
 
 	// 房间级可覆盖配置
 	OverridableConfig `yaml:",inline" json:",inline"` // 房间级配置覆盖
@@ -632,6 +736,11 @@ var defaultConfig = Config{
 		UploadTiming: UploadTimingAfterProcess,
 	},
 	TimeoutInUs: 60000000,
+	StallWatchdog: StallWatchdog{
+		TimeoutSec: 0,
+		Action:     StallActionRestart,
+	},
+	IntegritySidecar: true,
 	Notify: Notify{
 		SendRecordingSummary: false,
 		Telegram: Telegram{
@@ -734,6 +843,15 @@ func (c *Config) Verify() error {
 	if _, err := os.Stat(c.OutPutPath); err != nil {
 		return fmt.Errorf(`输出路径 "%s" 不存在`, c.OutPutPath)
 	}
+	if c.MaxConcurrentRecordings < 0 {
+		return fmt.Errorf("最大同时录制数不能为负数")
+	}
+	if err := c.StallWatchdog.verify(); err != nil {
+		return err
+	}
+	if !c.Feature.NativeOutputFormat.IsValid() {
+		return fmt.Errorf("无效的原生解析器输出格式: %s", c.Feature.NativeOutputFormat)
+	}
 	if maxDur := c.VideoSplitStrategies.MaxDuration; maxDur > 0 && maxDur < time.Minute {
 		return fmt.Errorf("单个视频的最大录制时长最小值为 1 分钟")
 	}
@@ -746,6 +864,23 @@ func (c *Config) Verify() error {
 		return err
 	}
 
+	// 验证存储池配置
+	if err := c.StoragePool.verify(); err != nil {
+		return err
+	}
+	for _, room := range c.LiveRooms {
+		if room.StoragePlacement != nil {
+			if err := c.StoragePool.verifyPlacement(*room.StoragePlacement); err != nil {
+				return fmt.Errorf("直播间 '%s': %w", room.Url, err)
+			}
+		}
+		if room.StallWatchdog != nil {
+			if err := room.StallWatchdog.verify(); err != nil {
+				return fmt.Errorf("直播间 '%s': %w", room.Url, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -790,6 +925,8 @@ func (c Config) getLiveRoomByUrlImpl(url string) (*LiveRoom, error) {
 
 func NewConfigWithBytes(b []byte) (*Config, error) {
 	config := defaultConfig
+	// yaml 会向已有的 map 中写入，不能与默认配置共用
+	config.PlatformConfigs = map[string]PlatformConfig{}
 	if err := yaml.Unmarshal(b, &config); err != nil {
 		return nil, err
 	}
@@ -870,7 +1007,7 @@ func (c Config) GetFilePath() (string, error) {
 
 // CloneConfigShallow 返回 Config 的浅克隆，并对常见可变字段做拷贝，便于进行“复制-更新-原子替换”以避免并发数据竞争。
 // 注意：该函数不会深拷贝嵌套结构中的所有指针字段，请根据需要扩展。
-// Config 结构体中还有其他复杂类型（如 RPC、Log、Feature、VideoSplitStrategies、OnRecordFinished、Notify 等嵌套结构体），
+// Config 结构体中还有其他复杂类型（如 RPC、Log、Feature、VideoSplitStrategies、Notify 等嵌套结构体），
 // 这些结构体目前仅包含字符串和基本类型，浅拷贝足够。但如果将来这些结构体中添加了指针或切片字段，需要更新克隆逻辑。
 func CloneConfigShallow(src *Config) *Config {
 	if src == nil {
@@ -878,10 +1015,18 @@ func CloneConfigShallow(src *Config) *Config {
 	}
 	cp := *src // 先按值复制（浅拷贝）
 	// 切片拷贝
+	if src.StoragePool.Roots != nil {
+		cp.StoragePool.Roots = make([]StorageRoot, len(src.StoragePool.Roots))
+		copy(cp.StoragePool.Roots, src.StoragePool.Roots)
+	}
 	if src.LiveRooms != nil {
 		cp.LiveRooms = make([]LiveRoom, len(src.LiveRooms))
 		copy(cp.LiveRooms, src.LiveRooms)
 	}
+	if src.OnRecordFinished.Pipeline != nil {
+		cp.OnRecordFinished.Pipeline = make([]PipelineStage, len(src.OnRecordFinished.Pipeline))
+		copy(cp.OnRecordFinished.Pipeline, src.OnRecordFinished.Pipeline)
+	}
 	// map 拷贝
 	if src.Cookies != nil {
 		cp.Cookies = make(map[string]string, len(src.Cookies))
@@ -914,6 +1059,7 @@ func (c *Config) ResolveConfigForRoom(room *LiveRoom, platformName string) Resol
 	resolved := ResolvedConfig{
 		Interval:             c.Interval,
 		OutPutPath:           c.OutPutPath,
+		ScratchPath:          c.ScratchPath,
 		FfmpegPath:           c.FfmpegPath,
 		Log:                  c.Log,
 		Feature:              c.Feature,
@@ -921,6 +1067,8 @@ func (c *Config) ResolveConfigForRoom(room *LiveRoom, platformName string) Resol
 		VideoSplitStrategies: c.VideoSplitStrategies,
 		OnRecordFinished:     c.OnRecordFinished,
 		TimeoutInUs:          c.TimeoutInUs,
+		StoragePlacement:     c.StoragePool.Placement,
+		StallWatchdog:        c.StallWatchdog,
 	}
 
 	// 应用平台级覆盖
@@ -972,6 +1120,7 @@ func (c *Config) syncPlatformRateLimits() {
 type ResolvedConfig struct {
 	Interval             int                  `json:"interval"`
 	OutPutPath           string               `json:"out_put_path"`
+	ScratchPath          string               `json:"scratch_path"`
 	FfmpegPath           string               `json:"ffmpeg_path"`
 	Log                  Log                  `json:"log"`
 	Feature              Feature              `json:"feature"`
@@ -980,6 +1129,8 @@ type ResolvedConfig struct {
 	OnRecordFinished     OnRecordFinished     `json:"on_record_finished"`
 	TimeoutInUs          int                  `json:"timeout_in_us"`
 	StreamPreference     StreamPreference     `json:"stream_preference"`
+	StoragePlacement     StoragePlacement     `json:"storage_placement"`
+	StallWatchdog        StallWatchdog        `json:"stall_watchdog"`
 }
 
 // applyOverrides 将可覆盖配置中的非空值应用到解析配置中
@@ -990,6 +1141,9 @@ func (r *ResolvedConfig) applyOverrides(override *OverridableConfig) {
 	if override.OutPutPath != nil {
 		r.OutPutPath = *override.OutPutPath
 	}
+	if override.ScratchPath != nil {
+		r.ScratchPath = *override.ScratchPath
+	}
 	if override.FfmpegPath != nil {
 		r.FfmpegPath = *override.FfmpegPath
 	}
@@ -1014,6 +1168,12 @@ func (r *ResolvedConfig) applyOverrides(override *OverridableConfig) {
 	if override.StreamPreference != nil {
 		r.StreamPreference = *MergeStreamPreference(&r.StreamPreference, override.StreamPreference)
 	}
+	if override.StoragePlacement != nil {
+		r.StoragePlacement = *override.StoragePlacement
+	}
+	if override.StallWatchdog != nil {
+		r.StallWatchdog = *override.StallWatchdog
+	}
 }
 
 // GetPlatformKeyFromUrl 从URL中提取平台键，用于配置查找
@@ -1069,6 +1229,39 @@ func (c *Config) GetEffectiveConfigForRoom(roomUrl string) ResolvedConfig {
 	return c.ResolveConfigForRoom(room, platformKey)
 }
 
+// GetAllOutputPaths 返回全局、存储池、平台级和房间级配置中出现过的所有输出目录（去重，保持出现顺序）
+func (c *Config) GetAllOutputPaths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(p string) {
+		if p == "" || seen[p] {
+			return
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+
+	for _, root := range c.GetStorageRoots() {
+		add(root)
+	}
+	platformKeys := make([]string, 0, len(c.PlatformConfigs))
+	for k := range c.PlatformConfigs {
+		platformKeys = append(platformKeys, k)
+	}
+	sort.Strings(platformKeys)
+	for _, k := range platformKeys {
+		if p := c.PlatformConfigs[k].OutPutPath; p != nil {
+			add(*p)
+		}
+	}
+	for _, room := range c.LiveRooms {
+		if room.OutPutPath != nil {
+			add(*room.OutPutPath)
+		}
+	}
+	return paths
+}
+
 // ValidatePlatformConfigs 验证平台配置的一致性
 func (c *Config) ValidatePlatformConfigs() error {
 	for platformKey, platformConfig := range c.PlatformConfigs {
@@ -1088,6 +1281,20 @@ func (c *Config) ValidatePlatformConfigs() error {
 				return fmt.Errorf("平台 '%s': 输出路径 '%s' 不存在", platformKey, *platformConfig.OutPutPath)
 			}
 		}
+
+		// 验证存储池放置策略（如果指定）
+		if platformConfig.StoragePlacement != nil {
+			if err := c.StoragePool.verifyPlacement(*platformConfig.StoragePlacement); err != nil {
+				return fmt.Errorf("平台 '%s': %w", platformKey, err)
+			}
+		}
+
+		// 验证录制停滞检测（如果指定）
+		if platformConfig.StallWatchdog != nil {
+			if err := platformConfig.StallWatchdog.verify(); err != nil {
+				return fmt.Errorf("平台 '%s': %w", platformKey, err)
+			}
+		}
 	}
 	return nil
 }
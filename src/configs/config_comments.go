@@ -32,6 +32,19 @@ func DecorateConfigNode(node *yaml.Node) {
 # 负数为非法值，程序会输出 log 提醒，并无视所设定的数值`, "")
 	}
 
+	setFieldComment(root, "on_record_finished",
+		`# 录制完成后的动作（可在平台/直播间级别覆盖）
+# 设置 pipeline 后按其中的阶段列表执行后处理，下面的各项开关和 custom_commandline 不再生效
+# 每个阶段支持 name、options、when、id、depends_on、parallel、retries、retry_backoff、timeout，例如：
+# pipeline:
+#   - name: fix_flv
+#   - name: transcode
+#     when: gt .Size (gb 2)
+#     options: {preset: hevc_archive}
+#   - name: s3_upload
+#     retries: 3
+#     options: {endpoint: https://s3.example.com, bucket: live}`, "")
+
 	finishNode := findNode(root, "on_record_finished")
 	if finishNode != nil {
 		setFieldComment(finishNode, "custom_commandline",
@@ -43,6 +56,35 @@ func DecorateConfigNode(node *yaml.Node) {
 #  custom_commandline: '{{ .Ffmpeg }} -hide_banner -i "{{ .FileName }}" -c copy "{{ .FileName | trimSuffix (.FileName | ext)}}.mp4"'`, "")
 	}
 
+	setFieldComment(root, "max_concurrent_recordings",
+		`# 最大同时录制数，0 表示不限制
+# 达到上限时，优先级（live_rooms 中的 priority，数值越大越优先）更高的直播间开播会抢占优先级最低的录制，
+# 否则进入等待队列，有空位时按优先级依次开始录制`, "")
+
+	setFieldComment(root, "scratch_path",
+		`# 录制临时目录（可选，可在平台/直播间级别覆盖）
+# 录制先写入本地高速存储，每个分段关闭后再移动到最终输出目录，避免直接写入较慢的 NAS 导致录制卡顿
+# 移动失败会自动重试，可通过 /api/scratch/moves 查看移动状态`, "")
+
+	setFieldComment(root, "integrity_sidecar",
+		`# 录制文件落盘后在同目录生成 .sha256 校验和与 .manifest.json 清单（大小、时长、编码）
+# 可通过 POST /api/integrity/verify 或 --verify-integrity 命令行参数重新校验，找出丢失、被修改或被截断的文件
+# 后处理管道在上传和删除文件前也会按清单校验
+# 校验和在后台计算，不阻塞下一个分段的录制`, "")
+
+	setFieldComment(root, "stall_watchdog",
+		`# 录制停滞检测：写入的字节数超过 timeout_sec 秒没有增长时视为停滞（0 表示关闭，默认关闭，建议设为 120）
+# action: restart（默认，停止下载器并重新获取直播流地址）、log（只记录日志）
+# 日志中会给出停滞原因：no_data（一直没有数据）、upstream_stalled（上游没有数据）、
+# downloader_stalled（上游有数据但下载器没有写出）、output_stalled（无法判断上游状态）
+# 可在平台或直播间配置中单独覆盖`, "")
+
+	setFieldHeadComment(root, "storage_pool",
+		`# 多磁盘存储池（可选）
+# 配置 roots 后，录制文件按放置策略写入其中一个根目录，out_put_tmpl 相对于被选中的根目录渲染
+# placement.policy: most_free（剩余空间最多，默认）、round_robin（轮流）、pinned（固定 placement.root 指定的根目录）
+# 直播间/平台可通过 storage_placement 覆盖放置策略`)
+
 	setFieldHeadComment(root, "notify", "# 通知服务配置")
 	notifyNode := findNode(root, "notify")
 	if notifyNode != nil {
@@ -126,6 +168,11 @@ func DecorateConfigNode(node *yaml.Node) {
 # 当检测到视频编码参数变化（新的 SPS/PPS）时，会主动断开连接触发 FFmpeg 分段
 # 这可以避免因编码参数变化导致的花屏问题
 # 注意：启用后会在本地启动一个 FLV 代理服务器，FFmpeg 从代理读取流`, "")
+		setFieldComment(featureNode, "native_output_format",
+			`# 原生 FLV 解析器的输出格式（仅对 native 下载器生效）：flv（默认）、fmp4
+# fmp4: 直接写入分片 MP4（.mp4），每个 GOP 一个分片，异常退出最多丢失最后一个 GOP，录制后无需转封装
+# 录制中途编码参数变化时会自动切换到新文件
+# 只录音频的 FLV 直播间始终使用原生解析器：默认直接提取 AAC 写为 .aac（ADTS），设为 fmp4 时写为 .m4a`, "")
 	}
 }
 
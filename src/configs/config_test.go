@@ -75,6 +75,37 @@ func TestResolveConfigForRoom(t *testing.T) {
 	assert.Equal(t, "/usr/bin/ffmpeg", resolved.FfmpegPath)
 }
 
+func TestStoragePoolConfig(t *testing.T) {
+	cfg := &Config{
+		OutPutPath: "/global",
+		StoragePool: StoragePool{
+			Roots: []StorageRoot{
+				{Name: "ssd", Path: "/mnt/ssd"},
+				{Name: "hdd", Path: "/mnt/hdd"},
+				{Name: "dup", Path: "/global/"},
+			},
+			Placement: StoragePlacement{Policy: PlacementRoundRobin},
+		},
+	}
+	assert.Equal(t, []string{"/global", "/mnt/ssd", "/mnt/hdd"}, cfg.GetStorageRoots())
+	assert.Equal(t, []string{"/global", "/mnt/ssd", "/mnt/hdd"}, cfg.GetAllOutputPaths())
+	assert.NoError(t, cfg.StoragePool.verify())
+
+	room := &LiveRoom{
+		Url: "https://live.douyin.com/123456",
+		OverridableConfig: OverridableConfig{
+			StoragePlacement: &StoragePlacement{Policy: PlacementPinned, Root: "hdd"},
+		},
+	}
+	assert.Equal(t, PlacementRoundRobin, cfg.ResolveConfigForRoom(&LiveRoom{}, "douyin").StoragePlacement.Policy)
+	assert.Equal(t, "hdd", cfg.ResolveConfigForRoom(room, "douyin").StoragePlacement.Root)
+
+	assert.Error(t, cfg.StoragePool.verifyPlacement(StoragePlacement{Policy: PlacementPinned, Root: "missing"}))
+	assert.Error(t, cfg.StoragePool.verifyPlacement(StoragePlacement{Policy: "random"}))
+	cfg.StoragePool.Roots = append(cfg.StoragePool.Roots, StorageRoot{Name: "ssd", Path: "/mnt/other"})
+	assert.Error(t, cfg.StoragePool.verify())
+}
+
 func TestGetPlatformMinAccessInterval(t *testing.T) {
 	cfg := &Config{
 		PlatformConfigs: map[string]PlatformConfig{
@@ -120,6 +151,16 @@ live_rooms:
 	assert.Equal(t, "./", resolved.OutPutPath)
 }
 
+func TestNewConfigWithBytesDoesNotShareDefaults(t *testing.T) {
+	_, err := NewConfigWithBytes([]byte("platform_configs:\n  huya:\n    interval: 10\n"))
+	assert.NoError(t, err)
+
+	cfg, err := NewConfigWithBytes([]byte("interval: 30\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.PlatformConfigs)
+	assert.Empty(t, defaultConfig.PlatformConfigs)
+}
+
 func TestGetPlatformKeyFromUrl(t *testing.T) {
 	tests := []struct {
 		url      string
@@ -0,0 +1,108 @@
+package configs
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// PlacementPolicy 存储池放置策略
+type PlacementPolicy string
+
+const (
+	// PlacementMostFree 选择剩余空间最多的根目录（默认）
+	PlacementMostFree PlacementPolicy = "most_free"
+	// PlacementRoundRobin 在可用根目录之间轮流分配
+	PlacementRoundRobin PlacementPolicy = "round_robin"
+	// PlacementPinned 固定使用指定名称的根目录
+	PlacementPinned PlacementPolicy = "pinned"
+)
+
+// StorageRoot 存储池中的单个根目录
+type StorageRoot struct {
+	Name string `yaml:"name" json:"name"` // 根目录名称，用于 pinned 策略引用
+	Path string `yaml:"path" json:"path"` // 根目录路径
+}
+
+// StoragePlacement 放置策略配置
+type StoragePlacement struct {
+	Policy PlacementPolicy `yaml:"policy,omitempty" json:"policy,omitempty"` // most_free / round_robin / pinned
+	Root   string          `yaml:"root,omitempty" json:"root,omitempty"`     // pinned 策略使用的根目录名称
+}
+
+// StoragePool 多磁盘存储池配置
+// 配置了 Roots 后，录制文件会按放置策略写入其中一个根目录，
+// 输出文件名模板（out_put_tmpl）相对于被选中的根目录渲染；未配置时沿用 out_put_path。
+type StoragePool struct {
+	Roots     []StorageRoot    `yaml:"roots,omitempty" json:"roots,omitempty"`
+	Placement StoragePlacement `yaml:"placement,omitempty" json:"placement,omitempty"` // 全局默认放置策略
+}
+
+// Enabled 是否启用了存储池
+func (p StoragePool) Enabled() bool {
+	return len(p.Roots) > 0
+}
+
+// GetRoot 按名称查找根目录
+func (p StoragePool) GetRoot(name string) (StorageRoot, bool) {
+	for _, root := range p.Roots {
+		if root.Name == name {
+			return root, true
+		}
+	}
+	return StorageRoot{}, false
+}
+
+// verify 校验存储池配置。不要求根目录当前存在，离线的磁盘会在放置时被跳过。
+func (p StoragePool) verify() error {
+	names := make(map[string]bool, len(p.Roots))
+	for i, root := range p.Roots {
+		if root.Name == "" {
+			return fmt.Errorf("存储池第 %d 个根目录缺少名称", i+1)
+		}
+		if root.Path == "" {
+			return fmt.Errorf("存储池根目录 '%s' 缺少路径", root.Name)
+		}
+		if names[root.Name] {
+			return fmt.Errorf("存储池根目录名称 '%s' 重复", root.Name)
+		}
+		names[root.Name] = true
+	}
+	return p.verifyPlacement(p.Placement)
+}
+
+// verifyPlacement 校验放置策略是否合法，pinned 策略引用的根目录必须存在于存储池中
+func (p StoragePool) verifyPlacement(placement StoragePlacement) error {
+	switch placement.Policy {
+	case "", PlacementMostFree, PlacementRoundRobin:
+		return nil
+	case PlacementPinned:
+		if _, ok := p.GetRoot(placement.Root); !ok {
+			return fmt.Errorf("放置策略固定的根目录 '%s' 不在存储池中", placement.Root)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的放置策略 '%s'", placement.Policy)
+	}
+}
+
+// GetStorageRoots 返回文件浏览等功能需要覆盖的所有根目录：out_put_path 在前，随后是存储池根目录（去重）
+func (c *Config) GetStorageRoots() []string {
+	seen := make(map[string]bool)
+	roots := make([]string, 0, len(c.StoragePool.Roots)+1)
+	add := func(p string) {
+		if p == "" {
+			return
+		}
+		key := filepath.Clean(p)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		roots = append(roots, p)
+	}
+	add(c.OutPutPath)
+	for _, root := range c.StoragePool.Roots {
+		add(root.Path)
+	}
+	return roots
+}
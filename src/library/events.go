@@ -0,0 +1,32 @@
+package library
+
+import (
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterEventListeners 注册事件监听器，在后处理任务状态变化时同步资料库
+// 参数：
+//   - ed: 事件分发器
+//   - manager: 资料库管理器
+//   - pm: 管道管理器，用于读取任务的最新状态（事件异步分发，可能乱序到达）
+func RegisterEventListeners(ed events.Dispatcher, manager *Manager, pm *pipeline.Manager) {
+	if manager == nil {
+		logrus.Debug("录播资料库未初始化，跳过事件监听器注册")
+		return
+	}
+
+	ed.AddEventListener(pipeline.PipelineTaskUpdateEvent, events.NewEventListener(func(event *events.Event) {
+		task, ok := event.Object.(*pipeline.PipelineTask)
+		if !ok || task == nil {
+			return
+		}
+		if pm != nil {
+			if latest, err := pm.GetTask(task.ID); err == nil && latest != nil {
+				task = latest
+			}
+		}
+		manager.OnPipelineTaskUpdate(task)
+	}))
+}
@@ -0,0 +1,477 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrScanRunning 已有扫描任务在运行
+var ErrScanRunning = errors.New("library scan is already running")
+
+// mediaExts 资料库索引的媒体文件扩展名
+var mediaExts = map[string]bool{
+	".flv": true,
+	".mp4": true,
+	".ts":  true,
+	".mkv": true,
+	".mov": true,
+	".m4a": true,
+	".aac": true,
+}
+
+// SessionResolver 根据直播间ID和时间点查找对应的开播会话ID，找不到时返回 0
+type SessionResolver func(liveID string, at time.Time) int64
+
+// RecordedFile 录制完成后提交给资料库的文件信息
+type RecordedFile struct {
+	Path        string
+	LiveID      string
+	Platform    string
+	PlatformKey string
+	HostName    string
+	RoomName    string
+	// StartTime 录制开始时间，为零值时根据文件时长推算
+	StartTime time.Time
+	// StreamInfo 录制时探测到的流信息，文件探测失败时作为补充，可为空
+	StreamInfo *streamprobe.StreamHeaderInfo
+}
+
+// Manager 录播资料库管理器
+type Manager struct {
+	store  Store
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu              sync.RWMutex
+	sessionResolver SessionResolver
+	scanStatus      ScanStatus
+
+	// pipelineMu 串行化管道事件处理，避免并发事件乱序写入
+	pipelineMu sync.Mutex
+}
+
+// NewManager 创建资料库管理器
+func NewManager(dbPath string) (*Manager, error) {
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		store:  store,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// GetManager 从 Instance 获取资料库管理器
+func GetManager(inst *instance.Instance) *Manager {
+	if inst == nil || inst.LibraryManager == nil {
+		return nil
+	}
+	m, _ := inst.LibraryManager.(*Manager)
+	return m
+}
+
+// Start 启动管理器
+func (m *Manager) Start(ctx context.Context) error {
+	logrus.Info("录播资料库已启动")
+	return nil
+}
+
+// Close 关闭管理器
+func (m *Manager) Close(ctx context.Context) {
+	m.cancel()
+	if err := m.store.Close(); err != nil {
+		logrus.WithError(err).Warn("关闭录播资料库数据库失败")
+	}
+	logrus.Info("录播资料库已关闭")
+}
+
+// GetStore 获取存储实例
+func (m *Manager) GetStore() Store {
+	return m.store
+}
+
+// SetSessionResolver 设置开播会话查找函数（由 livestate 提供，避免循环导入）
+func (m *Manager) SetSessionResolver(resolver SessionResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionResolver = resolver
+}
+
+// resolveSession 查找文件所属的开播会话
+func (m *Manager) resolveSession(liveID string, at time.Time) int64 {
+	m.mu.RLock()
+	resolver := m.sessionResolver
+	m.mu.RUnlock()
+	if resolver == nil || liveID == "" {
+		return 0
+	}
+	return resolver(liveID, at)
+}
+
+// AddRecordedFiles 异步索引录制完成的文件，不阻塞录制流程
+func (m *Manager) AddRecordedFiles(files ...RecordedFile) {
+	bilisentry.Go(func() {
+		for _, f := range files {
+			if _, err := m.IndexFile(m.ctx, f, SourceRecorder); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					// 空文件在录制结束时已被删除
+					continue
+				}
+				logrus.WithError(err).WithField("path", f.Path).Warn("索引录制文件失败")
+			}
+		}
+	})
+}
+
+// IndexFile 探测文件并写入资料库
+func (m *Manager) IndexFile(ctx context.Context, f RecordedFile, source string) (*Recording, error) {
+	rec, err := buildRecording(f, source)
+	if err != nil {
+		return nil, err
+	}
+	rec.SessionID = m.resolveSession(rec.LiveID, rec.StartTime)
+	if err := m.store.UpsertRecording(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// buildRecording 根据文件信息和探测结果构造录制记录
+func buildRecording(f RecordedFile, source string) (*Recording, error) {
+	path, err := normalizePath(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Recording{
+		Path:        path,
+		FileName:    filepath.Base(path),
+		Ext:         strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")),
+		LiveID:      f.LiveID,
+		Platform:    f.Platform,
+		PlatformKey: f.PlatformKey,
+		HostName:    f.HostName,
+		RoomName:    f.RoomName,
+		Size:        fi.Size(),
+		FileModTime: fi.ModTime(),
+		EndTime:     fi.ModTime(),
+		Source:      source,
+	}
+
+	streamInfo := f.StreamInfo
+	if probed, probeErr := streamprobe.ProbeFile(path); probeErr == nil {
+		streamInfo = probed.StreamHeaderInfo
+		rec.Duration = probed.Duration.Seconds()
+	} else {
+		logrus.WithError(probeErr).WithField("path", path).Debug("探测录制文件失败")
+	}
+	if streamInfo != nil {
+		rec.VideoCodec = streamInfo.VideoCodec
+		rec.AudioCodec = streamInfo.AudioCodec
+		rec.Width = streamInfo.Width
+		rec.Height = streamInfo.Height
+		rec.FrameRate = streamInfo.FrameRate
+	}
+
+	switch {
+	case !f.StartTime.IsZero():
+		rec.StartTime = f.StartTime
+	case rec.Duration > 0:
+		rec.StartTime = rec.EndTime.Add(-time.Duration(rec.Duration * float64(time.Second)))
+	default:
+		rec.StartTime = rec.EndTime
+	}
+	if rec.Duration == 0 && rec.EndTime.After(rec.StartTime) {
+		rec.Duration = rec.EndTime.Sub(rec.StartTime).Seconds()
+	}
+	return rec, nil
+}
+
+// normalizePath 将路径转换为绝对路径，确保同一文件在资料库中只有一条记录
+func normalizePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(abs), nil
+}
+
+// Search 查询录制记录
+func (m *Manager) Search(ctx context.Context, q Query) (*SearchResult, error) {
+	return m.store.Search(ctx, q)
+}
+
+// GetRecording 获取单条录制记录
+func (m *Manager) GetRecording(ctx context.Context, id int64) (*Recording, error) {
+	return m.store.GetRecording(ctx, id)
+}
+
+// GetStats 获取资料库汇总统计
+func (m *Manager) GetStats(ctx context.Context) (*Stats, error) {
+	return m.store.GetStats(ctx)
+}
+
+// GetScanStatus 获取最近一次扫描的状态
+func (m *Manager) GetScanStatus() ScanStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.scanStatus
+}
+
+// StartRescan 在后台扫描指定目录，补全或更新索引并移除已不存在的文件
+func (m *Manager) StartRescan(roots []string) error {
+	m.mu.Lock()
+	if m.scanStatus.Running {
+		m.mu.Unlock()
+		return ErrScanRunning
+	}
+	m.scanStatus = ScanStatus{
+		Running:   true,
+		Roots:     roots,
+		StartedAt: time.Now(),
+	}
+	m.mu.Unlock()
+
+	bilisentry.Go(func() {
+		err := m.rescan(m.ctx, roots)
+
+		m.mu.Lock()
+		m.scanStatus.Running = false
+		m.scanStatus.FinishedAt = time.Now()
+		if err != nil {
+			m.scanStatus.Error = err.Error()
+		}
+		status := m.scanStatus
+		m.mu.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"scanned": status.Scanned,
+			"added":   status.Added,
+			"updated": status.Updated,
+			"removed": status.Removed,
+		}).Info("录播资料库扫描完成")
+	})
+	return nil
+}
+
+// rescan 扫描目录的实际实现
+func (m *Manager) rescan(ctx context.Context, roots []string) error {
+	for _, root := range roots {
+		root, err := normalizePath(root)
+		if err != nil {
+			return err
+		}
+
+		// 目录不存在（如磁盘未挂载）时跳过，避免误删该目录下的所有索引
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			logrus.WithField("root", root).Warn("资料库扫描目录不可用，已跳过")
+			continue
+		}
+
+		existing, err := m.store.ListRecordingsUnder(ctx, root)
+		if err != nil {
+			return err
+		}
+		known := make(map[string]*Recording, len(existing))
+		for _, rec := range existing {
+			known[rec.Path] = rec
+		}
+
+		walkErr := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				// 单个目录无法访问时跳过，不中断整个扫描
+				return nil
+			}
+			name := d.Name()
+			if d.IsDir() {
+				// 跳过隐藏目录（如 .appdata）
+				if path != root && strings.HasPrefix(name, ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// 跳过隐藏文件和转换中的临时文件
+			if strings.HasPrefix(name, ".") || !mediaExts[strings.ToLower(filepath.Ext(name))] {
+				return nil
+			}
+
+			m.updateScanStatus(func(s *ScanStatus) { s.Scanned++ })
+
+			fi, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if rec, ok := known[path]; ok && rec.Size == fi.Size() && rec.FileModTime.Unix() == fi.ModTime().Unix() {
+				// 文件未变化，无需重新探测
+				return nil
+			}
+
+			f := parsePathMeta(root, path)
+			if _, err := m.IndexFile(ctx, f, SourceScan); err != nil {
+				logrus.WithError(err).WithField("path", path).Debug("扫描时索引文件失败")
+				return nil
+			}
+			if _, ok := known[path]; ok {
+				m.updateScanStatus(func(s *ScanStatus) { s.Updated++ })
+			} else {
+				m.updateScanStatus(func(s *ScanStatus) { s.Added++ })
+			}
+			return nil
+		})
+		if walkErr != nil {
+			return walkErr
+		}
+
+		// 移除已不存在的文件
+		for path := range known {
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				if err := m.store.DeleteRecordingByPath(ctx, path); err == nil {
+					m.updateScanStatus(func(s *ScanStatus) { s.Removed++ })
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// uploadStatusRank 汇总上传状态时的优先级，数值越大越优先
+var uploadStatusRank = map[pipeline.StageStatus]int{
+	pipeline.StageStatusSkipped:   1,
+	pipeline.StageStatusCompleted: 2,
+	pipeline.StageStatusPending:   3,
+	pipeline.StageStatusRunning:   4,
+	pipeline.StageStatusFailed:    5,
+}
+
+// summarizeUploadStatus 汇总所有上传阶段的状态：任一失败即为失败，其次是进行中和等待中，
+// 全部完成（或部分被跳过）才算完成，没有上传阶段时返回空
+func summarizeUploadStatus(results []pipeline.StageResult) string {
+	var status pipeline.StageStatus
+	for _, result := range results {
+		if !pipeline.IsUploadStage(result.StageName) {
+			continue
+		}
+		if uploadStatusRank[result.Status] > uploadStatusRank[status] {
+			status = result.Status
+		}
+	}
+	return string(status)
+}
+
+// updateScanStatus 在锁保护下更新扫描状态
+func (m *Manager) updateScanStatus(fn func(s *ScanStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&m.scanStatus)
+}
+
+// OnPipelineTaskUpdate 根据管道任务状态更新相关文件的后处理与上传状态
+// 任务完成时索引管道产出的新视频文件，并移除已被删除的源文件
+func (m *Manager) OnPipelineTaskUpdate(task *pipeline.PipelineTask) {
+	m.pipelineMu.Lock()
+	defer m.pipelineMu.Unlock()
+
+	ctx := m.ctx
+	uploadStatus := summarizeUploadStatus(task.StageResults)
+
+	// 取第一个已索引的源文件，作为管道产出文件的元数据来源
+	var origin *Recording
+	for _, f := range task.InitialFiles {
+		if f.Type != pipeline.FileTypeVideo {
+			continue
+		}
+		path, err := normalizePath(f.Path)
+		if err != nil {
+			continue
+		}
+		if err := m.store.UpdatePipelineState(ctx, path, task.ID, string(task.Status), uploadStatus); err != nil {
+			logrus.WithError(err).WithField("path", path).Debug("更新资料库后处理状态失败")
+		}
+		if origin == nil {
+			if rec, err := m.store.GetRecordingByPath(ctx, path); err == nil {
+				origin = rec
+			}
+		}
+	}
+
+	if task.Status != pipeline.PipelineStatusCompleted {
+		return
+	}
+
+	for _, f := range task.CurrentFiles {
+		if f.Type != pipeline.FileTypeVideo {
+			continue
+		}
+		path, err := normalizePath(f.Path)
+		if err != nil {
+			continue
+		}
+		if _, err := m.store.GetRecordingByPath(ctx, path); err == nil {
+			if err := m.store.UpdatePipelineState(ctx, path, task.ID, string(task.Status), uploadStatus); err != nil {
+				logrus.WithError(err).WithField("path", path).Debug("更新资料库后处理状态失败")
+			}
+			continue
+		}
+
+		rf := RecordedFile{
+			Path:     path,
+			LiveID:   string(task.RecordInfo.LiveID),
+			Platform: task.RecordInfo.Platform,
+			HostName: task.RecordInfo.HostName,
+			RoomName: task.RecordInfo.RoomName,
+		}
+		if origin != nil {
+			rf.PlatformKey = origin.PlatformKey
+			rf.StartTime = origin.StartTime
+		}
+		rec, err := m.IndexFile(ctx, rf, SourcePipeline)
+		if err != nil {
+			logrus.WithError(err).WithField("path", path).Debug("索引管道产出文件失败")
+			continue
+		}
+		if origin != nil && origin.SessionID > 0 && rec.SessionID == 0 {
+			rec.SessionID = origin.SessionID
+		}
+		rec.PipelineTaskID = task.ID
+		rec.PipelineStatus = string(task.Status)
+		rec.UploadStatus = uploadStatus
+		if err := m.store.UpsertRecording(ctx, rec); err != nil {
+			logrus.WithError(err).WithField("path", path).Debug("更新管道产出文件索引失败")
+		}
+	}
+
+	// 源文件可能已被管道删除（如转换后删除 flv）
+	for _, f := range task.InitialFiles {
+		path, err := normalizePath(f.Path)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := m.store.DeleteRecordingByPath(ctx, path); err != nil {
+				logrus.WithError(err).WithField("path", path).Debug("移除已删除文件的索引失败")
+			}
+		}
+	}
+}
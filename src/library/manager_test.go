@@ -0,0 +1,91 @@
+package library
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+func TestSummarizeUploadStatus(t *testing.T) {
+	result := func(name string, status pipeline.StageStatus) pipeline.StageResult {
+		return pipeline.StageResult{StageName: name, Status: status}
+	}
+	tests := []struct {
+		name    string
+		results []pipeline.StageResult
+		want    string
+	}{
+		{name: "没有上传阶段", results: []pipeline.StageResult{result(pipeline.StageNameFixFlv, pipeline.StageStatusCompleted)}},
+		{
+			name:    "只有 cloud_upload",
+			results: []pipeline.StageResult{result(pipeline.StageNameCloudUpload, pipeline.StageStatusCompleted)},
+			want:    "completed",
+		},
+		{
+			name:    "只有 S3",
+			results: []pipeline.StageResult{result(pipeline.StageNameS3Upload, pipeline.StageStatusRunning)},
+			want:    "running",
+		},
+		{
+			name: "任一上传失败",
+			results: []pipeline.StageResult{
+				result(pipeline.StageNameWebDAVUpload, pipeline.StageStatusFailed),
+				result(pipeline.StageNameS3Upload, pipeline.StageStatusCompleted),
+			},
+			want: "failed",
+		},
+		{
+			name: "跳过的上传不影响完成状态",
+			results: []pipeline.StageResult{
+				result(pipeline.StageNameS3Upload, pipeline.StageStatusSkipped),
+				result(pipeline.StageNameWebDAVUpload, pipeline.StageStatusCompleted),
+			},
+			want: "completed",
+		},
+		{
+			name: "全部跳过",
+			results: []pipeline.StageResult{
+				result(pipeline.StageNameS3Upload, pipeline.StageStatusSkipped),
+				result(pipeline.StageNameConvertMp4, pipeline.StageStatusFailed),
+			},
+			want: "skipped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeUploadStatus(tt.results))
+		})
+	}
+}
+
+func TestOnPipelineTaskUpdateRecordsNativeUploadStatus(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(filepath.Join(dir, "library.db"))
+	require.NoError(t, err)
+	defer m.Close(context.Background())
+
+	path := filepath.Join(dir, "a.flv")
+	require.NoError(t, m.store.UpsertRecording(context.Background(), &Recording{Path: path, FileName: "a.flv", Ext: "flv"}))
+
+	m.OnPipelineTaskUpdate(&pipeline.PipelineTask{
+		ID:           7,
+		Status:       pipeline.PipelineStatusRunning,
+		InitialFiles: []pipeline.FileInfo{pipeline.NewVideoFileInfo(path)},
+		StageResults: []pipeline.StageResult{
+			{StageName: pipeline.StageNameFixFlv, Status: pipeline.StageStatusCompleted},
+			{StageName: pipeline.StageNameS3Upload, Status: pipeline.StageStatusCompleted},
+			{StageName: pipeline.StageNameWebDAVUpload, Status: pipeline.StageStatusRunning},
+		},
+	})
+
+	rec, err := m.store.GetRecordingByPath(context.Background(), path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), rec.PipelineTaskID)
+	assert.Equal(t, "running", rec.PipelineStatus)
+	assert.Equal(t, "running", rec.UploadStatus)
+}
@@ -0,0 +1,8 @@
+-- 回滚：删除所有表
+DROP INDEX IF EXISTS idx_recordings_session_id;
+DROP INDEX IF EXISTS idx_recordings_start_time;
+DROP INDEX IF EXISTS idx_recordings_host_name;
+DROP INDEX IF EXISTS idx_recordings_platform;
+DROP INDEX IF EXISTS idx_recordings_live_id;
+
+DROP TABLE IF EXISTS recordings;
@@ -0,0 +1,36 @@
+-- 录制文件索引表
+CREATE TABLE IF NOT EXISTS recordings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    path TEXT UNIQUE NOT NULL,              -- 文件绝对路径
+    file_name TEXT NOT NULL,                -- 文件名
+    ext TEXT DEFAULT '',                    -- 扩展名（小写，不含点）
+    live_id TEXT DEFAULT '',                -- 直播间ID (types.LiveID)，扫描得到的文件可能为空
+    platform TEXT DEFAULT '',               -- 平台中文名
+    platform_key TEXT DEFAULT '',           -- 平台标识，如 bilibili、huya
+    host_name TEXT DEFAULT '',              -- 主播名称
+    room_name TEXT DEFAULT '',              -- 直播标题
+    session_id INTEGER DEFAULT 0,           -- 开播会话ID（对应 lives.db 中的 live_sessions.id），0 表示未知
+    start_time INTEGER DEFAULT 0,           -- 录制开始时间 (Unix timestamp)
+    end_time INTEGER DEFAULT 0,             -- 录制结束时间 (Unix timestamp)
+    duration REAL DEFAULT 0,                -- 时长（秒）
+    size INTEGER DEFAULT 0,                 -- 文件大小（字节）
+    file_mtime INTEGER DEFAULT 0,           -- 文件修改时间 (Unix timestamp)，用于增量扫描
+    video_codec TEXT DEFAULT '',
+    audio_codec TEXT DEFAULT '',
+    width INTEGER DEFAULT 0,
+    height INTEGER DEFAULT 0,
+    frame_rate REAL DEFAULT 0,
+    source TEXT DEFAULT 'recorder',         -- 来源: recorder, pipeline, scan
+    pipeline_task_id INTEGER DEFAULT 0,     -- 最近一次关联的后处理任务ID
+    pipeline_status TEXT DEFAULT '',        -- 后处理状态（PipelineStatus）
+    upload_status TEXT DEFAULT '',          -- 云上传阶段状态（StageStatus）
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 索引
+CREATE INDEX IF NOT EXISTS idx_recordings_live_id ON recordings(live_id);
+CREATE INDEX IF NOT EXISTS idx_recordings_platform ON recordings(platform);
+CREATE INDEX IF NOT EXISTS idx_recordings_host_name ON recordings(host_name);
+CREATE INDEX IF NOT EXISTS idx_recordings_start_time ON recordings(start_time);
+CREATE INDEX IF NOT EXISTS idx_recordings_session_id ON recordings(session_id);
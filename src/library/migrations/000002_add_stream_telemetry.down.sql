@@ -0,0 +1,7 @@
+-- 回滚：删除流质量遥测表
+DROP INDEX IF EXISTS idx_stream_telemetry_samples_telemetry_time;
+DROP INDEX IF EXISTS idx_stream_telemetry_session_id;
+DROP INDEX IF EXISTS idx_stream_telemetry_live_id;
+
+DROP TABLE IF EXISTS stream_telemetry_samples;
+DROP TABLE IF EXISTS stream_telemetry;
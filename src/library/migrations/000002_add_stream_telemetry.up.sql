@@ -0,0 +1,39 @@
+-- 录制过程中的流质量遥测，每次录制（录制器从开始到结束）一条记录
+CREATE TABLE IF NOT EXISTS stream_telemetry (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    live_id TEXT NOT NULL,                  -- 直播间ID (types.LiveID)
+    session_id INTEGER DEFAULT 0,           -- 开播会话ID（对应 lives.db 中的 live_sessions.id），0 表示未知
+    platform TEXT DEFAULT '',               -- 平台中文名
+    host_name TEXT DEFAULT '',              -- 主播名称
+    room_name TEXT DEFAULT '',              -- 直播标题
+    start_time INTEGER NOT NULL,            -- 开始时间 (Unix timestamp)
+    end_time INTEGER DEFAULT 0,             -- 结束时间 (Unix timestamp)，0 表示仍在录制
+    summary TEXT DEFAULT '',                -- 录制结束时的汇总（streamprobe.QualitySummary 的 JSON）
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 流质量采样点
+CREATE TABLE IF NOT EXISTS stream_telemetry_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    telemetry_id INTEGER NOT NULL,          -- 所属的 stream_telemetry.id
+    time INTEGER NOT NULL,                  -- 采样时刻 (Unix timestamp, 毫秒)
+    duration REAL DEFAULT 0,                -- 采样周期（秒）
+    bytes INTEGER DEFAULT 0,
+    bitrate_kbps REAL DEFAULT 0,
+    video_bitrate_kbps REAL DEFAULT 0,
+    audio_bitrate_kbps REAL DEFAULT 0,
+    fps REAL DEFAULT 0,
+    keyframe_interval REAL DEFAULT 0,       -- 关键帧平均间隔（秒）
+    timestamp_gaps INTEGER DEFAULT 0,
+    max_gap_ms INTEGER DEFAULT 0,
+    av_drift_ms INTEGER DEFAULT 0,
+    max_av_drift_ms INTEGER DEFAULT 0,
+    dropped_frames INTEGER DEFAULT 0,
+    corrupt_tags INTEGER DEFAULT 0,
+    reconnects INTEGER DEFAULT 0
+);
+
+-- 索引
+CREATE INDEX IF NOT EXISTS idx_stream_telemetry_live_id ON stream_telemetry(live_id);
+CREATE INDEX IF NOT EXISTS idx_stream_telemetry_session_id ON stream_telemetry(session_id);
+CREATE INDEX IF NOT EXISTS idx_stream_telemetry_samples_telemetry_time ON stream_telemetry_samples(telemetry_id, time);
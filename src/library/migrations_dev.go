@@ -0,0 +1,38 @@
+//go:build dev
+
+package library
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/bililive-go/bililive-go/src/pkg/migration"
+)
+
+// libraryMigrationSource 录播资料库数据库迁移源（dev模式）
+type libraryMigrationSource struct{}
+
+// GetFS 返回迁移文件目录的文件系统（dev模式使用实际文件）
+func (s *libraryMigrationSource) GetFS() (fs.FS, error) {
+	// 获取当前源文件所在目录
+	_, currentFile, _, _ := runtime.Caller(0)
+	migrationsDir := filepath.Join(filepath.Dir(currentFile), "migrations")
+	return os.DirFS(migrationsDir), nil
+}
+
+// GetSubDir 返回迁移文件在FS中的子目录
+func (s *libraryMigrationSource) GetSubDir() string {
+	return "."
+}
+
+// IsEmbedded 返回迁移文件是否嵌入
+func (s *libraryMigrationSource) IsEmbedded() bool {
+	return false
+}
+
+// GetMigrationSource 获取录播资料库数据库迁移源
+func GetMigrationSource() migration.MigrationSource {
+	return &libraryMigrationSource{}
+}
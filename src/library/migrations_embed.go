@@ -0,0 +1,36 @@
+//go:build !dev
+
+package library
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/bililive-go/bililive-go/src/pkg/migration"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// libraryMigrationSource 录播资料库数据库迁移源（release模式）
+type libraryMigrationSource struct{}
+
+// GetFS 返回迁移文件目录的文件系统（release模式使用嵌入文件）
+func (s *libraryMigrationSource) GetFS() (fs.FS, error) {
+	return embeddedMigrations, nil
+}
+
+// GetSubDir 返回迁移文件在FS中的子目录
+func (s *libraryMigrationSource) GetSubDir() string {
+	return "migrations"
+}
+
+// IsEmbedded 返回迁移文件是否嵌入
+func (s *libraryMigrationSource) IsEmbedded() bool {
+	return true
+}
+
+// GetMigrationSource 获取录播资料库数据库迁移源
+func GetMigrationSource() migration.MigrationSource {
+	return &libraryMigrationSource{}
+}
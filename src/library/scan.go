@@ -0,0 +1,40 @@
+package library
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultFileNameRegexp 匹配默认输出模板生成的文件名：[2006-01-02 15-04-05][主播名][直播标题]
+var defaultFileNameRegexp = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}-\d{2}-\d{2})\]\[([^\]]*)\]\[(.*)\]`)
+
+// parsePathMeta 尽可能从默认输出模板的目录结构中还原元数据：
+// {root}/{平台名}/{主播名}/[时间][主播名][直播标题].flv
+// 不符合默认模板的文件只返回路径，其他信息由探测结果补全
+func parsePathMeta(root, path string) RecordedFile {
+	f := RecordedFile{Path: path}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return f
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) >= 3 {
+		f.Platform = parts[len(parts)-3]
+		f.HostName = parts[len(parts)-2]
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if m := defaultFileNameRegexp.FindStringSubmatch(base); m != nil {
+		if t, err := time.ParseInLocation("2006-01-02 15-04-05", m[1], time.Local); err == nil {
+			f.StartTime = t
+		}
+		if m[2] != "" {
+			f.HostName = m[2]
+		}
+		f.RoomName = m[3]
+	}
+	return f
+}
@@ -0,0 +1,21 @@
+package library
+
+import (
+	"github.com/bililive-go/bililive-go/src/pkg/migration"
+)
+
+// DatabaseTypeLibrary 录播资料库数据库类型
+const DatabaseTypeLibrary migration.DatabaseType = "library"
+
+// LibraryDatabaseSchema 录播资料库数据库模式定义
+var LibraryDatabaseSchema = &migration.DatabaseSchema{
+	Type:            DatabaseTypeLibrary,
+	Category:        migration.CategoryNormal,
+	MigrationSource: GetMigrationSource(),
+	Description:     "录播资料库数据库，索引已完成的录制文件及其元数据、后处理与上传状态",
+}
+
+func init() {
+	// 注册录播资料库数据库模式
+	migration.MustRegisterSchema(LibraryDatabaseSchema)
+}
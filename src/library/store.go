@@ -0,0 +1,480 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/bililive-go/bililive-go/src/pkg/migration"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrRecordingNotFound 录制记录不存在
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// sortColumns 允许排序的字段到数据库列的映射
+var sortColumns = map[string]string{
+	"start_time": "start_time",
+	"end_time":   "end_time",
+	"duration":   "duration",
+	"size":       "size",
+	"host_name":  "host_name",
+	"platform":   "platform",
+	"file_name":  "file_name",
+	"created_at": "created_at",
+}
+
+// Store 录播资料库存储接口
+type Store interface {
+	// UpsertRecording 按路径创建或更新录制记录，成功后会回填 rec.ID
+	UpsertRecording(ctx context.Context, rec *Recording) error
+	GetRecording(ctx context.Context, id int64) (*Recording, error)
+	GetRecordingByPath(ctx context.Context, path string) (*Recording, error)
+	// ListRecordingsUnder 列出路径位于 root 目录下的所有记录
+	ListRecordingsUnder(ctx context.Context, root string) ([]*Recording, error)
+	Search(ctx context.Context, q Query) (*SearchResult, error)
+	GetStats(ctx context.Context) (*Stats, error)
+	// UpdatePipelineState 更新后处理状态，uploadStatus 为空时保持原值
+	UpdatePipelineState(ctx context.Context, path string, taskID int64, pipelineStatus, uploadStatus string) error
+	DeleteRecording(ctx context.Context, id int64) error
+	DeleteRecordingByPath(ctx context.Context, path string) error
+
+	// 流质量遥测
+	CreateTelemetry(ctx context.Context, t *Telemetry) error
+	AddTelemetrySample(ctx context.Context, telemetryID int64, sample streamprobe.QualitySample) error
+	FinishTelemetry(ctx context.Context, id int64, endTime time.Time, summary streamprobe.QualitySummary) error
+	GetTelemetry(ctx context.Context, id int64) (*Telemetry, error)
+	ListTelemetry(ctx context.Context, q TelemetryQuery) ([]*Telemetry, error)
+	// ListTelemetrySamples 按时间顺序返回采样点，from/to 为零值时不限制
+	ListTelemetrySamples(ctx context.Context, telemetryID int64, from, to time.Time) ([]streamprobe.QualitySample, error)
+
+	// 生命周期
+	Close() error
+}
+
+// SQLiteStore SQLite存储实现
+type SQLiteStore struct {
+	db     *sql.DB
+	dbPath string
+	mu     sync.RWMutex
+}
+
+// NewSQLiteStore 创建SQLite存储
+func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
+	// 确保目录存在
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+
+	store := &SQLiteStore{
+		db:     db,
+		dbPath: dbPath,
+	}
+
+	// 运行数据库迁移
+	if err := store.runMigrations(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("运行数据库迁移失败: %w", err)
+	}
+
+	return store, nil
+}
+
+// runMigrations 运行数据库迁移
+func (s *SQLiteStore) runMigrations() error {
+	config := &migration.MigrationConfig{
+		DBPath: s.dbPath,
+		Schema: LibraryDatabaseSchema,
+		DB:     s.db,
+	}
+
+	migrator, err := migration.NewMigrator(config)
+	if err != nil {
+		return fmt.Errorf("创建迁移器失败: %w", err)
+	}
+
+	// 检查是否需要从上次失败的迁移中恢复
+	recovered, err := migrator.CheckAndRecover()
+	if err != nil {
+		logrus.WithError(err).Warn("迁移恢复检查失败")
+	}
+	if recovered {
+		logrus.Info("从未完成的迁移中恢复")
+		s.db.Close()
+		db, err := sql.Open("sqlite", s.dbPath)
+		if err != nil {
+			return fmt.Errorf("恢复后重新打开数据库失败: %w", err)
+		}
+		s.db = db
+		config.DB = s.db
+		migrator, err = migration.NewMigrator(config)
+		if err != nil {
+			return fmt.Errorf("恢复后重新创建迁移器失败: %w", err)
+		}
+	}
+
+	// 执行迁移
+	if _, err := migrator.Run(); err != nil {
+		return fmt.Errorf("迁移失败: %w", err)
+	}
+	return nil
+}
+
+// recordingColumns 查询录制记录时使用的列，顺序与 scanRecording 保持一致
+const recordingColumns = `id, path, file_name, ext, live_id, platform, platform_key, host_name, room_name, session_id,
+	start_time, end_time, duration, size, file_mtime, video_codec, audio_codec, width, height, frame_rate,
+	source, pipeline_task_id, pipeline_status, upload_status, created_at, updated_at`
+
+// UpsertRecording 按路径创建或更新录制记录
+// 已存在的记录中非空的元数据不会被空值覆盖（例如扫描目录时无法得知直播间ID）
+func (s *SQLiteStore) UpsertRecording(ctx context.Context, rec *Recording) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source := rec.Source
+	if source == "" {
+		source = SourceRecorder
+	}
+
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO recordings (path, file_name, ext, live_id, platform, platform_key, host_name, room_name, session_id,
+			start_time, end_time, duration, size, file_mtime, video_codec, audio_codec, width, height, frame_rate,
+			source, pipeline_task_id, pipeline_status, upload_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			file_name = excluded.file_name,
+			ext = excluded.ext,
+			live_id = CASE WHEN excluded.live_id != '' THEN excluded.live_id ELSE recordings.live_id END,
+			platform = CASE WHEN excluded.platform != '' THEN excluded.platform ELSE recordings.platform END,
+			platform_key = CASE WHEN excluded.platform_key != '' THEN excluded.platform_key ELSE recordings.platform_key END,
+			host_name = CASE WHEN excluded.host_name != '' THEN excluded.host_name ELSE recordings.host_name END,
+			room_name = CASE WHEN excluded.room_name != '' THEN excluded.room_name ELSE recordings.room_name END,
+			session_id = CASE WHEN excluded.session_id > 0 THEN excluded.session_id ELSE recordings.session_id END,
+			start_time = CASE WHEN recordings.start_time > 0 AND recordings.source != 'scan' THEN recordings.start_time ELSE excluded.start_time END,
+			end_time = CASE WHEN excluded.end_time > 0 THEN excluded.end_time ELSE recordings.end_time END,
+			duration = CASE WHEN excluded.duration > 0 THEN excluded.duration ELSE recordings.duration END,
+			size = excluded.size,
+			file_mtime = excluded.file_mtime,
+			video_codec = CASE WHEN excluded.video_codec != '' THEN excluded.video_codec ELSE recordings.video_codec END,
+			audio_codec = CASE WHEN excluded.audio_codec != '' THEN excluded.audio_codec ELSE recordings.audio_codec END,
+			width = CASE WHEN excluded.width > 0 THEN excluded.width ELSE recordings.width END,
+			height = CASE WHEN excluded.height > 0 THEN excluded.height ELSE recordings.height END,
+			frame_rate = CASE WHEN excluded.frame_rate > 0 THEN excluded.frame_rate ELSE recordings.frame_rate END,
+			source = CASE WHEN recordings.source != 'scan' THEN recordings.source ELSE excluded.source END,
+			pipeline_task_id = CASE WHEN excluded.pipeline_task_id > 0 THEN excluded.pipeline_task_id ELSE recordings.pipeline_task_id END,
+			pipeline_status = CASE WHEN excluded.pipeline_status != '' THEN excluded.pipeline_status ELSE recordings.pipeline_status END,
+			upload_status = CASE WHEN excluded.upload_status != '' THEN excluded.upload_status ELSE recordings.upload_status END,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`, rec.Path, rec.FileName, rec.Ext, rec.LiveID, rec.Platform, rec.PlatformKey, rec.HostName, rec.RoomName, rec.SessionID,
+		toUnix(rec.StartTime), toUnix(rec.EndTime), rec.Duration, rec.Size, toUnix(rec.FileModTime),
+		rec.VideoCodec, rec.AudioCodec, rec.Width, rec.Height, rec.FrameRate,
+		source, rec.PipelineTaskID, rec.PipelineStatus, rec.UploadStatus,
+	).Scan(&rec.ID)
+	return err
+}
+
+// GetRecording 根据ID获取录制记录
+func (s *SQLiteStore) GetRecording(ctx context.Context, id int64) (*Recording, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+recordingColumns+` FROM recordings WHERE id = ?`, id)
+	rec, err := scanRecording(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrRecordingNotFound
+	}
+	return rec, err
+}
+
+// GetRecordingByPath 根据文件路径获取录制记录
+func (s *SQLiteStore) GetRecordingByPath(ctx context.Context, path string) (*Recording, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+recordingColumns+` FROM recordings WHERE path = ?`, path)
+	rec, err := scanRecording(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrRecordingNotFound
+	}
+	return rec, err
+}
+
+// ListRecordingsUnder 列出路径位于 root 目录下的所有记录
+func (s *SQLiteStore) ListRecordingsUnder(ctx context.Context, root string) ([]*Recording, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+recordingColumns+` FROM recordings WHERE path LIKE ? ESCAPE '\'`, escapeLike(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecordings(rows)
+}
+
+// Search 按条件查询录制记录
+func (s *SQLiteStore) Search(ctx context.Context, q Query) (*SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	where, args := buildWhere(q)
+
+	result := &SearchResult{Recordings: []*Recording{}}
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM recordings`+where, args...).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+
+	column, ok := sortColumns[q.SortBy]
+	if !ok {
+		column = "start_time"
+	}
+	order := "ASC"
+	if q.SortDesc {
+		order = "DESC"
+	}
+	query := fmt.Sprintf(`SELECT %s FROM recordings%s ORDER BY %s %s, id %s`, recordingColumns, where, column, order, order)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, q.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recs, err := scanRecordings(rows)
+	if err != nil {
+		return nil, err
+	}
+	if recs != nil {
+		result.Recordings = recs
+	}
+	return result, nil
+}
+
+// buildWhere 根据查询条件构造 WHERE 子句
+func buildWhere(q Query) (string, []any) {
+	var conds []string
+	var args []any
+
+	if q.Keyword != "" {
+		pattern := "%" + escapeLike(q.Keyword) + "%"
+		conds = append(conds, `(host_name LIKE ? ESCAPE '\' OR room_name LIKE ? ESCAPE '\' OR file_name LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if q.LiveID != "" {
+		conds = append(conds, "live_id = ?")
+		args = append(args, q.LiveID)
+	}
+	if q.Platform != "" {
+		conds = append(conds, "(platform = ? OR platform_key = ?)")
+		args = append(args, q.Platform, q.Platform)
+	}
+	if q.HostName != "" {
+		conds = append(conds, "host_name = ?")
+		args = append(args, q.HostName)
+	}
+	if q.SessionID > 0 {
+		conds = append(conds, "session_id = ?")
+		args = append(args, q.SessionID)
+	}
+	if q.Ext != "" {
+		conds = append(conds, "ext = ?")
+		args = append(args, strings.ToLower(strings.TrimPrefix(q.Ext, ".")))
+	}
+	if q.VideoCodec != "" {
+		conds = append(conds, "video_codec = ?")
+		args = append(args, q.VideoCodec)
+	}
+	if q.PipelineStatus != "" {
+		conds = append(conds, "pipeline_status = ?")
+		args = append(args, q.PipelineStatus)
+	}
+	if q.UploadStatus != "" {
+		conds = append(conds, "upload_status = ?")
+		args = append(args, q.UploadStatus)
+	}
+	if !q.StartFrom.IsZero() {
+		conds = append(conds, "start_time >= ?")
+		args = append(args, q.StartFrom.Unix())
+	}
+	if !q.StartTo.IsZero() {
+		conds = append(conds, "start_time < ?")
+		args = append(args, q.StartTo.Unix())
+	}
+	if q.MinDuration > 0 {
+		conds = append(conds, "duration >= ?")
+		args = append(args, q.MinDuration)
+	}
+	if q.MaxDuration > 0 {
+		conds = append(conds, "duration <= ?")
+		args = append(args, q.MaxDuration)
+	}
+	if q.MinSize > 0 {
+		conds = append(conds, "size >= ?")
+		args = append(args, q.MinSize)
+	}
+	if q.MaxSize > 0 {
+		conds = append(conds, "size <= ?")
+		args = append(args, q.MaxSize)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetStats 获取资料库汇总统计
+func (s *SQLiteStore) GetStats(ctx context.Context) (*Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &Stats{ByPlatform: make(map[string]int)}
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(duration), 0) FROM recordings`,
+	).Scan(&stats.Count, &stats.TotalSize, &stats.TotalDuration); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, COUNT(*) FROM recordings GROUP BY platform`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var platform string
+		var count int
+		if err := rows.Scan(&platform, &count); err != nil {
+			return nil, err
+		}
+		stats.ByPlatform[platform] = count
+	}
+	return stats, rows.Err()
+}
+
+// UpdatePipelineState 更新录制记录的后处理状态
+func (s *SQLiteStore) UpdatePipelineState(ctx context.Context, path string, taskID int64, pipelineStatus, uploadStatus string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE recordings SET
+			pipeline_task_id = ?,
+			pipeline_status = ?,
+			upload_status = CASE WHEN ? != '' THEN ? ELSE upload_status END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE path = ?
+	`, taskID, pipelineStatus, uploadStatus, uploadStatus, path)
+	return err
+}
+
+// DeleteRecording 删除录制记录（不删除文件）
+func (s *SQLiteStore) DeleteRecording(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM recordings WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrRecordingNotFound
+	}
+	return nil
+}
+
+// DeleteRecordingByPath 根据路径删除录制记录（不删除文件）
+func (s *SQLiteStore) DeleteRecordingByPath(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM recordings WHERE path = ?`, path)
+	return err
+}
+
+// Close 关闭数据库
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// rowScanner 抽象 *sql.Row 与 *sql.Rows 的 Scan 方法
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRecording 扫描单条录制记录
+func scanRecording(row rowScanner) (*Recording, error) {
+	rec := &Recording{}
+	var startTime, endTime, fileMtime int64
+	err := row.Scan(
+		&rec.ID, &rec.Path, &rec.FileName, &rec.Ext, &rec.LiveID, &rec.Platform, &rec.PlatformKey,
+		&rec.HostName, &rec.RoomName, &rec.SessionID,
+		&startTime, &endTime, &rec.Duration, &rec.Size, &fileMtime,
+		&rec.VideoCodec, &rec.AudioCodec, &rec.Width, &rec.Height, &rec.FrameRate,
+		&rec.Source, &rec.PipelineTaskID, &rec.PipelineStatus, &rec.UploadStatus,
+		&rec.CreatedAt, &rec.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rec.StartTime = fromUnix(startTime)
+	rec.EndTime = fromUnix(endTime)
+	rec.FileModTime = fromUnix(fileMtime)
+	return rec, nil
+}
+
+// scanRecordings 从 rows 扫描录制记录列表
+func scanRecordings(rows *sql.Rows) ([]*Recording, error) {
+	var recs []*Recording
+	for rows.Next() {
+		rec, err := scanRecording(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(ts int64) time.Time {
+	if ts <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
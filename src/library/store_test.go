@@ -0,0 +1,170 @@
+package library
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "library.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestUpsertRecordingKeepsExistingMetadata(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 5, 20, 0, 0, 0, time.Local)
+
+	rec := &Recording{
+		Path:      "/rec/huya/a.flv",
+		FileName:  "a.flv",
+		Ext:       "flv",
+		LiveID:    "live-1",
+		Platform:  "虎牙",
+		HostName:  "host",
+		RoomName:  "title",
+		StartTime: start,
+		Duration:  3600,
+		Size:      100,
+	}
+	require.NoError(t, store.UpsertRecording(ctx, rec))
+	require.NotZero(t, rec.ID)
+
+	// 扫描得到的同一文件缺少直播间信息，不应覆盖已有元数据
+	scanned := &Recording{
+		Path:      "/rec/huya/a.flv",
+		FileName:  "a.flv",
+		Ext:       "flv",
+		StartTime: start.Add(time.Hour),
+		Size:      200,
+		Source:    SourceScan,
+	}
+	require.NoError(t, store.UpsertRecording(ctx, scanned))
+	assert.Equal(t, rec.ID, scanned.ID)
+
+	got, err := store.GetRecording(ctx, rec.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "live-1", got.LiveID)
+	assert.Equal(t, "虎牙", got.Platform)
+	assert.Equal(t, "title", got.RoomName)
+	assert.Equal(t, start.Unix(), got.StartTime.Unix())
+	assert.Equal(t, 3600.0, got.Duration)
+	assert.Equal(t, int64(200), got.Size)
+	assert.Equal(t, SourceRecorder, got.Source)
+}
+
+func TestSearch(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	march := time.Date(2024, 3, 10, 20, 0, 0, 0, time.Local)
+
+	recs := []*Recording{
+		{Path: "/rec/1.flv", FileName: "1.flv", Ext: "flv", Platform: "虎牙", PlatformKey: "huya", HostName: "X", StartTime: march, Duration: 3 * 3600},
+		{Path: "/rec/2.flv", FileName: "2.flv", Ext: "flv", Platform: "虎牙", PlatformKey: "huya", HostName: "X", StartTime: march.Add(24 * time.Hour), Duration: 3600},
+		{Path: "/rec/3.flv", FileName: "3.flv", Ext: "flv", Platform: "虎牙", PlatformKey: "huya", HostName: "X", StartTime: march.AddDate(0, 1, 0), Duration: 4 * 3600},
+		{Path: "/rec/4.flv", FileName: "4.flv", Ext: "flv", Platform: "斗鱼", PlatformKey: "douyu", HostName: "X", StartTime: march, Duration: 5 * 3600},
+		{Path: "/rec/5.mp4", FileName: "5.mp4", Ext: "mp4", Platform: "虎牙", PlatformKey: "huya", HostName: "X", StartTime: march.Add(48 * time.Hour), Duration: 2.5 * 3600},
+	}
+	for _, rec := range recs {
+		require.NoError(t, store.UpsertRecording(ctx, rec))
+	}
+
+	result, err := store.Search(ctx, Query{
+		Platform:    "huya",
+		HostName:    "X",
+		StartFrom:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+		StartTo:     time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local),
+		MinDuration: 2 * 3600,
+		SortBy:      "duration",
+		SortDesc:    true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Total)
+	assert.Equal(t, "1.flv", result.Recordings[0].FileName)
+	assert.Equal(t, "5.mp4", result.Recordings[1].FileName)
+
+	result, err = store.Search(ctx, Query{Platform: "虎牙", Ext: ".MP4"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Total)
+
+	// 分页不影响总数
+	result, err = store.Search(ctx, Query{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Total)
+	assert.Len(t, result.Recordings, 2)
+}
+
+func TestParsePathMeta(t *testing.T) {
+	root := filepath.FromSlash("/rec")
+	path := filepath.FromSlash("/rec/虎牙/X/[2024-03-10 20-00-00][X][今晚打游戏].flv")
+
+	f := parsePathMeta(root, path)
+	assert.Equal(t, "虎牙", f.Platform)
+	assert.Equal(t, "X", f.HostName)
+	assert.Equal(t, "今晚打游戏", f.RoomName)
+	assert.Equal(t, time.Date(2024, 3, 10, 20, 0, 0, 0, time.Local), f.StartTime)
+
+	// 非默认模板的文件只保留路径
+	f = parsePathMeta(root, filepath.FromSlash("/rec/custom.flv"))
+	assert.Empty(t, f.Platform)
+	assert.True(t, f.StartTime.IsZero())
+}
+
+func TestTelemetry(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 5, 20, 0, 0, 0, time.Local)
+
+	tel := &Telemetry{LiveID: "live-1", SessionID: 7, HostName: "host", StartTime: start}
+	require.NoError(t, store.CreateTelemetry(ctx, tel))
+	require.NotZero(t, tel.ID)
+	require.NoError(t, store.CreateTelemetry(ctx, &Telemetry{LiveID: "live-2", StartTime: start}))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.AddTelemetrySample(ctx, tel.ID, streamprobe.QualitySample{
+			Time:        start.Add(time.Duration(i+1) * 10 * time.Second),
+			Duration:    10,
+			BitrateKbps: float64(1000 * (i + 1)),
+			FPS:         30,
+			CorruptTags: i,
+		}))
+	}
+
+	got, err := store.GetTelemetry(ctx, tel.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Summary)
+	assert.True(t, got.EndTime.IsZero())
+
+	samples, err := store.ListTelemetrySamples(ctx, tel.ID, start.Add(15*time.Second), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.Equal(t, 2000.0, samples[0].BitrateKbps)
+	assert.Equal(t, 2, samples[1].CorruptTags)
+	assert.Equal(t, start.Add(30*time.Second).UnixMilli(), samples[1].Time.UnixMilli())
+
+	summary := streamprobe.QualitySummary{Samples: 3, AvgBitrateKbps: 2000, CorruptTags: 3}
+	require.NoError(t, store.FinishTelemetry(ctx, tel.ID, start.Add(30*time.Second), summary))
+	got, err = store.GetTelemetry(ctx, tel.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Summary)
+	assert.Equal(t, summary, *got.Summary)
+	assert.Equal(t, start.Add(30*time.Second).Unix(), got.EndTime.Unix())
+
+	list, err := store.ListTelemetry(ctx, TelemetryQuery{SessionID: 7})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, tel.ID, list[0].ID)
+
+	_, err = store.GetTelemetry(ctx, 999)
+	assert.ErrorIs(t, err, ErrTelemetryNotFound)
+}
@@ -0,0 +1,220 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+// ErrTelemetryNotFound 遥测记录不存在
+var ErrTelemetryNotFound = errors.New("telemetry not found")
+
+// telemetryColumns 查询遥测记录时使用的列，顺序与 scanTelemetry 保持一致
+const telemetryColumns = `id, live_id, session_id, platform, host_name, room_name, start_time, end_time, summary, created_at`
+
+// telemetrySampleColumns 查询采样点时使用的列，顺序与 ListTelemetrySamples 的 Scan 保持一致
+const telemetrySampleColumns = `time, duration, bytes, bitrate_kbps, video_bitrate_kbps, audio_bitrate_kbps, fps,
+	keyframe_interval, timestamp_gaps, max_gap_ms, av_drift_ms, max_av_drift_ms, dropped_frames, corrupt_tags, reconnects`
+
+// CreateTelemetry 创建遥测记录，成功后会回填 t.ID
+func (s *SQLiteStore) CreateTelemetry(ctx context.Context, t *Telemetry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO stream_telemetry (live_id, session_id, platform, host_name, room_name, start_time)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, t.LiveID, t.SessionID, t.Platform, t.HostName, t.RoomName, toUnix(t.StartTime))
+	if err != nil {
+		return err
+	}
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+// AddTelemetrySample 写入一个采样点
+func (s *SQLiteStore) AddTelemetrySample(ctx context.Context, telemetryID int64, sample streamprobe.QualitySample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO stream_telemetry_samples (telemetry_id, `+telemetrySampleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, telemetryID, sample.Time.UnixMilli(), sample.Duration, sample.Bytes,
+		sample.BitrateKbps, sample.VideoBitrateKbps, sample.AudioBitrateKbps, sample.FPS, sample.KeyframeInterval,
+		sample.TimestampGaps, sample.MaxGapMs, sample.AVDriftMs, sample.MaxAVDriftMs,
+		sample.DroppedFrames, sample.CorruptTags, sample.Reconnects)
+	return err
+}
+
+// FinishTelemetry 写入结束时间和汇总
+func (s *SQLiteStore) FinishTelemetry(ctx context.Context, id int64, endTime time.Time, summary streamprobe.QualitySummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE stream_telemetry SET end_time = ?, summary = ? WHERE id = ?`,
+		toUnix(endTime), string(data), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTelemetryNotFound
+	}
+	return nil
+}
+
+// GetTelemetry 根据ID获取遥测记录
+func (s *SQLiteStore) GetTelemetry(ctx context.Context, id int64) (*Telemetry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+telemetryColumns+` FROM stream_telemetry WHERE id = ?`, id)
+	t, err := scanTelemetry(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrTelemetryNotFound
+	}
+	return t, err
+}
+
+// ListTelemetry 按开始时间倒序列出遥测记录
+func (s *SQLiteStore) ListTelemetry(ctx context.Context, q TelemetryQuery) ([]*Telemetry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var conds []string
+	var args []any
+	if q.LiveID != "" {
+		conds = append(conds, "live_id = ?")
+		args = append(args, q.LiveID)
+	}
+	if q.SessionID > 0 {
+		conds = append(conds, "session_id = ?")
+		args = append(args, q.SessionID)
+	}
+	query := `SELECT ` + telemetryColumns + ` FROM stream_telemetry`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY start_time DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Telemetry
+	for rows.Next() {
+		t, err := scanTelemetry(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// ListTelemetrySamples 按时间顺序返回采样点
+func (s *SQLiteStore) ListTelemetrySamples(ctx context.Context, telemetryID int64, from, to time.Time) ([]streamprobe.QualitySample, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := `SELECT ` + telemetrySampleColumns + ` FROM stream_telemetry_samples WHERE telemetry_id = ?`
+	args := []any{telemetryID}
+	if !from.IsZero() {
+		query += " AND time >= ?"
+		args = append(args, from.UnixMilli())
+	}
+	if !to.IsZero() {
+		query += " AND time < ?"
+		args = append(args, to.UnixMilli())
+	}
+	query += " ORDER BY time"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []streamprobe.QualitySample{}
+	for rows.Next() {
+		var sample streamprobe.QualitySample
+		var ts int64
+		if err := rows.Scan(&ts, &sample.Duration, &sample.Bytes,
+			&sample.BitrateKbps, &sample.VideoBitrateKbps, &sample.AudioBitrateKbps, &sample.FPS, &sample.KeyframeInterval,
+			&sample.TimestampGaps, &sample.MaxGapMs, &sample.AVDriftMs, &sample.MaxAVDriftMs,
+			&sample.DroppedFrames, &sample.CorruptTags, &sample.Reconnects); err != nil {
+			return nil, err
+		}
+		sample.Time = time.UnixMilli(ts)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// scanTelemetry 扫描单条遥测记录
+func scanTelemetry(row rowScanner) (*Telemetry, error) {
+	t := &Telemetry{}
+	var startTime, endTime int64
+	var summary string
+	err := row.Scan(&t.ID, &t.LiveID, &t.SessionID, &t.Platform, &t.HostName, &t.RoomName,
+		&startTime, &endTime, &summary, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.StartTime = fromUnix(startTime)
+	t.EndTime = fromUnix(endTime)
+	if summary != "" {
+		t.Summary = &streamprobe.QualitySummary{}
+		if err := json.Unmarshal([]byte(summary), t.Summary); err != nil {
+			t.Summary = nil
+		}
+	}
+	return t, nil
+}
+
+// StartTelemetry 为一次录制创建遥测记录，并关联到开始时所在的开播会话
+func (m *Manager) StartTelemetry(ctx context.Context, t *Telemetry) error {
+	t.SessionID = m.resolveSession(t.LiveID, t.StartTime)
+	return m.store.CreateTelemetry(ctx, t)
+}
+
+// AddTelemetrySample 写入一个采样点
+func (m *Manager) AddTelemetrySample(ctx context.Context, telemetryID int64, sample streamprobe.QualitySample) error {
+	return m.store.AddTelemetrySample(ctx, telemetryID, sample)
+}
+
+// FinishTelemetry 录制结束时写入汇总
+func (m *Manager) FinishTelemetry(ctx context.Context, id int64, endTime time.Time, summary streamprobe.QualitySummary) error {
+	return m.store.FinishTelemetry(ctx, id, endTime, summary)
+}
+
+// GetTelemetry 获取遥测记录
+func (m *Manager) GetTelemetry(ctx context.Context, id int64) (*Telemetry, error) {
+	return m.store.GetTelemetry(ctx, id)
+}
+
+// ListTelemetry 查询遥测记录
+func (m *Manager) ListTelemetry(ctx context.Context, q TelemetryQuery) ([]*Telemetry, error) {
+	return m.store.ListTelemetry(ctx, q)
+}
+
+// ListTelemetrySamples 获取遥测记录的采样点时间序列
+func (m *Manager) ListTelemetrySamples(ctx context.Context, telemetryID int64, from, to time.Time) ([]streamprobe.QualitySample, error) {
+	return m.store.ListTelemetrySamples(ctx, telemetryID, from, to)
+}
@@ -0,0 +1,121 @@
+package library
+
+import (
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+// Recording 录制文件索引记录
+type Recording struct {
+	ID             int64     `json:"id"`
+	Path           string    `json:"path"`             // 文件绝对路径
+	FileName       string    `json:"file_name"`        // 文件名
+	Ext            string    `json:"ext"`              // 扩展名（小写，不含点）
+	LiveID         string    `json:"live_id"`          // 直播间ID
+	Platform       string    `json:"platform"`         // 平台中文名
+	PlatformKey    string    `json:"platform_key"`     // 平台标识
+	HostName       string    `json:"host_name"`        // 主播名称
+	RoomName       string    `json:"room_name"`        // 直播标题
+	SessionID      int64     `json:"session_id"`       // 开播会话ID，0 表示未知
+	StartTime      time.Time `json:"start_time"`       // 录制开始时间
+	EndTime        time.Time `json:"end_time"`         // 录制结束时间
+	Duration       float64   `json:"duration"`         // 时长（秒）
+	Size           int64     `json:"size"`             // 文件大小（字节）
+	FileModTime    time.Time `json:"file_mtime"`       // 文件修改时间
+	VideoCodec     string    `json:"video_codec"`      // 视频编码
+	AudioCodec     string    `json:"audio_codec"`      // 音频编码
+	Width          int       `json:"width"`            // 视频宽度
+	Height         int       `json:"height"`           // 视频高度
+	FrameRate      float64   `json:"frame_rate"`       // 帧率
+	Source         string    `json:"source"`           // 来源
+	PipelineTaskID int64     `json:"pipeline_task_id"` // 最近一次关联的后处理任务ID
+	PipelineStatus string    `json:"pipeline_status"`  // 后处理状态
+	UploadStatus   string    `json:"upload_status"`    // 上传阶段状态（汇总所有上传阶段）
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// 以下字段由 API 层按当前存储根目录计算，不持久化
+	StorageRoot string `json:"storage_root,omitempty"` // 文件所在的存储根目录
+	RelPath     string `json:"rel_path,omitempty"`     // 相对于存储根目录的路径，可用于 /files/ 访问
+}
+
+// 录制记录来源常量
+const (
+	SourceRecorder = "recorder" // 录制器结束录制时写入
+	SourcePipeline = "pipeline" // 后处理管道产出（如转换后的 mp4）
+	SourceScan     = "scan"     // 扫描已有目录得到
+)
+
+// Query 录制记录查询条件，零值字段表示不过滤
+type Query struct {
+	Keyword        string    // 在主播名、标题、文件名中模糊匹配
+	LiveID         string    // 直播间ID
+	Platform       string    // 平台中文名或平台标识
+	HostName       string    // 主播名称（精确匹配）
+	SessionID      int64     // 开播会话ID
+	Ext            string    // 扩展名
+	VideoCodec     string    // 视频编码
+	PipelineStatus string    // 后处理状态
+	UploadStatus   string    // 上传状态
+	StartFrom      time.Time // 录制开始时间下限（含）
+	StartTo        time.Time // 录制开始时间上限（不含）
+	MinDuration    float64   // 最短时长（秒）
+	MaxDuration    float64   // 最长时长（秒）
+	MinSize        int64     // 最小文件大小（字节）
+	MaxSize        int64     // 最大文件大小（字节）
+	SortBy         string    // 排序字段，见 sortColumns
+	SortDesc       bool      // 是否降序
+	Limit          int       // 限制返回数量
+	Offset         int       // 偏移量
+}
+
+// SearchResult 查询结果
+type SearchResult struct {
+	Total      int          `json:"total"`      // 满足条件的总数（不受分页影响）
+	Recordings []*Recording `json:"recordings"` // 当前页的记录
+}
+
+// Stats 资料库汇总统计
+type Stats struct {
+	Count         int            `json:"count"`          // 记录总数
+	TotalSize     int64          `json:"total_size"`     // 总大小（字节）
+	TotalDuration float64        `json:"total_duration"` // 总时长（秒）
+	ByPlatform    map[string]int `json:"by_platform"`    // 各平台记录数
+}
+
+// ScanStatus 目录扫描状态
+type ScanStatus struct {
+	Running    bool      `json:"running"`
+	Roots      []string  `json:"roots"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Scanned    int       `json:"scanned"` // 已检查的媒体文件数
+	Added      int       `json:"added"`   // 新增索引数
+	Updated    int       `json:"updated"` // 更新索引数
+	Removed    int       `json:"removed"` // 因文件不存在而移除的索引数
+	Error      string    `json:"error,omitempty"`
+}
+
+// Telemetry 一次录制（录制器从开始到结束）的流质量遥测记录
+type Telemetry struct {
+	ID        int64     `json:"id"`
+	LiveID    string    `json:"live_id"`    // 直播间ID
+	SessionID int64     `json:"session_id"` // 开播会话ID，0 表示未知
+	Platform  string    `json:"platform"`   // 平台中文名
+	HostName  string    `json:"host_name"`  // 主播名称
+	RoomName  string    `json:"room_name"`  // 直播标题
+	StartTime time.Time `json:"start_time"` // 开始时间
+	EndTime   time.Time `json:"end_time"`   // 结束时间，零值表示仍在录制
+	// Summary 录制结束时写入的汇总，录制中为空
+	Summary   *streamprobe.QualitySummary `json:"summary,omitempty"`
+	CreatedAt time.Time                   `json:"created_at"`
+}
+
+// TelemetryQuery 遥测记录查询条件，零值字段表示不过滤
+type TelemetryQuery struct {
+	LiveID    string // 直播间ID
+	SessionID int64  // 开播会话ID
+	Limit     int    // 限制返回数量
+	Offset    int    // 偏移量
+}
@@ -57,6 +57,8 @@ type Info struct {
 	Status               bool // means isLiving, maybe better to rename it
 	Listening, Recording bool
 	RecordingPreparing   bool // 有 recorder 但尚未真正开始录制（重试中）
+	RecordingQueued      bool // 因最大同时录制数限制在等待队列中
+	QueuePosition        int  // 等待队列中的位置，从 1 开始
 	Initializing         bool
 	CustomLiveId         string
 	AudioOnly            bool
@@ -85,6 +87,8 @@ func (i *Info) MarshalJSON() ([]byte, error) {
 		Listening                 bool                   `json:"listening"`
 		Recording                 bool                   `json:"recording"`
 		RecordingPreparing        bool                   `json:"recording_preparing,omitempty"`
+		RecordingQueued           bool                   `json:"recording_queued,omitempty"`
+		QueuePosition             int                    `json:"queue_position,omitempty"`
 		Initializing              bool                   `json:"initializing"`
 		LastStartTime             string                 `json:"last_start_time,omitempty"`
 		LastStartTimeUnix         int64                  `json:"last_start_time_unix,omitempty"`
@@ -103,6 +107,8 @@ func (i *Info) MarshalJSON() ([]byte, error) {
 		Listening:                 i.Listening,
 		Recording:                 i.Recording,
 		RecordingPreparing:        i.RecordingPreparing,
+		RecordingQueued:           i.RecordingQueued,
+		QueuePosition:             i.QueuePosition,
 		Initializing:              i.Initializing,
 		AudioOnly:                 i.AudioOnly,
 		NickName:                  i.Live.GetOptions().NickName,
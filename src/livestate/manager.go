@@ -325,6 +325,24 @@ func (m *Manager) GetSessionHistory(liveID string, limit int) []*LiveSession {
 	return sessions
 }
 
+// FindSessionAt 查找指定时间点所在的开播会话ID，找不到时返回 0
+// 未结束的会话（EndTime 为零值）视为一直持续到当前
+func (m *Manager) FindSessionAt(liveID string, at time.Time) int64 {
+	sessions, err := m.store.GetSessionsByLiveID(m.ctx, liveID, 20)
+	if err != nil {
+		return 0
+	}
+	for _, s := range sessions {
+		if s.StartTime.After(at) {
+			continue
+		}
+		if s.EndTime.IsZero() || !s.EndTime.Before(at) {
+			return s.ID
+		}
+	}
+	return 0
+}
+
 // GetNameHistory 获取直播间的名称变更历史
 func (m *Manager) GetNameHistory(liveID string, limit int) []*NameChange {
 	changes, err := m.store.GetNameHistory(m.ctx, liveID, limit)
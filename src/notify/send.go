@@ -11,6 +11,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/notify/ntfy"
 	"github.com/bililive-go/bililive-go/src/notify/telegram"
 	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
+	"github.com/bililive-go/bililive-go/src/pkg/storagepool"
 )
 
 // RecordingFileDetail 录制文件详情
@@ -209,7 +210,7 @@ func buildRecordingSummaryMessage(hostName, platform string, files []RecordingFi
 	fmt.Fprintf(&sb, "总大小：%s", formatFileSize(totalSize))
 	// 显示剩余磁盘空间
 	if outputPath != "" {
-		if free, err := getDiskFreeSpace(outputPath); err == nil {
+		if free, err := storagepool.DiskFreeSpace(outputPath); err == nil {
 			fmt.Fprintf(&sb, "\n剩余磁盘空间：%s", formatFileSize(int64(free)))
 		}
 	}
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// ConditionFacts when 表达式可以引用的字段
+// 文件相关字段只统计输入中的视频文件：大小和时长为总和，扩展名和编码取第一个视频文件
+type ConditionFacts struct {
+	LiveID    string
+	Platform  string
+	HostName  string
+	RoomName  string
+	StartTime time.Time
+
+	Size       int64  // 字节
+	Duration   int64  // 秒，无法探测时为 0
+	Ext        string // 小写，不含点，例如 flv
+	VideoCodec string // h264、h265、av1
+	AudioCodec string
+	Width      int
+	Height     int
+	FileCount  int
+
+	// 录制开始时的本地时间，开始时间未知时取求值时的时间
+	Hour    int
+	Minute  int
+	Weekday int // 0 表示周日
+}
+
+// conditionFuncs when 表达式额外提供的函数，便于书写大小和时长
+// 模板的比较函数不允许整数与浮点数比较，因此大小和时长都以整数表示
+var conditionFuncs = template.FuncMap{
+	"kb":      func(n float64) int64 { return int64(n * 1024) },
+	"mb":      func(n float64) int64 { return int64(n * 1024 * 1024) },
+	"gb":      func(n float64) int64 { return int64(n * 1024 * 1024 * 1024) },
+	"minutes": func(n float64) int64 { return int64(n * 60) },
+	"hours":   func(n float64) int64 { return int64(n * 3600) },
+}
+
+// probedFieldPattern 需要探测视频文件才能得到的字段
+// 探测要读取每个视频文件的头尾，表达式没有引用这些字段时不探测
+var probedFieldPattern = regexp.MustCompile(`\.(Duration|VideoCodec|AudioCodec|Width|Height)\b`)
+
+// parseCondition 解析 when 表达式
+// 不含 {{ 的表达式视为单个动作，例如 `gt .Size (gb 2)` 等价于 `{{ gt .Size (gb 2) }}`
+func parseCondition(expr string) (*template.Template, error) {
+	text := strings.TrimSpace(expr)
+	if !strings.Contains(text, "{{") {
+		text = "{{ " + text + " }}"
+	}
+	tmpl, err := template.New("when").
+		Funcs(utils.GetFuncMap(TemplateConfig())).
+		Funcs(conditionFuncs).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid when expression %q: %w", expr, err)
+	}
+	return tmpl, nil
+}
+
+// evaluateCondition 对本次任务的输入求值 when 表达式
+// 返回是否满足条件以及可读的求值说明，说明会记录到跳过的阶段结果中
+func evaluateCondition(expr string, info RecordInfo, files []FileInfo) (bool, string, error) {
+	tmpl, err := parseCondition(expr)
+	if err != nil {
+		return false, "", err
+	}
+	facts := collectConditionFacts(info, files, probedFieldPattern.MatchString(expr))
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, facts); err != nil {
+		return false, "", fmt.Errorf("failed to evaluate when expression %q: %w", expr, err)
+	}
+	value := strings.TrimSpace(buf.String())
+	ok, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, "", fmt.Errorf("when expression %q must evaluate to true or false, got %q", expr, value)
+	}
+
+	reason := fmt.Sprintf("when: %s => %t (%s)", strings.TrimSpace(expr), ok, facts.describe())
+	return ok, reason, nil
+}
+
+// collectConditionFacts 汇总录制信息和输入文件信息，probe 为 false 时不探测时长和编码
+func collectConditionFacts(info RecordInfo, files []FileInfo, probe bool) ConditionFacts {
+	// 按录制开始时间判断时段，任务排队或重试时不会因为执行时间不同而得到不同的结果
+	at := info.StartTime
+	if at.IsZero() {
+		at = getTimeNow()
+	}
+	at = at.Local()
+	facts := ConditionFacts{
+		LiveID:    string(info.LiveID),
+		Platform:  info.Platform,
+		HostName:  info.HostName,
+		RoomName:  info.RoomName,
+		StartTime: info.StartTime,
+		Hour:      at.Hour(),
+		Minute:    at.Minute(),
+		Weekday:   int(at.Weekday()),
+	}
+
+	var duration float64
+	for _, file := range files {
+		if file.Type != FileTypeVideo {
+			continue
+		}
+		stat, err := os.Stat(file.Path)
+		if err != nil {
+			continue
+		}
+		facts.FileCount++
+		facts.Size += stat.Size()
+		first := facts.FileCount == 1
+		if first {
+			facts.Ext = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Path)), ".")
+		}
+		if !probe {
+			continue
+		}
+
+		probed, err := streamprobe.ProbeFile(file.Path)
+		if err != nil {
+			continue
+		}
+		duration += probed.Duration.Seconds()
+		if first && probed.StreamHeaderInfo != nil {
+			facts.VideoCodec = probed.VideoCodec
+			facts.AudioCodec = probed.AudioCodec
+			facts.Width = probed.Width
+			facts.Height = probed.Height
+		}
+	}
+	facts.Duration = int64(duration)
+	return facts
+}
+
+// describe 求值时用到的主要字段，便于排查条件为什么不满足
+func (f ConditionFacts) describe() string {
+	return fmt.Sprintf("Platform=%s HostName=%s Size=%d Duration=%d Ext=%s VideoCodec=%s Hour=%d",
+		f.Platform, f.HostName, f.Size, f.Duration, f.Ext, f.VideoCodec, f.Hour)
+}
@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestFLV 写入一个 H.264 + AAC、时长 90 秒的最小 FLV 文件
+func writeTestFLV(t *testing.T, path string) int64 {
+	t.Helper()
+	tag := func(tagType uint8, ts uint32, data []byte) []byte {
+		b := []byte{tagType, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data)),
+			byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24), 0, 0, 0}
+		b = append(b, data...)
+		return binary.BigEndian.AppendUint32(b, uint32(11+len(data)))
+	}
+	data := []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, tag(9, 0, []byte{0x17, 0, 0, 0, 0})...)
+	data = append(data, tag(8, 0, []byte{0xaf, 0, 0x12, 0x10})...)
+	data = append(data, tag(9, 90000, []byte{0x17, 1, 0, 0, 0})...)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return int64(len(data))
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	now := time.Date(2026, 10, 18, 3, 30, 0, 0, time.Local)
+	backup := getTimeNow
+	getTimeNow = func() time.Time { return now }
+	defer func() { getTimeNow = backup }()
+
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+	files := []FileInfo{
+		NewVideoFileInfo(video),
+		NewCoverFileInfo(filepath.Join(dir, "rec.jpg"), video),
+	}
+	info := RecordInfo{LiveID: "room", Platform: "哔哩哔哩", HostName: "主播", RoomName: "房间"}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`gt .Size (kb 0.01)`, true},
+		{`lt .Size (mb 1)`, true},
+		{`gt .Size (gb 2)`, false},
+		{`ge .Duration (minutes 1.5)`, true},
+		{`gt .Duration (minutes 1.5)`, false},
+		{`lt .Duration (hours 1)`, true},
+		{`eq .Ext "flv"`, true},
+		{`eq .Ext "mp4"`, false},
+		{`eq .FileCount 1`, true},
+		{`eq .Platform "哔哩哔哩"`, true},
+		{`eq .HostName "别人"`, false},
+		{`eq .LiveID "room"`, true},
+		{`eq .VideoCodec "h264"`, true},
+		{`eq .AudioCodec "aac"`, true},
+		{`and (ge .Hour 1) (lt .Hour 6)`, true},
+		{`and (eq .Hour 3) (eq .Minute 30)`, true},
+		{`ge .Hour 6`, false},
+		{`and (eq .Weekday 0) (gt .Size 0)`, now.Weekday() == time.Sunday},
+		{`{{ if eq .Ext "flv" }}true{{ else }}false{{ end }}`, true},
+		{`  eq .Ext "flv"  `, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ok, reason, err := evaluateCondition(tt.expr, info, files)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+			assert.Contains(t, reason, "when: ")
+		})
+	}
+}
+
+func TestEvaluateConditionErrors(t *testing.T) {
+	files := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "missing.flv"))}
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{`gt .Size (`, "invalid when expression"},
+		{`{{ if }}`, "invalid when expression"},
+		{`unknown_func .Size`, "invalid when expression"},
+		{`.HostName`, "must evaluate to true or false"},
+		{`printf "yes"`, "must evaluate to true or false"},
+		{`gt .Size "big"`, "failed to evaluate"},
+		{`eq .NoSuchField 1`, "failed to evaluate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, _, err := evaluateCondition(tt.expr, RecordInfo{HostName: "主播"}, files)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConditionFuncs(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`eq (kb 1) 1024`, true},
+		{`eq (kb 1.5) 1536`, true},
+		{`eq (mb 1) 1048576`, true},
+		{`eq (gb 2) 2147483648`, true},
+		{`eq (minutes 1.5) 90`, true},
+		{`eq (hours 2) 7200`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			ok, _, err := evaluateCondition(tt.expr, RecordInfo{}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
+
+func TestConditionProbesOnlyWhenReferenced(t *testing.T) {
+	tests := []struct {
+		expr  string
+		probe bool
+	}{
+		{`gt .Size (gb 2)`, false},
+		{`eq .HostName "主播"`, false},
+		{`gt .Duration (hours 1)`, true},
+		{`eq .VideoCodec "h265"`, true},
+		{`eq $.AudioCodec "aac"`, true},
+		{`gt .Width 1920`, true},
+		{`lt .Height 1080`, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.probe, probedFieldPattern.MatchString(tt.expr), tt.expr)
+	}
+
+	video := filepath.Join(t.TempDir(), "rec.flv")
+	size := writeTestFLV(t, video)
+	files := []FileInfo{NewVideoFileInfo(video)}
+
+	facts := collectConditionFacts(RecordInfo{}, files, false)
+	assert.Equal(t, size, facts.Size)
+	assert.Equal(t, "flv", facts.Ext)
+	assert.Zero(t, facts.Duration)
+	assert.Empty(t, facts.VideoCodec)
+
+	facts = collectConditionFacts(RecordInfo{}, files, true)
+	assert.Equal(t, int64(90), facts.Duration)
+	assert.Equal(t, "h264", facts.VideoCodec)
+}
+
+func TestConditionTimeOfDayUsesStartTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 3, 30, 0, 0, time.Local) // 周日
+	backup := getTimeNow
+	getTimeNow = func() time.Time { return now }
+	defer func() { getTimeNow = backup }()
+
+	// 深夜开播的录制在第二天凌晨才执行后处理，仍按开播时间判断
+	start := time.Date(2026, 10, 17, 23, 50, 0, 0, time.Local) // 周六
+	facts := collectConditionFacts(RecordInfo{StartTime: start}, nil, false)
+	assert.Equal(t, 23, facts.Hour)
+	assert.Equal(t, 50, facts.Minute)
+	assert.Equal(t, int(time.Saturday), facts.Weekday)
+
+	ok, _, err := evaluateCondition(`ge .Hour 22`, RecordInfo{StartTime: start}, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 开始时间未知时取当前时间
+	facts = collectConditionFacts(RecordInfo{}, nil, false)
+	assert.Equal(t, 3, facts.Hour)
+	assert.Equal(t, 30, facts.Minute)
+	assert.Equal(t, int(time.Sunday), facts.Weekday)
+}
@@ -1,6 +1,10 @@
 package pipeline
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+
 	"github.com/bililive-go/bililive-go/src/configs"
 )
 
@@ -11,8 +15,30 @@ const (
 	StageNameExtractCover = "extract_cover"
 	StageNameCloudUpload  = "cloud_upload"
 	StageNameCustomCmd    = "custom_command"
+	StageNameThumbnails   = "thumbnails"
+	StageNameHighlights   = "highlights"
+	StageNameClip         = "clip"
+	StageNameSessionMerge = "session_merge"
+	StageNameTranscode    = "transcode"
+	StageNameWebhook      = "webhook"
+	StageNameS3Upload     = "s3_upload"
+	StageNameWebDAVUpload = "webdav_upload"
+	StageNameMetadata     = "metadata_sidecar"
+	StageNameDanmakuASS   = "danmaku_ass"
 )
 
+// uploadStageNames 把文件上传到外部存储的阶段
+var uploadStageNames = map[string]bool{
+	StageNameCloudUpload:  true,
+	StageNameS3Upload:     true,
+	StageNameWebDAVUpload: true,
+}
+
+// IsUploadStage 判断阶段是否为上传阶段
+func IsUploadStage(name string) bool {
+	return uploadStageNames[name]
+}
+
 // 阶段选项键常量
 const (
 	// OptionDeleteSource 是否删除源文件
@@ -27,6 +53,135 @@ const (
 	OptionCommand = "command"
 	// OptionFileTypes 处理的文件类型过滤
 	OptionFileTypes = "file_types"
+	// OptionInterval 截图间隔（秒）
+	OptionInterval = "interval"
+	// OptionWidth 输出图片宽度（像素）
+	OptionWidth = "width"
+	// OptionColumns 雪碧图每行的缩略图数量
+	OptionColumns = "columns"
+	// OptionRows 雪碧图每列的缩略图数量，超出时输出多张雪碧图
+	OptionRows = "rows"
+	// OptionKeepFrames 是否保留单张缩略图
+	OptionKeepFrames = "keep_frames"
+	// OptionAccurate 是否逐帧解码以精确截图（默认只解码关键帧）
+	OptionAccurate = "accurate"
+	// OptionAnalyzer 响度分析滤镜：ebur128（默认）或 astats
+	OptionAnalyzer = "analyzer"
+	// OptionWindow 响度聚合窗口（秒）
+	OptionWindow = "window"
+	// OptionBaselineWindow 滚动基线覆盖的时长（秒）
+	OptionBaselineWindow = "baseline_window"
+	// OptionMinBaseline 开始检测前至少需要的基线时长（秒）
+	OptionMinBaseline = "min_baseline"
+	// OptionThreshold 响度高出基线多少 LU 时视为高光
+	OptionThreshold = "threshold"
+	// OptionMinLoudness 高光的最低响度（LUFS/dBFS）
+	OptionMinLoudness = "min_loudness"
+	// OptionMergeGap 相邻高光的合并间隔（秒）
+	OptionMergeGap = "merge_gap"
+	// OptionPreRoll 高光片段向前保留的时长（秒）
+	OptionPreRoll = "pre_roll"
+	// OptionPostRoll 高光片段向后保留的时长（秒）
+	OptionPostRoll = "post_roll"
+	// OptionMaxHighlights 最多记录的高光数量
+	OptionMaxHighlights = "max_highlights"
+	// OptionClipTop 按得分截取前 N 个高光片段（流复制），0 表示不截取；片段的文件类型为 other
+	OptionClipTop = "clip_top"
+	// OptionRanges 截取的时间范围列表，格式为 "开始-结束"，时间为秒数或 [HH:]MM:SS[.mmm]
+	OptionRanges = "ranges"
+	// OptionClipMode 截取方式：copy（默认，流复制，起点对齐到关键帧）或 reencode（重新编码，精确到帧）
+	OptionClipMode = "mode"
+	// OptionNameTemplate 输出文件名模板（不含扩展名）
+	OptionNameTemplate = "name_template"
+	// OptionConcat 是否把多个时间范围拼接为一个文件
+	OptionConcat = "concat"
+	// OptionPreset 转码预设：hevc_archive、preview_720p、target_size 或 custom
+	OptionPreset = "preset"
+	// OptionCRF 恒定质量参数，数值越小质量越高
+	OptionCRF = "crf"
+	// OptionSpeed 编码器速度预设（x264/x265 的 -preset，如 veryfast、medium、slow）
+	OptionSpeed = "speed"
+	// OptionTargetSize 目标文件大小，如 "500MB"
+	OptionTargetSize = "target_size"
+	// OptionAudioBitrate 音频码率（kbps）
+	OptionAudioBitrate = "audio_bitrate"
+	// OptionArgs 自定义 ffmpeg 输出参数（位于输入与输出文件之间）
+	OptionArgs = "args"
+	// OptionOutputExt 输出文件扩展名，如 ".mp4"
+	OptionOutputExt = "output_ext"
+	// OptionSuffix 输出文件名后缀（位于扩展名之前）
+	OptionSuffix = "suffix"
+	// OptionURLs 请求地址列表
+	OptionURLs = "urls"
+	// OptionBodyTemplate 请求体模板，渲染结果必须是 JSON，为空时使用默认格式
+	// 字段值用 toJson 转义，如 {"host": {{ toJson .HostName }}}
+	OptionBodyTemplate = "body_template"
+	// OptionHeaders 附加的请求头
+	OptionHeaders = "headers"
+	// OptionSecret HMAC-SHA256 签名密钥，为空时不签名
+	OptionSecret = "secret"
+	// OptionSignatureHeader 签名所在的请求头
+	OptionSignatureHeader = "signature_header"
+	// OptionPerFile 是否为每个文件单独发送请求
+	OptionPerFile = "per_file"
+	// OptionMaxRetries 单个请求失败后的重试次数
+	OptionMaxRetries = "max_retries"
+	// OptionRetryInterval 首次重试前的等待时间（秒），之后每次翻倍
+	OptionRetryInterval = "retry_interval"
+	// OptionRequestTimeout 单个请求的超时时间（秒）
+	OptionRequestTimeout = "request_timeout"
+	// OptionFailOnError 请求最终失败或返回非 2xx 时是否让管道失败
+	OptionFailOnError = "fail_on_error"
+	// OptionEndpoint S3 兼容服务的地址，例如 https://s3.us-east-1.amazonaws.com
+	OptionEndpoint = "endpoint"
+	// OptionRegion 签名使用的区域，默认 us-east-1
+	OptionRegion = "region"
+	// OptionBucket 存储桶名称
+	OptionBucket = "bucket"
+	// OptionAccessKey 访问密钥 ID，为空时读取环境变量 AWS_ACCESS_KEY_ID
+	OptionAccessKey = "access_key"
+	// OptionSecretKey 访问密钥，为空时读取环境变量 AWS_SECRET_ACCESS_KEY
+	OptionSecretKey = "secret_key"
+	// OptionPathStyle 是否使用路径形式访问存储桶（默认 true，MinIO 等自建服务通常需要）
+	OptionPathStyle = "path_style"
+	// OptionStorageClass 对象的存储类型，例如 STANDARD_IA、GLACIER_IR
+	OptionStorageClass = "storage_class"
+	// OptionPartSize 分片大小（MB），不小于 5
+	OptionPartSize = "part_size"
+	// OptionURL WebDAV 根地址，例如 https://nas.local:5006/records
+	OptionURL = "url"
+	// OptionUsername 认证用户名
+	OptionUsername = "username"
+	// OptionPassword 认证密码
+	OptionPassword = "password"
+	// OptionChunked 是否使用分块传输编码上传（不预先声明文件大小）
+	OptionChunked = "chunked"
+	// OptionFormats 输出的元数据格式：nfo、json，默认两者都输出
+	OptionFormats = "formats"
+	// OptionTitleTemplate 标题模板
+	OptionTitleTemplate = "title_template"
+	// OptionNFOTemplate NFO 文件模板，为空时使用 Kodi 电影格式
+	OptionNFOTemplate = "nfo_template"
+	// OptionJSONTemplate JSON 文件模板，必须生成合法的 JSON
+	OptionJSONTemplate = "json_template"
+	// OptionFontSize 弹幕字号（像素，相对视频分辨率），0 表示按分辨率自动计算
+	OptionFontSize = "font_size"
+	// OptionFontName 弹幕字体
+	OptionFontName = "font_name"
+	// OptionLanes 弹幕轨道数，0 表示铺满画面
+	OptionLanes = "lanes"
+	// OptionOpacity 弹幕不透明度（0-1）
+	OptionOpacity = "opacity"
+	// OptionDuration 滚动弹幕横穿画面的时长（秒）
+	OptionDuration = "duration"
+	// OptionBlockedWords 屏蔽词列表，包含任一屏蔽词的弹幕不显示
+	OptionBlockedWords = "blocked_words"
+	// OptionFilterGifts 是否过滤礼物、醒目留言和上舰消息
+	OptionFilterGifts = "filter_gifts"
+	// OptionFilterSystem 是否过滤系统弹幕
+	OptionFilterSystem = "filter_system"
+	// OptionBurnIn 是否把字幕压制进视频，另外输出一个视频文件
+	OptionBurnIn = "burn_in"
 )
 
 // OnRecordFinishedPipeline 扩展版的录制完成后配置
@@ -40,6 +195,7 @@ type OnRecordFinishedPipeline struct {
 	SaveCover             bool                 `yaml:"save_cover,omitempty" json:"save_cover,omitempty"`
 	CloudUpload           configs.CloudUpload  `yaml:"cloud_upload,omitempty" json:"cloud_upload,omitempty"`
 	UploadTiming          configs.UploadTiming `yaml:"upload_timing,omitempty" json:"upload_timing,omitempty"`
+	SessionMerge          configs.SessionMerge `yaml:"session_merge,omitempty" json:"session_merge,omitempty"`
 
 	// 新格式字段
 	Pipeline *PipelineConfig `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
@@ -61,7 +217,17 @@ func ConvertLegacyConfig(legacy *configs.OnRecordFinished) *PipelineConfig {
 		})
 	}
 
-	// 2. MP4 转换
+	// 2. 会话合并（分段各自修复后再拼接）
+	if legacy.SessionMerge.Enable {
+		stages = append(stages, StageConfig{
+			Name: StageNameSessionMerge,
+			Options: map[string]any{
+				OptionDeleteSource: legacy.SessionMerge.DeleteSource,
+			},
+		})
+	}
+
+	// 3. MP4 转换
 	if legacy.ConvertToMp4 {
 		stages = append(stages, StageConfig{
 			Name: StageNameConvertMp4,
@@ -71,14 +237,14 @@ func ConvertLegacyConfig(legacy *configs.OnRecordFinished) *PipelineConfig {
 		})
 	}
 
-	// 3. 封面提取
+	// 4. 封面提取
 	if legacy.SaveCover {
 		stages = append(stages, StageConfig{
 			Name: StageNameExtractCover,
 		})
 	}
 
-	// 4. 云上传
+	// 5. 云上传
 	if legacy.CloudUpload.Enable && legacy.CloudUpload.StorageName != "" {
 		stages = append(stages, StageConfig{
 			Name: StageNameCloudUpload,
@@ -90,7 +256,7 @@ func ConvertLegacyConfig(legacy *configs.OnRecordFinished) *PipelineConfig {
 		})
 	}
 
-	// 5. 自定义命令（在最后执行）
+	// 6. 自定义命令（在最后执行）
 	if legacy.CustomCommandline != "" {
 		stages = append(stages, StageConfig{
 			Name: StageNameCustomCmd,
@@ -107,17 +273,63 @@ func ConvertLegacyConfig(legacy *configs.OnRecordFinished) *PipelineConfig {
 // 如果配置了新格式的 pipeline，使用新格式
 // 否则自动转换旧格式
 func GetEffectivePipelineConfig(config *configs.OnRecordFinished) *PipelineConfig {
+	if !IsLegacyConfig(config) {
+		return &PipelineConfig{Stages: convertStageConfigs(config.Pipeline)}
+	}
 	// 旧格式，需要转换
 	return ConvertLegacyConfig(config)
 }
 
+// convertStageConfigs 将配置文件中的阶段列表转换为 StageConfig
+func convertStageConfigs(stages []configs.PipelineStage) []StageConfig {
+	if len(stages) == 0 {
+		return nil
+	}
+	result := make([]StageConfig, len(stages))
+	for i, s := range stages {
+		result[i] = StageConfig{
+			ID:           s.ID,
+			DependsOn:    slices.Clone(s.DependsOn),
+			Name:         s.Name,
+			Enabled:      s.Enabled,
+			Parallel:     convertStageConfigs(s.Parallel),
+			Options:      maps.Clone(s.Options),
+			When:         s.When,
+			Retries:      s.Retries,
+			RetryBackoff: s.RetryBackoff,
+			Timeout:      s.Timeout,
+		}
+	}
+	return result
+}
+
+// ValidateRecordFinished 检查全局、各平台和各直播间的录制完成管道
+// 配置了 pipeline 时检查其中的阶段、执行条件和依赖关系，否则检查旧格式转换出的管道
+func (e *Executor) ValidateRecordFinished(cfg *configs.Config) error {
+	if err := e.ValidateConfig(GetEffectivePipelineConfig(&cfg.OnRecordFinished)); err != nil {
+		return fmt.Errorf("录制完成动作配置无效: %w", err)
+	}
+	platforms := slices.Sorted(maps.Keys(cfg.PlatformConfigs))
+	for _, platform := range platforms {
+		resolved := cfg.ResolveConfigForRoom(&configs.LiveRoom{}, platform)
+		if err := e.ValidateConfig(GetEffectivePipelineConfig(&resolved.OnRecordFinished)); err != nil {
+			return fmt.Errorf("平台 '%s': 录制完成动作配置无效: %w", platform, err)
+		}
+	}
+	for _, room := range cfg.LiveRooms {
+		resolved := cfg.GetEffectiveConfigForRoom(room.Url)
+		if err := e.ValidateConfig(GetEffectivePipelineConfig(&resolved.OnRecordFinished)); err != nil {
+			return fmt.Errorf("直播间 '%s': 录制完成动作配置无效: %w", room.Url, err)
+		}
+	}
+	return nil
+}
+
 // IsLegacyConfig 检查是否为旧配置格式
 // 旧格式：任何传统字段被设置
 // 新格式：pipeline 字段被设置
 func IsLegacyConfig(config *configs.OnRecordFinished) bool {
-	// 目前总是返回 true，因为当前版本只有旧格式
-	// 后续可以添加 pipeline 字段检测
-	return true
+	return config == nil || len(config.Pipeline) == 0
 }
 
 // BuildDefaultPipelineConfig 构建默认的 Pipeline 配置
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+const pipelineConfigYAML = `
+on_record_finished:
+  convert_to_mp4: true
+  pipeline:
+    - id: fix
+      name: fix_flv
+    - id: hevc
+      name: transcode
+      depends_on: [fix]
+      when: gt .Size (gb 2)
+      retries: 2
+      retry_backoff: 30s
+      timeout: 2h
+      options:
+        preset: hevc_archive
+        crf: 24
+platform_configs:
+  bilibili:
+    on_record_finished:
+      pipeline:
+        - parallel:
+            - name: thumbnails
+            - name: highlights
+              enabled: false
+live_rooms:
+  - url: https://live.bilibili.com/1
+  - url: https://live.bilibili.com/2
+    on_record_finished:
+      convert_to_mp4: true
+  - url: https://www.huya.com/3
+`
+
+func TestGetEffectivePipelineConfig(t *testing.T) {
+	cfg, err := configs.NewConfigWithBytes([]byte(pipelineConfigYAML))
+	require.NoError(t, err)
+
+	// 全局配置使用 pipeline，忽略 convert_to_mp4
+	global := GetEffectivePipelineConfig(&cfg.OnRecordFinished)
+	require.Len(t, global.Stages, 2)
+	assert.Equal(t, StageConfig{ID: "fix", Name: StageNameFixFlv}, global.Stages[0])
+	transcode := global.Stages[1]
+	assert.Equal(t, "hevc", transcode.ID)
+	assert.Equal(t, StageNameTranscode, transcode.Name)
+	assert.Equal(t, []string{"fix"}, transcode.DependsOn)
+	assert.Equal(t, "gt .Size (gb 2)", transcode.When)
+	assert.Equal(t, 2, transcode.Retries)
+	assert.Equal(t, "30s", transcode.RetryBackoff)
+	assert.Equal(t, "2h", transcode.Timeout)
+	assert.Equal(t, "hevc_archive", transcode.GetStringOption(OptionPreset, ""))
+	assert.Equal(t, 24, transcode.GetIntOption(OptionCRF, 0))
+	assert.True(t, global.IsDAG())
+
+	// 没有覆盖的直播间继承全局管道
+	huya := cfg.GetEffectiveConfigForRoom("https://www.huya.com/3")
+	assert.Equal(t, global, GetEffectivePipelineConfig(&huya.OnRecordFinished))
+
+	// 平台级覆盖
+	room1 := cfg.GetEffectiveConfigForRoom("https://live.bilibili.com/1")
+	platform := GetEffectivePipelineConfig(&room1.OnRecordFinished)
+	require.Len(t, platform.Stages, 1)
+	require.Len(t, platform.Stages[0].Parallel, 2)
+	assert.True(t, platform.HasStage(StageNameThumbnails))
+	assert.False(t, platform.HasStage(StageNameHighlights))
+	assert.False(t, platform.HasStage(StageNameFixFlv))
+
+	// 直播间级覆盖没有设置 pipeline 时回退到旧格式
+	room2 := cfg.GetEffectiveConfigForRoom("https://live.bilibili.com/2")
+	assert.True(t, IsLegacyConfig(&room2.OnRecordFinished))
+	legacy := GetEffectivePipelineConfig(&room2.OnRecordFinished)
+	require.Len(t, legacy.Stages, 1)
+	assert.Equal(t, StageNameConvertMp4, legacy.Stages[0].Name)
+}
+
+func TestGetEffectivePipelineConfigDoesNotShareOptions(t *testing.T) {
+	finished := &configs.OnRecordFinished{Pipeline: []configs.PipelineStage{{
+		Name:      StageNameWebhook,
+		DependsOn: []string{"a"},
+		Options:   map[string]any{OptionURL: "http://example.com"},
+	}}}
+	converted := GetEffectivePipelineConfig(finished)
+	converted.Stages[0].Options[OptionURL] = "changed"
+	converted.Stages[0].DependsOn[0] = "b"
+	assert.Equal(t, "http://example.com", finished.Pipeline[0].Options[OptionURL])
+	assert.Equal(t, "a", finished.Pipeline[0].DependsOn[0])
+}
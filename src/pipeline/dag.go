@@ -0,0 +1,334 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+
+	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+)
+
+// dagNode DAG 管道中的一个已启用阶段
+type dagNode struct {
+	config     StageConfig
+	stageIndex int   // 在已启用阶段中的位置，与线性管道的 StageIndex 含义一致
+	deps       []int // 实际生效的依赖在 nodes 中的位置
+	dependents []int
+	level      int
+}
+
+// buildStageGraph 检查阶段依赖并构建 DAG
+// 没有 depends_on 的阶段是起始阶段，输入为任务的初始文件；
+// 禁用的阶段不执行，依赖它的阶段改为依赖它的上游
+func buildStageGraph(stages []StageConfig) ([]*dagNode, error) {
+	ids := make(map[string]int, len(stages))
+	for i, stage := range stages {
+		if stage.ID == "" {
+			continue
+		}
+		if j, ok := ids[stage.ID]; ok {
+			return nil, fmt.Errorf("stage[%d] and stage[%d] have the same id %q", j, i, stage.ID)
+		}
+		ids[stage.ID] = i
+	}
+
+	deps := make([][]int, len(stages))
+	for i, stage := range stages {
+		for _, id := range stage.DependsOn {
+			j, ok := ids[id]
+			if !ok {
+				return nil, fmt.Errorf("stage[%d] depends on unknown stage %q", i, id)
+			}
+			if j == i {
+				return nil, fmt.Errorf("stage[%d] depends on itself", i)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	if cycle := findStageCycle(stages, deps); cycle != "" {
+		return nil, fmt.Errorf("stage dependencies contain a cycle: %s", cycle)
+	}
+
+	// 启用的阶段按配置顺序编号
+	nodeOf := make(map[int]int, len(stages))
+	var nodes []*dagNode
+	for i, stage := range stages {
+		if !stage.IsEnabled() {
+			continue
+		}
+		nodeOf[i] = len(nodes)
+		nodes = append(nodes, &dagNode{config: stage, stageIndex: len(nodes)})
+	}
+
+	// 跳过禁用的阶段，解析实际生效的依赖
+	var resolve func(i int, seen map[int]bool, out *[]int)
+	resolve = func(i int, seen map[int]bool, out *[]int) {
+		for _, j := range deps[i] {
+			if n, ok := nodeOf[j]; ok {
+				if !seen[n] {
+					seen[n] = true
+					*out = append(*out, n)
+				}
+				continue
+			}
+			resolve(j, seen, out)
+		}
+	}
+	for i := range stages {
+		n, ok := nodeOf[i]
+		if !ok {
+			continue
+		}
+		resolve(i, make(map[int]bool), &nodes[n].deps)
+		for _, d := range nodes[n].deps {
+			nodes[d].dependents = append(nodes[d].dependents, n)
+		}
+	}
+
+	// 无环时按依赖计算层数
+	var level func(n int) int
+	levels := make(map[int]int, len(nodes))
+	level = func(n int) int {
+		if l, ok := levels[n]; ok {
+			return l
+		}
+		l := 0
+		for _, d := range nodes[n].deps {
+			l = max(l, level(d)+1)
+		}
+		levels[n] = l
+		return l
+	}
+	for n, node := range nodes {
+		node.level = level(n)
+	}
+	return nodes, nil
+}
+
+// findStageCycle 查找依赖环，返回环上阶段组成的描述，无环时返回空字符串
+func findStageCycle(stages []StageConfig, deps [][]int) string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(stages))
+	var path []int
+	var visit func(i int) string
+	visit = func(i int) string {
+		state[i] = visiting
+		path = append(path, i)
+		for _, j := range deps[i] {
+			switch state[j] {
+			case visiting:
+				// 从环的起点截取路径
+				var names []string
+				for k := len(path) - 1; k >= 0; k-- {
+					names = append([]string{stageLabel(stages[path[k]])}, names...)
+					if path[k] == j {
+						break
+					}
+				}
+				return strings.Join(append(names, stageLabel(stages[j])), " -> ")
+			case unvisited:
+				if cycle := visit(j); cycle != "" {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return ""
+	}
+	for i := range stages {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != "" {
+				return cycle
+			}
+		}
+	}
+	return ""
+}
+
+func stageLabel(stage StageConfig) string {
+	if stage.ID != "" {
+		return stage.ID
+	}
+	return stage.Name
+}
+
+// executeDAG 按依赖关系执行管道，依赖都完成的阶段立即开始，互不依赖的阶段并行执行
+// 阶段的输入是所有依赖阶段输出的并集；任一阶段失败后不再启动新的阶段，等待已启动的阶段结束
+func (e *Executor) executeDAG(
+	ctx *PipelineContext,
+	config *PipelineConfig,
+	initialFiles []FileInfo,
+	reusable map[int]StageResult,
+	onProgress func(stageIndex int, stageName string, status StageStatus),
+	onResult func(result StageResult),
+) ([]StageResult, error) {
+	nodes, err := buildStageGraph(config.Stages)
+	if err != nil {
+		return nil, err
+	}
+
+	// 上次已完成且所有上游也沿用的阶段不再执行
+	reused := make([]bool, len(nodes))
+	checked := make([]bool, len(nodes))
+	var canReuse func(n int) bool
+	canReuse = func(n int) bool {
+		if checked[n] {
+			return reused[n]
+		}
+		checked[n] = true
+		prev, ok := reusable[nodes[n].stageIndex]
+		ok = ok && prev.StageName == nodes[n].config.Name
+		for _, d := range nodes[n].deps {
+			ok = canReuse(d) && ok
+		}
+		reused[n] = ok
+		return ok
+	}
+
+	// 并行执行时以已完成的阶段数作为当前进度
+	var completed atomic.Int64
+	progress := func(_ int, stageName string, status StageStatus) {
+		if onProgress != nil {
+			onProgress(int(completed.Load()), stageName, status)
+		}
+	}
+
+	type dagDone struct {
+		node   int
+		result StageResult
+		err    error
+	}
+	doneCh := make(chan dagDone, len(nodes))
+	outputs := make([][]FileInfo, len(nodes))
+	waiting := make([]int, len(nodes))
+	running := 0
+
+	start := func(n int) {
+		node := nodes[n]
+		input := initialFiles
+		if len(node.deps) > 0 {
+			var merged []FileInfo
+			for _, d := range node.deps {
+				merged = append(merged, outputs[d]...)
+			}
+			input = deduplicateFiles(merged)
+		}
+		e.logger.WithFields(logrus.Fields{
+			"stage_index": node.stageIndex,
+			"stage_name":  node.config.Name,
+			"input_count": len(input),
+		}).Debug("executing dag stage")
+
+		running++
+		bilisentry.Go(func() {
+			result, err := e.runStage(ctx, node.config, node.stageIndex, input, progress)
+			doneCh <- dagDone{node: n, result: result, err: err}
+		})
+	}
+
+	for n := range nodes {
+		canReuse(n)
+	}
+	// 沿用阶段的输出是未沿用的后继阶段的输入，其中有文件已不存在时重新执行该阶段
+	for changed := true; changed; {
+		changed = false
+		for n, node := range nodes {
+			if !reused[n] || outputsExist(reusable[node.stageIndex]) {
+				continue
+			}
+			for _, next := range node.dependents {
+				if !reused[next] {
+					reused[n] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	results := make([]StageResult, 0, len(nodes))
+	for n, node := range nodes {
+		if !reused[n] {
+			continue
+		}
+		result := reusable[node.stageIndex]
+		outputs[n] = result.OutputFiles
+		completed.Add(1)
+		results = append(results, result)
+	}
+	for n, node := range nodes {
+		if reused[n] {
+			continue
+		}
+		for _, d := range node.deps {
+			if !reused[d] {
+				waiting[n]++
+			}
+		}
+	}
+	for n := range nodes {
+		if !reused[n] && waiting[n] == 0 {
+			start(n)
+		}
+	}
+
+	var firstErr error
+	for running > 0 {
+		done := <-doneCh
+		running--
+
+		node := nodes[done.node]
+		result := done.result
+		result.StageID = node.config.ID
+		result.Level = node.level
+		result.Terminal = len(node.dependents) == 0
+		for _, d := range node.deps {
+			result.DependsOn = append(result.DependsOn, stageLabel(nodes[d].config))
+		}
+		results = append(results, result)
+		if onResult != nil {
+			onResult(result)
+		}
+
+		if done.err != nil {
+			if firstErr == nil {
+				firstErr = done.err
+			}
+			continue
+		}
+		outputs[done.node] = result.OutputFiles
+		completed.Add(1)
+
+		if firstErr != nil || ctx.Ctx.Err() != nil {
+			continue
+		}
+		for _, next := range node.dependents {
+			if reused[next] {
+				continue
+			}
+			waiting[next]--
+			if waiting[next] == 0 {
+				start(next)
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].StageIndex < results[j].StageIndex
+	})
+	if firstErr != nil {
+		return results, firstErr
+	}
+	if err := ctx.Ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
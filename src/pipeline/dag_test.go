@@ -0,0 +1,293 @@
+package pipeline
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildStageGraph(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		stages  []StageConfig
+		wantErr string
+		// 每个节点的名称、依赖（节点名称）和层数
+		want []struct {
+			name  string
+			deps  []string
+			level int
+		}
+	}{
+		{
+			name: "duplicate id",
+			stages: []StageConfig{
+				{ID: "a", Name: "x"},
+				{ID: "a", Name: "y"},
+			},
+			wantErr: `stage[0] and stage[1] have the same id "a"`,
+		},
+		{
+			name: "unknown dependency",
+			stages: []StageConfig{
+				{ID: "a", Name: "x", DependsOn: []string{"missing"}},
+			},
+			wantErr: `stage[0] depends on unknown stage "missing"`,
+		},
+		{
+			name: "self dependency",
+			stages: []StageConfig{
+				{ID: "a", Name: "x", DependsOn: []string{"a"}},
+			},
+			wantErr: "stage[0] depends on itself",
+		},
+		{
+			name: "cycle",
+			stages: []StageConfig{
+				{ID: "start", Name: "x"},
+				{ID: "a", Name: "x", DependsOn: []string{"start", "c"}},
+				{ID: "b", Name: "x", DependsOn: []string{"a"}},
+				{ID: "c", Name: "x", DependsOn: []string{"b"}},
+			},
+			wantErr: "stage dependencies contain a cycle: a -> c -> b -> a",
+		},
+		{
+			name: "fan-out and fan-in",
+			stages: []StageConfig{
+				{ID: "a", Name: "a"},
+				{ID: "b", Name: "b", DependsOn: []string{"a"}},
+				{ID: "c", Name: "c", DependsOn: []string{"a"}},
+				{ID: "d", Name: "d", DependsOn: []string{"b", "c"}},
+			},
+			want: []struct {
+				name  string
+				deps  []string
+				level int
+			}{
+				{"a", nil, 0},
+				{"b", []string{"a"}, 1},
+				{"c", []string{"a"}, 1},
+				{"d", []string{"b", "c"}, 2},
+			},
+		},
+		{
+			name: "disabled dependency resolves to its upstream",
+			stages: []StageConfig{
+				{ID: "a", Name: "a"},
+				{ID: "b", Name: "b", DependsOn: []string{"a"}, Enabled: &disabled},
+				{ID: "c", Name: "c", DependsOn: []string{"b", "a"}},
+				{ID: "d", Name: "d", DependsOn: []string{"b"}},
+			},
+			want: []struct {
+				name  string
+				deps  []string
+				level int
+			}{
+				{"a", nil, 0},
+				{"c", []string{"a"}, 1},
+				{"d", []string{"a"}, 1},
+			},
+		},
+		{
+			name: "disabled root makes dependents start stages",
+			stages: []StageConfig{
+				{ID: "a", Name: "a", Enabled: &disabled},
+				{ID: "b", Name: "b", DependsOn: []string{"a"}},
+			},
+			want: []struct {
+				name  string
+				deps  []string
+				level int
+			}{
+				{"b", nil, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := buildStageGraph(tt.stages)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, nodes, len(tt.want))
+			for i, want := range tt.want {
+				node := nodes[i]
+				assert.Equal(t, want.name, node.config.Name)
+				assert.Equal(t, i, node.stageIndex)
+				var deps []string
+				for _, d := range node.deps {
+					deps = append(deps, nodes[d].config.Name)
+				}
+				assert.Equal(t, want.deps, deps, want.name)
+				assert.Equal(t, want.level, node.level, want.name)
+			}
+		})
+	}
+}
+
+// dagTestStages 记录每个阶段收到的输入，并为每个输入输出一个带阶段名后缀的文件
+type dagTestStages struct {
+	mu     sync.Mutex
+	inputs map[string][]string
+	fail   map[string]bool
+}
+
+func (s *dagTestStages) executor(names ...string) *Executor {
+	s.inputs = make(map[string][]string)
+	stages := make(map[string]func(*PipelineContext, []FileInfo) ([]FileInfo, error))
+	for _, name := range names {
+		suffix := suffixStage("." + name)
+		stages[name] = func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+			s.mu.Lock()
+			s.inputs[name] = testPaths(input)
+			fail := s.fail[name]
+			s.mu.Unlock()
+			if fail {
+				return nil, errors.New("boom")
+			}
+			return suffix(ctx, input)
+		}
+	}
+	return newTestExecutor(stages)
+}
+
+func TestExecuteDAGFanOutFanIn(t *testing.T) {
+	s := &dagTestStages{}
+	e := s.executor("a", "b", "c", "d")
+	config := &PipelineConfig{Stages: []StageConfig{
+		{ID: "a", Name: "a"},
+		{ID: "b", Name: "b", DependsOn: []string{"a"}},
+		{ID: "c", Name: "c", DependsOn: []string{"a"}},
+		{ID: "d", Name: "d", DependsOn: []string{"b", "c"}},
+	}}
+	require.NoError(t, e.ValidateConfig(config))
+	input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+
+	results, err := e.Execute(newTestPipelineContext(), config, input, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"rec.flv"}, s.inputs["a"])
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["b"])
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["c"])
+	// 汇合阶段的输入是所有依赖阶段输出的并集，按依赖声明顺序排列
+	assert.Equal(t, []string{"rec.flv.a.b", "rec.flv.a.c"}, s.inputs["d"])
+
+	require.Len(t, results, 4)
+	for i, want := range []struct {
+		id       string
+		deps     []string
+		level    int
+		terminal bool
+	}{
+		{"a", nil, 0, false},
+		{"b", []string{"a"}, 1, false},
+		{"c", []string{"a"}, 1, false},
+		{"d", []string{"b", "c"}, 2, true},
+	} {
+		assert.Equal(t, i, results[i].StageIndex)
+		assert.Equal(t, want.id, results[i].StageID)
+		assert.Equal(t, want.deps, results[i].DependsOn)
+		assert.Equal(t, want.level, results[i].Level)
+		assert.Equal(t, want.terminal, results[i].Terminal)
+		assert.Equal(t, StageStatusCompleted, results[i].Status)
+	}
+	assert.Equal(t, []string{"rec.flv.a.b.d", "rec.flv.a.c.d"}, testPaths(FinalOutputFiles(results)))
+}
+
+func TestExecuteDAGStopsAfterFailureAndResumes(t *testing.T) {
+	s := &dagTestStages{fail: map[string]bool{"c": true}}
+	e := s.executor("a", "b", "c", "d")
+	config := &PipelineConfig{Stages: []StageConfig{
+		{ID: "a", Name: "a"},
+		{ID: "b", Name: "b", DependsOn: []string{"a"}},
+		{ID: "c", Name: "c", DependsOn: []string{"a"}},
+		{ID: "d", Name: "d", DependsOn: []string{"b", "c"}},
+	}}
+	input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+
+	results, err := e.Execute(newTestPipelineContext(), config, input, nil)
+	require.Error(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, StageStatusFailed, results[2].Status)
+	assert.NotContains(t, s.inputs, "d")
+
+	// 恢复执行时沿用 a、b 的结果，只执行失败的 c 和之后的 d
+	s.mu.Lock()
+	s.fail = nil
+	s.inputs = make(map[string][]string)
+	s.mu.Unlock()
+	results, err = e.Resume(newTestPipelineContext(), config, input, results, nil, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, s.inputs, "a")
+	assert.NotContains(t, s.inputs, "b")
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["c"])
+	assert.Equal(t, []string{"rec.flv.a.b", "rec.flv.a.c"}, s.inputs["d"])
+	require.Len(t, results, 4)
+	for _, r := range results {
+		assert.Equal(t, StageStatusCompleted, r.Status, r.StageName)
+	}
+}
+
+func TestExecuteDAGDisabledStage(t *testing.T) {
+	disabled := false
+	s := &dagTestStages{}
+	e := s.executor("a", "b", "c")
+	config := &PipelineConfig{Stages: []StageConfig{
+		{ID: "a", Name: "a"},
+		{ID: "b", Name: "b", DependsOn: []string{"a"}, Enabled: &disabled},
+		{ID: "c", Name: "c", DependsOn: []string{"b"}},
+	}}
+	input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+
+	results, err := e.Execute(newTestPipelineContext(), config, input, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, s.inputs, "b")
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["c"])
+	require.Len(t, results, 2)
+	assert.Equal(t, []string{"a"}, results[1].DependsOn)
+}
+
+func TestValidateConfig(t *testing.T) {
+	e := newTestExecutor(map[string]func(*PipelineContext, []FileInfo) ([]FileInfo, error){
+		"a": suffixStage(".a"),
+	})
+	e.RegisterStage("strict", func(cfg StageConfig) (Stage, error) {
+		if _, ok := cfg.GetOption("required"); !ok {
+			return nil, errors.New("option required is missing")
+		}
+		return &fakeStage{name: cfg.Name}, nil
+	})
+	tests := []struct {
+		name    string
+		stages  []StageConfig
+		wantErr string
+	}{
+		{"valid", []StageConfig{{ID: "x", Name: "a"}, {Name: "a", DependsOn: []string{"x"}}}, ""},
+		{"no name", []StageConfig{{}}, "stage[0] has no name"},
+		{"unknown stage", []StageConfig{{Name: "nope"}}, "unknown stage[0]: nope"},
+		{"stage options are checked when the stage is created", []StageConfig{{Name: "strict"}}, ""},
+		{"bad retry", []StageConfig{{Name: "a", Timeout: "soon"}}, `invalid timeout "soon"`},
+		{"bad when", []StageConfig{{Name: "a", When: "gt .Size ("}}, "invalid when expression"},
+		{"cycle", []StageConfig{{ID: "x", Name: "a", DependsOn: []string{"y"}}, {ID: "y", Name: "a", DependsOn: []string{"x"}}}, "cycle"},
+		{"parallel id", []StageConfig{{Name: "g", Parallel: []StageConfig{{Name: "a", ID: "p"}}}}, "cannot declare id or depends_on"},
+		{"parallel unknown", []StageConfig{{Name: "g", Parallel: []StageConfig{{Name: "nope"}}}}, "unknown parallel stage[0][0]: nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.ValidateConfig(&PipelineConfig{Stages: tt.stages})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -50,15 +51,36 @@ func (e *Executor) Execute(
 	config *PipelineConfig,
 	initialFiles []FileInfo,
 	onProgress func(stageIndex int, stageName string, status StageStatus),
+) ([]StageResult, error) {
+	return e.Resume(ctx, config, initialFiles, nil, onProgress, nil)
+}
+
+// Resume 从第一个未完成的阶段继续执行管道
+// previous 是上次执行留下的阶段结果，已完成或已跳过的阶段直接沿用其输出，不再执行；
+// 线性管道从第一个缺少结果的阶段开始，之后的阶段全部重新执行。
+// onResult 在每个阶段结束时调用（包括失败），可用于持久化进度，可以为 nil
+func (e *Executor) Resume(
+	ctx *PipelineContext,
+	config *PipelineConfig,
+	initialFiles []FileInfo,
+	previous []StageResult,
+	onProgress func(stageIndex int, stageName string, status StageStatus),
+	onResult func(result StageResult),
 ) ([]StageResult, error) {
 	if config == nil || len(config.Stages) == 0 {
 		e.logger.Debug("pipeline config is empty, skipping")
 		return nil, nil
 	}
 
+	reusable := reusableResults(previous)
+	if config.IsDAG() {
+		return e.executeDAG(ctx, config, initialFiles, reusable, onProgress, onResult)
+	}
+
 	files := initialFiles
 	results := make([]StageResult, 0, len(config.Stages))
 	stageIndex := 0
+	reuse := linearReusePrefix(config.Stages, reusable)
 
 	for i, stageCfg := range config.Stages {
 		// 检查上下文是否已取消
@@ -72,74 +94,177 @@ func (e *Executor) Execute(
 			continue
 		}
 
-		// 记录开始
-		if onProgress != nil {
-			onProgress(stageIndex, stageCfg.Name, StageStatusRunning)
+		if stageIndex < reuse {
+			prev := reusable[stageIndex]
+			e.logger.WithField("stage_name", stageCfg.Name).Debug("stage already completed, resuming after it")
+			results = append(results, prev)
+			files = prev.OutputFiles
+			stageIndex++
+			continue
 		}
 
-		var output []FileInfo
-		var err error
-		var commands []string
-		var logs string
+		e.logger.WithFields(logrus.Fields{
+			"stage_index": i,
+			"stage_name":  stageCfg.Name,
+			"input_count": len(files),
+		}).Debug("executing stage")
 
-		// 并行阶段处理
-		if stageCfg.IsParallel() {
-			e.logger.WithField("stage_index", i).Debug("executing parallel stages")
-			output, commands, logs, err = e.executeParallel(ctx, stageCfg.Parallel, files)
-		} else {
-			e.logger.WithFields(logrus.Fields{
-				"stage_index": i,
-				"stage_name":  stageCfg.Name,
-				"input_count": len(files),
-			}).Debug("executing stage")
-			output, commands, logs, err = e.executeStage(ctx, stageCfg, files)
+		result, err := e.runStage(ctx, stageCfg, stageIndex, files, onProgress)
+		results = append(results, result)
+		if onResult != nil {
+			onResult(result)
 		}
-
-		// 记录结果
-		result := StageResult{
-			StageName:  stageCfg.Name,
-			StageIndex: stageIndex,
-			InputFiles: files,
-			Commands:   commands,
-			Logs:       logs,
+		if err != nil {
+			return results, err
 		}
-		result.StartedAt = getTimeNow()
 
-		if err != nil {
-			result.Status = StageStatusFailed
-			result.ErrorMessage = err.Error()
-			now := getTimeNow()
-			result.CompletedAt = &now
-			results = append(results, result)
+		// 更新文件列表给下一阶段
+		files = result.OutputFiles
+		stageIndex++
+	}
 
-			if onProgress != nil {
-				onProgress(stageIndex, stageCfg.Name, StageStatusFailed)
-			}
+	return results, nil
+}
+
+// reusableResults 按阶段序号索引上次执行中已完成或已跳过的阶段结果
+func reusableResults(previous []StageResult) map[int]StageResult {
+	reusable := make(map[int]StageResult, len(previous))
+	for _, result := range previous {
+		if result.Status == StageStatusCompleted || result.Status == StageStatusSkipped {
+			reusable[result.StageIndex] = result
+		}
+	}
+	return reusable
+}
 
-			return results, fmt.Errorf("stage %s failed: %w", stageCfg.Name, err)
+// linearReusePrefix 线性管道中从头开始可以直接沿用上次结果的阶段数
+// 沿用的最后一个阶段的输出是下一阶段的输入，其中有文件已不存在时（例如被手动删除），
+// 退回到更早的阶段重新执行
+func linearReusePrefix(stages []StageConfig, reusable map[int]StageResult) int {
+	n := 0
+	for _, stage := range stages {
+		if !stage.IsEnabled() {
+			continue
+		}
+		prev, ok := reusable[n]
+		if !ok || prev.StageName != stage.Name {
+			break
 		}
+		n++
+	}
+	for n > 0 && !outputsExist(reusable[n-1]) {
+		n--
+	}
+	return n
+}
 
-		result.Status = StageStatusCompleted
-		result.OutputFiles = output
+// outputsExist 阶段结果的输出文件是否都还存在
+func outputsExist(result StageResult) bool {
+	for _, f := range result.OutputFiles {
+		if _, err := os.Stat(f.Path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// runStage 执行一个已启用的阶段（单个或并行组）并生成阶段结果
+// 执行条件不满足时记录为跳过，输入文件原样作为输出
+func (e *Executor) runStage(
+	ctx *PipelineContext,
+	stageCfg StageConfig,
+	stageIndex int,
+	files []FileInfo,
+	onProgress func(stageIndex int, stageName string, status StageStatus),
+) (StageResult, error) {
+	result := StageResult{
+		StageName:  stageCfg.Name,
+		StageIndex: stageIndex,
+		InputFiles: files,
+		StartedAt:  getTimeNow(),
+	}
+	report := func(status StageStatus) {
+		if onProgress != nil {
+			onProgress(stageIndex, stageCfg.Name, status)
+		}
+	}
+	fail := func(err error) (StageResult, error) {
+		result.Status = StageStatusFailed
+		result.ErrorMessage = err.Error()
 		now := getTimeNow()
 		result.CompletedAt = &now
-		results = append(results, result)
+		report(StageStatusFailed)
+		return result, fmt.Errorf("stage %s failed: %w", stageCfg.Name, err)
+	}
 
-		if onProgress != nil {
-			onProgress(stageIndex, stageCfg.Name, StageStatusCompleted)
+	// 检查执行条件，条件不满足时跳过，文件原样传给下一阶段
+	if stageCfg.When != "" {
+		ok, reason, err := evaluateCondition(stageCfg.When, ctx.RecordInfo, files)
+		if err != nil {
+			return fail(err)
+		}
+		if !ok {
+			result.Status = StageStatusSkipped
+			result.OutputFiles = files
+			result.Logs = reason
+			now := getTimeNow()
+			result.CompletedAt = &now
+			report(StageStatusSkipped)
+			e.logger.WithFields(logrus.Fields{
+				"stage_name": stageCfg.Name,
+				"reason":     reason,
+			}).Debug("stage condition not met, skipping")
+			return result, nil
 		}
+	}
 
-		// 更新文件列表给下一阶段
-		files = output
-		stageIndex++
+	// 并行组中执行条件不满足的分支记录为跳过，只执行其余分支
+	if stageCfg.IsParallel() {
+		var err error
+		stageCfg, result.SubResults, err = e.filterParallel(ctx, stageCfg, stageIndex, files)
+		if err != nil {
+			return fail(err)
+		}
+		if len(stageCfg.Parallel) == 0 && len(result.SubResults) > 0 {
+			result.Status = StageStatusSkipped
+			result.OutputFiles = files
+			now := getTimeNow()
+			result.CompletedAt = &now
+			report(StageStatusSkipped)
+			return result, nil
+		}
+	}
 
-		e.logger.WithFields(logrus.Fields{
-			"stage_name":   stageCfg.Name,
-			"output_count": len(output),
-		}).Debug("stage completed")
+	policy, err := parseRetryPolicy(stageCfg)
+	if err != nil {
+		return fail(err)
 	}
 
-	return results, nil
+	// 记录开始
+	report(StageStatusRunning)
+
+	var output []FileInfo
+	output, result.Commands, result.Logs, result.Attempts, err = e.executeWithRetry(
+		ctx, stageCfg, files, policy,
+		func() { report(StageStatusRunning) },
+	)
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Status = StageStatusCompleted
+	result.OutputFiles = output
+	now := getTimeNow()
+	result.CompletedAt = &now
+	report(StageStatusCompleted)
+
+	ensureManifests(ctx, files, output)
+
+	e.logger.WithFields(logrus.Fields{
+		"stage_name":   stageCfg.Name,
+		"output_count": len(output),
+	}).Debug("stage completed")
+	return result, nil
 }
 
 // executeStage 执行单个阶段
@@ -175,6 +300,49 @@ func (e *Executor) executeStage(
 	return output, commands, logs, nil
 }
 
+// filterParallel 对并行组的各分支求值执行条件
+// 返回只包含启用且满足条件的分支的并行组，以及不满足条件的分支的跳过结果
+func (e *Executor) filterParallel(
+	ctx *PipelineContext,
+	stageCfg StageConfig,
+	stageIndex int,
+	files []FileInfo,
+) (StageConfig, []StageResult, error) {
+	var run []StageConfig
+	var skipped []StageResult
+	for _, sub := range stageCfg.Parallel {
+		if !sub.IsEnabled() {
+			continue
+		}
+		if sub.When != "" {
+			ok, reason, err := evaluateCondition(sub.When, ctx.RecordInfo, files)
+			if err != nil {
+				return stageCfg, nil, fmt.Errorf("parallel stage %s: %w", sub.Name, err)
+			}
+			if !ok {
+				now := getTimeNow()
+				skipped = append(skipped, StageResult{
+					StageName:   sub.Name,
+					StageIndex:  stageIndex,
+					Status:      StageStatusSkipped,
+					InputFiles:  files,
+					StartedAt:   now,
+					CompletedAt: &now,
+					Logs:        reason,
+				})
+				e.logger.WithFields(logrus.Fields{
+					"stage_name": sub.Name,
+					"reason":     reason,
+				}).Debug("parallel stage condition not met, skipping")
+				continue
+			}
+		}
+		run = append(run, sub)
+	}
+	stageCfg.Parallel = run
+	return stageCfg, skipped, nil
+}
+
 // executeParallel 并行执行多个阶段
 func (e *Executor) executeParallel(
 	ctx *PipelineContext,
@@ -205,8 +373,14 @@ func (e *Executor) executeParallel(
 		bilisentry.Go(func() {
 			defer wg.Done()
 
-			// 每个并行分支使用相同的输入
-			out, cmds, lg, err := e.executeStage(ctx, stageCfg, input)
+			// 每个并行分支使用相同的输入，按各自的设置重试
+			var out []FileInfo
+			var cmds []string
+			var lg string
+			policy, err := parseRetryPolicy(stageCfg)
+			if err == nil {
+				out, cmds, lg, _, err = e.executeWithRetry(ctx, stageCfg, input, policy, nil)
+			}
 			results <- parallelResult{
 				index:    i,
 				output:   out,
@@ -303,6 +477,14 @@ func (e *Executor) ValidateConfig(config *PipelineConfig) error {
 	}
 
 	for i, stage := range config.Stages {
+		if _, err := parseRetryPolicy(stage); err != nil {
+			return fmt.Errorf("invalid stage[%d]: %w", i, err)
+		}
+		if stage.When != "" {
+			if _, err := parseCondition(stage.When); err != nil {
+				return fmt.Errorf("invalid stage[%d]: %w", i, err)
+			}
+		}
 		if stage.IsParallel() {
 			// 验证并行阶段
 			for j, ps := range stage.Parallel {
@@ -312,6 +494,17 @@ func (e *Executor) ValidateConfig(config *PipelineConfig) error {
 				if _, ok := e.getFactory(ps.Name); !ok {
 					return fmt.Errorf("unknown parallel stage[%d][%d]: %s", i, j, ps.Name)
 				}
+				if ps.ID != "" || len(ps.DependsOn) > 0 {
+					return fmt.Errorf("parallel stage[%d][%d] cannot declare id or depends_on", i, j)
+				}
+				if _, err := parseRetryPolicy(ps); err != nil {
+					return fmt.Errorf("invalid parallel stage[%d][%d]: %w", i, j, err)
+				}
+				if ps.When != "" {
+					if _, err := parseCondition(ps.When); err != nil {
+						return fmt.Errorf("invalid parallel stage[%d][%d]: %w", i, j, err)
+					}
+				}
 			}
 		} else {
 			if stage.Name == "" {
@@ -323,5 +516,10 @@ func (e *Executor) ValidateConfig(config *PipelineConfig) error {
 		}
 	}
 
+	// 检查依赖引用和依赖环
+	if _, err := buildStageGraph(config.Stages); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
+)
+
+// fakeStage 测试用阶段，执行逻辑由 run 决定
+type fakeStage struct {
+	name string
+	run  func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error)
+}
+
+func (s *fakeStage) Name() string { return s.name }
+
+func (s *fakeStage) Execute(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+	return s.run(ctx, input)
+}
+
+// newTestExecutor 创建注册了测试阶段的执行器
+func newTestExecutor(stages map[string]func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error)) *Executor {
+	e := NewExecutor(logrus.New())
+	for name, run := range stages {
+		e.RegisterStage(name, func(cfg StageConfig) (Stage, error) {
+			return &fakeStage{name: cfg.Name, run: run}, nil
+		})
+	}
+	return e
+}
+
+// suffixStage 为每个输入文件生成一个加了后缀的新文件
+func suffixStage(suffix string) func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+	return func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+		output := make([]FileInfo, len(input))
+		for i, f := range input {
+			output[i] = NewVideoFileInfo(f.Path + suffix)
+			if err := os.WriteFile(output[i].Path, nil, 0o644); err != nil {
+				return nil, err
+			}
+		}
+		return output, nil
+	}
+}
+
+func newTestPipelineContext() *PipelineContext {
+	return &PipelineContext{
+		Ctx:    context.Background(),
+		Logger: livelogger.New(livelogger.DefaultBufferSize, logrus.Fields{}),
+	}
+}
+
+func testPaths(files []FileInfo) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = filepath.Base(f.Path)
+	}
+	return paths
+}
+
+func TestExecuteParallelRecordsSkippedBranches(t *testing.T) {
+	e := newTestExecutor(map[string]func(*PipelineContext, []FileInfo) ([]FileInfo, error){
+		"a": suffixStage(".a"),
+		"b": suffixStage(".b"),
+	})
+	video := filepath.Join(t.TempDir(), "rec.flv")
+	require.NoError(t, os.WriteFile(video, []byte("flv"), 0o644))
+	input := []FileInfo{NewVideoFileInfo(video)}
+
+	config := &PipelineConfig{Stages: []StageConfig{{
+		Name: "group",
+		Parallel: []StageConfig{
+			{Name: "a", When: `eq .Ext "mp4"`},
+			{Name: "b"},
+		},
+	}}}
+	results, err := e.Execute(newTestPipelineContext(), config, input, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	group := results[0]
+	assert.Equal(t, StageStatusCompleted, group.Status)
+	assert.Equal(t, []string{"rec.flv.b"}, testPaths(group.OutputFiles))
+	require.Len(t, group.SubResults, 1)
+	skipped := group.SubResults[0]
+	assert.Equal(t, "a", skipped.StageName)
+	assert.Equal(t, StageStatusSkipped, skipped.Status)
+	assert.True(t, strings.HasPrefix(skipped.Logs, `when: eq .Ext "mp4" => false`), skipped.Logs)
+	assert.NotNil(t, skipped.CompletedAt)
+
+	// 所有分支都不满足条件时整个并行组记为跳过，文件原样传给下一阶段
+	config.Stages[0].Parallel[1].When = `gt .Size (gb 1)`
+	results, err = e.Execute(newTestPipelineContext(), config, input, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, StageStatusSkipped, results[0].Status)
+	assert.Equal(t, []string{"rec.flv"}, testPaths(results[0].OutputFiles))
+	assert.Len(t, results[0].SubResults, 2)
+}
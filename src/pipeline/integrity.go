@@ -0,0 +1,31 @@
+package pipeline
+
+import (
+	"errors"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+)
+
+// ensureManifests 为阶段新产出的视频文件（如转换得到的 mp4）生成完整性附属文件，
+// 后续的上传和删除阶段可以按清单校验
+func ensureManifests(ctx *PipelineContext, input, output []FileInfo) {
+	if cfg := configs.GetCurrentConfig(); cfg == nil || !cfg.IntegritySidecar {
+		return
+	}
+	inputPaths := make(map[string]bool, len(input))
+	for _, f := range input {
+		inputPaths[f.Path] = true
+	}
+	for _, f := range output {
+		if f.Type != FileTypeVideo || inputPaths[f.Path] {
+			continue
+		}
+		if _, err := integrity.Load(f.Path); !errors.Is(err, integrity.ErrNoManifest) {
+			continue
+		}
+		if _, err := integrity.Create(ctx.Ctx, f.Path); err != nil && ctx.Logger != nil {
+			ctx.Logger.WithError(err).Warnf("生成完整性附属文件失败: %s", f.Path)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/pkg/events"
 	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
 	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+	"github.com/bililive-go/bililive-go/src/types"
 	"github.com/sirupsen/logrus"
 )
 
@@ -27,8 +28,17 @@ type Manager struct {
 	wg            sync.WaitGroup
 	eventDispatch events.Dispatcher
 	ticker        *time.Ticker
+
+	// sessions 等待关播的会话合并任务，按直播间索引
+	sessions  map[types.LiveID]*sessionBatch
+	sessionMu sync.Mutex
+
+	sessionResolver SessionResolver
 }
 
+// SessionResolver 根据直播间ID和时间点查找对应的开播会话ID，找不到时返回 0
+type SessionResolver func(liveID string, at time.Time) int64
+
 // ManagerConfig 管理器配置
 type ManagerConfig struct {
 	MaxConcurrent int           `yaml:"max_concurrent" json:"max_concurrent"` // 最大并发数
@@ -65,6 +75,7 @@ func NewManager(ctx context.Context, store Store, config *ManagerConfig, dispatc
 		config:        config,
 		runningTasks:  make(map[int64]context.CancelFunc),
 		eventDispatch: dispatcher,
+		sessions:      make(map[types.LiveID]*sessionBatch),
 	}
 
 	return m
@@ -75,12 +86,31 @@ func (m *Manager) RegisterStage(name string, factory StageFactory) {
 	m.executor.RegisterStage(name, factory)
 }
 
+// SetSessionResolver 设置开播会话查找函数（由 livestate 提供，避免循环导入）
+func (m *Manager) SetSessionResolver(resolver SessionResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionResolver = resolver
+}
+
+// resolveSession 查找录制所属的开播会话
+func (m *Manager) resolveSession(info RecordInfo) int64 {
+	m.mu.RLock()
+	resolver := m.sessionResolver
+	m.mu.RUnlock()
+	if resolver == nil || info.LiveID == "" {
+		return 0
+	}
+	return resolver(string(info.LiveID), info.StartTime)
+}
+
 // Start 启动管理器（实现 Module 接口）
 func (m *Manager) Start(ctx context.Context) error {
 	// 重置所有运行中的任务（处理程序非正常退出的情况）
 	if err := m.store.ResetRunningTasks(m.ctx); err != nil {
 		logrus.WithError(err).Warn("failed to reset running pipeline tasks")
 	}
+	m.releaseWaitingTasks()
 
 	// 启动轮询调度
 	m.ticker = time.NewTicker(m.config.PollInterval)
@@ -198,22 +228,56 @@ func (m *Manager) executeTask(ctx context.Context, task *PipelineTask) {
 			"host":     task.RecordInfo.HostName,
 			"room":     task.RecordInfo.RoomName,
 		}),
-		WorkDir: "", // 后续可以从配置获取
+		WorkDir:   "", // 后续可以从配置获取
+		SessionID: m.resolveSession(task.RecordInfo),
 	}
 
-	// 执行管道
-	results, err := m.executor.Execute(
+	// 阶段内进度可能从阶段的后台 goroutine 上报，与阶段切换共用同一把锁
+	var progressMu sync.Mutex
+	saveProgress := func() {
+		if err := m.store.UpdateTask(ctx, task); err != nil {
+			logrus.WithError(err).Warn("failed to update pipeline task progress")
+		}
+		m.broadcastTaskUpdate(task)
+	}
+	pipelineCtx.OnProgress = func(percent float64) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		last := task.Progress
+		task.UpdateStageProgress(percent)
+		// 只在整数百分比变化时写入，避免频繁更新存储
+		if task.Progress != last {
+			saveProgress()
+		}
+	}
+
+	// 执行管道，已完成的阶段沿用上次的结果
+	if len(task.StageResults) > 0 {
+		logrus.WithField("task_id", task.ID).Info("resuming pipeline task from first incomplete stage")
+		task.prepareResume()
+	}
+	results, err := m.executor.Resume(
 		pipelineCtx,
 		task.PipelineConfig,
-		task.CurrentFiles,
+		task.InitialFiles,
+		task.StageResults,
 		func(stageIndex int, stageName string, status StageStatus) {
+			progressMu.Lock()
+			defer progressMu.Unlock()
 			// 更新任务进度
 			task.CurrentStage = stageIndex
 			task.UpdateProgress()
-			if err := m.store.UpdateTask(ctx, task); err != nil {
-				logrus.WithError(err).Warn("failed to update pipeline task progress")
+			saveProgress()
+		},
+		func(result StageResult) {
+			progressMu.Lock()
+			defer progressMu.Unlock()
+			// 每个阶段结束后立即持久化，重启或重试时从第一个未完成的阶段继续
+			task.SetStageResult(result)
+			if result.Status == StageStatusCompleted && !task.PipelineConfig.IsDAG() {
+				task.CurrentFiles = result.OutputFiles
 			}
-			m.broadcastTaskUpdate(task)
+			saveProgress()
 		},
 	)
 
@@ -232,8 +296,7 @@ func (m *Manager) executeTask(ctx context.Context, task *PipelineTask) {
 		task.MarkCompleted()
 		// 更新最终文件列表
 		if len(results) > 0 {
-			lastResult := results[len(results)-1]
-			task.CurrentFiles = lastResult.OutputFiles
+			task.CurrentFiles = FinalOutputFiles(results)
 		}
 		logrus.WithField("task_id", task.ID).Info("pipeline task completed successfully")
 	}
@@ -276,11 +339,12 @@ func (m *Manager) EnqueueTask(task *PipelineTask) error {
 }
 
 // EnqueueRecordingTask 创建并入队录制完成后的处理任务
-// 这是 recorder.go 调用的主要入口
+// 这是 recorder.go 调用的主要入口，startTime 是这段录制的开始时间，为零值时使用当前时间
 func (m *Manager) EnqueueRecordingTask(
 	info *live.Info,
 	pipelineConfig *PipelineConfig,
 	outputFiles []string,
+	startTime time.Time,
 ) error {
 	// 构建文件信息列表
 	files := make([]FileInfo, len(outputFiles))
@@ -289,7 +353,11 @@ func (m *Manager) EnqueueRecordingTask(
 	}
 
 	// 创建任务
-	task := NewPipelineTask(NewRecordInfo(info), pipelineConfig, files)
+	recordInfo := NewRecordInfo(info)
+	if !startTime.IsZero() {
+		recordInfo.StartTime = startTime
+	}
+	task := NewPipelineTask(recordInfo, pipelineConfig, files)
 
 	return m.EnqueueTask(task)
 }
@@ -309,8 +377,8 @@ func (m *Manager) CancelTask(taskID int64) error {
 		// 取消正在运行的任务
 		cancel()
 		// 状态更新会在 executeTask 中完成
-	} else if task.Status == PipelineStatusPending {
-		// 取消待执行的任务
+	} else if task.Status == PipelineStatusPending || task.Status == PipelineStatusWaiting {
+		// 取消待执行或等待关播的任务
 		task.MarkCancelled()
 		if err := m.store.UpdateTask(m.ctx, task); err != nil {
 			return err
@@ -322,7 +390,8 @@ func (m *Manager) CancelTask(taskID int64) error {
 }
 
 // RetryTask 重试失败的任务
-func (m *Manager) RetryTask(taskID int64) error {
+// 默认从第一个未完成的阶段继续，已完成阶段的输出直接沿用；fromStart 为 true 时从头执行所有阶段
+func (m *Manager) RetryTask(taskID int64, fromStart bool) error {
 	task, err := m.store.GetTask(m.ctx, taskID)
 	if err != nil {
 		return err
@@ -341,9 +410,14 @@ func (m *Manager) RetryTask(taskID int64) error {
 	task.StartedAt = nil
 	task.CompletedAt = nil
 	task.ErrorMessage = ""
-	task.CurrentStage = 0
-	task.StageResults = nil
-	task.Progress = 0
+	if fromStart {
+		task.CurrentStage = 0
+		task.StageResults = nil
+		task.CurrentFiles = task.InitialFiles
+		task.Progress = 0
+	} else {
+		task.prepareResume()
+	}
 
 	if err := m.store.UpdateTask(m.ctx, task); err != nil {
 		return err
@@ -398,6 +472,7 @@ func (m *Manager) GetStats() (*ManagerStats, error) {
 
 	// 获取各状态的任务数
 	for _, status := range []PipelineStatus{
+		PipelineStatusWaiting,
 		PipelineStatusPending,
 		PipelineStatusCompleted,
 		PipelineStatusFailed,
@@ -408,6 +483,8 @@ func (m *Manager) GetStats() (*ManagerStats, error) {
 			return nil, err
 		}
 		switch status {
+		case PipelineStatusWaiting:
+			stats.WaitingCount = len(tasks)
 		case PipelineStatusPending:
 			stats.PendingCount = len(tasks)
 		case PipelineStatusCompleted:
@@ -426,6 +503,7 @@ func (m *Manager) GetStats() (*ManagerStats, error) {
 type ManagerStats struct {
 	MaxConcurrent  int `json:"max_concurrent"`
 	RunningCount   int `json:"running_count"`
+	WaitingCount   int `json:"waiting_count"`
 	PendingCount   int `json:"pending_count"`
 	CompletedCount int `json:"completed_count"`
 	FailedCount    int `json:"failed_count"`
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newResumeTestManager 创建注册了 a、b 两个测试阶段的管理器和一个在 a 完成后中断的任务
+func newResumeTestManager(t *testing.T, status PipelineStatus) (*Manager, *MemoryStore, *dagTestStages, *PipelineTask) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store := NewMemoryStore()
+	m := NewManager(ctx, store, &ManagerConfig{MaxConcurrent: 1, PollInterval: 10 * time.Millisecond}, nil)
+	s := &dagTestStages{}
+	e := s.executor("a", "b")
+	for _, name := range []string{"a", "b"} {
+		factory, _ := e.getFactory(name)
+		m.RegisterStage(name, factory)
+	}
+
+	// 第一阶段已完成，任务在第二阶段中断
+	input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+	first, err := suffixStage(".a")(newTestPipelineContext(), input)
+	require.NoError(t, err)
+	task := NewPipelineTask(RecordInfo{}, &PipelineConfig{Stages: []StageConfig{{Name: "a"}, {Name: "b"}}}, input)
+	task.Status = status
+	task.CurrentStage = 1
+	task.CurrentFiles = first
+	task.Progress = 50
+	task.StageResults = []StageResult{
+		{StageIndex: 0, StageName: "a", Status: StageStatusCompleted, InputFiles: input, OutputFiles: first},
+		{StageIndex: 1, StageName: "b", Status: StageStatusFailed, InputFiles: first},
+	}
+	require.NoError(t, store.CreateTask(ctx, task))
+	return m, store, s, task
+}
+
+func waitTaskStatus(t *testing.T, store *MemoryStore, id int64, status PipelineStatus) *PipelineTask {
+	t.Helper()
+	var task *PipelineTask
+	require.Eventually(t, func() bool {
+		var err error
+		task, err = store.GetTask(context.Background(), id)
+		return err == nil && task.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return task
+}
+
+func TestManagerResumesRunningTasksAfterRestart(t *testing.T) {
+	m, store, s, task := newResumeTestManager(t, PipelineStatusRunning)
+	require.NoError(t, m.Start(context.Background()))
+	defer m.Close(context.Background())
+
+	done := waitTaskStatus(t, store, task.ID, PipelineStatusCompleted)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.NotContains(t, s.inputs, "a")
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["b"])
+	assert.Equal(t, 100, done.Progress)
+	assert.Equal(t, []string{"rec.flv.a.b"}, testPaths(done.CurrentFiles))
+}
+
+func TestManagerRetryTaskResumesFromFailedStage(t *testing.T) {
+	m, store, s, task := newResumeTestManager(t, PipelineStatusFailed)
+	defer m.Close(context.Background())
+	require.NoError(t, m.RetryTask(task.ID, false))
+
+	done := waitTaskStatus(t, store, task.ID, PipelineStatusCompleted)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.NotContains(t, s.inputs, "a")
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["b"])
+	require.Len(t, done.StageResults, 2)
+	assert.Equal(t, StageStatusCompleted, done.StageResults[1].Status)
+}
+
+func TestManagerRetryTaskFromStart(t *testing.T) {
+	m, store, s, task := newResumeTestManager(t, PipelineStatusFailed)
+	defer m.Close(context.Background())
+	require.NoError(t, m.RetryTask(task.ID, true))
+
+	waitTaskStatus(t, store, task.ID, PipelineStatusCompleted)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, []string{"rec.flv"}, s.inputs["a"])
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["b"])
+}
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultRetryBackoff 未配置 retry_backoff 时首次重试前的等待时间
+	defaultRetryBackoff = 10 * time.Second
+	// maxRetryBackoff 重试等待时间的上限
+	maxRetryBackoff = 10 * time.Minute
+)
+
+// stageRetryPolicy 阶段的自动重试与超时设置
+type stageRetryPolicy struct {
+	retries int
+	backoff time.Duration
+	timeout time.Duration
+}
+
+// parseRetryPolicy 解析阶段配置中的重试与超时设置
+func parseRetryPolicy(cfg StageConfig) (stageRetryPolicy, error) {
+	policy := stageRetryPolicy{retries: cfg.Retries, backoff: defaultRetryBackoff}
+	if cfg.Retries < 0 {
+		return policy, fmt.Errorf("retries must not be negative")
+	}
+	if cfg.RetryBackoff != "" {
+		d, err := time.ParseDuration(cfg.RetryBackoff)
+		if err != nil || d < 0 {
+			return policy, fmt.Errorf("invalid retry_backoff %q", cfg.RetryBackoff)
+		}
+		policy.backoff = d
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+		}
+		policy.timeout = d
+	}
+	return policy, nil
+}
+
+// delay 第 attempt 次执行失败后的等待时间，按指数退避
+func (p stageRetryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
+
+// executeWithRetry 执行阶段，失败时按策略自动重试
+// 任务被取消时不再重试；返回的命令和日志包含所有尝试
+func (e *Executor) executeWithRetry(
+	ctx *PipelineContext,
+	stageCfg StageConfig,
+	input []FileInfo,
+	policy stageRetryPolicy,
+	onRetry func(),
+) (output []FileInfo, commands []string, logs string, attempts int, err error) {
+	for attempts = 1; ; attempts++ {
+		out, cmds, lg, execErr := e.executeAttempt(ctx, stageCfg, input, policy.timeout)
+		commands = append(commands, cmds...)
+		if lg != "" {
+			if logs != "" {
+				logs += "\n---\n"
+			}
+			logs += lg
+		}
+		if execErr == nil {
+			return out, commands, logs, attempts, nil
+		}
+		if attempts > policy.retries || ctx.Ctx.Err() != nil {
+			return nil, commands, logs, attempts, execErr
+		}
+
+		wait := policy.delay(attempts)
+		if logs != "" {
+			logs += "\n"
+		}
+		logs += fmt.Sprintf("第 %d 次执行失败: %s，%s 后重试", attempts, execErr, wait)
+		e.logger.WithError(execErr).WithField("stage_name", stageCfg.Name).
+			Warnf("stage failed, retrying in %s (%d/%d)", wait, attempts, policy.retries)
+
+		select {
+		case <-ctx.Ctx.Done():
+			return nil, commands, logs, attempts, ctx.Ctx.Err()
+		case <-time.After(wait):
+		}
+		if onRetry != nil {
+			onRetry()
+		}
+	}
+}
+
+// executeAttempt 执行一次阶段，设置了超时时使用独立的超时上下文
+func (e *Executor) executeAttempt(
+	ctx *PipelineContext,
+	stageCfg StageConfig,
+	input []FileInfo,
+	timeout time.Duration,
+) ([]FileInfo, []string, string, error) {
+	run := func(c *PipelineContext) ([]FileInfo, []string, string, error) {
+		if stageCfg.IsParallel() {
+			return e.executeParallel(c, stageCfg.Parallel, input)
+		}
+		return e.executeStage(c, stageCfg, input)
+	}
+	if timeout <= 0 {
+		return run(ctx)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx.Ctx, timeout)
+	defer cancel()
+	attemptCtx := *ctx
+	attemptCtx.Ctx = timeoutCtx
+	output, commands, logs, err := run(&attemptCtx)
+	if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && ctx.Ctx.Err() == nil {
+		err = fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return output, commands, logs, err
+}
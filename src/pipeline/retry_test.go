@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStage 前 failures 次执行失败，之后按 suffixStage 输出
+func failingStage(failures int32, calls *atomic.Int32) func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+	next := suffixStage(".out")
+	return func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+		if calls.Add(1) <= failures {
+			return nil, errors.New("boom")
+		}
+		return next(ctx, input)
+	}
+}
+
+func TestParseRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     StageConfig
+		want    stageRetryPolicy
+		wantErr bool
+	}{
+		{name: "默认", cfg: StageConfig{}, want: stageRetryPolicy{backoff: defaultRetryBackoff}},
+		{
+			name: "完整设置",
+			cfg:  StageConfig{Retries: 2, RetryBackoff: "30s", Timeout: "1h"},
+			want: stageRetryPolicy{retries: 2, backoff: 30 * time.Second, timeout: time.Hour},
+		},
+		{name: "不等待重试", cfg: StageConfig{Retries: 1, RetryBackoff: "0s"}, want: stageRetryPolicy{retries: 1}},
+		{name: "负数重试次数", cfg: StageConfig{Retries: -1}, wantErr: true},
+		{name: "无效退避时间", cfg: StageConfig{RetryBackoff: "soon"}, wantErr: true},
+		{name: "负数退避时间", cfg: StageConfig{RetryBackoff: "-1s"}, wantErr: true},
+		{name: "无效超时", cfg: StageConfig{Timeout: "1 hour"}, wantErr: true},
+		{name: "零超时", cfg: StageConfig{Timeout: "0s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := parseRetryPolicy(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, policy)
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := stageRetryPolicy{backoff: 10 * time.Second}
+	assert.Equal(t, 10*time.Second, policy.delay(1))
+	assert.Equal(t, 20*time.Second, policy.delay(2))
+	assert.Equal(t, 40*time.Second, policy.delay(3))
+	// 指数退避不超过上限
+	assert.Equal(t, maxRetryBackoff, policy.delay(10))
+	assert.Equal(t, maxRetryBackoff, policy.delay(100))
+}
+
+func TestExecuteRetriesFailedStage(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "首次成功", failures: 0, retries: 2, wantAttempts: 1},
+		{name: "重试后成功", failures: 2, retries: 2, wantAttempts: 3},
+		{name: "重试次数用尽", failures: 5, retries: 2, wantErr: true, wantAttempts: 3},
+		{name: "不重试", failures: 1, retries: 0, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			e := newTestExecutor(map[string]func(*PipelineContext, []FileInfo) ([]FileInfo, error){
+				"flaky": failingStage(tt.failures, &calls),
+			})
+			config := &PipelineConfig{Stages: []StageConfig{
+				{Name: "flaky", Retries: tt.retries, RetryBackoff: "1ms"},
+			}}
+			input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+
+			results, err := e.Execute(newTestPipelineContext(), config, input, nil)
+			require.Len(t, results, 1)
+			assert.Equal(t, tt.wantAttempts, results[0].Attempts)
+			assert.Equal(t, int32(tt.wantAttempts), calls.Load())
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, StageStatusFailed, results[0].Status)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, StageStatusCompleted, results[0].Status)
+				assert.Equal(t, []string{"rec.flv.out"}, testPaths(results[0].OutputFiles))
+			}
+			if tt.wantAttempts > 1 {
+				assert.Contains(t, results[0].Logs, "第 1 次执行失败: boom")
+			}
+		})
+	}
+}
+
+func TestExecuteStageTimeout(t *testing.T) {
+	var calls atomic.Int32
+	e := newTestExecutor(map[string]func(*PipelineContext, []FileInfo) ([]FileInfo, error){
+		"slow": func(ctx *PipelineContext, input []FileInfo) ([]FileInfo, error) {
+			calls.Add(1)
+			<-ctx.Ctx.Done()
+			return nil, ctx.Ctx.Err()
+		},
+	})
+	config := &PipelineConfig{Stages: []StageConfig{
+		{Name: "slow", Timeout: "20ms", Retries: 1, RetryBackoff: "1ms"},
+	}}
+
+	results, err := e.Execute(newTestPipelineContext(), config, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 20ms")
+	// 超时按失败处理，同样会自动重试
+	require.Len(t, results, 1)
+	assert.Equal(t, StageStatusFailed, results[0].Status)
+	assert.Equal(t, 2, results[0].Attempts)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestReusableResults(t *testing.T) {
+	reusable := reusableResults([]StageResult{
+		{StageIndex: 0, StageName: "a", Status: StageStatusCompleted},
+		{StageIndex: 1, StageName: "b", Status: StageStatusSkipped},
+		{StageIndex: 2, StageName: "c", Status: StageStatusFailed},
+		{StageIndex: 3, StageName: "d", Status: StageStatusRunning},
+	})
+	assert.Len(t, reusable, 2)
+	assert.Equal(t, "a", reusable[0].StageName)
+	assert.Equal(t, "b", reusable[1].StageName)
+}
+
+func TestResumeLinearPipeline(t *testing.T) {
+	s := &dagTestStages{fail: map[string]bool{"c": true}}
+	e := s.executor("a", "b", "c")
+	config := &PipelineConfig{Stages: []StageConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}}}
+	input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+
+	failed, err := e.Execute(newTestPipelineContext(), config, input, nil)
+	require.Error(t, err)
+	require.Len(t, failed, 3)
+
+	resume := func() []StageResult {
+		t.Helper()
+		s.mu.Lock()
+		s.fail = nil
+		s.inputs = make(map[string][]string)
+		s.mu.Unlock()
+		results, err := e.Resume(newTestPipelineContext(), config, input, failed, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		for _, r := range results {
+			assert.Equal(t, StageStatusCompleted, r.Status, r.StageName)
+		}
+		return results
+	}
+
+	t.Run("沿用已完成的阶段", func(t *testing.T) {
+		resume()
+		assert.NotContains(t, s.inputs, "a")
+		assert.NotContains(t, s.inputs, "b")
+		assert.Equal(t, []string{"rec.flv.a.b"}, s.inputs["c"])
+	})
+
+	t.Run("输出文件缺失时重新执行", func(t *testing.T) {
+		require.NoError(t, removeFiles(failed[1].OutputFiles))
+		resume()
+		assert.NotContains(t, s.inputs, "a")
+		assert.Equal(t, []string{"rec.flv.a"}, s.inputs["b"])
+		assert.Equal(t, []string{"rec.flv.a.b"}, s.inputs["c"])
+	})
+
+	t.Run("阶段配置改变后重新执行", func(t *testing.T) {
+		config.Stages[1].Name = "c"
+		defer func() { config.Stages[1].Name = "b" }()
+		resume()
+		assert.NotContains(t, s.inputs, "a")
+		assert.Contains(t, s.inputs, "c")
+	})
+}
+
+func TestResumeDAGRerunsStageWithMissingOutputs(t *testing.T) {
+	s := &dagTestStages{fail: map[string]bool{"d": true}}
+	e := s.executor("a", "b", "c", "d")
+	config := &PipelineConfig{Stages: []StageConfig{
+		{ID: "a", Name: "a"},
+		{ID: "b", Name: "b", DependsOn: []string{"a"}},
+		{ID: "c", Name: "c", DependsOn: []string{"a"}},
+		{ID: "d", Name: "d", DependsOn: []string{"b", "c"}},
+	}}
+	input := []FileInfo{NewVideoFileInfo(filepath.Join(t.TempDir(), "rec.flv"))}
+
+	failed, err := e.Execute(newTestPipelineContext(), config, input, nil)
+	require.Error(t, err)
+	require.Len(t, failed, 4)
+
+	// c 的输出被删除，恢复时重新执行 c；a、b 的输出仍在，直接沿用
+	require.NoError(t, removeFiles(failed[2].OutputFiles))
+	s.mu.Lock()
+	s.fail = nil
+	s.inputs = make(map[string][]string)
+	s.mu.Unlock()
+	results, err := e.Resume(newTestPipelineContext(), config, input, failed, nil, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, s.inputs, "a")
+	assert.NotContains(t, s.inputs, "b")
+	assert.Equal(t, []string{"rec.flv.a"}, s.inputs["c"])
+	assert.Equal(t, []string{"rec.flv.a.b", "rec.flv.a.c"}, s.inputs["d"])
+	require.Len(t, results, 4)
+}
+
+func TestPrepareResume(t *testing.T) {
+	input := []FileInfo{NewVideoFileInfo("/rec.flv")}
+	config := &PipelineConfig{Stages: []StageConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}}}
+	task := NewPipelineTask(RecordInfo{}, config, input)
+	task.CurrentStage = 2
+	task.Progress = 80
+	task.CurrentFiles = nil
+	task.StageResults = []StageResult{
+		{StageIndex: 0, StageName: "a", Status: StageStatusCompleted, OutputFiles: []FileInfo{NewVideoFileInfo("/rec.a")}},
+		{StageIndex: 1, StageName: "b", Status: StageStatusFailed},
+	}
+
+	task.prepareResume()
+	assert.Equal(t, 1, task.CurrentStage)
+	assert.Equal(t, 33, task.Progress)
+	assert.Equal(t, []FileInfo{NewVideoFileInfo("/rec.a")}, task.CurrentFiles)
+
+	task.StageResults = nil
+	task.prepareResume()
+	assert.Equal(t, 0, task.CurrentStage)
+	assert.Equal(t, 0, task.Progress)
+	assert.Equal(t, input, task.CurrentFiles)
+}
+
+func removeFiles(files []FileInfo) error {
+	for _, f := range files {
+		if err := os.Remove(f.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
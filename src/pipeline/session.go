@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/live"
+	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// sessionBatch 一场直播的会话合并任务
+// 任务在收到第一个分段时以 waiting 状态创建，之后的分段追加到同一个任务，
+// 关播且所有分段的文件都已就绪（移出临时目录、生成校验文件）后转为 pending 开始执行
+type sessionBatch struct {
+	taskID  int64
+	nextSeq int64
+	// pending 已结束录制但文件尚未就绪的分段，值为分段开始录制的时间
+	pending map[int64]time.Time
+	// segments 已加入任务的分段，按录制顺序排列
+	// 分段文件就绪的顺序取决于移动和校验耗时，可能与录制顺序不同
+	segments []sessionSegment
+	ended    bool // 是否已关播
+}
+
+// sessionSegment 已加入会话任务的分段
+type sessionSegment struct {
+	seq   int64
+	files []FileInfo
+}
+
+// BeginSessionSegment 登记一个已结束录制的会话分段，必须按录制顺序调用
+// 返回分段序号，分段文件就绪后用它调用 AddSessionSegment，没有可用文件时调用 SkipSessionSegment
+func (m *Manager) BeginSessionSegment(liveID types.LiveID, startTime time.Time) int64 {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	batch := m.sessionLocked(liveID)
+	batch.nextSeq++
+	batch.pending[batch.nextSeq] = startTime
+	return batch.nextSeq
+}
+
+// SkipSessionSegment 登记的分段没有可用文件
+func (m *Manager) SkipSessionSegment(liveID types.LiveID, seq int64) {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	batch := m.sessionLocked(liveID)
+	delete(batch.pending, seq)
+	m.releaseSessionLocked(liveID, batch)
+}
+
+// AddSessionSegment 把分段文件按录制顺序加入本场直播的会话任务
+// 任务的录制信息取自最早的分段
+func (m *Manager) AddSessionSegment(seq int64, info *live.Info, pipelineConfig *PipelineConfig, outputFiles []string) error {
+	liveID := info.Live.GetLiveId()
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	batch := m.sessionLocked(liveID)
+	startTime := batch.pending[seq]
+	delete(batch.pending, seq)
+	defer m.releaseSessionLocked(liveID, batch)
+
+	files := make([]FileInfo, len(outputFiles))
+	for i, path := range outputFiles {
+		files[i] = NewVideoFileInfo(path)
+	}
+	recordInfo := NewRecordInfo(info)
+	if !startTime.IsZero() {
+		recordInfo.StartTime = startTime
+	}
+
+	// 任务被取消或删除后，之后的分段另起一个任务
+	if batch.taskID != 0 {
+		task, err := m.store.GetTask(m.ctx, batch.taskID)
+		if err == nil && task.Status == PipelineStatusWaiting {
+			if batch.insertSegment(seq, files) == 0 {
+				task.RecordInfo = recordInfo
+			}
+			task.InitialFiles = batch.files()
+			task.CurrentFiles = batch.files()
+			if err := m.store.UpdateTask(m.ctx, task); err != nil {
+				return fmt.Errorf("failed to add segment to session task: %w", err)
+			}
+			m.broadcastTaskUpdate(task)
+			return nil
+		}
+	}
+
+	batch.segments = nil
+	batch.insertSegment(seq, files)
+	task := NewPipelineTask(recordInfo, pipelineConfig, batch.files())
+	task.Status = PipelineStatusWaiting
+	if err := m.store.CreateTask(m.ctx, task); err != nil {
+		return fmt.Errorf("failed to create session task: %w", err)
+	}
+	batch.taskID = task.ID
+	logrus.WithFields(logrus.Fields{
+		"task_id": task.ID,
+		"live_id": liveID,
+	}).Info("session pipeline task created, waiting for live end")
+	m.broadcastTaskUpdate(task)
+	return nil
+}
+
+// insertSegment 按序号插入分段，返回插入的位置
+func (b *sessionBatch) insertSegment(seq int64, files []FileInfo) int {
+	i := sort.Search(len(b.segments), func(i int) bool { return b.segments[i].seq > seq })
+	b.segments = slices.Insert(b.segments, i, sessionSegment{seq: seq, files: files})
+	return i
+}
+
+// files 按录制顺序返回所有分段的文件
+func (b *sessionBatch) files() []FileInfo {
+	var files []FileInfo
+	for _, seg := range b.segments {
+		files = append(files, seg.files...)
+	}
+	return files
+}
+
+// EndSession 标记本场直播结束，所有分段就绪后会话任务开始执行
+func (m *Manager) EndSession(liveID types.LiveID) {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	batch, ok := m.sessions[liveID]
+	if !ok {
+		return
+	}
+	batch.ended = true
+	m.releaseSessionLocked(liveID, batch)
+}
+
+func (m *Manager) sessionLocked(liveID types.LiveID) *sessionBatch {
+	batch, ok := m.sessions[liveID]
+	if !ok {
+		batch = &sessionBatch{pending: make(map[int64]time.Time)}
+		m.sessions[liveID] = batch
+	}
+	return batch
+}
+
+// releaseSessionLocked 关播且没有未就绪的分段时，把会话任务转为待执行
+func (m *Manager) releaseSessionLocked(liveID types.LiveID, batch *sessionBatch) {
+	if !batch.ended || len(batch.pending) > 0 {
+		return
+	}
+	delete(m.sessions, liveID)
+	if batch.taskID == 0 {
+		return
+	}
+	task, err := m.store.GetTask(m.ctx, batch.taskID)
+	if err != nil || task.Status != PipelineStatusWaiting {
+		return
+	}
+	m.releaseWaitingTask(task)
+}
+
+// releaseWaitingTask 把等待关播的任务转为待执行并立即尝试调度
+func (m *Manager) releaseWaitingTask(task *PipelineTask) {
+	task.Status = PipelineStatusPending
+	if err := m.store.UpdateTask(m.ctx, task); err != nil {
+		logrus.WithError(err).WithField("task_id", task.ID).Error("failed to release session pipeline task")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"task_id":       task.ID,
+		"initial_files": len(task.InitialFiles),
+	}).Info("session pipeline task released")
+	m.broadcastTaskUpdate(task)
+	bilisentry.Go(func() { m.scheduleNextTasks() })
+}
+
+// releaseWaitingTasks 程序启动时处理上次遗留的等待关播任务
+// 重启后无法确认开播会话是否延续，已有的分段直接开始处理，之后的分段另起一个任务
+func (m *Manager) releaseWaitingTasks() {
+	status := PipelineStatusWaiting
+	tasks, err := m.store.ListTasks(m.ctx, TaskFilter{Status: &status})
+	if err != nil {
+		logrus.WithError(err).Warn("failed to list waiting pipeline tasks")
+		return
+	}
+	for _, task := range tasks {
+		m.releaseWaitingTask(task)
+	}
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/live/mock"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+func newSessionTestManager(t *testing.T) (*Manager, *MemoryStore) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store := NewMemoryStore()
+	return NewManager(ctx, store, nil, nil), store
+}
+
+func newSessionTestInfo(t *testing.T, liveID types.LiveID) *live.Info {
+	t.Helper()
+	l := mock.NewMockLive(gomock.NewController(t))
+	l.EXPECT().GetLiveId().Return(liveID).AnyTimes()
+	l.EXPECT().GetPlatformCNName().Return("哔哩哔哩").AnyTimes()
+	return &live.Info{Live: l, HostName: "主播", RoomName: "房间"}
+}
+
+func sessionTask(t *testing.T, store *MemoryStore) *PipelineTask {
+	t.Helper()
+	tasks, err := store.ListTasks(context.Background(), TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	return tasks[0]
+}
+
+func filePaths(files []FileInfo) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+func TestSessionSegmentsKeepRecordingOrder(t *testing.T) {
+	m, store := newSessionTestManager(t)
+	info := newSessionTestInfo(t, "room")
+	cfg := &PipelineConfig{}
+	start := time.Date(2026, 10, 1, 20, 0, 0, 0, time.UTC)
+
+	seq1 := m.BeginSessionSegment("room", start)
+	seq2 := m.BeginSessionSegment("room", start.Add(time.Hour))
+	seq3 := m.BeginSessionSegment("room", start.Add(2*time.Hour))
+
+	// 第三个分段先就绪，第一个分段的文件移动较慢，第二个分段没有可用文件
+	require.NoError(t, m.AddSessionSegment(seq3, info, cfg, []string{"/rec/3.flv"}))
+	task := sessionTask(t, store)
+	assert.Equal(t, start.Add(2*time.Hour), task.RecordInfo.StartTime)
+
+	require.NoError(t, m.AddSessionSegment(seq1, info, cfg, []string{"/rec/1a.flv", "/rec/1b.flv"}))
+	m.SkipSessionSegment("room", seq2)
+
+	task = sessionTask(t, store)
+	assert.Equal(t, PipelineStatusWaiting, task.Status)
+	assert.Equal(t, []string{"/rec/1a.flv", "/rec/1b.flv", "/rec/3.flv"}, filePaths(task.InitialFiles))
+	assert.Equal(t, filePaths(task.InitialFiles), filePaths(task.CurrentFiles))
+	assert.Equal(t, start, task.RecordInfo.StartTime)
+	assert.Equal(t, types.LiveID("room"), task.RecordInfo.LiveID)
+
+	m.EndSession("room")
+	assert.NotEqual(t, PipelineStatusWaiting, sessionTask(t, store).Status)
+}
+
+func TestSessionWaitsForPendingSegmentsAfterLiveEnd(t *testing.T) {
+	m, store := newSessionTestManager(t)
+	info := newSessionTestInfo(t, "room")
+	cfg := &PipelineConfig{}
+
+	seq1 := m.BeginSessionSegment("room", time.Now())
+	require.NoError(t, m.AddSessionSegment(seq1, info, cfg, []string{"/rec/1.flv"}))
+	seq2 := m.BeginSessionSegment("room", time.Now())
+	m.EndSession("room")
+
+	// 关播时第二个分段仍在移出临时目录
+	assert.Equal(t, PipelineStatusWaiting, sessionTask(t, store).Status)
+
+	require.NoError(t, m.AddSessionSegment(seq2, info, cfg, []string{"/rec/2.flv"}))
+	task := sessionTask(t, store)
+	assert.NotEqual(t, PipelineStatusWaiting, task.Status)
+	assert.Equal(t, []string{"/rec/1.flv", "/rec/2.flv"}, filePaths(task.InitialFiles))
+}
+
+func TestSessionSkippedOnlySegmentCreatesNoTask(t *testing.T) {
+	m, store := newSessionTestManager(t)
+
+	seq := m.BeginSessionSegment("room", time.Now())
+	m.EndSession("room")
+	m.SkipSessionSegment("room", seq)
+
+	tasks, err := store.ListTasks(context.Background(), TaskFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	assert.Empty(t, m.sessions)
+}
+
+func TestSessionCancelledTaskStartsNewTask(t *testing.T) {
+	m, store := newSessionTestManager(t)
+	info := newSessionTestInfo(t, "room")
+	cfg := &PipelineConfig{}
+
+	seq1 := m.BeginSessionSegment("room", time.Now())
+	require.NoError(t, m.AddSessionSegment(seq1, info, cfg, []string{"/rec/1.flv"}))
+	first := sessionTask(t, store)
+	require.NoError(t, m.CancelTask(first.ID))
+
+	seq2 := m.BeginSessionSegment("room", time.Now())
+	require.NoError(t, m.AddSessionSegment(seq2, info, cfg, []string{"/rec/2.flv"}))
+
+	status := PipelineStatusWaiting
+	tasks, err := store.ListTasks(context.Background(), TaskFilter{Status: &status})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.NotEqual(t, first.ID, tasks[0].ID)
+	assert.Equal(t, []string{"/rec/2.flv"}, filePaths(tasks[0].InitialFiles))
+}
@@ -0,0 +1,391 @@
+package stages
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// 截取方式
+const (
+	clipModeCopy     = "copy"
+	clipModeReencode = "reencode"
+)
+
+// defaultClipNameTemplate 默认输出文件名模板
+const defaultClipNameTemplate = "{{ .FileName }}_clip_{{ .Range }}"
+
+// ClipStage 片段截取阶段
+// 按时间范围从录像中截取片段：流复制时起点对齐到之前最近的关键帧，速度快且无损；
+// 重新编码时精确到帧，输出 H.264/AAC 的 MP4
+type ClipStage struct {
+	config   pipeline.StageConfig
+	ranges   []clipRange
+	mode     string
+	concat   bool
+	nameTmpl *template.Template
+	commands []string
+	logs     string
+}
+
+// clipRange 截取的时间范围（秒）
+type clipRange struct {
+	Start float64
+	End   float64
+}
+
+// clipNameData 文件名模板可用的字段
+type clipNameData struct {
+	FileName string  // 源文件名（不含扩展名）
+	Ext      string  // 输出扩展名（不含点）
+	Index    int     // 片段序号，从 1 开始
+	Start    float64 // 开始时间（秒）
+	End      float64 // 结束时间（秒）
+	Range    string  // 时间范围，如 00-10-00_00-10-30，可直接用于文件名
+	Platform string
+	HostName string
+	RoomName string
+}
+
+// NewClipStage 创建片段截取阶段工厂
+func NewClipStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	s := &ClipStage{
+		config: config,
+		mode:   config.GetStringOption(pipeline.OptionClipMode, clipModeCopy),
+		concat: config.GetBoolOption(pipeline.OptionConcat, false),
+	}
+	if s.mode != clipModeCopy && s.mode != clipModeReencode {
+		return nil, fmt.Errorf("clip: unsupported mode %q", s.mode)
+	}
+
+	raw := config.GetStringSliceOption(pipeline.OptionRanges)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("clip: no ranges specified")
+	}
+	for _, r := range raw {
+		cr, err := parseClipRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("clip: %w", err)
+		}
+		s.ranges = append(s.ranges, cr)
+	}
+
+	tmpl, err := template.New("clip_name").
+		Funcs(utils.GetFuncMap(pipeline.TemplateConfig())).
+		Parse(config.GetStringOption(pipeline.OptionNameTemplate, defaultClipNameTemplate))
+	if err != nil {
+		return nil, fmt.Errorf("clip: invalid name template: %w", err)
+	}
+	s.nameTmpl = tmpl
+	return s, nil
+}
+
+// parseClipRange 解析 "开始-结束" 格式的时间范围
+func parseClipRange(s string) (clipRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return clipRange{}, fmt.Errorf("invalid range %q, expected start-end", s)
+	}
+	start, err := parseClipTime(from)
+	if err != nil {
+		return clipRange{}, fmt.Errorf("invalid range %q: %w", s, err)
+	}
+	end, err := parseClipTime(to)
+	if err != nil {
+		return clipRange{}, fmt.Errorf("invalid range %q: %w", s, err)
+	}
+	if end <= start {
+		return clipRange{}, fmt.Errorf("invalid range %q: end must be after start", s)
+	}
+	return clipRange{Start: start, End: end}, nil
+}
+
+// parseClipTime 解析秒数或 [HH:]MM:SS[.mmm] 格式的时间
+func parseClipTime(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var total float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		// 只有秒可以带小数，分和秒不超过 60
+		if (i < len(parts)-1 && v != math.Trunc(v)) || (i > 0 && v >= 60) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		total = total*60 + v
+	}
+	return total, nil
+}
+
+// formatClipTime 格式化为可用于文件名的 HH-MM-SS
+func formatClipTime(sec float64) string {
+	t := int(sec)
+	return fmt.Sprintf("%02d-%02d-%02d", t/3600, t/60%60, t%60)
+}
+
+func (s *ClipStage) Name() string {
+	return pipeline.StageNameClip
+}
+
+func (s *ClipStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	ffmpegPath := ctx.FFmpegPath
+	if ffmpegPath == "" {
+		var err error
+		ffmpegPath, err = utils.GetFFmpegPath(ctx.Ctx)
+		if err != nil {
+			s.logs = fmt.Sprintf("ffmpeg 不可用: %s", err.Error())
+			return nil, fmt.Errorf("ffmpeg not available: %w", err)
+		}
+	}
+
+	var videos int
+	for _, file := range input {
+		if file.Type == pipeline.FileTypeVideo {
+			videos++
+		}
+	}
+
+	// 视频文件替换为截取出的片段，其余文件原样传递
+	var output []pipeline.FileInfo
+	done := 0
+	for _, file := range input {
+		if file.Type != pipeline.FileTypeVideo {
+			output = append(output, file)
+			continue
+		}
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			s.logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			return nil, fmt.Errorf("file not found: %s", file.Path)
+		}
+
+		ctx.Logger.Infof("截取片段: %s", file.Path)
+		offset := done
+		files, err := s.process(ctx, ffmpegPath, file, func(fraction float64) {
+			ctx.ReportProgress((float64(offset) + fraction) * 100 / float64(videos))
+		})
+		if err != nil {
+			s.logs += fmt.Sprintf("截取失败: %s - %s\n", filepath.Base(file.Path), err.Error())
+			return nil, fmt.Errorf("clip %s: %w", file.Path, err)
+		}
+		output = append(output, files...)
+		done++
+	}
+
+	return output, nil
+}
+
+// process 截取单个文件，progress 接收该文件的完成比例（0-1）
+func (s *ClipStage) process(ctx *pipeline.PipelineContext, ffmpegPath string, file pipeline.FileInfo, progress func(fraction float64)) ([]pipeline.FileInfo, error) {
+	ranges, err := s.clampRanges(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(file.Path)
+	if s.mode == clipModeReencode {
+		ext = ".mp4"
+	}
+
+	var total float64
+	for _, r := range ranges {
+		total += r.End - r.Start
+	}
+	var elapsed float64
+	cut := func(r clipRange, dst string) error {
+		err := s.cut(ctx, ffmpegPath, file.Path, dst, r, func(sec float64) {
+			progress((elapsed + min(sec, r.End-r.Start)) / total)
+		})
+		elapsed += r.End - r.Start
+		return err
+	}
+
+	// 拼接时所有范围输出到一个文件，否则每个范围一个文件
+	groups := [][]clipRange{ranges}
+	if !s.concat {
+		groups = groups[:0]
+		for _, r := range ranges {
+			groups = append(groups, []clipRange{r})
+		}
+	}
+
+	var output []pipeline.FileInfo
+	for i, group := range groups {
+		dst, err := s.outputPath(ctx, file.Path, ext, i+1, clipRange{Start: group[0].Start, End: group[len(group)-1].End})
+		if err != nil {
+			return nil, err
+		}
+		tempFile := filepath.Join(filepath.Dir(dst), ".clipping_"+filepath.Base(dst))
+
+		if len(group) == 1 {
+			err = cut(group[0], tempFile)
+		} else {
+			err = s.cutAndConcat(ctx, ffmpegPath, group, tempFile, cut)
+		}
+		if err != nil {
+			os.Remove(tempFile)
+			return nil, err
+		}
+		if err := os.Rename(tempFile, dst); err != nil {
+			os.Remove(tempFile)
+			return nil, fmt.Errorf("failed to rename temp file: %w", err)
+		}
+
+		output = append(output, pipeline.FileInfo{
+			Path:       dst,
+			Type:       pipeline.FileTypeVideo,
+			SourcePath: file.Path,
+		})
+		s.logs += fmt.Sprintf("片段已生成: %s\n", filepath.Base(dst))
+		ctx.Logger.Infof("片段已生成: %s", dst)
+	}
+	return output, nil
+}
+
+// clampRanges 按录像时长截断时间范围，无法探测时长时原样返回
+func (s *ClipStage) clampRanges(path string) ([]clipRange, error) {
+	probed, err := streamprobe.ProbeFile(path)
+	if err != nil || probed.Duration <= 0 {
+		return s.ranges, nil
+	}
+	duration := probed.Duration.Seconds()
+	ranges := make([]clipRange, 0, len(s.ranges))
+	for _, r := range s.ranges {
+		if r.Start >= duration {
+			return nil, fmt.Errorf("range starts at %.3fs, beyond the end of the recording (%.3fs)", r.Start, duration)
+		}
+		r.End = min(r.End, duration)
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// outputPath 按模板生成输出路径，输出与源文件位于同一目录，不覆盖已有文件
+func (s *ClipStage) outputPath(ctx *pipeline.PipelineContext, src, ext string, index int, r clipRange) (string, error) {
+	data := clipNameData{
+		FileName: strings.TrimSuffix(filepath.Base(src), filepath.Ext(src)),
+		Ext:      strings.TrimPrefix(ext, "."),
+		Index:    index,
+		Start:    r.Start,
+		End:      r.End,
+		Range:    formatClipTime(r.Start) + "_" + formatClipTime(r.End),
+		Platform: ctx.RecordInfo.Platform,
+		HostName: ctx.RecordInfo.HostName,
+		RoomName: ctx.RecordInfo.RoomName,
+	}
+	var b strings.Builder
+	if err := s.nameTmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render name template: %w", err)
+	}
+	name := strings.TrimSpace(b.String())
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid output name %q", name)
+	}
+
+	dst := filepath.Join(filepath.Dir(src), name+ext)
+	if dst == src {
+		return "", fmt.Errorf("output name %q would overwrite the source file", name+ext)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("output file already exists: %s", filepath.Base(dst))
+	}
+	return dst, nil
+}
+
+// cut 截取一个时间范围，onTime 接收已输出的时长（秒）
+func (s *ClipStage) cut(ctx *pipeline.PipelineContext, ffmpegPath, src, dst string, r clipRange, onTime func(sec float64)) error {
+	// 输入前的 -ss 快速定位：流复制时从之前最近的关键帧开始，重新编码时解码后精确丢弃多余的帧
+	args := []string{
+		"-ss", strconv.FormatFloat(r.Start, 'f', 3, 64),
+		"-i", src,
+		"-t", strconv.FormatFloat(r.End-r.Start, 'f', 3, 64),
+	}
+	if s.mode == clipModeReencode {
+		args = append(args,
+			"-map", "0:v?", "-map", "0:a?",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+			"-c:a", "aac", "-b:a", "192k",
+		)
+	} else {
+		args = append(args,
+			"-map", "0",
+			"-c", "copy",
+			"-avoid_negative_ts", "make_zero",
+		)
+	}
+	if strings.EqualFold(filepath.Ext(dst), ".mp4") {
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, "-y", dst)
+
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+	return runFFmpegWithProgress(ctx, ffmpegPath, args, onTime)
+}
+
+// cutAndConcat 分别截取每个范围后以 concat 分离器无损拼接
+func (s *ClipStage) cutAndConcat(ctx *pipeline.PipelineContext, ffmpegPath string, ranges []clipRange, dst string, cut func(r clipRange, dst string) error) error {
+	dir := filepath.Dir(dst)
+	ext := filepath.Ext(dst)
+	base := strings.TrimSuffix(filepath.Base(dst), ext)
+
+	var parts []string
+	defer func() {
+		for _, p := range parts {
+			os.Remove(p)
+		}
+	}()
+
+	var list strings.Builder
+	for i, r := range ranges {
+		part := filepath.Join(dir, fmt.Sprintf("%s.part%02d%s", base, i+1, ext))
+		parts = append(parts, part)
+		if err := cut(r, part); err != nil {
+			return err
+		}
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(part, "'", `'\''`))
+	}
+
+	listFile := filepath.Join(dir, base+".concat.txt")
+	parts = append(parts, listFile)
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+
+	args := []string{
+		"-f", "concat", "-safe", "0",
+		"-i", listFile,
+		"-map", "0",
+		"-c", "copy",
+	}
+	if strings.EqualFold(ext, ".mp4") {
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, "-y", dst)
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+	return runFFmpegWithProgress(ctx, ffmpegPath, args, nil)
+}
+
+func (s *ClipStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *ClipStage) GetLogs() string {
+	return s.logs
+}
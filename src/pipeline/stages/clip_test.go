@@ -0,0 +1,176 @@
+package stages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+func newClipTestStage(t *testing.T, options map[string]any) *ClipStage {
+	t.Helper()
+	stage, err := NewClipStage(pipeline.StageConfig{Name: pipeline.StageNameClip, Options: options})
+	require.NoError(t, err)
+	return stage.(*ClipStage)
+}
+
+func TestParseClipTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "90", want: 90},
+		{in: "90.5", want: 90.5},
+		{in: " 12 ", want: 12},
+		{in: "01:30", want: 90},
+		{in: "1:02:03", want: 3723},
+		{in: "01:02:03.250", want: 3723.25},
+		{in: "00:00:59.999", want: 59.999},
+		{in: "100:00", want: 6000}, // 最高位不限制大小
+		{in: "01:60", wantErr: true},
+		{in: "1:60:00", wantErr: true},
+		{in: "1:00:60", wantErr: true},
+		{in: "1.5:00", wantErr: true}, // 只有秒可以带小数
+		{in: "1:2:3:4", wantErr: true},
+		{in: "-5", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "1:", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseClipTime(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestParseClipRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    clipRange
+		wantErr bool
+	}{
+		{in: "10-20", want: clipRange{Start: 10, End: 20}},
+		{in: "00:10:00-00:10:30.5", want: clipRange{Start: 600, End: 630.5}},
+		{in: "59.5-1:00", want: clipRange{Start: 59.5, End: 60}},
+		{in: "20-20", wantErr: true},
+		{in: "30-20", wantErr: true},
+		{in: "10", wantErr: true},
+		{in: "10-", wantErr: true},
+		{in: "-10", wantErr: true},
+		{in: "10-20-30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseClipRange(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewClipStageInvalidOptions(t *testing.T) {
+	for name, options := range map[string]map[string]any{
+		"没有范围":   {},
+		"范围无效":   {pipeline.OptionRanges: []any{"10-5"}},
+		"未知模式":   {pipeline.OptionRanges: []any{"0-10"}, pipeline.OptionClipMode: "fast"},
+		"模板无效":   {pipeline.OptionRanges: []any{"0-10"}, pipeline.OptionNameTemplate: "{{ .FileName"},
+		"部分范围无效": {pipeline.OptionRanges: []any{"0-10", "1:60-2:00"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewClipStage(pipeline.StageConfig{Name: pipeline.StageNameClip, Options: options})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClipOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "rec.flv")
+	require.NoError(t, os.WriteFile(src, nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "exists.flv"), nil, 0o644))
+	ctx := newWebhookTestContext()
+	r := clipRange{Start: 3723.5, End: 3753}
+
+	tests := []struct {
+		name     string
+		template string
+		ext      string
+		want     string
+		wantErr  bool
+	}{
+		{name: "默认模板", ext: ".flv", want: "rec_clip_01-02-03_01-02-33.flv"},
+		{name: "重新编码输出 MP4", ext: ".mp4", want: "rec_clip_01-02-03_01-02-33.mp4"},
+		{
+			name:     "自定义模板",
+			template: `{{ .HostName }}_{{ .FileName }}_{{ .Index }}_{{ printf "%.1f" .Start }}_{{ .Ext }}`,
+			ext:      ".mp4",
+			want:     "主播_rec_2_3723.5_mp4.mp4",
+		},
+		{name: "覆盖源文件", template: "rec", ext: ".flv", wantErr: true},
+		{name: "已有文件", template: "exists", ext: ".flv", wantErr: true},
+		{name: "上级目录", template: "../escape", ext: ".flv", wantErr: true},
+		{name: "子目录", template: "sub/clip", ext: ".flv", wantErr: true},
+		{name: "反斜杠", template: `sub\clip`, ext: ".flv", wantErr: true},
+		{name: "点", template: "..", ext: ".flv", wantErr: true},
+		{name: "空白", template: "  ", ext: ".flv", wantErr: true},
+		{name: "模板执行失败", template: "{{ .Missing }}", ext: ".flv", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]any{pipeline.OptionRanges: []any{"0-10"}}
+			if tt.template != "" {
+				options[pipeline.OptionNameTemplate] = tt.template
+			}
+			stage := newClipTestStage(t, options)
+			got, err := stage.outputPath(ctx, src, tt.ext, 2, r)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(dir, tt.want), got)
+		})
+	}
+}
+
+func TestClipClampRanges(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video) // 时长 90 秒
+
+	stage := newClipTestStage(t, map[string]any{pipeline.OptionRanges: []any{"10-20", "01:20-02:00"}})
+	ranges, err := stage.clampRanges(video)
+	require.NoError(t, err)
+	assert.Equal(t, []clipRange{{Start: 10, End: 20}, {Start: 80, End: 90}}, ranges)
+	// 不修改阶段配置中的范围
+	assert.Equal(t, 120.0, stage.ranges[1].End)
+
+	stage = newClipTestStage(t, map[string]any{pipeline.OptionRanges: []any{"10-20", "90-100"}})
+	_, err = stage.clampRanges(video)
+	assert.Error(t, err)
+
+	// 无法探测时长时原样返回
+	unknown := filepath.Join(dir, "rec.ts")
+	require.NoError(t, os.WriteFile(unknown, []byte("not a video"), 0o644))
+	ranges, err = stage.clampRanges(unknown)
+	require.NoError(t, err)
+	assert.Equal(t, stage.ranges, ranges)
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
 	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
 	"github.com/sirupsen/logrus"
@@ -90,7 +91,7 @@ func (s *ConvertMp4Stage) Execute(ctx *pipeline.PipelineContext, input []pipelin
 		ctx.Logger.Infof("转换 MP4: %s -> %s", file.Path, outputPath)
 
 		// 获取视频时长用于进度计算
-		duration := s.getVideoDuration(ctx.Ctx, ffmpegPath, file.Path)
+		duration := getVideoDuration(ctx.Ctx, ffmpegPath, file.Path)
 
 		// 构建 ffmpeg 命令
 		args := []string{
@@ -120,7 +121,7 @@ func (s *ConvertMp4Stage) Execute(ctx *pipeline.PipelineContext, input []pipelin
 
 		// 解析进度（后台）
 		bilisentry.GoWithContext(ctx.Ctx, func(goCtx context.Context) {
-			s.parseProgress(goCtx, stdout, duration)
+			s.parseProgress(goCtx, stdout, duration, ctx.ReportProgress)
 		})
 
 		// 等待命令完成
@@ -151,10 +152,16 @@ func (s *ConvertMp4Stage) Execute(ctx *pipeline.PipelineContext, input []pipelin
 
 		// 删除原始文件
 		if s.deleteSource && file.Path != outputPath {
-			if err := os.Remove(file.Path); err != nil {
+			if err := integrity.Check(ctx.Ctx, file.Path); err != nil {
+				// 原始文件与清单不一致，保留以便排查
+				s.logs += fmt.Sprintf("原始文件未通过完整性校验，不删除: %s\n", err)
+				ctx.Logger.Warnf("原始文件未通过完整性校验，不删除: %s", err)
+				output = append(output, file)
+			} else if err := os.Remove(file.Path); err != nil {
 				logrus.WithError(err).WithField("file", file.Path).Warn("failed to delete original file")
 				s.logs += fmt.Sprintf("删除原始文件失败: %s\n", file.Path)
 			} else {
+				integrity.Remove(file.Path)
 				s.logs += fmt.Sprintf("已删除原始文件: %s\n", file.Path)
 				ctx.Logger.Infof("已删除原始文件: %s", file.Path)
 			}
@@ -173,7 +180,7 @@ func (s *ConvertMp4Stage) Execute(ctx *pipeline.PipelineContext, input []pipelin
 }
 
 // getVideoDuration 获取视频时长（秒）
-func (s *ConvertMp4Stage) getVideoDuration(ctx context.Context, ffmpegPath, inputFile string) float64 {
+func getVideoDuration(ctx context.Context, ffmpegPath, inputFile string) float64 {
 	cmd := exec.CommandContext(ctx, ffmpegPath,
 		"-i", inputFile,
 		"-hide_banner",
@@ -196,8 +203,8 @@ func (s *ConvertMp4Stage) getVideoDuration(ctx context.Context, ffmpegPath, inpu
 	return hours*3600 + minutes*60 + seconds + ms/100
 }
 
-// parseProgress 解析 ffmpeg 进度输出
-func (s *ConvertMp4Stage) parseProgress(ctx context.Context, stdout io.Reader, totalDuration float64) {
+// parseProgress 解析 ffmpeg 进度输出，并通过 report 上报百分比
+func (s *ConvertMp4Stage) parseProgress(ctx context.Context, stdout io.Reader, totalDuration float64, report func(percent float64)) {
 	scanner := bufio.NewScanner(stdout)
 	re := regexp.MustCompile(`out_time_us=(\d+)`)
 
@@ -214,7 +221,7 @@ func (s *ConvertMp4Stage) parseProgress(ctx context.Context, stdout io.Reader, t
 			timeUs, _ := strconv.ParseFloat(matches[1], 64)
 			currentTime := timeUs / 1000000
 			progress := (currentTime / totalDuration) * 100
-			_ = progress // 可以通过回调上报进度
+			report(progress)
 		}
 	}
 }
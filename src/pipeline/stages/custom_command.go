@@ -11,6 +11,7 @@ import (
 	"text/template"
 
 	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
 )
 
@@ -222,12 +223,21 @@ func (s *DeleteSourceStage) Execute(ctx *pipeline.PipelineContext, input []pipel
 			}
 		}
 
+		// 与完整性清单不一致的文件不删除，保留以便排查
+		if err := integrity.Check(ctx.Ctx, file.Path); err != nil {
+			s.logs += fmt.Sprintf("未通过完整性校验，不删除: %s\n", err)
+			ctx.Logger.Warnf("未通过完整性校验，不删除: %s", err)
+			output = append(output, file)
+			continue
+		}
+
 		// 删除文件
 		if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
 			s.logs += fmt.Sprintf("删除失败: %s - %s\n", file.Path, err.Error())
 			ctx.Logger.Warnf("删除文件失败: %s - %s", file.Path, err)
 			output = append(output, file) // 删除失败，保留在输出中
 		} else {
+			integrity.Remove(file.Path)
 			s.logs += fmt.Sprintf("已删除: %s\n", file.Path)
 			ctx.Logger.Infof("已删除文件: %s", file.Path)
 			// 文件已删除，不添加到输出
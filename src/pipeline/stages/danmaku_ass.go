@@ -0,0 +1,252 @@
+package stages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+const (
+	defaultDanmakuLanes    = 12
+	defaultDanmakuOpacity  = 0.8
+	defaultDanmakuDuration = 8.0 // 秒
+	defaultBurnInCRF       = 23
+	defaultBurnInSpeed     = "veryfast"
+	defaultBurnInSuffix    = ".danmaku"
+)
+
+// DanmakuASSStage 弹幕转字幕阶段
+// 为每个视频查找同名的弹幕 XML（BililiveRecorder 格式），转换为滚动的 ASS 字幕，可选压制进视频
+type DanmakuASSStage struct {
+	config   pipeline.StageConfig
+	options  danmaku.Options
+	burnIn   bool
+	crf      int
+	speed    string
+	suffix   string
+	commands []string
+	logs     string
+}
+
+// NewDanmakuASSStage 创建弹幕转字幕阶段工厂
+func NewDanmakuASSStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	s := &DanmakuASSStage{
+		config: config,
+		options: danmaku.Options{
+			FontName:     config.GetStringOption(pipeline.OptionFontName, ""),
+			FontSize:     config.GetIntOption(pipeline.OptionFontSize, 0),
+			Lanes:        config.GetIntOption(pipeline.OptionLanes, defaultDanmakuLanes),
+			Opacity:      config.GetFloatOption(pipeline.OptionOpacity, defaultDanmakuOpacity),
+			Duration:     config.GetFloatOption(pipeline.OptionDuration, defaultDanmakuDuration),
+			BlockedWords: config.GetStringSliceOption(pipeline.OptionBlockedWords),
+			FilterGifts:  config.GetBoolOption(pipeline.OptionFilterGifts, true),
+			FilterSystem: config.GetBoolOption(pipeline.OptionFilterSystem, true),
+		},
+		burnIn: config.GetBoolOption(pipeline.OptionBurnIn, false),
+		crf:    config.GetIntOption(pipeline.OptionCRF, defaultBurnInCRF),
+		speed:  config.GetStringOption(pipeline.OptionSpeed, defaultBurnInSpeed),
+		suffix: config.GetStringOption(pipeline.OptionSuffix, defaultBurnInSuffix),
+	}
+	if s.options.FontSize < 0 || s.options.Lanes < 0 {
+		return nil, fmt.Errorf("danmaku_ass: font_size and lanes must not be negative")
+	}
+	if s.options.Opacity <= 0 || s.options.Opacity > 1 {
+		return nil, fmt.Errorf("danmaku_ass: opacity must be between 0 and 1")
+	}
+	if s.options.Duration <= 0 {
+		return nil, fmt.Errorf("danmaku_ass: duration must be positive")
+	}
+	if s.burnIn && (s.crf < 0 || s.crf > 51) {
+		return nil, fmt.Errorf("danmaku_ass: crf must be between 0 and 51")
+	}
+	return s, nil
+}
+
+func (s *DanmakuASSStage) Name() string {
+	return pipeline.StageNameDanmakuASS
+}
+
+func (s *DanmakuASSStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	// 输入中的弹幕文件，按去掉扩展名的路径索引
+	sidecars := make(map[string]string)
+	for _, file := range input {
+		if file.Type == pipeline.FileTypeOther && strings.EqualFold(filepath.Ext(file.Path), ".xml") {
+			sidecars[trimExt(file.Path)] = file.Path
+		}
+	}
+
+	var videos []pipeline.FileInfo
+	for _, file := range input {
+		if file.Type == pipeline.FileTypeVideo {
+			videos = append(videos, file)
+		}
+	}
+
+	var ffmpegPath string
+	if s.burnIn {
+		ffmpegPath = ctx.FFmpegPath
+		if ffmpegPath == "" {
+			var err error
+			ffmpegPath, err = utils.GetFFmpegPath(ctx.Ctx)
+			if err != nil {
+				s.logs = fmt.Sprintf("ffmpeg 不可用: %s", err.Error())
+				return nil, fmt.Errorf("ffmpeg not available: %w", err)
+			}
+		}
+	}
+
+	output := append([]pipeline.FileInfo{}, input...)
+	for i, video := range videos {
+		xmlPath := findDanmakuXML(video, sidecars)
+		if xmlPath == "" {
+			s.logs += fmt.Sprintf("未找到弹幕文件: %s\n", filepath.Base(video.Path))
+			continue
+		}
+
+		assPath := trimExt(video.Path) + ".ass"
+		var duration float64
+		opts := s.options
+		if probed, err := streamprobe.ProbeFile(video.Path); err == nil {
+			duration = probed.Duration.Seconds()
+			if probed.StreamHeaderInfo != nil {
+				opts.Width, opts.Height = probed.Width, probed.Height
+			}
+		}
+		stats, err := s.convert(xmlPath, assPath, opts)
+		if err != nil {
+			s.logs += fmt.Sprintf("转换弹幕失败: %s - %s\n", filepath.Base(xmlPath), err)
+			return nil, fmt.Errorf("convert danmaku %s: %w", xmlPath, err)
+		}
+		s.commands = append(s.commands, fmt.Sprintf("danmaku2ass %s -> %s", xmlPath, assPath))
+		s.logs += fmt.Sprintf("字幕已保存: %s（共 %d 条，显示 %d 条，过滤 %d 条，轨道已满丢弃 %d 条）\n",
+			filepath.Base(assPath), stats.Total, stats.Rendered, stats.Filtered, stats.Dropped)
+		ctx.Logger.Infof("弹幕字幕已保存: %s", assPath)
+		output = append(output, pipeline.FileInfo{
+			Path:       assPath,
+			Type:       pipeline.FileTypeOther,
+			SourcePath: video.Path,
+		})
+
+		if !s.burnIn {
+			ctx.ReportProgress(float64(i+1) * 100 / float64(len(videos)))
+			continue
+		}
+		burned := trimExt(video.Path) + s.suffix + ".mp4"
+		if burned == video.Path {
+			return nil, fmt.Errorf("burn-in output would overwrite the source file: %s", video.Path)
+		}
+		ctx.Logger.Infof("压制弹幕: %s -> %s", video.Path, burned)
+		index := i
+		err = s.burn(ctx, ffmpegPath, video.Path, assPath, burned, func(sec float64) {
+			if duration > 0 {
+				ctx.ReportProgress((float64(index) + min(sec/duration, 1)) * 100 / float64(len(videos)))
+			}
+		})
+		if err != nil {
+			s.logs += fmt.Sprintf("压制失败: %s - %s\n", filepath.Base(video.Path), err)
+			return nil, fmt.Errorf("burn in danmaku %s: %w", video.Path, err)
+		}
+		s.logs += fmt.Sprintf("压制完成: %s\n", filepath.Base(burned))
+		output = append(output, pipeline.FileInfo{
+			Path:       burned,
+			Type:       pipeline.FileTypeVideo,
+			SourcePath: video.Path,
+		})
+	}
+
+	return output, nil
+}
+
+// findDanmakuXML 查找视频对应的弹幕文件：先在输入中按同名匹配（包括转换前的源文件名），再查找磁盘上的同名文件
+func findDanmakuXML(video pipeline.FileInfo, sidecars map[string]string) string {
+	candidates := []string{trimExt(video.Path)}
+	if video.SourcePath != "" {
+		candidates = append(candidates, trimExt(video.SourcePath))
+	}
+	for _, base := range candidates {
+		if path, ok := sidecars[base]; ok {
+			return path
+		}
+	}
+	for _, base := range candidates {
+		if _, err := os.Stat(base + ".xml"); err == nil {
+			return base + ".xml"
+		}
+	}
+	return ""
+}
+
+// convert 解析弹幕文件并写入 ASS 字幕
+func (s *DanmakuASSStage) convert(xmlPath, assPath string, opts danmaku.Options) (danmaku.Stats, error) {
+	comments, err := danmaku.ParseFile(xmlPath)
+	if err != nil {
+		return danmaku.Stats{}, err
+	}
+	f, err := os.Create(assPath)
+	if err != nil {
+		return danmaku.Stats{}, err
+	}
+	stats, err := danmaku.WriteASS(f, comments, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(assPath)
+	}
+	return stats, err
+}
+
+// burn 用 libx264 重新编码视频并叠加字幕，先写入临时文件再重命名
+func (s *DanmakuASSStage) burn(ctx *pipeline.PipelineContext, ffmpegPath, src, assPath, dst string, onTime func(sec float64)) error {
+	tempFile := filepath.Join(filepath.Dir(dst), ".burning_"+filepath.Base(dst))
+	args := []string{
+		"-i", src,
+		"-vf", "ass=" + escapeFilterValue(assPath),
+		"-map", "0:v?", "-map", "0:a?",
+		"-c:v", "libx264", "-preset", s.speed, "-crf", strconv.Itoa(s.crf),
+		"-c:a", "copy",
+		"-movflags", "+faststart",
+		"-y", tempFile,
+	}
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+	if err := runFFmpegWithProgress(ctx, ffmpegPath, args, onTime); err != nil {
+		os.Remove(tempFile)
+		return err
+	}
+	if err := os.Rename(tempFile, dst); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+// escapeFilterValue 转义 ffmpeg 滤镜参数中的路径：先转义选项值中的特殊字符，再转义滤镜图中的特殊字符
+func escapeFilterValue(path string) string {
+	path = filepath.ToSlash(path)
+	path = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(path)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(path)
+}
+
+func trimExt(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+func (s *DanmakuASSStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *DanmakuASSStage) GetLogs() string {
+	return s.logs
+}
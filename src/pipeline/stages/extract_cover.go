@@ -5,8 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
 	"github.com/bililive-go/bililive-go/src/tools"
 )
 
@@ -89,6 +91,7 @@ type CloudUploadStage struct {
 	config       pipeline.StageConfig
 	storageName  string
 	pathTemplate string
+	pathTmpl     *template.Template // 模板无法解析时为空，按旧版规则逐字替换
 	deleteAfter  bool
 	fileTypes    []string // 过滤的文件类型，空表示所有
 	commands     []string
@@ -97,10 +100,14 @@ type CloudUploadStage struct {
 
 // NewCloudUploadStage 创建云上传阶段工厂
 func NewCloudUploadStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	pathTemplate := config.GetStringOption(pipeline.OptionPathTemplate, "")
+	// 旧版本只做简单的字符串替换，无法解析的模板也要能继续使用
+	pathTmpl, _ := parseUploadPathTemplate(pathTemplate)
 	return &CloudUploadStage{
 		config:       config,
 		storageName:  config.GetStringOption(pipeline.OptionStorage, ""),
-		pathTemplate: config.GetStringOption(pipeline.OptionPathTemplate, ""),
+		pathTemplate: pathTemplate,
+		pathTmpl:     pathTmpl,
 		deleteAfter:  config.GetBoolOption(pipeline.OptionDeleteAfter, false),
 		fileTypes:    config.GetStringSliceOption(pipeline.OptionFileTypes),
 	}, nil
@@ -125,7 +132,7 @@ func (s *CloudUploadStage) Execute(ctx *pipeline.PipelineContext, input []pipeli
 
 	for _, file := range input {
 		// 文件类型过滤
-		if len(s.fileTypes) > 0 && !s.matchFileType(file.Type) {
+		if len(s.fileTypes) > 0 && !matchFileTypes(s.fileTypes, file.Type) {
 			output = append(output, file)
 			continue
 		}
@@ -136,6 +143,11 @@ func (s *CloudUploadStage) Execute(ctx *pipeline.PipelineContext, input []pipeli
 			continue
 		}
 
+		if err := integrity.Check(ctx.Ctx, file.Path); err != nil {
+			s.logs += fmt.Sprintf("未通过完整性校验，取消上传: %s\n", err)
+			return nil, err
+		}
+
 		// 渲染目标路径
 		targetPath := s.renderTargetPath(ctx, file)
 		if targetPath == "" {
@@ -162,46 +174,26 @@ func (s *CloudUploadStage) Execute(ctx *pipeline.PipelineContext, input []pipeli
 	return output, nil
 }
 
-// matchFileType 检查文件类型是否匹配
-func (s *CloudUploadStage) matchFileType(fileType pipeline.FileType) bool {
-	for _, ft := range s.fileTypes {
-		if strings.EqualFold(ft, string(fileType)) {
-			return true
-		}
-	}
-	return false
-}
-
-// renderTargetPath 渲染目标路径
+// renderTargetPath 渲染目标路径，模板无法解析或执行失败时按旧版规则替换
 func (s *CloudUploadStage) renderTargetPath(ctx *pipeline.PipelineContext, file pipeline.FileInfo) string {
-	if s.pathTemplate == "" {
-		// 默认路径：/录播归档/{平台}/{主播名}/{文件名}
-		return fmt.Sprintf("/录播归档/%s/%s/%s",
-			ctx.RecordInfo.Platform,
-			ctx.RecordInfo.HostName,
-			filepath.Base(file.Path),
-		)
+	if s.pathTmpl != nil {
+		if path, err := renderUploadPath(s.pathTmpl, ctx, file); err == nil {
+			return path
+		}
 	}
 
-	// 简单的模板替换
 	path := s.pathTemplate
-	path = strings.ReplaceAll(path, "{{ .Platform }}", ctx.RecordInfo.Platform)
-	path = strings.ReplaceAll(path, "{{.Platform}}", ctx.RecordInfo.Platform)
-	path = strings.ReplaceAll(path, "{{ .HostName }}", ctx.RecordInfo.HostName)
-	path = strings.ReplaceAll(path, "{{.HostName}}", ctx.RecordInfo.HostName)
-	path = strings.ReplaceAll(path, "{{ .RoomName }}", ctx.RecordInfo.RoomName)
-	path = strings.ReplaceAll(path, "{{.RoomName}}", ctx.RecordInfo.RoomName)
-	path = strings.ReplaceAll(path, "{{ .FileName }}", filepath.Base(file.Path))
-	path = strings.ReplaceAll(path, "{{.FileName}}", filepath.Base(file.Path))
-
-	// 获取扩展名
-	ext := filepath.Ext(file.Path)
-	if len(ext) > 0 && ext[0] == '.' {
-		ext = ext[1:]
+	ext := strings.TrimPrefix(filepath.Ext(file.Path), ".")
+	for _, field := range []struct{ name, value string }{
+		{"Platform", ctx.RecordInfo.Platform},
+		{"HostName", ctx.RecordInfo.HostName},
+		{"RoomName", ctx.RecordInfo.RoomName},
+		{"FileName", filepath.Base(file.Path)},
+		{"Ext", ext},
+	} {
+		path = strings.ReplaceAll(path, "{{ ."+field.name+" }}", field.value)
+		path = strings.ReplaceAll(path, "{{."+field.name+"}}", field.value)
 	}
-	path = strings.ReplaceAll(path, "{{ .Ext }}", ext)
-	path = strings.ReplaceAll(path, "{{.Ext}}", ext)
-
 	return path
 }
 
@@ -0,0 +1,45 @@
+package stages
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+// runFFmpegWithProgress 运行 ffmpeg 并解析 -progress 输出，每次更新时以已输出的时长（秒）调用 onTime
+func runFFmpegWithProgress(ctx *pipeline.PipelineContext, ffmpegPath string, args []string, onTime func(seconds float64)) error {
+	args = append([]string{"-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1"}, args...)
+	cmd := exec.CommandContext(ctx.Ctx, ffmpegPath, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		// 进度输出为 key=value 行，out_time_us 在部分版本中为 N/A
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !ok || onTime == nil {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us >= 0 {
+			onTime(float64(us) / 1e6)
+		}
+	}
+	io.Copy(io.Discard, stdout)
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package stages
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeFFmpeg 在临时目录中写入一个代替 ffmpeg 的 shell 脚本，返回脚本路径
+func writeFakeFFmpeg(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o755))
+	return path
+}
+
+// writeTestFLV 写入一个时长 90 秒的最小 FLV 文件（H.264 + AAC）
+func writeTestFLV(t *testing.T, path string) {
+	t.Helper()
+	tag := func(tagType uint8, ts uint32, data []byte) []byte {
+		b := []byte{tagType, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data)),
+			byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24), 0, 0, 0}
+		b = append(b, data...)
+		return binary.BigEndian.AppendUint32(b, uint32(11+len(data)))
+	}
+	data := []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+	data = append(data, tag(9, 0, []byte{0x17, 0, 0, 0, 0})...)
+	data = append(data, tag(8, 0, []byte{0xaf, 0, 0x12, 0x10})...)
+	data = append(data, tag(9, 90000, []byte{0x17, 1, 0, 0, 0})...)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
@@ -0,0 +1,262 @@
+package stages
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/highlight"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// 响度分析滤镜
+const (
+	analyzerEBUR128 = "ebur128"
+	analyzerAstats  = "astats"
+)
+
+// HighlightsStage 响度高光检测阶段
+// 分析音频响度，找出明显高于滚动基线的片段，输出高光 JSON，可选按得分截取前 N 个片段
+type HighlightsStage struct {
+	config   pipeline.StageConfig
+	analyzer string
+	opts     highlight.Options
+	clipTop  int
+	commands []string
+	logs     string
+}
+
+// highlightsReport 高光 JSON 文件内容
+type highlightsReport struct {
+	Source     string                `json:"source"`
+	Analyzer   string                `json:"analyzer"`
+	Duration   float64               `json:"duration"`
+	Options    highlight.Options     `json:"options"`
+	Highlights []highlight.Highlight `json:"highlights"`
+	// Clips 截取的片段文件名，与 TopN 的顺序一致
+	Clips []string `json:"clips,omitempty"`
+}
+
+// NewHighlightsStage 创建高光检测阶段工厂
+func NewHighlightsStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	def := highlight.DefaultOptions()
+	s := &HighlightsStage{
+		config:   config,
+		analyzer: config.GetStringOption(pipeline.OptionAnalyzer, analyzerEBUR128),
+		opts: highlight.Options{
+			Window:         config.GetFloatOption(pipeline.OptionWindow, def.Window),
+			BaselineWindow: config.GetFloatOption(pipeline.OptionBaselineWindow, def.BaselineWindow),
+			MinBaseline:    config.GetFloatOption(pipeline.OptionMinBaseline, def.MinBaseline),
+			Threshold:      config.GetFloatOption(pipeline.OptionThreshold, def.Threshold),
+			MinLoudness:    config.GetFloatOption(pipeline.OptionMinLoudness, def.MinLoudness),
+			MergeGap:       config.GetFloatOption(pipeline.OptionMergeGap, def.MergeGap),
+			PreRoll:        config.GetFloatOption(pipeline.OptionPreRoll, def.PreRoll),
+			PostRoll:       config.GetFloatOption(pipeline.OptionPostRoll, def.PostRoll),
+			MaxHighlights:  config.GetIntOption(pipeline.OptionMaxHighlights, def.MaxHighlights),
+		},
+		clipTop: config.GetIntOption(pipeline.OptionClipTop, 0),
+	}
+	if s.analyzer != analyzerEBUR128 && s.analyzer != analyzerAstats {
+		return nil, fmt.Errorf("highlights: unsupported analyzer %q", s.analyzer)
+	}
+	if s.opts.Window <= 0 || s.opts.BaselineWindow < s.opts.Window || s.opts.Threshold <= 0 {
+		return nil, fmt.Errorf("highlights: window, baseline_window and threshold must be positive")
+	}
+	return s, nil
+}
+
+func (s *HighlightsStage) Name() string {
+	return pipeline.StageNameHighlights
+}
+
+func (s *HighlightsStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	ffmpegPath := ctx.FFmpegPath
+	if ffmpegPath == "" {
+		var err error
+		ffmpegPath, err = utils.GetFFmpegPath(ctx.Ctx)
+		if err != nil {
+			s.logs = fmt.Sprintf("ffmpeg 不可用: %s", err.Error())
+			return nil, fmt.Errorf("ffmpeg not available: %w", err)
+		}
+	}
+
+	output := append([]pipeline.FileInfo(nil), input...)
+
+	for _, file := range input {
+		if file.Type != pipeline.FileTypeVideo {
+			continue
+		}
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			s.logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			continue
+		}
+
+		ctx.Logger.Infof("分析音频响度: %s", file.Path)
+		files, err := s.process(ctx, ffmpegPath, file)
+		if err != nil {
+			if ctx.Ctx.Err() != nil {
+				return nil, ctx.Ctx.Err()
+			}
+			s.logs += fmt.Sprintf("高光检测失败: %s - %s\n", filepath.Base(file.Path), err.Error())
+			ctx.Logger.Warnf("高光检测失败: %s - %s", file.Path, err)
+			continue
+		}
+		output = append(output, files...)
+	}
+
+	return output, nil
+}
+
+// process 分析单个文件，写入高光 JSON 并截取片段
+func (s *HighlightsStage) process(ctx *pipeline.PipelineContext, ffmpegPath string, file pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	points, err := s.measure(ctx, ffmpegPath, file.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("没有音频响度数据（文件可能没有音轨）")
+	}
+
+	var duration float64
+	if probed, err := streamprobe.ProbeFile(file.Path); err == nil && probed.Duration > 0 {
+		duration = probed.Duration.Seconds()
+	} else {
+		duration = points[len(points)-1].Time
+	}
+
+	report := highlightsReport{
+		Source:     filepath.Base(file.Path),
+		Analyzer:   s.analyzer,
+		Duration:   duration,
+		Options:    s.opts,
+		Highlights: highlight.Detect(points, duration, s.opts),
+	}
+	if report.Highlights == nil {
+		report.Highlights = []highlight.Highlight{}
+	}
+
+	ext := filepath.Ext(file.Path)
+	base := strings.TrimSuffix(file.Path, ext)
+	var output []pipeline.FileInfo
+	if s.clipTop > 0 {
+		for i, h := range highlight.TopN(report.Highlights, s.clipTop) {
+			clipPath := fmt.Sprintf("%s.highlight_%02d%s", base, i+1, ext)
+			if err := s.cut(ctx, ffmpegPath, file.Path, clipPath, h); err != nil {
+				return nil, err
+			}
+			report.Clips = append(report.Clips, filepath.Base(clipPath))
+			// 片段不是完整的录像，不作为视频交给后续的转码、封面等阶段处理
+			output = append(output, pipeline.FileInfo{
+				Path:       clipPath,
+				Type:       pipeline.FileTypeOther,
+				SourcePath: file.Path,
+				Metadata:   map[string]any{"highlight": i + 1, "score": h.Score},
+			})
+		}
+	}
+
+	jsonPath := base + ".highlights.json"
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+		return nil, err
+	}
+	output = append(output, pipeline.FileInfo{
+		Path:       jsonPath,
+		Type:       pipeline.FileTypeOther,
+		SourcePath: file.Path,
+	})
+
+	s.logs += fmt.Sprintf("检测到 %d 个高光片段: %s，截取 %d 个\n", len(report.Highlights), filepath.Base(jsonPath), len(report.Clips))
+	ctx.Logger.Infof("检测到 %d 个高光片段: %s，截取 %d 个", len(report.Highlights), jsonPath, len(report.Clips))
+	return output, nil
+}
+
+// measure 用 ffmpeg 测量整段音频的响度
+func (s *HighlightsStage) measure(ctx *pipeline.PipelineContext, ffmpegPath, path string) ([]highlight.Point, error) {
+	var filter, key string
+	switch s.analyzer {
+	case analyzerAstats:
+		// 按 100ms 分块统计 RMS 电平
+		key = "lavfi.astats.Overall.RMS_level"
+		filter = "aresample=48000,asetnsamples=n=4800:p=0,astats=metadata=1:reset=1,ametadata=mode=print:key=" + key + ":file=-"
+	default:
+		// 瞬时响度（400ms 窗口，每 100ms 输出一次）
+		key = "lavfi.r128.M"
+		filter = "ebur128=metadata=1,ametadata=mode=print:key=" + key + ":file=-"
+	}
+	args := []string{
+		"-hide_banner", "-nostats", "-loglevel", "error",
+		"-i", path,
+		"-vn", "-sn", "-dn",
+		"-af", filter,
+		"-f", "null", "-",
+	}
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+
+	cmd := exec.CommandContext(ctx.Ctx, ffmpegPath, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	points, parseErr := highlight.ParseMetadata(stdout, key)
+	// 解析出错时读完剩余输出，避免 ffmpeg 阻塞在写入上
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return points, parseErr
+}
+
+// cut 以流复制方式截取片段，先写入临时文件再重命名，避免留下不完整的片段
+func (s *HighlightsStage) cut(ctx *pipeline.PipelineContext, ffmpegPath, src, dst string, h highlight.Highlight) error {
+	tempFile := filepath.Join(filepath.Dir(dst), ".cutting_"+filepath.Base(dst))
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(h.Start, 'f', 3, 64),
+		"-i", src,
+		"-t", strconv.FormatFloat(h.End-h.Start, 'f', 3, 64),
+		"-map", "0",
+		"-c", "copy",
+		"-avoid_negative_ts", "make_zero",
+		"-y",
+		tempFile,
+	}
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+	if out, err := exec.CommandContext(ctx.Ctx, ffmpegPath, args...).CombinedOutput(); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("ffmpeg cut failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err := os.Rename(tempFile, dst); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+func (s *HighlightsStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *HighlightsStage) GetLogs() string {
+	return s.logs
+}
@@ -0,0 +1,77 @@
+package stages
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+// fakeHighlightsFFmpeg 测量时输出 60 秒的响度（第 30～32 秒明显更响），截取时创建目标文件
+const fakeHighlightsFFmpeg = `#!/bin/sh
+for a; do last=$a; done
+if [ "$last" != "-" ]; then
+	: > "$last"
+	exit 0
+fi
+i=0
+while [ $i -lt 60 ]; do
+	v=-30
+	if [ $i -ge 30 ] && [ $i -lt 33 ]; then v=-10; fi
+	printf 'frame:%-4d pts:%-7d pts_time:%d\nlavfi.r128.M=%d.000\n' $i $((i*48000)) $i $v
+	i=$((i+1))
+done
+`
+
+func TestHighlightsStageTagsClips(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	require.NoError(t, os.WriteFile(ffmpeg, []byte(fakeHighlightsFFmpeg), 0o755))
+	video := filepath.Join(dir, "rec.mp4")
+	require.NoError(t, os.WriteFile(video, nil, 0o644))
+
+	stage, err := NewHighlightsStage(pipeline.StageConfig{Name: pipeline.StageNameHighlights, Options: map[string]any{
+		pipeline.OptionBaselineWindow: 10,
+		pipeline.OptionMinBaseline:    5,
+		pipeline.OptionPreRoll:        2,
+		pipeline.OptionPostRoll:       2,
+		pipeline.OptionClipTop:        1,
+	}})
+	require.NoError(t, err)
+	ctx := newWebhookTestContext()
+	ctx.FFmpegPath = ffmpeg
+
+	input := []pipeline.FileInfo{pipeline.NewVideoFileInfo(video)}
+	output, err := stage.Execute(ctx, input)
+	require.NoError(t, err)
+	require.Len(t, output, 3, stage.(*HighlightsStage).GetLogs())
+	assert.Equal(t, input[0], output[0])
+
+	// 截取的片段不是完整的录像，不能再作为视频交给后续阶段
+	clip := output[1]
+	assert.Equal(t, filepath.Join(dir, "rec.highlight_01.mp4"), clip.Path)
+	assert.Equal(t, pipeline.FileTypeOther, clip.Type)
+	assert.Equal(t, video, clip.SourcePath)
+	assert.Equal(t, 1, clip.Metadata["highlight"])
+	assert.FileExists(t, clip.Path)
+
+	report := output[2]
+	assert.Equal(t, filepath.Join(dir, "rec.highlights.json"), report.Path)
+	assert.Equal(t, pipeline.FileTypeOther, report.Type)
+	content, err := os.ReadFile(report.Path)
+	require.NoError(t, err)
+	var parsed highlightsReport
+	require.NoError(t, json.Unmarshal(content, &parsed))
+	require.Len(t, parsed.Highlights, 1)
+	assert.Equal(t, 30.0, parsed.Highlights[0].Peak)
+	assert.Equal(t, []string{"rec.highlight_01.mp4"}, parsed.Clips)
+}
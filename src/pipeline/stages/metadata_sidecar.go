@@ -0,0 +1,323 @@
+package stages
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+const (
+	metadataFormatNFO  = "nfo"
+	metadataFormatJSON = "json"
+
+	defaultMetadataTitleTemplate = `{{ .HostName }} - {{ .RoomName }} ({{ .StartTime.Format "2006-01-02 15:04" }})`
+)
+
+// MetadataSidecarStage 媒体库元数据阶段
+// 为每个视频文件在同目录下生成同名的 .nfo（Kodi 格式，Jellyfin/Plex 插件可读取）和 .json 文件，
+// 封面取 extract_cover 为该视频生成的图片
+type MetadataSidecarStage struct {
+	config    pipeline.StageConfig
+	formats   []string
+	titleTmpl *template.Template
+	nfoTmpl   *template.Template // 为空时使用默认格式
+	jsonTmpl  *template.Template // 为空时使用默认格式
+	commands  []string
+	logs      string
+}
+
+// metadataData 元数据模板可以引用的字段
+type metadataData struct {
+	Title     string // 渲染后的标题，标题模板中为空
+	LiveID    string
+	Platform  string
+	HostName  string
+	RoomName  string
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  float64 // 秒
+	Runtime   int     // 分钟，向上取整
+	SessionID int64   // 开播会话ID，0 表示未知
+	Poster    string  // 封面文件名（相对于视频所在目录），没有封面时为空
+	FileName  string
+	Path      string
+}
+
+// metadataJSON 未配置模板时的 JSON 格式
+type metadataJSON struct {
+	Title     string    `json:"title"`
+	LiveID    string    `json:"live_id"`
+	Platform  string    `json:"platform"`
+	HostName  string    `json:"host_name"`
+	RoomName  string    `json:"room_name"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Duration  float64   `json:"duration"`
+	SessionID int64     `json:"session_id,omitempty"`
+	Poster    string    `json:"poster,omitempty"`
+	FileName  string    `json:"file_name"`
+}
+
+// nfoMovie 未配置模板时的 NFO 格式，参照 Kodi 的 movie.nfo
+type nfoMovie struct {
+	XMLName   xml.Name `xml:"movie"`
+	Title     string   `xml:"title"`
+	Plot      string   `xml:"plot"`
+	Studio    string   `xml:"studio"`
+	Premiered string   `xml:"premiered"`
+	DateAdded string   `xml:"dateadded"`
+	Runtime   int      `xml:"runtime,omitempty"`
+	Genre     string   `xml:"genre"`
+	Tag       string   `xml:"tag"`
+	Actor     struct {
+		Name string `xml:"name"`
+		Role string `xml:"role"`
+	} `xml:"actor"`
+	Thumb    *nfoThumb    `xml:"thumb,omitempty"`
+	UniqueID *nfoUniqueID `xml:"uniqueid,omitempty"`
+}
+
+type nfoThumb struct {
+	Aspect string `xml:"aspect,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type nfoUniqueID struct {
+	Type  string `xml:"type,attr"`
+	Value int64  `xml:",chardata"`
+}
+
+// NewMetadataSidecarStage 创建媒体库元数据阶段工厂
+func NewMetadataSidecarStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	s := &MetadataSidecarStage{
+		config:  config,
+		formats: config.GetStringSliceOption(pipeline.OptionFormats),
+	}
+	if len(s.formats) == 0 {
+		s.formats = []string{metadataFormatNFO, metadataFormatJSON}
+	}
+	for i, format := range s.formats {
+		s.formats[i] = strings.ToLower(format)
+		if s.formats[i] != metadataFormatNFO && s.formats[i] != metadataFormatJSON {
+			return nil, fmt.Errorf("metadata_sidecar: unknown format %q", format)
+		}
+	}
+
+	var err error
+	if s.titleTmpl, err = parseMetadataTemplate("title", config.GetStringOption(pipeline.OptionTitleTemplate, defaultMetadataTitleTemplate)); err != nil {
+		return nil, err
+	}
+	if text := config.GetStringOption(pipeline.OptionNFOTemplate, ""); text != "" {
+		if s.nfoTmpl, err = parseMetadataTemplate("nfo", text); err != nil {
+			return nil, err
+		}
+	}
+	if text := config.GetStringOption(pipeline.OptionJSONTemplate, ""); text != "" {
+		if s.jsonTmpl, err = parseMetadataTemplate("json", text); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseMetadataTemplate 解析模板，额外提供 xmlEscape 函数用于 NFO 模板
+func parseMetadataTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).
+		Funcs(utils.GetFuncMap(pipeline.TemplateConfig())).
+		Funcs(template.FuncMap{"xmlEscape": xmlEscape}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("metadata_sidecar: invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func (s *MetadataSidecarStage) Name() string {
+	return pipeline.StageNameMetadata
+}
+
+func (s *MetadataSidecarStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	// extract_cover 生成的封面以 SourcePath 指向对应的视频
+	posters := make(map[string]string)
+	for _, file := range input {
+		if file.Type == pipeline.FileTypeCover && file.SourcePath != "" {
+			posters[file.SourcePath] = file.Path
+		}
+	}
+
+	output := append([]pipeline.FileInfo{}, input...)
+	first := true
+	for _, file := range input {
+		if file.Type != pipeline.FileTypeVideo {
+			continue
+		}
+		stat, err := os.Stat(file.Path)
+		if err != nil {
+			s.logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			continue
+		}
+
+		data := s.buildData(ctx, file, stat, posters[file.Path], first)
+		first = false
+		var title bytes.Buffer
+		if err := s.titleTmpl.Execute(&title, data); err != nil {
+			return nil, fmt.Errorf("failed to execute title template: %w", err)
+		}
+		data.Title = strings.TrimSpace(title.String())
+
+		base := strings.TrimSuffix(file.Path, filepath.Ext(file.Path))
+		for _, format := range s.formats {
+			content, err := s.render(format, data)
+			if err != nil {
+				s.logs += fmt.Sprintf("生成 %s 失败: %s\n", format, err)
+				return nil, err
+			}
+			path := base + "." + format
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				s.logs += fmt.Sprintf("写入失败: %s: %s\n", path, err)
+				return nil, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			s.commands = append(s.commands, fmt.Sprintf("write %s", path))
+			s.logs += fmt.Sprintf("元数据已保存: %s\n", filepath.Base(path))
+			output = append(output, pipeline.FileInfo{
+				Path:       path,
+				Type:       pipeline.FileTypeOther,
+				SourcePath: file.Path,
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// buildData 收集视频的元数据
+// 录制开始时间只属于第一个视频文件，以它为开始时间、加上时长作为结束时间；
+// 其余文件（如录播姬的分段）和缺少开始时间的任务以文件修改时间作为结束时间倒推
+func (s *MetadataSidecarStage) buildData(ctx *pipeline.PipelineContext, file pipeline.FileInfo, stat os.FileInfo, poster string, first bool) metadataData {
+	data := metadataData{
+		LiveID:    string(ctx.RecordInfo.LiveID),
+		Platform:  ctx.RecordInfo.Platform,
+		HostName:  ctx.RecordInfo.HostName,
+		RoomName:  ctx.RecordInfo.RoomName,
+		SessionID: ctx.SessionID,
+		FileName:  filepath.Base(file.Path),
+		Path:      file.Path,
+	}
+	if poster != "" {
+		data.Poster = filepath.Base(poster)
+	}
+	if probed, err := streamprobe.ProbeFile(file.Path); err == nil {
+		data.Duration = probed.Duration.Seconds()
+	} else {
+		ctx.Logger.Debugf("探测视频时长失败: %s - %s", file.Path, err)
+	}
+	duration := time.Duration(data.Duration * float64(time.Second))
+	if data.Duration > 0 {
+		data.Runtime = int((data.Duration + 59) / 60)
+	}
+
+	if first && !ctx.RecordInfo.StartTime.IsZero() {
+		data.StartTime = ctx.RecordInfo.StartTime
+		data.EndTime = data.StartTime.Add(duration)
+		if duration <= 0 {
+			data.EndTime = stat.ModTime()
+		}
+	} else {
+		data.EndTime = stat.ModTime()
+		data.StartTime = data.EndTime.Add(-duration)
+	}
+	return data
+}
+
+// render 生成指定格式的文件内容
+func (s *MetadataSidecarStage) render(format string, data metadataData) ([]byte, error) {
+	tmpl := s.nfoTmpl
+	if format == metadataFormatJSON {
+		tmpl = s.jsonTmpl
+	}
+	if tmpl != nil {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to execute %s template: %w", format, err)
+		}
+		if format == metadataFormatJSON && !json.Valid(buf.Bytes()) {
+			return nil, fmt.Errorf("json template did not produce valid JSON")
+		}
+		return buf.Bytes(), nil
+	}
+
+	if format == metadataFormatJSON {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(metadataJSON{
+			Title:     data.Title,
+			LiveID:    data.LiveID,
+			Platform:  data.Platform,
+			HostName:  data.HostName,
+			RoomName:  data.RoomName,
+			StartTime: data.StartTime,
+			EndTime:   data.EndTime,
+			Duration:  data.Duration,
+			SessionID: data.SessionID,
+			Poster:    data.Poster,
+			FileName:  data.FileName,
+		})
+		return buf.Bytes(), err
+	}
+
+	movie := nfoMovie{
+		Title:     data.Title,
+		Plot:      data.RoomName,
+		Studio:    data.Platform,
+		Premiered: data.StartTime.Format("2006-01-02"),
+		DateAdded: data.EndTime.Format("2006-01-02 15:04:05"),
+		Runtime:   data.Runtime,
+		Genre:     "直播录像",
+		Tag:       data.HostName,
+	}
+	movie.Actor.Name = data.HostName
+	movie.Actor.Role = "主播"
+	if data.Poster != "" {
+		movie.Thumb = &nfoThumb{Aspect: "poster", Value: data.Poster}
+	}
+	if data.SessionID > 0 {
+		movie.UniqueID = &nfoUniqueID{Type: "bililive_session", Value: data.SessionID}
+	}
+	body, err := xml.MarshalIndent(movie, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// xmlEscape 转义 XML 特殊字符
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func (s *MetadataSidecarStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *MetadataSidecarStage) GetLogs() string {
+	return s.logs
+}
@@ -0,0 +1,149 @@
+package stages
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+func newMetadataTestStage(t *testing.T, options map[string]any) pipeline.Stage {
+	t.Helper()
+	stage, err := NewMetadataSidecarStage(pipeline.StageConfig{Name: pipeline.StageNameMetadata, Options: options})
+	require.NoError(t, err)
+	return stage
+}
+
+func TestMetadataSidecarDefaultOutput(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	part := filepath.Join(dir, "rec_PART001.flv")
+	writeTestFLV(t, video)
+	writeTestFLV(t, part)
+	partModTime := time.Date(2026, 10, 18, 23, 0, 0, 0, time.Local)
+	require.NoError(t, os.Chtimes(part, partModTime, partModTime))
+
+	ctx := newWebhookTestContext()
+	start := time.Date(2026, 10, 18, 20, 0, 0, 0, time.Local)
+	ctx.RecordInfo.StartTime = start
+	ctx.SessionID = 42
+	input := []pipeline.FileInfo{
+		pipeline.NewVideoFileInfo(video),
+		pipeline.NewCoverFileInfo(filepath.Join(dir, "rec.jpg"), video),
+		pipeline.NewVideoFileInfo(part),
+	}
+
+	output, err := newMetadataTestStage(t, nil).Execute(ctx, input)
+	require.NoError(t, err)
+	require.Len(t, output, 7)
+	assert.Equal(t, input, output[:3])
+	for i, want := range []string{"rec.nfo", "rec.json", "rec_PART001.nfo", "rec_PART001.json"} {
+		assert.Equal(t, filepath.Join(dir, want), output[3+i].Path)
+		assert.Equal(t, pipeline.FileTypeOther, output[3+i].Type)
+	}
+	assert.Equal(t, video, output[3].SourcePath)
+	assert.Equal(t, part, output[5].SourcePath)
+
+	// 第一个文件从录制开始时间算起
+	var meta metadataJSON
+	content, err := os.ReadFile(filepath.Join(dir, "rec.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &meta))
+	assert.Equal(t, "主播 - 房间 (2026-10-18 20:00)", meta.Title)
+	assert.Equal(t, "abc", meta.LiveID)
+	assert.Equal(t, "哔哩哔哩", meta.Platform)
+	assert.True(t, start.Equal(meta.StartTime), meta.StartTime)
+	assert.True(t, start.Add(90*time.Second).Equal(meta.EndTime), meta.EndTime)
+	assert.Equal(t, 90.0, meta.Duration)
+	assert.Equal(t, int64(42), meta.SessionID)
+	assert.Equal(t, "rec.jpg", meta.Poster)
+	assert.Equal(t, "rec.flv", meta.FileName)
+
+	var movie nfoMovie
+	content, err = os.ReadFile(filepath.Join(dir, "rec.nfo"))
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(content, &movie))
+	assert.Equal(t, "主播 - 房间 (2026-10-18 20:00)", movie.Title)
+	assert.Equal(t, "房间", movie.Plot)
+	assert.Equal(t, "哔哩哔哩", movie.Studio)
+	assert.Equal(t, "2026-10-18", movie.Premiered)
+	assert.Equal(t, "2026-10-18 20:01:30", movie.DateAdded)
+	assert.Equal(t, 2, movie.Runtime)
+	assert.Equal(t, "主播", movie.Actor.Name)
+	require.NotNil(t, movie.Thumb)
+	assert.Equal(t, nfoThumb{Aspect: "poster", Value: "rec.jpg"}, *movie.Thumb)
+	require.NotNil(t, movie.UniqueID)
+	assert.Equal(t, int64(42), movie.UniqueID.Value)
+
+	// 之后的分段以文件修改时间作为结束时间
+	content, err = os.ReadFile(filepath.Join(dir, "rec_PART001.json"))
+	require.NoError(t, err)
+	meta = metadataJSON{}
+	require.NoError(t, json.Unmarshal(content, &meta))
+	assert.True(t, partModTime.Equal(meta.EndTime), meta.EndTime)
+	assert.True(t, partModTime.Add(-90*time.Second).Equal(meta.StartTime), meta.StartTime)
+	assert.Empty(t, meta.Poster)
+}
+
+func TestMetadataSidecarTemplates(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+	ctx := newWebhookTestContext()
+	ctx.RecordInfo.RoomName = "A & B"
+	ctx.RecordInfo.StartTime = time.Date(2026, 10, 18, 20, 0, 0, 0, time.Local)
+
+	stage := newMetadataTestStage(t, map[string]any{
+		pipeline.OptionFormats:       []any{"NFO", "json"},
+		pipeline.OptionTitleTemplate: `{{ .RoomName }}`,
+		pipeline.OptionNFOTemplate:   `<movie><title>{{ xmlEscape .Title }}</title><runtime>{{ .Runtime }}</runtime></movie>`,
+		pipeline.OptionJSONTemplate:  `{"title": {{ printf "%q" .Title }}, "start": "{{ .StartTime.Format "15:04" }}", "duration": {{ .Duration }}}`,
+	})
+	output, err := stage.Execute(ctx, []pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, output, 3)
+
+	content, err := os.ReadFile(filepath.Join(dir, "rec.nfo"))
+	require.NoError(t, err)
+	assert.Equal(t, `<movie><title>A &amp; B</title><runtime>2</runtime></movie>`, string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "rec.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title": "A & B", "start": "20:00", "duration": 90}`, string(content))
+
+	// 只输出指定的格式
+	require.NoError(t, os.Remove(filepath.Join(dir, "rec.nfo")))
+	stage = newMetadataTestStage(t, map[string]any{pipeline.OptionFormats: []any{"json"}})
+	output, err = stage.Execute(ctx, []pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, output, 2)
+	assert.NoFileExists(t, filepath.Join(dir, "rec.nfo"))
+
+	// JSON 模板必须生成合法的 JSON
+	stage = newMetadataTestStage(t, map[string]any{
+		pipeline.OptionFormats:      []any{"json"},
+		pipeline.OptionJSONTemplate: `{"title": "{{ .Title }}"`,
+	})
+	_, err = stage.Execute(ctx, []pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	assert.Error(t, err)
+}
+
+func TestNewMetadataSidecarStageInvalidOptions(t *testing.T) {
+	for name, options := range map[string]map[string]any{
+		"未知格式":     {pipeline.OptionFormats: []any{"yaml"}},
+		"标题模板无效":   {pipeline.OptionTitleTemplate: `{{ .HostName`},
+		"NFO模板无效":  {pipeline.OptionNFOTemplate: `{{ end }}`},
+		"JSON模板无效": {pipeline.OptionJSONTemplate: `{{ .Missing`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewMetadataSidecarStage(pipeline.StageConfig{Name: pipeline.StageNameMetadata, Options: options})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package stages
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	davserver "golang.org/x/net/webdav"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+// runConfiguredPipeline 按 YAML 配置解析直播间的录制完成动作，用内置阶段执行得到的管道
+func runConfiguredPipeline(t *testing.T, config, roomURL string, ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.StageResult, error) {
+	t.Helper()
+	cfg, err := configs.NewConfigWithBytes([]byte(config))
+	require.NoError(t, err)
+	require.NoError(t, ValidateRecordFinishedConfig(cfg))
+
+	resolved := cfg.GetEffectiveConfigForRoom(roomURL)
+	executor := pipeline.NewExecutor(nil)
+	RegisterBuiltinStages(executor)
+	return executor.Execute(ctx, pipeline.GetEffectivePipelineConfig(&resolved.OnRecordFinished), input, nil)
+}
+
+func TestConfiguredPipelineConditionsAndDependencies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("custom_command uses sh")
+	}
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	require.NoError(t, os.WriteFile(video, []byte("flv"), 0o644))
+
+	config := `
+on_record_finished:
+  custom_commandline: 'echo legacy >> "{{ .Dir }}/log.txt"'
+live_rooms:
+  - url: https://live.bilibili.com/1
+    on_record_finished:
+      pipeline:
+        - id: small
+          name: custom_command
+          when: lt .Size (kb 1)
+          options: {command: 'echo small >> "{{ .Dir }}/log.txt"'}
+        - id: big
+          name: custom_command
+          when: ge .Size (kb 1)
+          options: {command: 'echo big >> "{{ .Dir }}/log.txt"'}
+        - name: custom_command
+          depends_on: [small]
+          retries: 1
+          options: {command: 'echo after >> "{{ .Dir }}/log.txt"'}
+`
+	ctx := newWebhookTestContext()
+	ctx.FFmpegPath = "ffmpeg" // 避免渲染命令时查找 ffmpeg
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", ctx,
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+
+	require.Len(t, results, 3)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status)
+	assert.Equal(t, pipeline.StageStatusSkipped, results[1].Status)
+	assert.Contains(t, results[1].Logs, "when: ge .Size (kb 1) => false")
+	assert.Equal(t, pipeline.StageStatusCompleted, results[2].Status)
+	assert.Equal(t, []string{"small"}, results[2].DependsOn)
+
+	log, err := os.ReadFile(filepath.Join(dir, "log.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "small\nafter\n", string(log))
+}
+
+func TestConfiguredPipelineThumbnails(t *testing.T) {
+	// 抽帧时写出三张缩略图，拼接雪碧图时创建目标文件
+	ffmpeg := writeFakeFFmpeg(t, `#!/bin/sh
+for a; do last=$a; done
+case "$last" in
+*%05d.jpg) for i in 1 2 3; do : > "$(dirname "$last")/0000$i.jpg"; done ;;
+*) : > "$last" ;;
+esac
+`)
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+
+	config := `
+on_record_finished:
+  pipeline:
+    - name: thumbnails
+      options: {interval: 30, width: 160, columns: 2, rows: 2}
+`
+	ctx := newWebhookTestContext()
+	ctx.FFmpegPath = ffmpeg
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", ctx,
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	vtt, err := os.ReadFile(filepath.Join(dir, "rec.thumbnails.vtt"))
+	require.NoError(t, err)
+	assert.Equal(t, "WEBVTT\n"+
+		"\n00:00:00.000 --> 00:00:30.000\nrec.sprite.jpg#xywh=0,0,160,90\n"+
+		"\n00:00:30.000 --> 00:01:00.000\nrec.sprite.jpg#xywh=160,0,160,90\n"+
+		"\n00:01:00.000 --> 00:01:30.000\nrec.sprite.jpg#xywh=0,90,160,90\n", string(vtt))
+	assert.FileExists(t, filepath.Join(dir, "rec.sprite.jpg"))
+}
+
+func TestConfiguredPipelineHighlights(t *testing.T) {
+	ffmpeg := writeFakeFFmpeg(t, fakeHighlightsFFmpeg)
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.mp4")
+	require.NoError(t, os.WriteFile(video, nil, 0o644))
+
+	// 平台级配置的管道
+	config := `
+platform_configs:
+  bilibili:
+    on_record_finished:
+      pipeline:
+        - name: highlights
+          options: {baseline_window: 10, min_baseline: 5, pre_roll: 2, post_roll: 2, clip_top: 1}
+`
+	ctx := newWebhookTestContext()
+	ctx.FFmpegPath = ffmpeg
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", ctx,
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	var paths []string
+	for _, file := range results[0].OutputFiles {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{
+		video,
+		filepath.Join(dir, "rec.highlight_01.mp4"),
+		filepath.Join(dir, "rec.highlights.json"),
+	}, paths)
+	assert.FileExists(t, filepath.Join(dir, "rec.highlights.json"))
+}
+
+func TestConfiguredPipelineTranscode(t *testing.T) {
+	ffmpeg := writeFakeFFmpeg(t, fakeTranscodeFFmpeg)
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+
+	// 直播间级配置的管道
+	config := `
+live_rooms:
+  - url: https://live.bilibili.com/1
+    on_record_finished:
+      pipeline:
+        - name: transcode
+          when: lt .Size (mb 1)
+          options: {preset: hevc_archive, crf: 24}
+`
+	ctx := newWebhookTestContext()
+	ctx.FFmpegPath = ffmpeg
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", ctx,
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	dst := filepath.Join(dir, "rec.hevc.mp4")
+	require.Len(t, results[0].OutputFiles, 2)
+	assert.Equal(t, dst, results[0].OutputFiles[0].Path)
+	assert.FileExists(t, dst)
+	require.Len(t, results[0].Commands, 1)
+	assert.Contains(t, results[0].Commands[0], "-c:v libx265 -preset medium -crf 24")
+}
+
+func TestConfiguredPipelineWebhook(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	config := `
+on_record_finished:
+  pipeline:
+    - name: webhook
+      options:
+        urls: [` + server.URL + `]
+        secret: secret
+        headers: {Authorization: Bearer token}
+        body_template: '{"host": {{ toJson .HostName }}, "file": {{ toJson .File.Path }}}'
+`
+	input := []pipeline.FileInfo{pipeline.NewVideoFileInfo("/rec/a.mp4")}
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", newWebhookTestContext(), input)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+	assert.Equal(t, input, results[0].OutputFiles)
+
+	assert.JSONEq(t, `{"host": "主播", "file": "/rec/a.mp4"}`, string(body))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Equal(t, signWebhookBody("secret", body), header.Get(defaultWebhookSignatureHeader))
+}
+
+func TestConfiguredPipelineS3Upload(t *testing.T) {
+	// 只实现简单上传和 HEAD 的 S3 服务
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	storageClasses := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			storageClasses[r.URL.Path] = r.Header.Get("X-Amz-Storage-Class")
+			sum := md5.Sum(body)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		case http.MethodHead:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sum := md5.Sum(body)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+	data, err := os.ReadFile(video)
+	require.NoError(t, err)
+
+	config := `
+on_record_finished:
+  pipeline:
+    - name: s3_upload
+      options:
+        endpoint: ` + server.URL + `
+        bucket: archive
+        access_key: key
+        secret_key: secret
+        storage_class: STANDARD_IA
+        path_template: '/{{ .HostName }}/{{ .FileName }}'
+`
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", newWebhookTestContext(),
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	assert.Equal(t, data, objects["/archive/主播/rec.flv"])
+	assert.Equal(t, "STANDARD_IA", storageClasses["/archive/主播/rec.flv"])
+	require.Len(t, results[0].OutputFiles, 1)
+	assert.Equal(t, "主播/rec.flv", results[0].OutputFiles[0].Metadata["s3_key"])
+	assert.FileExists(t, video)
+}
+
+func TestConfiguredPipelineWebDAVUpload(t *testing.T) {
+	fs := davserver.NewMemFS()
+	handler := &davserver.Handler{Prefix: "/dav", FileSystem: fs, LockSystem: davserver.NewMemLS()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+	data, err := os.ReadFile(video)
+	require.NoError(t, err)
+
+	config := `
+on_record_finished:
+  pipeline:
+    - name: webdav_upload
+      options:
+        url: ` + server.URL + `/dav/
+        username: user
+        password: pass
+        delete_after: true
+`
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", newWebhookTestContext(),
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	// 默认路径：/录播归档/{平台}/{主播名}/{文件名}
+	remote, err := fs.OpenFile(context.Background(), "/录播归档/哔哩哔哩/主播/rec.flv", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer remote.Close()
+	uploaded, err := io.ReadAll(remote)
+	require.NoError(t, err)
+	assert.Equal(t, data, uploaded)
+	assert.Empty(t, results[0].OutputFiles)
+	assert.NoFileExists(t, video)
+}
+
+func TestConfiguredPipelineMetadataSidecar(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+
+	config := `
+on_record_finished:
+  pipeline:
+    - name: metadata_sidecar
+      options:
+        formats: [json]
+        title_template: '{{ .HostName }} {{ .StartTime.Format "2006-01-02" }}'
+`
+	ctx := newWebhookTestContext()
+	ctx.RecordInfo.StartTime = time.Date(2026, 10, 18, 20, 0, 0, 0, time.Local)
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", ctx,
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	sidecar := filepath.Join(dir, "rec.json")
+	require.Len(t, results[0].OutputFiles, 2)
+	assert.Equal(t, sidecar, results[0].OutputFiles[1].Path)
+	assert.NoFileExists(t, filepath.Join(dir, "rec.nfo"))
+
+	var meta metadataJSON
+	content, err := os.ReadFile(sidecar)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &meta))
+	assert.Equal(t, "主播 2026-10-18", meta.Title)
+	assert.Equal(t, 90.0, meta.Duration)
+}
+
+func TestConfiguredPipelineDanmakuASS(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+	// 录播姬格式的弹幕文件与录像同名
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rec.xml"), []byte(`<?xml version="1.0" encoding="utf-8"?>
+<i>
+<d p="1.5,1,25,16777215,1704110401500,0,a1b2c3,0" user="观众A">第一条</d>
+<d p="2,1,25,16777215,1704110402000,0,a1b2c4,0" user="观众B">广告消息</d>
+</i>`), 0o644))
+
+	config := `
+on_record_finished:
+  pipeline:
+    - name: danmaku_ass
+      options: {blocked_words: [广告]}
+`
+	results, err := runConfiguredPipeline(t, config, "https://live.bilibili.com/1", newWebhookTestContext(),
+		[]pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, pipeline.StageStatusCompleted, results[0].Status, results[0].Logs)
+
+	assPath := filepath.Join(dir, "rec.ass")
+	require.Len(t, results[0].OutputFiles, 2)
+	assert.Equal(t, pipeline.FileInfo{Path: assPath, Type: pipeline.FileTypeOther, SourcePath: video}, results[0].OutputFiles[1])
+	assert.Contains(t, results[0].Logs, "共 2 条，显示 1 条，过滤 1 条")
+
+	ass, err := os.ReadFile(assPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(ass), "第一条")
+	assert.NotContains(t, string(ass), "广告消息")
+}
@@ -1,22 +1,63 @@
 // Package stages 提供内置的 Pipeline 阶段实现
 package stages
 
-import "github.com/bililive-go/bililive-go/src/pipeline"
+import (
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+// ValidateRecordFinishedConfig 用内置阶段检查配置中录制完成动作对应的管道
+// 在加载和保存配置时调用，阶段名称、执行条件和依赖关系的错误不必等到录制结束才暴露
+func ValidateRecordFinishedConfig(cfg *configs.Config) error {
+	executor := pipeline.NewExecutor(nil)
+	RegisterBuiltinStages(executor)
+	return executor.ValidateRecordFinished(cfg)
+}
 
 // RegisterBuiltinStages 注册所有内置阶段到执行器
 func RegisterBuiltinStages(executor *pipeline.Executor) {
 	// FLV 修复
 	executor.RegisterStage(pipeline.StageNameFixFlv, NewFixFlvStage)
 
+	// 会话合并
+	executor.RegisterStage(pipeline.StageNameSessionMerge, NewSessionMergeStage)
+
 	// MP4 转换
 	executor.RegisterStage(pipeline.StageNameConvertMp4, NewConvertMp4Stage)
 
+	// 转码
+	executor.RegisterStage(pipeline.StageNameTranscode, NewTranscodeStage)
+
 	// 封面提取
 	executor.RegisterStage(pipeline.StageNameExtractCover, NewExtractCoverStage)
 
+	// 时间轴缩略图
+	executor.RegisterStage(pipeline.StageNameThumbnails, NewThumbnailsStage)
+
+	// 响度高光检测
+	executor.RegisterStage(pipeline.StageNameHighlights, NewHighlightsStage)
+
+	// 片段截取
+	executor.RegisterStage(pipeline.StageNameClip, NewClipStage)
+
 	// 云上传
 	executor.RegisterStage(pipeline.StageNameCloudUpload, NewCloudUploadStage)
 
+	// Webhook 通知
+	executor.RegisterStage(pipeline.StageNameWebhook, NewWebhookStage)
+
+	// S3 兼容存储上传
+	executor.RegisterStage(pipeline.StageNameS3Upload, NewS3UploadStage)
+
+	// WebDAV 上传
+	executor.RegisterStage(pipeline.StageNameWebDAVUpload, NewWebDAVUploadStage)
+
+	// 媒体库元数据（NFO/JSON）
+	executor.RegisterStage(pipeline.StageNameMetadata, NewMetadataSidecarStage)
+
+	// 弹幕转 ASS 字幕
+	executor.RegisterStage(pipeline.StageNameDanmakuASS, NewDanmakuASSStage)
+
 	// 自定义命令
 	executor.RegisterStage(pipeline.StageNameCustomCmd, NewCustomCommandStage)
 
@@ -32,15 +73,45 @@ func RegisterBuiltinStagesToManager(manager *pipeline.Manager) {
 	// FLV 修复
 	manager.RegisterStage(pipeline.StageNameFixFlv, NewFixFlvStage)
 
+	// 会话合并
+	manager.RegisterStage(pipeline.StageNameSessionMerge, NewSessionMergeStage)
+
 	// MP4 转换
 	manager.RegisterStage(pipeline.StageNameConvertMp4, NewConvertMp4Stage)
 
+	// 转码
+	manager.RegisterStage(pipeline.StageNameTranscode, NewTranscodeStage)
+
 	// 封面提取
 	manager.RegisterStage(pipeline.StageNameExtractCover, NewExtractCoverStage)
 
+	// 时间轴缩略图
+	manager.RegisterStage(pipeline.StageNameThumbnails, NewThumbnailsStage)
+
+	// 响度高光检测
+	manager.RegisterStage(pipeline.StageNameHighlights, NewHighlightsStage)
+
+	// 片段截取
+	manager.RegisterStage(pipeline.StageNameClip, NewClipStage)
+
 	// 云上传
 	manager.RegisterStage(pipeline.StageNameCloudUpload, NewCloudUploadStage)
 
+	// Webhook 通知
+	manager.RegisterStage(pipeline.StageNameWebhook, NewWebhookStage)
+
+	// S3 兼容存储上传
+	manager.RegisterStage(pipeline.StageNameS3Upload, NewS3UploadStage)
+
+	// WebDAV 上传
+	manager.RegisterStage(pipeline.StageNameWebDAVUpload, NewWebDAVUploadStage)
+
+	// 媒体库元数据（NFO/JSON）
+	manager.RegisterStage(pipeline.StageNameMetadata, NewMetadataSidecarStage)
+
+	// 弹幕转 ASS 字幕
+	manager.RegisterStage(pipeline.StageNameDanmakuASS, NewDanmakuASSStage)
+
 	// 自定义命令
 	manager.RegisterStage(pipeline.StageNameCustomCmd, NewCustomCommandStage)
 
@@ -0,0 +1,136 @@
+package stages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+func TestValidateRecordFinishedConfig(t *testing.T) {
+	cfg := configs.NewConfig()
+	assert.NoError(t, ValidateRecordFinishedConfig(cfg))
+
+	// 所有录制完成动作都启用时，转换出的阶段选项应当都有效
+	all := configs.OnRecordFinished{
+		ConvertToMp4:          true,
+		DeleteFlvAfterConvert: true,
+		CustomCommandline:     "echo {{ .FileName }}",
+		FixFlvAtFirst:         true,
+		SaveCover:             true,
+		CloudUpload: configs.CloudUpload{
+			Enable:         true,
+			StorageName:    "nas",
+			UploadPathTmpl: "/live/{{ .HostName }}",
+		},
+		SessionMerge: configs.SessionMerge{Enable: true},
+	}
+	cfg.LiveRooms = []configs.LiveRoom{{
+		Url:               "https://live.bilibili.com/1",
+		OverridableConfig: configs.OverridableConfig{OnRecordFinished: &all},
+	}}
+	cfg.RefreshLiveRoomIndexCache()
+	assert.NoError(t, ValidateRecordFinishedConfig(cfg))
+
+	all.Pipeline = []configs.PipelineStage{{Name: "nope"}}
+	err := ValidateRecordFinishedConfig(cfg)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "https://live.bilibili.com/1")
+	}
+}
+
+func TestValidateRecordFinishedConfigPipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr []string
+	}{
+		{
+			name: "有效的 DAG",
+			config: `
+on_record_finished:
+  pipeline:
+    - {id: fix, name: fix_flv}
+    - {name: thumbnails, depends_on: [fix], when: gt .Duration (minutes 10)}
+    - {name: metadata_sidecar, depends_on: [fix], retries: 2, retry_backoff: 1m}
+`,
+		},
+		{
+			name: "重复的 id",
+			config: `
+on_record_finished:
+  pipeline:
+    - {id: a, name: fix_flv}
+    - {id: a, name: thumbnails}
+`,
+			wantErr: []string{"录制完成动作配置无效", `have the same id "a"`},
+		},
+		{
+			name: "依赖不存在的阶段",
+			config: `
+platform_configs:
+  huya:
+    on_record_finished:
+      pipeline:
+        - {name: thumbnails, depends_on: [fix]}
+`,
+			wantErr: []string{"平台 'huya'", `depends on unknown stage "fix"`},
+		},
+		{
+			name: "依赖环",
+			config: `
+live_rooms:
+  - url: https://live.bilibili.com/1
+    on_record_finished:
+      pipeline:
+        - {id: a, name: fix_flv, depends_on: [b]}
+        - {id: b, name: thumbnails, depends_on: [a]}
+`,
+			wantErr: []string{"https://live.bilibili.com/1", "cycle"},
+		},
+		{
+			name: "未知阶段",
+			config: `
+on_record_finished:
+  pipeline:
+    - {name: upload_everything}
+`,
+			wantErr: []string{"unknown stage[0]: upload_everything"},
+		},
+		{
+			name: "执行条件无效",
+			config: `
+on_record_finished:
+  pipeline:
+    - {name: fix_flv, when: gt .Size (}
+`,
+			wantErr: []string{"invalid when expression"},
+		},
+		{
+			name: "重试配置无效",
+			config: `
+on_record_finished:
+  pipeline:
+    - {name: fix_flv, timeout: soon}
+`,
+			wantErr: []string{`invalid timeout "soon"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := configs.NewConfigWithBytes([]byte(tt.config))
+			require.NoError(t, err)
+			err = ValidateRecordFinishedConfig(cfg)
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
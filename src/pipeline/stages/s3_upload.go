@@ -0,0 +1,117 @@
+package stages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/s3"
+)
+
+const defaultS3PartSizeMB = 16
+
+// S3UploadStage S3 兼容存储上传阶段
+// 大文件使用分片上传，进度记录在源文件旁的隐藏文件中，任务重启后从已完成的分片继续
+type S3UploadStage struct {
+	config       pipeline.StageConfig
+	client       *s3.Client
+	pathTmpl     *template.Template
+	storageClass string
+	partSize     int64
+	deleteAfter  bool
+	fileTypes    []string // 过滤的文件类型，空表示所有
+	commands     []string
+	logs         string
+}
+
+// NewS3UploadStage 创建 S3 上传阶段工厂
+func NewS3UploadStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	accessKey := config.GetStringOption(pipeline.OptionAccessKey, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretKey := config.GetStringOption(pipeline.OptionSecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	client, err := s3.NewClient(s3.Config{
+		Endpoint:  config.GetStringOption(pipeline.OptionEndpoint, ""),
+		Region:    config.GetStringOption(pipeline.OptionRegion, ""),
+		Bucket:    config.GetStringOption(pipeline.OptionBucket, ""),
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: config.GetBoolOption(pipeline.OptionPathStyle, true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3_upload: %w", err)
+	}
+
+	partSize := int64(config.GetIntOption(pipeline.OptionPartSize, defaultS3PartSizeMB)) << 20
+	if partSize < s3.MinPartSize {
+		return nil, fmt.Errorf("s3_upload: part_size must be at least %d MB", s3.MinPartSize>>20)
+	}
+	pathTmpl, err := parseUploadPathTemplate(config.GetStringOption(pipeline.OptionPathTemplate, ""))
+	if err != nil {
+		return nil, fmt.Errorf("s3_upload: %w", err)
+	}
+	return &S3UploadStage{
+		config:       config,
+		client:       client,
+		pathTmpl:     pathTmpl,
+		storageClass: config.GetStringOption(pipeline.OptionStorageClass, ""),
+		partSize:     partSize,
+		deleteAfter:  config.GetBoolOption(pipeline.OptionDeleteAfter, false),
+		fileTypes:    config.GetStringSliceOption(pipeline.OptionFileTypes),
+	}, nil
+}
+
+func (s *S3UploadStage) Name() string {
+	return pipeline.StageNameS3Upload
+}
+
+func (s *S3UploadStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	job := uploadJob{
+		fileTypes:   s.fileTypes,
+		deleteAfter: s.deleteAfter,
+		scheme:      "s3://",
+		target: func(file pipeline.FileInfo) (string, error) {
+			path, err := renderUploadPath(s.pathTmpl, ctx, file)
+			return strings.TrimLeft(path, "/"), err
+		},
+		upload: func(file pipeline.FileInfo, key string, onProgress func(int64)) (string, error) {
+			result, err := s.client.UploadFile(ctx.Ctx, file.Path, key, s3.UploadOptions{
+				StorageClass: s.storageClass,
+				PartSize:     s.partSize,
+				StatePath:    filepath.Join(filepath.Dir(file.Path), "."+filepath.Base(file.Path)+".s3upload"),
+				OnProgress:   func(uploaded, _ int64) { onProgress(uploaded) },
+			})
+			if err != nil {
+				return "", err
+			}
+			if result.ResumedParts > 0 {
+				return fmt.Sprintf("%s（%d 个分片，续传 %d 个）", key, result.Parts, result.ResumedParts), nil
+			}
+			return key, nil
+		},
+		annotate: func(file pipeline.FileInfo, key string) pipeline.FileInfo {
+			metadata := make(map[string]any, len(file.Metadata)+1)
+			for k, v := range file.Metadata {
+				metadata[k] = v
+			}
+			metadata["s3_key"] = key
+			file.Metadata = metadata
+			return file
+		},
+	}
+	return job.run(ctx, input, &s.commands, &s.logs)
+}
+
+func (s *S3UploadStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *S3UploadStage) GetLogs() string {
+	return s.logs
+}
@@ -0,0 +1,231 @@
+package stages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// mergeDurationTolerance 合并后时长低于分段总时长的该比例时视为合并不完整，不删除分段
+const mergeDurationTolerance = 0.98
+
+// SessionMergeStage 会话合并阶段
+// 把同一场直播的分段按录制顺序以流复制方式拼接，只有封装、编码和分辨率都相同的相邻分段才会合并，
+// 不兼容的分段（例如中途切换了清晰度）保持独立，后续阶段处理合并后的文件
+type SessionMergeStage struct {
+	config       pipeline.StageConfig
+	deleteSource bool
+	commands     []string
+	logs         string
+}
+
+// mergeSegment 待合并的分段
+type mergeSegment struct {
+	file     pipeline.FileInfo
+	key      string  // 兼容性标识，为空表示无法探测，不与其他分段合并
+	duration float64 // 秒，无法得知时为 0
+}
+
+// NewSessionMergeStage 创建会话合并阶段工厂
+func NewSessionMergeStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	return &SessionMergeStage{
+		config:       config,
+		deleteSource: config.GetBoolOption(pipeline.OptionDeleteSource, false),
+	}, nil
+}
+
+func (s *SessionMergeStage) Name() string {
+	return pipeline.StageNameSessionMerge
+}
+
+func (s *SessionMergeStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	var output []pipeline.FileInfo
+	var segments []mergeSegment
+	for _, file := range input {
+		if file.Type != pipeline.FileTypeVideo {
+			output = append(output, file)
+			continue
+		}
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			s.logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			continue
+		}
+		segments = append(segments, probeMergeSegment(file))
+	}
+
+	// 相邻且兼容的分段归为一组
+	var groups [][]mergeSegment
+	for _, seg := range segments {
+		if n := len(groups); n > 0 && seg.key != "" && groups[n-1][0].key == seg.key {
+			groups[n-1] = append(groups[n-1], seg)
+			continue
+		}
+		groups = append(groups, []mergeSegment{seg})
+	}
+
+	var ffmpegPath string
+	for _, group := range groups {
+		if len(group) == 1 {
+			output = append(output, group[0].file)
+			continue
+		}
+		if ffmpegPath == "" {
+			var err error
+			if ffmpegPath, err = s.ffmpegPath(ctx); err != nil {
+				return nil, err
+			}
+		}
+		merged, err := s.merge(ctx, ffmpegPath, group)
+		if err != nil {
+			s.logs += fmt.Sprintf("合并失败: %s\n", err.Error())
+			return nil, err
+		}
+		output = append(output, merged)
+	}
+
+	if len(groups) > 1 {
+		s.logs += fmt.Sprintf("%d 个分段中有不兼容的分段，合并为 %d 个文件\n", len(segments), len(groups))
+	}
+	return output, nil
+}
+
+func (s *SessionMergeStage) ffmpegPath(ctx *pipeline.PipelineContext) (string, error) {
+	if ctx.FFmpegPath != "" {
+		return ctx.FFmpegPath, nil
+	}
+	ffmpegPath, err := utils.GetFFmpegPath(ctx.Ctx)
+	if err != nil {
+		s.logs = fmt.Sprintf("ffmpeg 不可用: %s", err.Error())
+		return "", fmt.Errorf("ffmpeg not available: %w", err)
+	}
+	return ffmpegPath, nil
+}
+
+// probeMergeSegment 探测分段的编码信息，生成兼容性标识
+func probeMergeSegment(file pipeline.FileInfo) mergeSegment {
+	seg := mergeSegment{file: file}
+	probed, err := streamprobe.ProbeFile(file.Path)
+	if err != nil || probed.Unsupported {
+		return seg
+	}
+	seg.key = strings.Join([]string{
+		strings.ToLower(filepath.Ext(file.Path)),
+		probed.VideoCodec,
+		probed.AudioCodec,
+		probed.Resolution(),
+	}, "|")
+	seg.duration = probed.Duration.Seconds()
+	return seg
+}
+
+// merge 用 concat 分离器无损拼接一组分段，输出文件以第一个分段命名
+func (s *SessionMergeStage) merge(ctx *pipeline.PipelineContext, ffmpegPath string, group []mergeSegment) (pipeline.FileInfo, error) {
+	first := group[0].file.Path
+	ext := filepath.Ext(first)
+	dst := strings.TrimSuffix(first, ext) + "_merged" + ext
+	tempFile := filepath.Join(filepath.Dir(dst), ".merging_"+filepath.Base(dst))
+	listFile := tempFile + ".txt"
+	defer os.Remove(listFile)
+
+	var list strings.Builder
+	var total float64
+	for _, seg := range group {
+		abs, err := filepath.Abs(seg.file.Path)
+		if err != nil {
+			return pipeline.FileInfo{}, err
+		}
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+		total += seg.duration
+	}
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return pipeline.FileInfo{}, err
+	}
+
+	args := []string{
+		"-f", "concat", "-safe", "0",
+		"-i", listFile,
+		"-map", "0",
+		"-c", "copy",
+	}
+	if strings.EqualFold(ext, ".mp4") {
+		args = append(args, "-movflags", "+faststart")
+	}
+	args = append(args, "-y", tempFile)
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+
+	ctx.Logger.Infof("合并 %d 个分段: %s", len(group), dst)
+	err := runFFmpegWithProgress(ctx, ffmpegPath, args, func(sec float64) {
+		if total > 0 {
+			ctx.ReportProgress(sec * 100 / total)
+		}
+	})
+	if err != nil {
+		os.Remove(tempFile)
+		return pipeline.FileInfo{}, err
+	}
+	if err := os.Rename(tempFile, dst); err != nil {
+		os.Remove(tempFile)
+		return pipeline.FileInfo{}, fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	s.logs += fmt.Sprintf("已合并 %d 个分段: %s\n", len(group), filepath.Base(dst))
+	ctx.Logger.Infof("已合并 %d 个分段: %s", len(group), dst)
+
+	if s.deleteSource {
+		s.deleteSegments(ctx, dst, total, group)
+	}
+
+	return pipeline.FileInfo{
+		Path:       dst,
+		Type:       pipeline.FileTypeVideo,
+		SourcePath: first,
+	}, nil
+}
+
+// deleteSegments 确认合并结果完整后删除分段
+func (s *SessionMergeStage) deleteSegments(ctx *pipeline.PipelineContext, merged string, total float64, group []mergeSegment) {
+	if total > 0 {
+		probed, err := streamprobe.ProbeFile(merged)
+		if err == nil && probed.Duration > 0 && probed.Duration.Seconds() < total*mergeDurationTolerance {
+			s.logs += fmt.Sprintf("合并后时长 %.0fs 少于分段总时长 %.0fs，不删除分段\n", probed.Duration.Seconds(), total)
+			ctx.Logger.Warnf("合并后时长 %.0fs 少于分段总时长 %.0fs，不删除分段", probed.Duration.Seconds(), total)
+			return
+		}
+	}
+	for _, seg := range group {
+		path := seg.file.Path
+		if err := integrity.Check(ctx.Ctx, path); err != nil {
+			// 分段与清单不一致，保留以便排查
+			s.logs += fmt.Sprintf("分段未通过完整性校验，不删除: %s\n", err)
+			ctx.Logger.Warnf("分段未通过完整性校验，不删除: %s", err)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			logrus.WithError(err).WithField("file", path).Warn("failed to delete merged segment")
+			s.logs += fmt.Sprintf("删除分段失败: %s\n", path)
+			continue
+		}
+		integrity.Remove(path)
+		s.logs += fmt.Sprintf("已删除分段: %s\n", filepath.Base(path))
+	}
+}
+
+func (s *SessionMergeStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *SessionMergeStage) GetLogs() string {
+	return s.logs
+}
@@ -0,0 +1,267 @@
+package stages
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// 缩略图默认参数
+const (
+	defaultThumbnailInterval = 60
+	defaultThumbnailWidth    = 160
+	defaultThumbnailColumns  = 10
+	defaultThumbnailRows     = 10
+	// thumbnailJPEGQuality JPEG 质量 (2-31，数值越小质量越高)
+	thumbnailJPEGQuality = "5"
+)
+
+// ThumbnailsStage 时间轴缩略图阶段
+// 每隔固定时间截取一张缩略图，拼接为雪碧图，并生成网页播放器可用的 WebVTT 缩略图轨道
+type ThumbnailsStage struct {
+	config     pipeline.StageConfig
+	interval   float64
+	width      int
+	columns    int
+	rows       int
+	keepFrames bool
+	accurate   bool
+	commands   []string
+	logs       string
+}
+
+// NewThumbnailsStage 创建时间轴缩略图阶段工厂
+func NewThumbnailsStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	s := &ThumbnailsStage{
+		config:     config,
+		interval:   config.GetFloatOption(pipeline.OptionInterval, defaultThumbnailInterval),
+		width:      config.GetIntOption(pipeline.OptionWidth, defaultThumbnailWidth),
+		columns:    config.GetIntOption(pipeline.OptionColumns, defaultThumbnailColumns),
+		rows:       config.GetIntOption(pipeline.OptionRows, defaultThumbnailRows),
+		keepFrames: config.GetBoolOption(pipeline.OptionKeepFrames, false),
+		accurate:   config.GetBoolOption(pipeline.OptionAccurate, false),
+	}
+	if s.interval < 1 {
+		return nil, fmt.Errorf("thumbnails: interval must be at least 1 second")
+	}
+	if s.width < 16 || s.columns < 1 || s.rows < 1 {
+		return nil, fmt.Errorf("thumbnails: invalid width/columns/rows")
+	}
+	s.width -= s.width % 2
+	return s, nil
+}
+
+func (s *ThumbnailsStage) Name() string {
+	return pipeline.StageNameThumbnails
+}
+
+func (s *ThumbnailsStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	ffmpegPath := ctx.FFmpegPath
+	if ffmpegPath == "" {
+		var err error
+		ffmpegPath, err = utils.GetFFmpegPath(ctx.Ctx)
+		if err != nil {
+			s.logs = fmt.Sprintf("ffmpeg 不可用: %s", err.Error())
+			return nil, fmt.Errorf("ffmpeg not available: %w", err)
+		}
+	}
+
+	// 先添加所有输入文件到输出
+	output := append([]pipeline.FileInfo(nil), input...)
+
+	for _, file := range input {
+		if file.Type != pipeline.FileTypeVideo {
+			continue
+		}
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			s.logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			continue
+		}
+
+		ctx.Logger.Infof("生成时间轴缩略图: %s", file.Path)
+		files, err := s.generate(ctx, ffmpegPath, file.Path)
+		if err != nil {
+			if ctx.Ctx.Err() != nil {
+				return nil, ctx.Ctx.Err()
+			}
+			// 与封面提取一致，缩略图失败不影响后续阶段
+			s.logs += fmt.Sprintf("生成缩略图失败: %s - %s\n", filepath.Base(file.Path), err.Error())
+			ctx.Logger.Warnf("生成缩略图失败: %s - %s", file.Path, err)
+			continue
+		}
+		for _, f := range files {
+			output = append(output, pipeline.FileInfo{
+				Path:       f,
+				Type:       pipeline.FileTypeOther,
+				SourcePath: file.Path,
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// generate 为单个视频生成缩略图、雪碧图和 WebVTT 文件，返回生成的文件列表
+func (s *ThumbnailsStage) generate(ctx *pipeline.PipelineContext, ffmpegPath, videoPath string) ([]string, error) {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+
+	// 按视频宽高比计算缩略图高度，无法探测时按 16:9 处理
+	height := s.width * 9 / 16
+	var duration time.Duration
+	if probed, err := streamprobe.ProbeFile(videoPath); err == nil {
+		duration = probed.Duration
+		if probed.Width > 0 && probed.Height > 0 {
+			height = int(math.Round(float64(s.width) * float64(probed.Height) / float64(probed.Width)))
+		}
+	}
+	height += height % 2
+
+	// 单张缩略图：保留时写到视频旁的目录，否则写到临时目录
+	var framesDir string
+	if s.keepFrames {
+		framesDir = base + "_thumbs"
+		if err := os.MkdirAll(framesDir, 0755); err != nil {
+			return nil, err
+		}
+	} else {
+		dir, err := os.MkdirTemp(ctx.TempDir, "thumbnails_")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		framesDir = dir
+	}
+
+	var args []string
+	if !s.accurate {
+		// 只解码关键帧，长录像也能很快完成，截图时间误差在一个 GOP 以内
+		args = append(args, "-skip_frame", "nokey")
+	}
+	args = append(args,
+		"-i", videoPath,
+		"-an", "-sn",
+		"-vf", fmt.Sprintf("fps=1/%s,scale=%d:%d", strconv.FormatFloat(s.interval, 'f', -1, 64), s.width, height),
+		"-q:v", thumbnailJPEGQuality,
+		"-y",
+		filepath.Join(framesDir, "%05d.jpg"),
+	)
+	if err := s.runFFmpeg(ctx, ffmpegPath, args); err != nil {
+		return nil, err
+	}
+
+	frames, err := filepath.Glob(filepath.Join(framesDir, "[0-9][0-9][0-9][0-9][0-9].jpg"))
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("ffmpeg 没有输出任何缩略图")
+	}
+
+	var generated []string
+	if s.keepFrames {
+		generated = append(generated, frames...)
+	}
+
+	// 拼接雪碧图，每张最多 columns*rows 个缩略图
+	perSheet := s.columns * s.rows
+	sheets := (len(frames) + perSheet - 1) / perSheet
+	sheetPaths := make([]string, sheets)
+	for i := range sheetPaths {
+		if sheets == 1 {
+			sheetPaths[i] = base + ".sprite.jpg"
+		} else {
+			sheetPaths[i] = fmt.Sprintf("%s.sprite_%03d.jpg", base, i+1)
+		}
+		// 最后一张雪碧图按实际数量减少行数，未填满的一行由 tile 滤镜在输入结束时输出
+		count := min(perSheet, len(frames)-i*perSheet)
+		rows := (count + s.columns - 1) / s.columns
+		args := []string{
+			"-start_number", strconv.Itoa(i*perSheet + 1),
+			"-i", filepath.Join(framesDir, "%05d.jpg"),
+			"-frames:v", "1",
+			"-vf", fmt.Sprintf("tile=%dx%d", s.columns, rows),
+			"-q:v", thumbnailJPEGQuality,
+			"-y",
+			sheetPaths[i],
+		}
+		if err := s.runFFmpeg(ctx, ffmpegPath, args); err != nil {
+			return nil, err
+		}
+		generated = append(generated, sheetPaths[i])
+	}
+
+	// 最后一张缩略图的结束时间取视频时长，无法得知时按间隔推算
+	end := time.Duration(float64(len(frames)) * s.interval * float64(time.Second))
+	if duration > 0 && duration < end {
+		end = duration
+	}
+	vttPath := base + ".thumbnails.vtt"
+	vtt := buildThumbnailVTT(len(frames), s.interval, end, s.width, height, s.columns, s.rows, sheetPaths)
+	if err := os.WriteFile(vttPath, []byte(vtt), 0644); err != nil {
+		return nil, err
+	}
+	generated = append(generated, vttPath)
+
+	s.logs += fmt.Sprintf("缩略图已生成: %s (%d 张，%d 张雪碧图)\n", filepath.Base(vttPath), len(frames), sheets)
+	ctx.Logger.Infof("缩略图已生成: %s (%d 张，%d 张雪碧图)", vttPath, len(frames), sheets)
+	return generated, nil
+}
+
+func (s *ThumbnailsStage) runFFmpeg(ctx *pipeline.PipelineContext, ffmpegPath string, args []string) error {
+	args = append([]string{"-hide_banner", "-loglevel", "error"}, args...)
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+	out, err := exec.CommandContext(ctx.Ctx, ffmpegPath, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// buildThumbnailVTT 生成 WebVTT 缩略图轨道，每个 cue 指向雪碧图中的一个区域（媒体片段 #xywh）
+// 雪碧图按与 VTT 文件的相对路径引用，两者位于同一目录
+func buildThumbnailVTT(count int, interval float64, end time.Duration, width, height, columns, rows int, sheets []string) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	perSheet := columns * rows
+	step := time.Duration(interval * float64(time.Second))
+	for i := 0; i < count; i++ {
+		from := time.Duration(i) * step
+		to := from + step
+		if i == count-1 && end > from {
+			to = end
+		}
+		idx := i % perSheet
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			formatVTTTime(from), formatVTTTime(to), filepath.Base(sheets[i/perSheet]),
+			idx%columns*width, idx/columns*height, width, height)
+	}
+	return b.String()
+}
+
+// formatVTTTime 格式化为 WebVTT 时间戳 HH:MM:SS.mmm
+func formatVTTTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+func (s *ThumbnailsStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *ThumbnailsStage) GetLogs() string {
+	return s.logs
+}
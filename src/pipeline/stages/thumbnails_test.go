@@ -0,0 +1,104 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatVTTTime(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "00:00:00.000"},
+		{1500 * time.Millisecond, "00:00:01.500"},
+		{59*time.Second + 999*time.Millisecond + 999*time.Microsecond, "00:00:59.999"},
+		{61 * time.Minute, "01:01:00.000"},
+		{25*time.Hour + 2*time.Second + 5*time.Millisecond, "25:00:02.005"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatVTTTime(tt.in), tt.in.String())
+	}
+}
+
+func TestBuildThumbnailVTT(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		interval float64
+		end      time.Duration
+		columns  int
+		rows     int
+		sheets   []string
+		want     string
+	}{
+		{
+			name:     "最后一个 cue 截止到视频结尾",
+			count:    3,
+			interval: 10,
+			end:      25 * time.Second,
+			columns:  2,
+			rows:     2,
+			sheets:   []string{"/rec/rec.thumbs_001.jpg"},
+			want: "WEBVTT\n" +
+				"\n00:00:00.000 --> 00:00:10.000\nrec.thumbs_001.jpg#xywh=0,0,160,90\n" +
+				"\n00:00:10.000 --> 00:00:20.000\nrec.thumbs_001.jpg#xywh=160,0,160,90\n" +
+				"\n00:00:20.000 --> 00:00:25.000\nrec.thumbs_001.jpg#xywh=0,90,160,90\n",
+		},
+		{
+			name:     "超出一张雪碧图时从下一张的左上角开始",
+			count:    5,
+			interval: 2.5,
+			end:      12*time.Second + 300*time.Millisecond,
+			columns:  2,
+			rows:     2,
+			sheets:   []string{"/rec/a_001.jpg", "/rec/a_002.jpg"},
+			want: "WEBVTT\n" +
+				"\n00:00:00.000 --> 00:00:02.500\na_001.jpg#xywh=0,0,160,90\n" +
+				"\n00:00:02.500 --> 00:00:05.000\na_001.jpg#xywh=160,0,160,90\n" +
+				"\n00:00:05.000 --> 00:00:07.500\na_001.jpg#xywh=0,90,160,90\n" +
+				"\n00:00:07.500 --> 00:00:10.000\na_001.jpg#xywh=160,90,160,90\n" +
+				"\n00:00:10.000 --> 00:00:12.300\na_002.jpg#xywh=0,0,160,90\n",
+		},
+		{
+			name:     "单列",
+			count:    3,
+			interval: 60,
+			end:      3 * time.Minute,
+			columns:  1,
+			rows:     2,
+			sheets:   []string{"s1.jpg", "s2.jpg"},
+			want: "WEBVTT\n" +
+				"\n00:00:00.000 --> 00:01:00.000\ns1.jpg#xywh=0,0,160,90\n" +
+				"\n00:01:00.000 --> 00:02:00.000\ns1.jpg#xywh=0,90,160,90\n" +
+				"\n00:02:00.000 --> 00:03:00.000\ns2.jpg#xywh=0,0,160,90\n",
+		},
+		{
+			name:     "时长未知时最后一个 cue 保持间隔",
+			count:    2,
+			interval: 10,
+			end:      0,
+			columns:  3,
+			rows:     1,
+			sheets:   []string{"s.jpg"},
+			want: "WEBVTT\n" +
+				"\n00:00:00.000 --> 00:00:10.000\ns.jpg#xywh=0,0,160,90\n" +
+				"\n00:00:10.000 --> 00:00:20.000\ns.jpg#xywh=160,0,160,90\n",
+		},
+		{
+			name:    "没有缩略图",
+			count:   0,
+			columns: 1,
+			rows:    1,
+			want:    "WEBVTT\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildThumbnailVTT(tt.count, tt.interval, tt.end, 160, 90, tt.columns, tt.rows, tt.sheets)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
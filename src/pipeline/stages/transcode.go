@@ -0,0 +1,309 @@
+package stages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// 转码预设
+const (
+	// transcodePresetHEVC HEVC 归档：libx265 恒定质量，音频直接复制
+	transcodePresetHEVC = "hevc_archive"
+	// transcodePresetPreview 720p 预览：libx264 快速编码的小文件
+	transcodePresetPreview = "preview_720p"
+	// transcodePresetTargetSize 目标大小：libx264 两遍编码，按时长计算码率
+	transcodePresetTargetSize = "target_size"
+	// transcodePresetCustom 自定义 ffmpeg 参数
+	transcodePresetCustom = "custom"
+)
+
+// transcodeDefaults 各预设的默认参数
+var transcodeDefaults = map[string]struct {
+	crf          int
+	speed        string
+	audioBitrate int
+	suffix       string
+}{
+	transcodePresetHEVC:       {crf: 26, speed: "medium", suffix: ".hevc"},
+	transcodePresetPreview:    {crf: 28, speed: "veryfast", audioBitrate: 96, suffix: ".720p"},
+	transcodePresetTargetSize: {speed: "medium", audioBitrate: 128, suffix: ".target"},
+	transcodePresetCustom:     {suffix: ".transcoded"},
+}
+
+// minTargetVideoKbps 目标大小换算出的视频码率低于该值时拒绝转码，画面已无法观看
+const minTargetVideoKbps = 100
+
+// TranscodeStage 转码阶段
+// 只使用 CPU 软件编码器，通过解析 ffmpeg -progress 输出上报实际进度
+type TranscodeStage struct {
+	config       pipeline.StageConfig
+	preset       string
+	crf          int
+	speed        string
+	audioBitrate int
+	targetSize   int64
+	args         []string
+	outputExt    string
+	suffix       string
+	deleteSource bool
+	commands     []string
+	logs         string
+}
+
+// NewTranscodeStage 创建转码阶段工厂
+func NewTranscodeStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	preset := config.GetStringOption(pipeline.OptionPreset, "")
+	def, ok := transcodeDefaults[preset]
+	if !ok {
+		return nil, fmt.Errorf("transcode: unsupported preset %q", preset)
+	}
+	s := &TranscodeStage{
+		config:       config,
+		preset:       preset,
+		crf:          config.GetIntOption(pipeline.OptionCRF, def.crf),
+		speed:        config.GetStringOption(pipeline.OptionSpeed, def.speed),
+		audioBitrate: config.GetIntOption(pipeline.OptionAudioBitrate, def.audioBitrate),
+		args:         config.GetStringSliceOption(pipeline.OptionArgs),
+		outputExt:    config.GetStringOption(pipeline.OptionOutputExt, ".mp4"),
+		suffix:       config.GetStringOption(pipeline.OptionSuffix, def.suffix),
+		deleteSource: config.GetBoolOption(pipeline.OptionDeleteSource, false),
+	}
+	if !strings.HasPrefix(s.outputExt, ".") {
+		s.outputExt = "." + s.outputExt
+	}
+
+	switch preset {
+	case transcodePresetHEVC, transcodePresetPreview:
+		if s.crf < 0 || s.crf > 51 {
+			return nil, fmt.Errorf("transcode: crf must be between 0 and 51")
+		}
+	case transcodePresetTargetSize:
+		// 目标大小可以写成字节数或带单位的字符串
+		if v := config.GetFloatOption(pipeline.OptionTargetSize, 0); v > 0 {
+			s.targetSize = int64(v)
+		} else {
+			size, err := configs.ParseByteSize(config.GetStringOption(pipeline.OptionTargetSize, ""))
+			if err != nil {
+				return nil, fmt.Errorf("transcode: invalid target_size: %w", err)
+			}
+			s.targetSize = int64(size)
+		}
+		if s.targetSize <= 0 {
+			return nil, fmt.Errorf("transcode: target_size is required for preset %s", preset)
+		}
+	case transcodePresetCustom:
+		if len(s.args) == 0 {
+			return nil, fmt.Errorf("transcode: args is required for preset %s", preset)
+		}
+	}
+	return s, nil
+}
+
+func (s *TranscodeStage) Name() string {
+	return pipeline.StageNameTranscode
+}
+
+func (s *TranscodeStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	ffmpegPath := ctx.FFmpegPath
+	if ffmpegPath == "" {
+		var err error
+		ffmpegPath, err = utils.GetFFmpegPath(ctx.Ctx)
+		if err != nil {
+			s.logs = fmt.Sprintf("ffmpeg 不可用: %s", err.Error())
+			return nil, fmt.Errorf("ffmpeg not available: %w", err)
+		}
+	}
+
+	var videos int
+	for _, file := range input {
+		if file.Type == pipeline.FileTypeVideo {
+			videos++
+		}
+	}
+
+	var output []pipeline.FileInfo
+	done := 0
+	for _, file := range input {
+		if file.Type != pipeline.FileTypeVideo {
+			output = append(output, file)
+			continue
+		}
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			s.logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			continue
+		}
+
+		outputPath := strings.TrimSuffix(file.Path, filepath.Ext(file.Path)) + s.suffix + s.outputExt
+		if outputPath == file.Path {
+			return nil, fmt.Errorf("transcode output would overwrite the source file: %s", file.Path)
+		}
+
+		ctx.Logger.Infof("转码 (%s): %s -> %s", s.preset, file.Path, outputPath)
+		offset := done
+		err := s.transcode(ctx, ffmpegPath, file.Path, outputPath, func(fraction float64) {
+			ctx.ReportProgress((float64(offset) + fraction) * 100 / float64(videos))
+		})
+		if err != nil {
+			s.logs += fmt.Sprintf("转码失败: %s - %s\n", filepath.Base(file.Path), err.Error())
+			return nil, fmt.Errorf("transcode %s: %w", file.Path, err)
+		}
+		done++
+
+		output = append(output, pipeline.FileInfo{
+			Path:       outputPath,
+			Type:       pipeline.FileTypeVideo,
+			SourcePath: file.Path,
+		})
+		s.logs += fmt.Sprintf("转码完成: %s -> %s\n", filepath.Base(file.Path), filepath.Base(outputPath))
+		ctx.Logger.Infof("转码完成: %s", outputPath)
+
+		if !s.deleteSource {
+			output = append(output, file)
+			continue
+		}
+		if err := integrity.Check(ctx.Ctx, file.Path); err != nil {
+			// 原始文件与清单不一致，保留以便排查
+			s.logs += fmt.Sprintf("原始文件未通过完整性校验，不删除: %s\n", err)
+			ctx.Logger.Warnf("原始文件未通过完整性校验，不删除: %s", err)
+			output = append(output, file)
+		} else if err := os.Remove(file.Path); err != nil {
+			logrus.WithError(err).WithField("file", file.Path).Warn("failed to delete original file")
+			s.logs += fmt.Sprintf("删除原始文件失败: %s\n", file.Path)
+		} else {
+			integrity.Remove(file.Path)
+			s.logs += fmt.Sprintf("已删除原始文件: %s\n", file.Path)
+		}
+	}
+
+	return output, nil
+}
+
+// transcode 转码单个文件，先写入临时文件再重命名，progress 接收完成比例（0-1）
+func (s *TranscodeStage) transcode(ctx *pipeline.PipelineContext, ffmpegPath, src, dst string, progress func(fraction float64)) error {
+	var duration float64
+	if probed, err := streamprobe.ProbeFile(src); err == nil && probed.Duration > 0 {
+		duration = probed.Duration.Seconds()
+	} else {
+		duration = getVideoDuration(ctx.Ctx, ffmpegPath, src)
+	}
+	// 每一遍编码占总进度的相同比例
+	pass := func(index, total int) func(sec float64) {
+		return func(sec float64) {
+			if duration > 0 {
+				progress((float64(index) + min(sec/duration, 1)) / float64(total))
+			}
+		}
+	}
+
+	tempFile := filepath.Join(filepath.Dir(dst), ".transcoding_"+filepath.Base(dst))
+	var err error
+	if s.preset == transcodePresetTargetSize {
+		err = s.twoPass(ctx, ffmpegPath, src, tempFile, duration, pass)
+	} else {
+		args := append([]string{"-i", src}, s.outputArgs()...)
+		err = s.run(ctx, ffmpegPath, s.finishArgs(args, tempFile), pass(0, 1))
+	}
+	if err != nil {
+		os.Remove(tempFile)
+		return err
+	}
+	if err := os.Rename(tempFile, dst); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
+
+// outputArgs 单遍编码预设的输出参数
+func (s *TranscodeStage) outputArgs() []string {
+	switch s.preset {
+	case transcodePresetHEVC:
+		return []string{
+			"-map", "0:v?", "-map", "0:a?",
+			"-c:v", "libx265", "-preset", s.speed, "-crf", strconv.Itoa(s.crf),
+			// hvc1 标签让 Apple 设备和浏览器能识别 MP4 中的 HEVC
+			"-tag:v", "hvc1",
+			"-c:a", "copy",
+		}
+	case transcodePresetPreview:
+		return []string{
+			"-map", "0:v?", "-map", "0:a?",
+			// 不放大低于 720p 的视频，宽度保持偶数
+			"-vf", "scale=-2:'min(720,ih)'",
+			"-c:v", "libx264", "-preset", s.speed, "-crf", strconv.Itoa(s.crf),
+			"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", s.audioBitrate),
+		}
+	default:
+		return s.args
+	}
+}
+
+// twoPass 按目标大小计算码率，两遍编码以准确控制文件大小
+func (s *TranscodeStage) twoPass(ctx *pipeline.PipelineContext, ffmpegPath, src, dst string, duration float64, pass func(index, total int) func(sec float64)) error {
+	if duration <= 0 {
+		return fmt.Errorf("无法获取视频时长，不能按目标大小转码")
+	}
+	videoKbps := int(float64(s.targetSize)*8/1000/duration) - s.audioBitrate
+	if videoKbps < minTargetVideoKbps {
+		return fmt.Errorf("目标大小过小：%.0f 秒的视频只能分配 %d kbps 视频码率", duration, videoKbps)
+	}
+
+	logDir, err := os.MkdirTemp(ctx.TempDir, "transcode_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(logDir)
+	passLog := filepath.Join(logDir, "ffmpeg2pass")
+
+	video := []string{
+		"-c:v", "libx264", "-preset", s.speed,
+		"-b:v", fmt.Sprintf("%dk", videoKbps),
+		"-passlogfile", passLog,
+	}
+	first := append([]string{"-i", src, "-map", "0:v?"}, video...)
+	first = append(first, "-pass", "1", "-an", "-f", "null", "-y", os.DevNull)
+	if err := s.run(ctx, ffmpegPath, first, pass(0, 2)); err != nil {
+		return err
+	}
+
+	second := append([]string{"-i", src, "-map", "0:v?", "-map", "0:a?"}, video...)
+	second = append(second, "-pass", "2", "-c:a", "aac", "-b:a", fmt.Sprintf("%dk", s.audioBitrate))
+	return s.run(ctx, ffmpegPath, s.finishArgs(second, dst), pass(1, 2))
+}
+
+// finishArgs 追加封装参数和输出文件
+func (s *TranscodeStage) finishArgs(args []string, dst string) []string {
+	if strings.EqualFold(s.outputExt, ".mp4") {
+		args = append(args, "-movflags", "+faststart")
+	}
+	return append(args, "-y", dst)
+}
+
+func (s *TranscodeStage) run(ctx *pipeline.PipelineContext, ffmpegPath string, args []string, onTime func(sec float64)) error {
+	s.commands = append(s.commands, fmt.Sprintf("%s %s", ffmpegPath, strings.Join(args, " ")))
+	return runFFmpegWithProgress(ctx, ffmpegPath, args, onTime)
+}
+
+func (s *TranscodeStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *TranscodeStage) GetLogs() string {
+	return s.logs
+}
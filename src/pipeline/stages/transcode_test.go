@@ -0,0 +1,229 @@
+package stages
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+// fakeTranscodeFFmpeg 报告已输出 45 秒，并创建输出文件（第一遍输出到空设备时不创建）
+const fakeTranscodeFFmpeg = `#!/bin/sh
+for a; do last=$a; done
+if [ "$last" != "/dev/null" ]; then : > "$last"; fi
+echo out_time_us=45000000
+echo progress=end
+`
+
+func newTranscodeTestStage(t *testing.T, options map[string]any) *TranscodeStage {
+	t.Helper()
+	stage, err := NewTranscodeStage(pipeline.StageConfig{Name: pipeline.StageNameTranscode, Options: options})
+	require.NoError(t, err)
+	return stage.(*TranscodeStage)
+}
+
+func TestNewTranscodeStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]any
+		check   func(t *testing.T, s *TranscodeStage)
+		wantErr string
+	}{
+		{
+			name:    "HEVC 默认参数",
+			options: map[string]any{pipeline.OptionPreset: "hevc_archive"},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, 26, s.crf)
+				assert.Equal(t, "medium", s.speed)
+				assert.Equal(t, ".hevc", s.suffix)
+				assert.Equal(t, ".mp4", s.outputExt)
+			},
+		},
+		{
+			name: "预览自定义参数",
+			options: map[string]any{
+				pipeline.OptionPreset:       "preview_720p",
+				pipeline.OptionCRF:          30.0,
+				pipeline.OptionAudioBitrate: 64,
+				pipeline.OptionOutputExt:    "mkv",
+			},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, 30, s.crf)
+				assert.Equal(t, "veryfast", s.speed)
+				assert.Equal(t, 64, s.audioBitrate)
+				assert.Equal(t, ".mkv", s.outputExt)
+			},
+		},
+		{
+			name:    "目标大小为数字",
+			options: map[string]any{pipeline.OptionPreset: "target_size", pipeline.OptionTargetSize: 734003200.0},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, int64(734003200), s.targetSize)
+				assert.Equal(t, 128, s.audioBitrate)
+			},
+		},
+		{
+			name:    "目标大小为整数",
+			options: map[string]any{pipeline.OptionPreset: "target_size", pipeline.OptionTargetSize: 1048576},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, int64(1048576), s.targetSize)
+			},
+		},
+		{
+			name:    "目标大小带单位",
+			options: map[string]any{pipeline.OptionPreset: "target_size", pipeline.OptionTargetSize: "700MB"},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, int64(700*configs.MB), s.targetSize)
+			},
+		},
+		{
+			name:    "目标大小为数字字符串",
+			options: map[string]any{pipeline.OptionPreset: "target_size", pipeline.OptionTargetSize: "1048576"},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, int64(1048576), s.targetSize)
+			},
+		},
+		{
+			name:    "自定义参数",
+			options: map[string]any{pipeline.OptionPreset: "custom", pipeline.OptionArgs: []any{"-c:v", "libvpx-vp9"}},
+			check: func(t *testing.T, s *TranscodeStage) {
+				assert.Equal(t, []string{"-c:v", "libvpx-vp9"}, s.outputArgs())
+				assert.Equal(t, ".transcoded", s.suffix)
+			},
+		},
+		{name: "未指定预设", options: map[string]any{}, wantErr: "unsupported preset"},
+		{name: "未知预设", options: map[string]any{pipeline.OptionPreset: "av1"}, wantErr: "unsupported preset"},
+		{name: "CRF 过大", options: map[string]any{pipeline.OptionPreset: "hevc_archive", pipeline.OptionCRF: 52}, wantErr: "crf"},
+		{name: "CRF 为负", options: map[string]any{pipeline.OptionPreset: "preview_720p", pipeline.OptionCRF: -1}, wantErr: "crf"},
+		{name: "缺少目标大小", options: map[string]any{pipeline.OptionPreset: "target_size"}, wantErr: "target_size is required"},
+		{name: "目标大小为零", options: map[string]any{pipeline.OptionPreset: "target_size", pipeline.OptionTargetSize: 0}, wantErr: "target_size is required"},
+		{name: "目标大小无效", options: map[string]any{pipeline.OptionPreset: "target_size", pipeline.OptionTargetSize: "big"}, wantErr: "invalid target_size"},
+		{name: "自定义缺少参数", options: map[string]any{pipeline.OptionPreset: "custom"}, wantErr: "args is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewTranscodeStage(pipeline.StageConfig{Name: pipeline.StageNameTranscode, Options: tt.options})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, stage.(*TranscodeStage))
+		})
+	}
+}
+
+func TestTranscodeOutputArgs(t *testing.T) {
+	hevc := newTranscodeTestStage(t, map[string]any{pipeline.OptionPreset: "hevc_archive", pipeline.OptionSpeed: "slow"})
+	assert.Equal(t, []string{
+		"-map", "0:v?", "-map", "0:a?",
+		"-c:v", "libx265", "-preset", "slow", "-crf", "26",
+		"-tag:v", "hvc1",
+		"-c:a", "copy",
+	}, hevc.outputArgs())
+
+	preview := newTranscodeTestStage(t, map[string]any{pipeline.OptionPreset: "preview_720p"})
+	assert.Equal(t, []string{
+		"-map", "0:v?", "-map", "0:a?",
+		"-vf", "scale=-2:'min(720,ih)'",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+		"-c:a", "aac", "-b:a", "96k",
+	}, preview.outputArgs())
+
+	// 只有 MP4 添加 faststart
+	assert.Equal(t, []string{"-i", "in", "-movflags", "+faststart", "-y", "out"}, hevc.finishArgs([]string{"-i", "in"}, "out"))
+	mkv := newTranscodeTestStage(t, map[string]any{pipeline.OptionPreset: "hevc_archive", pipeline.OptionOutputExt: ".mkv"})
+	assert.Equal(t, []string{"-i", "in", "-y", "out"}, mkv.finishArgs([]string{"-i", "in"}, "out"))
+}
+
+// runTranscodeTest 用假的 ffmpeg 转码一个 90 秒的 FLV，返回输出、命令和上报的进度
+func runTranscodeTest(t *testing.T, options map[string]any) (string, []pipeline.FileInfo, []string, []float64, error) {
+	t.Helper()
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.flv")
+	writeTestFLV(t, video)
+	stage := newTranscodeTestStage(t, options)
+	ctx := newWebhookTestContext()
+	ctx.FFmpegPath = writeFakeFFmpeg(t, fakeTranscodeFFmpeg)
+	var progress []float64
+	ctx.OnProgress = func(percent float64) { progress = append(progress, percent) }
+
+	output, err := stage.Execute(ctx, []pipeline.FileInfo{pipeline.NewVideoFileInfo(video)})
+	commands := make([]string, len(stage.GetCommands()))
+	for i, cmd := range stage.GetCommands() {
+		commands[i] = strings.TrimPrefix(cmd, ctx.FFmpegPath+" ")
+	}
+	return dir, output, commands, progress, err
+}
+
+func TestTranscodeStageSinglePass(t *testing.T) {
+	dir, output, commands, progress, err := runTranscodeTest(t, map[string]any{pipeline.OptionPreset: "hevc_archive"})
+	require.NoError(t, err)
+
+	video := filepath.Join(dir, "rec.flv")
+	dst := filepath.Join(dir, "rec.hevc.mp4")
+	require.Len(t, output, 2)
+	assert.Equal(t, pipeline.FileInfo{Path: dst, Type: pipeline.FileTypeVideo, SourcePath: video}, output[0])
+	assert.Equal(t, video, output[1].Path)
+	assert.FileExists(t, dst)
+	assert.NoFileExists(t, filepath.Join(dir, ".transcoding_rec.hevc.mp4"))
+
+	require.Len(t, commands, 1)
+	assert.Equal(t, "-i "+video+" -map 0:v? -map 0:a? -c:v libx265 -preset medium -crf 26 -tag:v hvc1 -c:a copy"+
+		" -movflags +faststart -y "+filepath.Join(dir, ".transcoding_rec.hevc.mp4"), commands[0])
+	assert.Equal(t, []float64{50}, progress)
+}
+
+func TestTranscodeStageTargetSize(t *testing.T) {
+	dir, output, commands, progress, err := runTranscodeTest(t, map[string]any{
+		pipeline.OptionPreset:     "target_size",
+		pipeline.OptionTargetSize: "100MB",
+	})
+	require.NoError(t, err)
+	require.Len(t, output, 2)
+	assert.Equal(t, filepath.Join(dir, "rec.target.mp4"), output[0].Path)
+
+	// 100MB * 8 / 90 秒 = 9320 kbps，减去 128 kbps 音频
+	require.Len(t, commands, 2)
+	video := filepath.Join(dir, "rec.flv")
+	assert.True(t, strings.HasPrefix(commands[0], "-i "+video+" -map 0:v? -c:v libx264 -preset medium -b:v 9192k -passlogfile "), commands[0])
+	assert.True(t, strings.HasSuffix(commands[0], "/ffmpeg2pass -pass 1 -an -f null -y "+os.DevNull), commands[0])
+	assert.True(t, strings.HasPrefix(commands[1], "-i "+video+" -map 0:v? -map 0:a? -c:v libx264 -preset medium -b:v 9192k -passlogfile "), commands[1])
+	assert.True(t, strings.HasSuffix(commands[1], "/ffmpeg2pass -pass 2 -c:a aac -b:a 128k -movflags +faststart -y "+
+		filepath.Join(dir, ".transcoding_rec.target.mp4")), commands[1])
+	// 每一遍占一半进度
+	assert.Equal(t, []float64{25, 75}, progress)
+}
+
+func TestTranscodeStageRejectsTinyTargetSize(t *testing.T) {
+	// 1MB * 8 / 90 秒只有 93 kbps，扣除音频后低于 minTargetVideoKbps
+	dir, output, commands, _, err := runTranscodeTest(t, map[string]any{
+		pipeline.OptionPreset:     "target_size",
+		pipeline.OptionTargetSize: "1MB",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "目标大小过小")
+	assert.Nil(t, output)
+	assert.Empty(t, commands)
+	assert.NoFileExists(t, filepath.Join(dir, "rec.target.mp4"))
+}
+
+func TestTranscodeStageRefusesToOverwriteSource(t *testing.T) {
+	dir, _, commands, _, err := runTranscodeTest(t, map[string]any{
+		pipeline.OptionPreset:    "custom",
+		pipeline.OptionArgs:      []any{"-c", "copy"},
+		pipeline.OptionSuffix:    "",
+		pipeline.OptionOutputExt: ".flv",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overwrite the source file")
+	assert.Empty(t, commands)
+	assert.FileExists(t, filepath.Join(dir, "rec.flv"))
+}
@@ -0,0 +1,150 @@
+package stages
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// defaultUploadPathTemplate 默认上传路径：/录播归档/{平台}/{主播名}/{文件名}
+const defaultUploadPathTemplate = "/录播归档/{{ .Platform }}/{{ .HostName }}/{{ .FileName }}"
+
+// uploadPathData 上传路径模板可以引用的字段，各上传阶段共用
+type uploadPathData struct {
+	Platform  string
+	HostName  string
+	RoomName  string
+	FileName  string
+	Ext       string // 不含点
+	StartTime time.Time
+}
+
+// parseUploadPathTemplate 解析上传路径模板，为空时使用默认路径
+func parseUploadPathTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultUploadPathTemplate
+	}
+	tmpl, err := template.New("upload_path").Funcs(utils.GetFuncMap(pipeline.TemplateConfig())).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderUploadPath 渲染文件的上传路径
+func renderUploadPath(tmpl *template.Template, ctx *pipeline.PipelineContext, file pipeline.FileInfo) (string, error) {
+	data := uploadPathData{
+		Platform:  ctx.RecordInfo.Platform,
+		HostName:  ctx.RecordInfo.HostName,
+		RoomName:  ctx.RecordInfo.RoomName,
+		FileName:  filepath.Base(file.Path),
+		Ext:       strings.TrimPrefix(filepath.Ext(file.Path), "."),
+		StartTime: ctx.RecordInfo.StartTime,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// matchFileTypes 检查文件类型是否在过滤列表中
+func matchFileTypes(fileTypes []string, fileType pipeline.FileType) bool {
+	for _, ft := range fileTypes {
+		if strings.EqualFold(ft, string(fileType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadJob 上传阶段共用的上传流程，各阶段只提供目标路径和单个文件的上传方式
+type uploadJob struct {
+	fileTypes   []string // 过滤的文件类型，空表示所有
+	deleteAfter bool
+	scheme      string // 日志和命令中目标路径的前缀，如 "s3://"
+	// target 生成文件的上传目标，出错或为空时跳过该文件并保留在输出中
+	target func(file pipeline.FileInfo) (string, error)
+	// upload 上传单个文件并核对远端，返回日志中显示的远端位置；onProgress 报告该文件已上传的字节数
+	upload func(file pipeline.FileInfo, target string, onProgress func(uploaded int64)) (string, error)
+	// annotate 为上传后保留在输出中的文件附加信息，可以为 nil
+	annotate func(file pipeline.FileInfo, target string) pipeline.FileInfo
+}
+
+// run 依次上传输入文件，返回保留在输出中的文件
+func (j uploadJob) run(ctx *pipeline.PipelineContext, input []pipeline.FileInfo, commands *[]string, logs *string) ([]pipeline.FileInfo, error) {
+	// 先筛选出要上传的文件，以便按字节数汇总进度
+	var output, pending []pipeline.FileInfo
+	sizes := make(map[string]int64)
+	var total int64
+	for _, file := range input {
+		if len(j.fileTypes) > 0 && !matchFileTypes(j.fileTypes, file.Type) {
+			output = append(output, file)
+			continue
+		}
+		stat, err := os.Stat(file.Path)
+		if err != nil {
+			*logs += fmt.Sprintf("文件不存在: %s\n", file.Path)
+			continue
+		}
+		pending = append(pending, file)
+		sizes[file.Path] = stat.Size()
+		total += stat.Size()
+	}
+
+	var done int64
+	for _, file := range pending {
+		// 不上传与完整性清单不一致的文件，避免把损坏的文件当作归档
+		if err := integrity.Check(ctx.Ctx, file.Path); err != nil {
+			*logs += fmt.Sprintf("未通过完整性校验，取消上传: %s\n", err)
+			return nil, err
+		}
+
+		target, err := j.target(file)
+		if err != nil || target == "" {
+			*logs += fmt.Sprintf("无法生成目标路径: %s\n", file.Path)
+			output = append(output, file)
+			continue
+		}
+
+		ctx.Logger.Infof("上传文件: %s -> %s%s", file.Path, j.scheme, target)
+		*commands = append(*commands, fmt.Sprintf("PUT %s -> %s%s", file.Path, j.scheme, target))
+		base := done
+		remote, err := j.upload(file, target, func(uploaded int64) {
+			if total > 0 {
+				ctx.ReportProgress(float64(base+uploaded) * 100 / float64(total))
+			}
+		})
+		if err != nil {
+			*logs += fmt.Sprintf("上传失败: %s: %s\n", file.Path, err)
+			return nil, fmt.Errorf("failed to upload %s: %w", file.Path, err)
+		}
+		done += sizes[file.Path]
+		*logs += fmt.Sprintf("已上传并校验: %s -> %s\n", filepath.Base(file.Path), remote)
+
+		// upload 返回时远端已核对过大小和校验值，此时才删除本地文件
+		if j.deleteAfter {
+			if err := os.Remove(file.Path); err != nil {
+				*logs += fmt.Sprintf("删除本地文件失败: %s\n", file.Path)
+			} else {
+				integrity.Remove(file.Path)
+				*logs += fmt.Sprintf("上传后删除: %s\n", filepath.Base(file.Path))
+				continue
+			}
+		}
+		if j.annotate != nil {
+			file = j.annotate(file, target)
+		}
+		output = append(output, file)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,137 @@
+package stages
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+func TestUploadJobRun(t *testing.T) {
+	dir := t.TempDir()
+	video := filepath.Join(dir, "rec.mp4")
+	cover := filepath.Join(dir, "rec.jpg")
+	require.NoError(t, os.WriteFile(video, make([]byte, 300), 0o644))
+	require.NoError(t, os.WriteFile(cover, make([]byte, 100), 0o644))
+	input := []pipeline.FileInfo{
+		pipeline.NewVideoFileInfo(video),
+		pipeline.NewCoverFileInfo(cover, video),
+		pipeline.NewVideoFileInfo(filepath.Join(dir, "missing.mp4")),
+		{Path: filepath.Join(dir, "rec.json"), Type: pipeline.FileTypeOther},
+	}
+
+	var uploaded []string
+	var progress []float64
+	ctx := newWebhookTestContext()
+	ctx.OnProgress = func(percent float64) { progress = append(progress, percent) }
+	job := uploadJob{
+		fileTypes: []string{"video", "COVER"},
+		scheme:    "dav://",
+		target: func(file pipeline.FileInfo) (string, error) {
+			return "/archive/" + filepath.Base(file.Path), nil
+		},
+		upload: func(file pipeline.FileInfo, target string, onProgress func(int64)) (string, error) {
+			uploaded = append(uploaded, target)
+			onProgress(50)
+			return "remote" + target, nil
+		},
+		annotate: func(file pipeline.FileInfo, target string) pipeline.FileInfo {
+			file.Metadata = map[string]any{"target": target}
+			return file
+		},
+	}
+	var commands []string
+	var logs string
+	output, err := job.run(ctx, input, &commands, &logs)
+	require.NoError(t, err)
+
+	// 不在过滤列表中的文件原样输出，不存在的文件被丢弃
+	assert.Equal(t, []string{"/archive/rec.mp4", "/archive/rec.jpg"}, uploaded)
+	require.Len(t, output, 3)
+	assert.Equal(t, input[3], output[0])
+	assert.Equal(t, video, output[1].Path)
+	assert.Equal(t, "/archive/rec.mp4", output[1].Metadata["target"])
+	assert.Equal(t, []string{
+		"PUT " + video + " -> dav:///archive/rec.mp4",
+		"PUT " + cover + " -> dav:///archive/rec.jpg",
+	}, commands)
+	assert.Contains(t, logs, "文件不存在: "+input[2].Path)
+	assert.Contains(t, logs, "已上传并校验: rec.mp4 -> remote/archive/rec.mp4")
+	// 进度按所有待上传文件的总字节数汇总
+	assert.Equal(t, []float64{50.0 * 100 / 400, 350.0 * 100 / 400}, progress)
+	assert.FileExists(t, video)
+
+	t.Run("上传后删除", func(t *testing.T) {
+		job := job
+		job.deleteAfter = true
+		logs = ""
+		output, err := job.run(ctx, input[:1], &commands, &logs)
+		require.NoError(t, err)
+		assert.Empty(t, output)
+		assert.NoFileExists(t, video)
+		assert.Contains(t, logs, "上传后删除: rec.mp4")
+	})
+
+	t.Run("无法生成目标路径", func(t *testing.T) {
+		job := job
+		job.target = func(pipeline.FileInfo) (string, error) { return "", nil }
+		job.upload = func(pipeline.FileInfo, string, func(int64)) (string, error) {
+			t.Fatal("upload should not be called")
+			return "", nil
+		}
+		logs = ""
+		output, err := job.run(ctx, input[1:2], &commands, &logs)
+		require.NoError(t, err)
+		assert.Equal(t, input[1:2], output)
+		assert.Contains(t, logs, "无法生成目标路径")
+	})
+
+	t.Run("上传失败", func(t *testing.T) {
+		job := job
+		job.upload = func(pipeline.FileInfo, string, func(int64)) (string, error) {
+			return "", errors.New("boom")
+		}
+		logs = ""
+		_, err := job.run(ctx, input[1:2], &commands, &logs)
+		require.Error(t, err)
+		assert.Contains(t, logs, "上传失败")
+	})
+}
+
+func TestCloudUploadStageRenderTargetPath(t *testing.T) {
+	ctx := newWebhookTestContext()
+	file := pipeline.NewVideoFileInfo("/rec/a.flv")
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{name: "默认路径", want: "/录播归档/哔哩哔哩/主播/a.flv"},
+		{name: "模板", template: "/{{ .HostName }}/{{.RoomName}}.{{ .Ext }}", want: "/主播/房间.flv"},
+		{
+			name:     "无法解析时按旧版规则替换",
+			template: "/{{ .Platform }}/{{.HostName}}/{{ .FileName }} {{",
+			want:     "/哔哩哔哩/主播/a.flv {{",
+		},
+		{
+			name:     "执行失败时按旧版规则替换",
+			template: "/{{ .HostName }}/{{ .Unknown }}/{{.Ext}}",
+			want:     "/主播/{{ .Unknown }}/flv",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewCloudUploadStage(pipeline.StageConfig{
+				Name:    pipeline.StageNameCloudUpload,
+				Options: map[string]any{pipeline.OptionPathTemplate: tt.template},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, stage.(*CloudUploadStage).renderTargetPath(ctx, file))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package stages
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/webdav"
+)
+
+// WebDAVUploadStage WebDAV 上传阶段
+// 直接上传到 NAS 等 WebDAV 服务，目标目录不存在时自动创建，路径模板与 cloud_upload 相同
+type WebDAVUploadStage struct {
+	config      pipeline.StageConfig
+	client      *webdav.Client
+	pathTmpl    *template.Template
+	chunked     bool
+	deleteAfter bool
+	fileTypes   []string // 过滤的文件类型，空表示所有
+	commands    []string
+	logs        string
+}
+
+// NewWebDAVUploadStage 创建 WebDAV 上传阶段工厂
+func NewWebDAVUploadStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	client, err := webdav.NewClient(webdav.Config{
+		URL:      config.GetStringOption(pipeline.OptionURL, ""),
+		Username: config.GetStringOption(pipeline.OptionUsername, ""),
+		Password: config.GetStringOption(pipeline.OptionPassword, ""),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webdav_upload: %w", err)
+	}
+	pathTmpl, err := parseUploadPathTemplate(config.GetStringOption(pipeline.OptionPathTemplate, ""))
+	if err != nil {
+		return nil, fmt.Errorf("webdav_upload: %w", err)
+	}
+	return &WebDAVUploadStage{
+		config:      config,
+		client:      client,
+		pathTmpl:    pathTmpl,
+		chunked:     config.GetBoolOption(pipeline.OptionChunked, false),
+		deleteAfter: config.GetBoolOption(pipeline.OptionDeleteAfter, false),
+		fileTypes:   config.GetStringSliceOption(pipeline.OptionFileTypes),
+	}, nil
+}
+
+func (s *WebDAVUploadStage) Name() string {
+	return pipeline.StageNameWebDAVUpload
+}
+
+func (s *WebDAVUploadStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	if len(input) == 0 {
+		s.logs = "没有输入文件"
+		return input, nil
+	}
+
+	job := uploadJob{
+		fileTypes:   s.fileTypes,
+		deleteAfter: s.deleteAfter,
+		target: func(file pipeline.FileInfo) (string, error) {
+			return renderUploadPath(s.pathTmpl, ctx, file)
+		},
+		upload: func(file pipeline.FileInfo, targetPath string, onProgress func(int64)) (string, error) {
+			result, err := s.client.UploadFile(ctx.Ctx, file.Path, targetPath, webdav.UploadOptions{
+				Chunked:    s.chunked,
+				OnProgress: func(uploaded, _ int64) { onProgress(uploaded) },
+			})
+			if err != nil {
+				return "", err
+			}
+			return result.Path, nil
+		},
+	}
+	return job.run(ctx, input, &s.commands, &s.logs)
+}
+
+func (s *WebDAVUploadStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *WebDAVUploadStage) GetLogs() string {
+	return s.logs
+}
@@ -0,0 +1,237 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+const (
+	defaultWebhookSignatureHeader = "X-Bililive-Signature-256"
+	defaultWebhookMaxRetries      = 2
+	defaultWebhookRetryInterval   = 2.0  // 秒
+	defaultWebhookRequestTimeout  = 10.0 // 秒
+)
+
+// webhookClient 发送 Webhook 请求的客户端，超时由每个请求的上下文控制
+var webhookClient = &http.Client{}
+
+// WebhookStage Webhook 通知阶段
+// 把录制信息和当前文件列表以 JSON 形式 POST 到配置的地址，文件原样传给下一阶段
+type WebhookStage struct {
+	config          pipeline.StageConfig
+	urls            []string
+	bodyTmpl        *template.Template // 为空时使用默认格式
+	headers         map[string]string
+	secret          string
+	signatureHeader string
+	perFile         bool
+	maxRetries      int
+	retryInterval   time.Duration
+	requestTimeout  time.Duration
+	failOnError     bool
+	commands        []string
+	logs            string
+}
+
+// webhookData 请求体模板可以引用的字段
+type webhookData struct {
+	LiveID    string
+	Platform  string
+	HostName  string
+	RoomName  string
+	StartTime time.Time
+	Files     []pipeline.FileInfo
+	File      pipeline.FileInfo // per_file 时为当前文件，否则为第一个文件
+}
+
+// webhookPayload 未配置模板时的默认请求体
+type webhookPayload struct {
+	RecordInfo pipeline.RecordInfo `json:"record_info"`
+	Files      []pipeline.FileInfo `json:"files"`
+}
+
+// NewWebhookStage 创建 Webhook 阶段工厂
+func NewWebhookStage(config pipeline.StageConfig) (pipeline.Stage, error) {
+	s := &WebhookStage{
+		config:          config,
+		urls:            config.GetStringSliceOption(pipeline.OptionURLs),
+		headers:         config.GetStringMapOption(pipeline.OptionHeaders),
+		secret:          config.GetStringOption(pipeline.OptionSecret, ""),
+		signatureHeader: config.GetStringOption(pipeline.OptionSignatureHeader, defaultWebhookSignatureHeader),
+		perFile:         config.GetBoolOption(pipeline.OptionPerFile, false),
+		maxRetries:      config.GetIntOption(pipeline.OptionMaxRetries, defaultWebhookMaxRetries),
+		retryInterval:   time.Duration(config.GetFloatOption(pipeline.OptionRetryInterval, defaultWebhookRetryInterval) * float64(time.Second)),
+		requestTimeout:  time.Duration(config.GetFloatOption(pipeline.OptionRequestTimeout, defaultWebhookRequestTimeout) * float64(time.Second)),
+		failOnError:     config.GetBoolOption(pipeline.OptionFailOnError, false),
+	}
+	if len(s.urls) == 0 {
+		return nil, fmt.Errorf("webhook stage requires 'urls' option")
+	}
+	if s.maxRetries < 0 {
+		return nil, fmt.Errorf("webhook: max_retries must not be negative")
+	}
+	if s.requestTimeout <= 0 {
+		return nil, fmt.Errorf("webhook: request_timeout must be positive")
+	}
+	if text := config.GetStringOption(pipeline.OptionBodyTemplate, ""); text != "" {
+		tmpl, err := template.New("webhook").Funcs(utils.GetFuncMap(pipeline.TemplateConfig())).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("webhook: invalid body template: %w", err)
+		}
+		s.bodyTmpl = tmpl
+	}
+	return s, nil
+}
+
+func (s *WebhookStage) Name() string {
+	return pipeline.StageNameWebhook
+}
+
+func (s *WebhookStage) Execute(ctx *pipeline.PipelineContext, input []pipeline.FileInfo) ([]pipeline.FileInfo, error) {
+	batches := [][]pipeline.FileInfo{input}
+	if s.perFile {
+		if len(input) == 0 {
+			s.logs = "没有输入文件"
+			return input, nil
+		}
+		batches = batches[:0]
+		for _, file := range input {
+			batches = append(batches, []pipeline.FileInfo{file})
+		}
+	}
+
+	for _, files := range batches {
+		body, err := s.renderBody(ctx, files)
+		if err != nil {
+			s.logs += fmt.Sprintf("渲染请求体失败: %s\n", err)
+			ctx.Logger.Warnf("Webhook 渲染请求体失败: %s", err)
+			if s.failOnError {
+				return nil, fmt.Errorf("webhook: %w", err)
+			}
+			continue
+		}
+		for _, url := range s.urls {
+			if err := s.send(ctx, url, body); err != nil {
+				s.logs += fmt.Sprintf("通知失败: %s: %s\n", url, err)
+				ctx.Logger.Warnf("Webhook 通知失败: %s: %s", url, err)
+				if s.failOnError {
+					return nil, fmt.Errorf("webhook %s failed: %w", url, err)
+				}
+			}
+		}
+	}
+	return input, nil
+}
+
+// renderBody 渲染请求体并检查是否为合法的 JSON
+func (s *WebhookStage) renderBody(ctx *pipeline.PipelineContext, files []pipeline.FileInfo) ([]byte, error) {
+	if files == nil {
+		files = []pipeline.FileInfo{}
+	}
+	if s.bodyTmpl == nil {
+		return json.Marshal(webhookPayload{RecordInfo: ctx.RecordInfo, Files: files})
+	}
+
+	data := webhookData{
+		LiveID:    string(ctx.RecordInfo.LiveID),
+		Platform:  ctx.RecordInfo.Platform,
+		HostName:  ctx.RecordInfo.HostName,
+		RoomName:  ctx.RecordInfo.RoomName,
+		StartTime: ctx.RecordInfo.StartTime,
+		Files:     files,
+	}
+	if len(files) > 0 {
+		data.File = files[0]
+	}
+	var buf bytes.Buffer
+	if err := s.bodyTmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute body template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("body template did not produce valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// send 发送请求，网络错误和非 2xx 响应按指数退避重试
+func (s *WebhookStage) send(ctx *pipeline.PipelineContext, url string, body []byte) error {
+	s.commands = append(s.commands, fmt.Sprintf("POST %s", url))
+	wait := s.retryInterval
+	var err error
+	for attempt := 0; ; attempt++ {
+		var status int
+		status, err = s.post(ctx.Ctx, url, body)
+		if err == nil {
+			s.logs += fmt.Sprintf("已通知 %s: HTTP %d\n", url, status)
+			return nil
+		}
+		if attempt >= s.maxRetries || ctx.Ctx.Err() != nil {
+			return err
+		}
+		s.logs += fmt.Sprintf("请求 %s 失败: %s，%s 后重试\n", url, err, wait)
+		select {
+		case <-ctx.Ctx.Done():
+			return ctx.Ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// post 发送一次请求，返回状态码
+func (s *WebhookStage) post(parent context.Context, url string, body []byte) (int, error) {
+	reqCtx, cancel := context.WithTimeout(parent, s.requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	if s.secret != "" {
+		req.Header.Set(s.signatureHeader, signWebhookBody(s.secret, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// signWebhookBody 计算请求体的 HMAC-SHA256 签名，格式为 sha256=<hex>
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookStage) GetCommands() []string {
+	return s.commands
+}
+
+func (s *WebhookStage) GetLogs() string {
+	return s.logs
+}
@@ -0,0 +1,164 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
+)
+
+func newWebhookTestContext() *pipeline.PipelineContext {
+	return &pipeline.PipelineContext{
+		Ctx: context.Background(),
+		RecordInfo: pipeline.RecordInfo{
+			LiveID:   "abc",
+			Platform: "哔哩哔哩",
+			HostName: "主播",
+			RoomName: "房间",
+		},
+		Logger: livelogger.New(livelogger.DefaultBufferSize, logrus.Fields{}),
+	}
+}
+
+func newWebhookTestStage(t *testing.T, options map[string]any) *WebhookStage {
+	t.Helper()
+	stage, err := NewWebhookStage(pipeline.StageConfig{Name: pipeline.StageNameWebhook, Options: options})
+	require.NoError(t, err)
+	return stage.(*WebhookStage)
+}
+
+func TestWebhookStageDefaultPayloadAndSignature(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	stage := newWebhookTestStage(t, map[string]any{
+		pipeline.OptionURLs:    []any{server.URL},
+		pipeline.OptionHeaders: map[string]any{"Authorization": "Bearer token"},
+		pipeline.OptionSecret:  "secret",
+	})
+	input := []pipeline.FileInfo{pipeline.NewVideoFileInfo("/rec/a.mp4")}
+	output, err := stage.Execute(newWebhookTestContext(), input)
+	require.NoError(t, err)
+	assert.Equal(t, input, output)
+
+	var payload webhookPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "主播", payload.RecordInfo.HostName)
+	assert.Equal(t, input, payload.Files)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Equal(t, signWebhookBody("secret", body), header.Get(defaultWebhookSignatureHeader))
+}
+
+func TestWebhookStageBodyTemplatePerFile(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer server.Close()
+
+	stage := newWebhookTestStage(t, map[string]any{
+		pipeline.OptionURLs:         []any{server.URL},
+		pipeline.OptionPerFile:      true,
+		pipeline.OptionBodyTemplate: `{"host": {{ toJson .HostName }}, "path": {{ toJson .File.Path }}}`,
+	})
+	_, err := stage.Execute(newWebhookTestContext(), []pipeline.FileInfo{
+		pipeline.NewVideoFileInfo("/rec/a.mp4"),
+		pipeline.NewVideoFileInfo("/rec/b.mp4"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"host": "主播", "path": "/rec/a.mp4"}`,
+		`{"host": "主播", "path": "/rec/b.mp4"}`,
+	}, bodies)
+}
+
+func TestWebhookStageRetriesAndFailOnError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 前两次失败，第三次成功
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	options := map[string]any{
+		pipeline.OptionURLs:          []any{server.URL},
+		pipeline.OptionMaxRetries:    2,
+		pipeline.OptionRetryInterval: 0.001,
+		pipeline.OptionFailOnError:   true,
+	}
+	_, err := newWebhookTestStage(t, options).Execute(newWebhookTestContext(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// 重试次数用尽后按 fail_on_error 决定是否失败
+	calls.Store(-10)
+	_, err = newWebhookTestStage(t, options).Execute(newWebhookTestContext(), nil)
+	assert.ErrorContains(t, err, "HTTP 502")
+
+	calls.Store(-10)
+	options[pipeline.OptionFailOnError] = false
+	_, err = newWebhookTestStage(t, options).Execute(newWebhookTestContext(), nil)
+	assert.NoError(t, err)
+}
+
+func TestWebhookStageInvalidConfig(t *testing.T) {
+	_, err := NewWebhookStage(pipeline.StageConfig{Name: pipeline.StageNameWebhook})
+	assert.Error(t, err)
+
+	_, err = NewWebhookStage(pipeline.StageConfig{Name: pipeline.StageNameWebhook, Options: map[string]any{
+		pipeline.OptionURLs:         []any{"http://localhost"},
+		pipeline.OptionBodyTemplate: "{{ .HostName",
+	}})
+	assert.Error(t, err)
+}
+
+func TestWebhookStageRenderErrorFollowsFailOnError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	// 未转义的主播名让请求体不是合法的 JSON
+	options := map[string]any{
+		pipeline.OptionURLs:         []any{server.URL},
+		pipeline.OptionBodyTemplate: `{"host": "{{ .HostName }}"}`,
+	}
+	ctx := newWebhookTestContext()
+	ctx.RecordInfo.HostName = `"主播"`
+	input := []pipeline.FileInfo{pipeline.NewVideoFileInfo("/rec/a.mp4")}
+
+	output, err := newWebhookTestStage(t, options).Execute(ctx, input)
+	require.NoError(t, err)
+	assert.Equal(t, input, output)
+	assert.Zero(t, calls.Load())
+
+	options[pipeline.OptionFailOnError] = true
+	_, err = newWebhookTestStage(t, options).Execute(ctx, input)
+	assert.ErrorContains(t, err, "valid JSON")
+
+	// toJson 转义后可以正常发送
+	options[pipeline.OptionBodyTemplate] = `{"host": {{ toJson .HostName }}}`
+	_, err = newWebhookTestStage(t, options).Execute(ctx, input)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -298,6 +298,7 @@ func (s *SQLiteStore) GetPendingTasks(ctx context.Context, limit int) ([]*Pipeli
 }
 
 // ResetRunningTasks 重置所有运行中的任务为待执行
+// 已持久化的阶段结果保留，任务重新调度后从第一个未完成的阶段继续
 func (s *SQLiteStore) ResetRunningTasks(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
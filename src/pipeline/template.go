@@ -0,0 +1,12 @@
+package pipeline
+
+import "github.com/bililive-go/bililive-go/src/configs"
+
+// TemplateConfig 模板函数需要的配置，未加载配置时使用默认值
+// when 表达式和各阶段的模板共用
+func TemplateConfig() *configs.Config {
+	if cfg := configs.GetCurrentConfig(); cfg != nil {
+		return cfg
+	}
+	return &configs.Config{}
+}
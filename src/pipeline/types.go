@@ -79,9 +79,21 @@ type PipelineContext struct {
 	Logger     *livelogger.LiveLogger // 日志记录器
 	WorkDir    string                 // 工作目录
 	TempDir    string                 // 临时文件目录
+	SessionID  int64                  // 开播会话ID，0 表示未知
 
 	// FFmpegPath 是 ffmpeg 可执行文件的路径
 	FFmpegPath string
+
+	// OnProgress 接收当前阶段的进度百分比（0-100），由管理器设置，可能为 nil
+	OnProgress func(percent float64)
+}
+
+// ReportProgress 上报当前阶段的进度百分比，阶段可以在任意 goroutine 中调用
+func (c *PipelineContext) ReportProgress(percent float64) {
+	if c.OnProgress == nil {
+		return
+	}
+	c.OnProgress(min(max(percent, 0), 100))
 }
 
 // Stage 管道阶段接口
@@ -100,10 +112,18 @@ type StageFactory func(config StageConfig) (Stage, error)
 
 // StageConfig 阶段配置（用于 YAML/JSON 配置）
 type StageConfig struct {
-	Name     string         `yaml:"name" json:"name"`                   // 阶段名称
-	Enabled  *bool          `yaml:"enabled,omitempty" json:"enabled"`   // 是否启用（nil 表示 true）
-	Parallel []StageConfig  `yaml:"parallel,omitempty" json:"parallel"` // 并行执行的子阶段
-	Options  map[string]any `yaml:"options,omitempty" json:"options"`   // 阶段特定选项
+	ID        string         `yaml:"id,omitempty" json:"id,omitempty"`                 // 阶段标识，供 depends_on 引用
+	DependsOn []string       `yaml:"depends_on,omitempty" json:"depends_on,omitempty"` // 依赖的阶段 ID，设置后管道按 DAG 执行
+	Name      string         `yaml:"name" json:"name"`                                 // 阶段名称
+	Enabled   *bool          `yaml:"enabled,omitempty" json:"enabled"`                 // 是否启用（nil 表示 true）
+	Parallel  []StageConfig  `yaml:"parallel,omitempty" json:"parallel"`               // 并行执行的子阶段
+	Options   map[string]any `yaml:"options,omitempty" json:"options"`                 // 阶段特定选项
+	When      string         `yaml:"when,omitempty" json:"when,omitempty"`             // 执行条件，为空表示总是执行
+
+	// 失败重试和超时，时长使用 Go duration 格式，例如 30s、2h
+	Retries      int    `yaml:"retries,omitempty" json:"retries,omitempty"`             // 失败后自动重试的次数
+	RetryBackoff string `yaml:"retry_backoff,omitempty" json:"retry_backoff,omitempty"` // 首次重试前的等待时间，之后每次翻倍
+	Timeout      string `yaml:"timeout,omitempty" json:"timeout,omitempty"`             // 单次执行的超时时间，为空表示不限制
 }
 
 // IsEnabled 检查阶段是否启用
@@ -152,6 +172,28 @@ func (sc *StageConfig) GetStringOption(key string, defaultValue string) string {
 	return defaultValue
 }
 
+// GetFloatOption 获取数值类型选项（YAML 解析为整数、JSON 解析为浮点数，两者都接受）
+func (sc *StageConfig) GetFloatOption(key string, defaultValue float64) float64 {
+	v, ok := sc.GetOption(key)
+	if !ok {
+		return defaultValue
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return defaultValue
+}
+
+// GetIntOption 获取整数类型选项
+func (sc *StageConfig) GetIntOption(key string, defaultValue int) int {
+	return int(sc.GetFloatOption(key, float64(defaultValue)))
+}
+
 // GetStringSliceOption 获取字符串切片类型选项
 func (sc *StageConfig) GetStringSliceOption(key string) []string {
 	v, ok := sc.GetOption(key)
@@ -173,15 +215,66 @@ func (sc *StageConfig) GetStringSliceOption(key string) []string {
 	return nil
 }
 
+// GetStringMapOption 获取字符串映射类型选项，非字符串的值会被忽略
+func (sc *StageConfig) GetStringMapOption(key string) map[string]string {
+	v, ok := sc.GetOption(key)
+	if !ok {
+		return nil
+	}
+	switch val := v.(type) {
+	case map[string]string:
+		return val
+	case map[string]any:
+		result := make(map[string]string, len(val))
+		for k, item := range val {
+			if s, ok := item.(string); ok {
+				result[k] = s
+			}
+		}
+		return result
+	}
+	return nil
+}
+
 // PipelineConfig 管道配置
 type PipelineConfig struct {
 	Stages []StageConfig `yaml:"stages" json:"stages"` // 阶段列表
 }
 
+// IsDAG 是否有阶段声明了依赖，此时不再按列表顺序执行
+func (pc *PipelineConfig) IsDAG() bool {
+	for _, stage := range pc.Stages {
+		if len(stage.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasStage 是否包含指定名称的启用阶段（含并行子阶段）
+func (pc *PipelineConfig) HasStage(name string) bool {
+	for _, stage := range pc.Stages {
+		if !stage.IsEnabled() {
+			continue
+		}
+		if stage.Name == name {
+			return true
+		}
+		for _, ps := range stage.Parallel {
+			if ps.Name == name && ps.IsEnabled() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // PipelineStatus 管道任务状态
 type PipelineStatus string
 
 const (
+	// PipelineStatusWaiting 等待关播，会话合并任务在本场所有分段就绪前处于此状态
+	PipelineStatusWaiting PipelineStatus = "waiting"
 	// PipelineStatusPending 等待执行
 	PipelineStatusPending PipelineStatus = "pending"
 	// PipelineStatusRunning 正在执行
@@ -222,6 +315,36 @@ type StageResult struct {
 	Commands     []string    `json:"commands,omitempty"` // 执行的命令
 	Logs         string      `json:"logs,omitempty"`     // 执行日志
 	ErrorMessage string      `json:"error_message,omitempty"`
+	Attempts     int         `json:"attempts,omitempty"` // 执行次数，包括自动重试
+
+	// SubResults 并行组中因执行条件不满足而跳过的分支
+	SubResults []StageResult `json:"sub_results,omitempty"`
+
+	// DAG 管道中阶段的位置，线性管道不设置
+	StageID   string   `json:"stage_id,omitempty"`
+	DependsOn []string `json:"depends_on,omitempty"` // 实际生效的依赖（已跳过禁用的阶段）
+	Level     int      `json:"level,omitempty"`      // 距离起始阶段的层数，起始阶段为 0
+	Terminal  bool     `json:"terminal,omitempty"`   // 没有后继阶段，输出计入任务最终文件
+}
+
+// FinalOutputFiles 管道执行完成后的最终文件
+// 线性管道取最后一个阶段的输出，DAG 管道合并所有末端阶段的输出
+func FinalOutputFiles(results []StageResult) []FileInfo {
+	if len(results) == 0 {
+		return nil
+	}
+	var files []FileInfo
+	dag := false
+	for _, result := range results {
+		if result.Terminal {
+			dag = true
+			files = append(files, result.OutputFiles...)
+		}
+	}
+	if !dag {
+		return results[len(results)-1].OutputFiles
+	}
+	return deduplicateFiles(files)
 }
 
 // PipelineTask 管道任务（持久化到数据库）
@@ -277,6 +400,36 @@ func (pt *PipelineTask) UpdateProgress() {
 	pt.Progress = (pt.CurrentStage * 100) / pt.TotalStages
 }
 
+// prepareResume 按可沿用的阶段结果重新计算当前阶段、进度和当前文件
+// 用于从失败处重试和程序重启后继续执行之前
+func (pt *PipelineTask) prepareResume() {
+	reusable := reusableResults(pt.StageResults)
+	done := 0
+	if pt.PipelineConfig != nil && pt.PipelineConfig.IsDAG() {
+		done = len(reusable)
+	} else {
+		files := pt.InitialFiles
+		for ; ; done++ {
+			result, ok := reusable[done]
+			if !ok {
+				break
+			}
+			files = result.OutputFiles
+		}
+		pt.CurrentFiles = files
+	}
+	pt.CurrentStage = done
+	pt.UpdateProgress()
+}
+
+// UpdateStageProgress 按当前阶段内的进度百分比更新任务进度
+func (pt *PipelineTask) UpdateStageProgress(percent float64) {
+	if pt.TotalStages == 0 {
+		return
+	}
+	pt.Progress = (pt.CurrentStage*100 + int(percent)) / pt.TotalStages
+}
+
 // MarkStarted 标记任务开始
 func (pt *PipelineTask) MarkStarted() {
 	now := time.Now()
@@ -314,6 +467,17 @@ func (pt *PipelineTask) AddStageResult(result StageResult) {
 	pt.StageResults = append(pt.StageResults, result)
 }
 
+// SetStageResult 记录阶段结果，替换同一阶段之前的结果
+func (pt *PipelineTask) SetStageResult(result StageResult) {
+	for i := range pt.StageResults {
+		if pt.StageResults[i].StageIndex == result.StageIndex {
+			pt.StageResults[i] = result
+			return
+		}
+	}
+	pt.AddStageResult(result)
+}
+
 // GetLastStageResult 获取最后一个阶段结果
 func (pt *PipelineTask) GetLastStageResult() *StageResult {
 	if len(pt.StageResults) == 0 {
@@ -0,0 +1,220 @@
+package danmaku
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Options ASS 转换选项
+type Options struct {
+	Width    int     // 字幕画布宽度，通常与视频分辨率一致，为 0 时使用 1920
+	Height   int     // 字幕画布高度，为 0 时使用 1080
+	FontName string  // 为空时使用 Microsoft YaHei
+	FontSize int     // 为 0 时按画布高度换算，1080p 下为 36
+	Lanes    int     // 弹幕轨道数，为 0 时铺满画面
+	Opacity  float64 // 不透明度（0-1），为 0 时使用 0.8
+	Duration float64 // 滚动弹幕横穿画面的秒数，顶部和底部弹幕显示一半的时间，为 0 时使用 8
+
+	BlockedWords []string // 包含任一关键词（不区分大小写）的弹幕被过滤
+	FilterGifts  bool     // 过滤礼物、醒目留言和上舰
+	FilterSystem bool     // 过滤系统弹幕
+}
+
+// Stats 转换结果统计
+type Stats struct {
+	Total    int // 弹幕总数
+	Filtered int // 按规则过滤的数量
+	Dropped  int // 轨道已满而丢弃的数量
+	Rendered int // 写入字幕的数量
+}
+
+func (o *Options) setDefaults() {
+	if o.Width <= 0 {
+		o.Width = 1920
+	}
+	if o.Height <= 0 {
+		o.Height = 1080
+	}
+	if o.FontName == "" {
+		o.FontName = "Microsoft YaHei"
+	}
+	if o.FontSize <= 0 {
+		o.FontSize = max(o.Height*36/1080, 12)
+	}
+	if o.Opacity <= 0 || o.Opacity > 1 {
+		o.Opacity = 0.8
+	}
+	if o.Duration <= 0 {
+		o.Duration = 8
+	}
+	if maxLanes := o.Height / o.lineHeight(); o.Lanes <= 0 || o.Lanes > maxLanes {
+		o.Lanes = max(maxLanes, 1)
+	}
+}
+
+func (o *Options) lineHeight() int {
+	return o.FontSize * 5 / 4
+}
+
+// filtered 判断弹幕是否被过滤规则排除
+func (o *Options) filtered(c Comment) bool {
+	switch c.Kind {
+	case KindGift, KindSuperChat, KindGuard:
+		if o.FilterGifts {
+			return true
+		}
+	case KindSystem:
+		// 高级弹幕无法渲染，不受 FilterSystem 影响
+		if o.FilterSystem || (c.Mode != ModeScroll && c.Mode != ModeTop && c.Mode != ModeBottom) {
+			return true
+		}
+	}
+	text := strings.ToLower(c.Text)
+	for _, word := range o.BlockedWords {
+		if word != "" && strings.Contains(text, strings.ToLower(word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrollLane 滚动轨道上最后一条弹幕的位置信息
+type scrollLane struct {
+	start float64 // 出现时间
+	width float64 // 文字宽度
+	used  bool
+}
+
+// WriteASS 把弹幕转换为 ASS 字幕写入 w
+// 滚动弹幕从右向左移动，同一轨道内的弹幕互不重叠，没有空闲轨道时丢弃
+func WriteASS(w io.Writer, comments []Comment, opts Options) (Stats, error) {
+	opts.setDefaults()
+	stats := Stats{Total: len(comments)}
+
+	sorted := make([]Comment, len(comments))
+	copy(sorted, comments)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, opts)
+
+	lineHeight := float64(opts.lineHeight())
+	width := float64(opts.Width)
+	fixedDuration := opts.Duration / 2
+	scroll := make([]scrollLane, opts.Lanes)
+	top := make([]float64, opts.Lanes)    // 各轨道空闲的时间
+	bottom := make([]float64, opts.Lanes) // 各轨道空闲的时间
+
+	for _, c := range sorted {
+		if opts.filtered(c) {
+			stats.Filtered++
+			continue
+		}
+		textWidth := estimateWidth(c.Text, opts.FontSize)
+		var end float64
+		var tags string
+
+		switch c.Mode {
+		case ModeTop, ModeBottom:
+			lanes := top
+			if c.Mode == ModeBottom {
+				lanes = bottom
+			}
+			lane := -1
+			for i, free := range lanes {
+				if c.Time >= free {
+					lane = i
+					break
+				}
+			}
+			if lane < 0 {
+				stats.Dropped++
+				continue
+			}
+			end = c.Time + fixedDuration
+			lanes[lane] = end
+			if c.Mode == ModeTop {
+				tags = fmt.Sprintf(`\an8\pos(%d,%d)`, opts.Width/2, int(float64(lane)*lineHeight))
+			} else {
+				tags = fmt.Sprintf(`\an2\pos(%d,%d)`, opts.Width/2, opts.Height-int(float64(lane)*lineHeight))
+			}
+		default:
+			lane := -1
+			speed := (width + textWidth) / opts.Duration
+			for i, l := range scroll {
+				if !l.used {
+					lane = i
+					break
+				}
+				prevSpeed := (width + l.width) / opts.Duration
+				// 上一条弹幕的尾部已完全进入画面，且本条弹幕追上它之前它已离开画面
+				entered := c.Time >= l.start+l.width/prevSpeed
+				noCatchUp := c.Time+width/speed >= l.start+opts.Duration
+				if entered && noCatchUp {
+					lane = i
+					break
+				}
+			}
+			if lane < 0 {
+				stats.Dropped++
+				continue
+			}
+			scroll[lane] = scrollLane{start: c.Time, width: textWidth, used: true}
+			end = c.Time + opts.Duration
+			y := int(float64(lane) * lineHeight)
+			tags = fmt.Sprintf(`\move(%d,%d,%d,%d)`, opts.Width, y, -int(math.Ceil(textWidth)), y)
+		}
+
+		if color := c.Color & 0xFFFFFF; color != 0xFFFFFF && c.Color != 0 {
+			tags += fmt.Sprintf(`\c&H%02X%02X%02X&`, color&0xFF, (color>>8)&0xFF, color>>16)
+		}
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Danmaku,,0,0,0,,{%s}%s\n",
+			formatTime(c.Time), formatTime(end), tags, escapeText(c.Text))
+		stats.Rendered++
+	}
+
+	return stats, bw.Flush()
+}
+
+func writeHeader(w io.Writer, opts Options) {
+	alpha := int(math.Round((1 - opts.Opacity) * 255))
+	fmt.Fprintf(w, "[Script Info]\nScriptType: v4.00+\nPlayResX: %d\nPlayResY: %d\nWrapStyle: 2\nScaledBorderAndShadow: yes\n\n",
+		opts.Width, opts.Height)
+	fmt.Fprint(w, "[V4+ Styles]\n")
+	fmt.Fprint(w, "Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, "+
+		"Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, "+
+		"Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(w, "Style: Danmaku,%s,%d,&H%02XFFFFFF,&H%02XFFFFFF,&H%02X000000,&H%02X000000,0,0,0,0,100,100,0,0,1,%d,0,7,0,0,0,1\n\n",
+		opts.FontName, opts.FontSize, alpha, alpha, alpha, alpha, max(opts.FontSize/24, 1))
+	fmt.Fprint(w, "[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+}
+
+// estimateWidth 估算文字宽度：ASCII 字符约为半个字宽，其余按一个字宽计算
+func estimateWidth(text string, fontSize int) float64 {
+	var width float64
+	for _, r := range text {
+		if r < 0x80 {
+			width += float64(fontSize) * 0.55
+		} else {
+			width += float64(fontSize)
+		}
+	}
+	return width
+}
+
+// formatTime 格式化为 ASS 时间 H:MM:SS.cc
+func formatTime(seconds float64) string {
+	cs := int64(math.Round(seconds * 100))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+var assTextReplacer = strings.NewReplacer(`\`, `＼`, `{`, `｛`, `}`, `｝`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// escapeText 替换会被解释为样式代码的字符，并把换行合并为空格
+func escapeText(text string) string {
+	return assTextReplacer.Replace(text)
+}
@@ -0,0 +1,168 @@
+// Package danmaku 解析 BililiveRecorder 格式的弹幕 XML，并转换为 ASS 字幕
+package danmaku
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Kind 弹幕类型
+type Kind int
+
+const (
+	// KindDanmaku 普通弹幕
+	KindDanmaku Kind = iota
+	// KindGift 礼物
+	KindGift
+	// KindSuperChat 醒目留言
+	KindSuperChat
+	// KindGuard 上舰
+	KindGuard
+	// KindSystem 系统弹幕（非普通弹幕池或高级弹幕）
+	KindSystem
+)
+
+// 弹幕位置，与 p 属性中的 mode 取值一致
+const (
+	ModeScroll = 1
+	ModeBottom = 4
+	ModeTop    = 5
+)
+
+// Comment 一条弹幕
+type Comment struct {
+	Kind     Kind
+	Time     float64 // 相对录制开始的秒数
+	Mode     int
+	FontSize int
+	Color    int // RGB
+	User     string
+	Text     string
+}
+
+// ParseFile 解析弹幕文件
+func ParseFile(path string) ([]Comment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse 解析弹幕 XML
+// 录制异常中断时文件可能没有结束标签，此时返回已解析的部分
+func Parse(r io.Reader) ([]Comment, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	var comments []Comment
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) || (len(comments) > 0 && errors.Is(err, io.ErrUnexpectedEOF)) {
+				return comments, nil
+			}
+			var syntaxErr *xml.SyntaxError
+			if len(comments) > 0 && errors.As(err, &syntaxErr) {
+				return comments, nil
+			}
+			return nil, fmt.Errorf("danmaku: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var c Comment
+		switch start.Name.Local {
+		case "d":
+			var text string
+			if err := decoder.DecodeElement(&text, &start); err != nil {
+				continue
+			}
+			c, ok = parseDanmaku(attr(start, "p"), attr(start, "user"), text)
+		case "gift":
+			c, ok = parseEvent(start, KindGift, fmt.Sprintf("%s 赠送 %s ×%s", attr(start, "user"), attr(start, "giftname"), attr(start, "giftcount")))
+		case "guard":
+			c, ok = parseEvent(start, KindGuard, fmt.Sprintf("%s 开通了 %s", attr(start, "user"), guardName(attr(start, "level"))))
+		case "sc":
+			var text string
+			if err := decoder.DecodeElement(&text, &start); err != nil {
+				continue
+			}
+			c, ok = parseEvent(start, KindSuperChat, fmt.Sprintf("SC ¥%s %s: %s", attr(start, "price"), attr(start, "user"), strings.TrimSpace(text)))
+		default:
+			continue
+		}
+		if ok {
+			comments = append(comments, c)
+		}
+	}
+}
+
+// parseDanmaku 解析普通弹幕，p 属性格式为 时间,类型,字号,颜色,时间戳,弹幕池,用户哈希,弹幕ID
+func parseDanmaku(p, user, text string) (Comment, bool) {
+	fields := strings.Split(p, ",")
+	if len(fields) < 4 {
+		return Comment{}, false
+	}
+	t, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || t < 0 {
+		return Comment{}, false
+	}
+	c := Comment{Kind: KindDanmaku, Time: t, User: user, Text: strings.TrimSpace(text)}
+	c.Mode, _ = strconv.Atoi(fields[1])
+	c.FontSize, _ = strconv.Atoi(fields[2])
+	c.Color, _ = strconv.Atoi(fields[3])
+	if len(fields) > 5 && fields[5] != "0" {
+		c.Kind = KindSystem
+	}
+	switch c.Mode {
+	case ModeScroll, 2, 3, 6:
+		// 2、3 为旧版滚动弹幕，6 为逆向滚动，都按普通滚动弹幕处理
+		c.Mode = ModeScroll
+	case ModeBottom, ModeTop:
+	default:
+		// 高级弹幕和代码弹幕无法转换为普通字幕
+		c.Kind = KindSystem
+	}
+	return c, c.Text != ""
+}
+
+// parseEvent 解析礼物、醒目留言和上舰，ts 属性为相对录制开始的秒数
+func parseEvent(start xml.StartElement, kind Kind, text string) (Comment, bool) {
+	t, err := strconv.ParseFloat(attr(start, "ts"), 64)
+	if err != nil || t < 0 {
+		return Comment{}, false
+	}
+	c := Comment{Kind: kind, Time: t, Mode: ModeBottom, Color: 0xFFFFFF, User: attr(start, "user"), Text: text}
+	if kind == KindSuperChat {
+		c.Color = 0xFFD700
+	}
+	return c, true
+}
+
+func guardName(level string) string {
+	switch level {
+	case "1":
+		return "总督"
+	case "2":
+		return "提督"
+	default:
+		return "舰长"
+	}
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package danmaku
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleXML = `<?xml version="1.0" encoding="utf-8"?>
+<?xml-stylesheet type="text/xsl" href="#s"?>
+<i>
+<chatserver>chat.bilibili.com</chatserver>
+<BililiveRecorder version="2.6.0" />
+<BililiveRecorderRecordInfo roomid="1" name="主播" title="标题" start_time="2024-01-01T20:00:00+08:00" />
+<d p="1.5,1,25,16777215,1704110401500,0,a1b2c3,0" user="观众A">第一条</d>
+<d p="0.5,5,25,16711680,1704110400500,0,a1b2c4,0" user="观众B">置顶 {红色}</d>
+<d p="2,7,25,16777215,1704110402000,0,a1b2c5,0" user="观众C">[0,0,"1-1",4.5,"高级"]</d>
+<d p="3,1,25,16777215,1704110403000,2,0,0" user="系统">系统消息</d>
+<gift ts="4.25" user="观众D" uid="4" giftname="辣条" giftcount="10" />
+<sc ts="5" user="观众E" uid="5" price="30" time="60">加油</sc>
+<guard ts="6" user="观众F" uid="6" level="3" count="1" />
+</i>`
+
+func TestParse(t *testing.T) {
+	comments, err := Parse(strings.NewReader(sampleXML))
+	require.NoError(t, err)
+	require.Len(t, comments, 7)
+
+	assert.Equal(t, Comment{Kind: KindDanmaku, Time: 1.5, Mode: ModeScroll, FontSize: 25, Color: 0xFFFFFF, User: "观众A", Text: "第一条"}, comments[0])
+	assert.Equal(t, ModeTop, comments[1].Mode)
+	assert.Equal(t, 0xFF0000, comments[1].Color)
+	assert.Equal(t, KindSystem, comments[2].Kind)
+	assert.Equal(t, KindSystem, comments[3].Kind)
+	assert.Equal(t, Comment{Kind: KindGift, Time: 4.25, Mode: ModeBottom, Color: 0xFFFFFF, User: "观众D", Text: "观众D 赠送 辣条 ×10"}, comments[4])
+	assert.Equal(t, "SC ¥30 观众E: 加油", comments[5].Text)
+	assert.Equal(t, "观众F 开通了 舰长", comments[6].Text)
+}
+
+func TestParseTruncated(t *testing.T) {
+	// 录制中断时文件没有结束标签
+	truncated := sampleXML[:strings.Index(sampleXML, "<gift")] + `<d p="7,1,25,167`
+	comments, err := Parse(strings.NewReader(truncated))
+	require.NoError(t, err)
+	assert.Len(t, comments, 4)
+
+	_, err = Parse(strings.NewReader("not xml <"))
+	assert.Error(t, err)
+}
+
+func TestWriteASSFilters(t *testing.T) {
+	comments, err := Parse(strings.NewReader(sampleXML))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	stats, err := WriteASS(&buf, comments, Options{FilterGifts: true, FilterSystem: true, BlockedWords: []string{"第一"}})
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Total: 7, Filtered: 6, Rendered: 1}, stats)
+
+	out := buf.String()
+	assert.Contains(t, out, "PlayResX: 1920\nPlayResY: 1080\n")
+	assert.Contains(t, out, "Style: Danmaku,Microsoft YaHei,36,&H33FFFFFF,")
+	// 顶部弹幕居中显示一半的滚动时长，颜色转换为 BGR，花括号被替换
+	assert.Contains(t, out, `Dialogue: 0,0:00:00.50,0:00:04.50,Danmaku,,0,0,0,,{\an8\pos(960,0)\c&H0000FF&}置顶 ｛红色｝`)
+
+	buf.Reset()
+	stats, err = WriteASS(&buf, comments, Options{Width: 1280, Height: 720, FontSize: 30, Opacity: 1, Duration: 10})
+	require.NoError(t, err)
+	// 高级弹幕总是被过滤
+	assert.Equal(t, Stats{Total: 7, Filtered: 1, Rendered: 6}, stats)
+	assert.Contains(t, buf.String(), `Dialogue: 0,0:00:01.50,0:00:11.50,Danmaku,,0,0,0,,{\move(1280,0,-90,0)}第一条`)
+	assert.Contains(t, buf.String(), `{\an2\pos(640,720)}观众D 赠送 辣条 ×10`)
+	assert.Contains(t, buf.String(), "&H00FFFFFF")
+}
+
+func TestWriteASSLanes(t *testing.T) {
+	// 同一时刻的弹幕依次占用不同轨道，轨道用完后丢弃
+	var comments []Comment
+	for i := 0; i < 5; i++ {
+		comments = append(comments, Comment{Time: 10, Mode: ModeScroll, Text: "同时出现的弹幕"})
+	}
+	var buf bytes.Buffer
+	stats, err := WriteASS(&buf, comments, Options{Lanes: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Rendered)
+	assert.Equal(t, 2, stats.Dropped)
+	for _, y := range []string{",0)}", ",45)}", ",90)}"} {
+		assert.Contains(t, buf.String(), y)
+	}
+
+	// 前一条弹幕完全进入画面且不会被追上后，轨道可以复用
+	comments = []Comment{
+		{Time: 0, Mode: ModeScroll, Text: "短"},
+		{Time: 1, Mode: ModeScroll, Text: "短"},
+	}
+	buf.Reset()
+	stats, err = WriteASS(&buf, comments, Options{Lanes: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Rendered)
+}
+
+func TestFormatTime(t *testing.T) {
+	assert.Equal(t, "0:00:00.00", formatTime(0))
+	assert.Equal(t, "1:01:01.25", formatTime(3661.25))
+}
@@ -0,0 +1,310 @@
+// Package filemover 负责将临时目录（scratch）中已关闭的录制分段移动到最终输出目录
+// 同一文件系统内直接重命名；跨文件系统时先复制到目标目录的临时文件，校验大小和 SHA-256 后再重命名，
+// 最后删除源文件。失败会按退避间隔持续重试，移动状态可通过 API 查询。
+package filemover
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State 移动任务状态
+type State string
+
+const (
+	StatePending  State = "pending"  // 等待移动
+	StateMoving   State = "moving"   // 正在移动
+	StateRetrying State = "retrying" // 上次失败，等待重试
+	StateDone     State = "done"     // 已移动到最终目录
+	StateFailed   State = "failed"   // 放弃移动（context 取消），文件仍留在临时目录
+)
+
+// 移动方式
+const (
+	MethodRename = "rename" // 同一文件系统内重命名
+	MethodCopy   = "copy"   // 跨文件系统复制并校验
+)
+
+const (
+	// minRetryDelay 首次重试间隔
+	minRetryDelay = 5 * time.Second
+	// maxRetryDelay 重试间隔上限
+	maxRetryDelay = 5 * time.Minute
+	// doneRetention 已完成任务在列表中保留的时长
+	doneRetention = time.Hour
+	// partSuffix 复制过程中目标临时文件的后缀
+	partSuffix = ".moving"
+)
+
+// ErrDestinationExists 目标路径已存在同名文件
+var ErrDestinationExists = errors.New("目标文件已存在")
+
+// Job 单个文件的移动任务
+type Job struct {
+	ID          int64     `json:"id"`
+	LiveID      string    `json:"live_id"`
+	Src         string    `json:"src"`
+	Dst         string    `json:"dst"`
+	Size        int64     `json:"size"`
+	State       State     `json:"state"`
+	Method      string    `json:"method,omitempty"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	NextRetryAt time.Time `json:"next_retry_at,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Mover 管理所有移动任务
+type Mover struct {
+	mu     sync.RWMutex
+	jobs   map[int64]*Job
+	nextID int64
+
+	// retryDelay 计算第 attempt 次失败后的重试间隔，测试时可替换
+	retryDelay func(attempt int) time.Duration
+}
+
+var globalMover = New()
+
+// GetGlobalMover 返回全局移动器
+func GetGlobalMover() *Mover {
+	return globalMover
+}
+
+// New 创建移动器
+func New() *Mover {
+	return &Mover{
+		jobs:       make(map[int64]*Job),
+		retryDelay: backoff,
+	}
+}
+
+// backoff 指数退避：5s、10s、20s……最长 5 分钟
+func backoff(attempt int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// Move 将 src 移动到 dst，阻塞直到成功或 ctx 取消
+// 失败时按退避间隔无限重试，保证分段不会因为临时的网络存储故障而丢失
+func (m *Mover) Move(ctx context.Context, liveID, src, dst string) error {
+	job := m.addJob(liveID, src, dst)
+	for {
+		m.update(job.ID, func(j *Job) {
+			j.State = StateMoving
+			j.Attempts++
+			j.NextRetryAt = time.Time{}
+		})
+
+		method, err := moveFile(src, dst)
+		if err == nil {
+			m.update(job.ID, func(j *Job) {
+				j.State = StateDone
+				j.Method = method
+				j.LastError = ""
+			})
+			return nil
+		}
+
+		attempts := m.attempts(job.ID)
+		delay := m.retryDelay(attempts)
+		m.update(job.ID, func(j *Job) {
+			j.State = StateRetrying
+			j.Method = method
+			j.LastError = err.Error()
+			j.NextRetryAt = time.Now().Add(delay)
+		})
+
+		select {
+		case <-ctx.Done():
+			m.update(job.ID, func(j *Job) {
+				j.State = StateFailed
+				j.NextRetryAt = time.Time{}
+			})
+			return fmt.Errorf("移动 %s 失败: %w", src, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// List 返回所有未过期的移动任务，最新的在前；liveID 非空时只返回该直播间的任务
+func (m *Mover) List(liveID string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if liveID == "" || j.LiveID == liveID {
+			jobs = append(jobs, *j)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].ID > jobs[b].ID })
+	return jobs
+}
+
+// Pending 返回指定直播间尚未完成的移动任务
+func (m *Mover) Pending(liveID string) []Job {
+	jobs := m.List(liveID)
+	pending := jobs[:0]
+	for _, j := range jobs {
+		if j.State != StateDone {
+			pending = append(pending, j)
+		}
+	}
+	return pending
+}
+
+func (m *Mover) addJob(liveID, src, dst string) *Job {
+	var size int64
+	if fi, err := os.Stat(src); err == nil {
+		size = fi.Size()
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	job := &Job{
+		ID:        m.nextID,
+		LiveID:    liveID,
+		Src:       src,
+		Dst:       dst,
+		Size:      size,
+		State:     StatePending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.jobs[job.ID] = job
+	return job
+}
+
+func (m *Mover) update(id int64, fn func(j *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j, ok := m.jobs[id]; ok {
+		fn(j)
+		j.UpdatedAt = time.Now()
+	}
+}
+
+func (m *Mover) attempts(id int64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if j, ok := m.jobs[id]; ok {
+		return j.Attempts
+	}
+	return 0
+}
+
+// pruneLocked 清理过期的已完成任务，调用方需持有写锁
+func (m *Mover) pruneLocked() {
+	cutoff := time.Now().Add(-doneRetention)
+	for id, j := range m.jobs {
+		if j.State == StateDone && j.UpdatedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// moveFile 执行一次移动，返回使用的移动方式
+func moveFile(src, dst string) (string, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrDestinationExists, dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return MethodRename, nil
+	} else if _, statErr := os.Stat(src); statErr != nil {
+		// 源文件不存在时复制也无济于事
+		return MethodRename, err
+	}
+	return MethodCopy, copyAndVerify(src, dst)
+}
+
+// copyAndVerify 复制到目标目录的临时文件，校验大小和 SHA-256 一致后重命名为目标文件并删除源文件
+func copyAndVerify(src, dst string) (err error) {
+	part := dst + partSuffix
+	defer func() {
+		if err != nil {
+			os.Remove(part)
+		}
+	}()
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	srcHash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, srcHash), in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	srcInfo, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if written != srcInfo.Size() {
+		return fmt.Errorf("复制大小不一致: 源 %d 字节, 已写入 %d 字节", srcInfo.Size(), written)
+	}
+
+	// 回读目标文件校验内容，防止网络存储静默写坏
+	dstHash, err := hashFile(part)
+	if err != nil {
+		return err
+	}
+	if string(dstHash) != string(srcHash.Sum(nil)) {
+		return errors.New("复制后 SHA-256 校验不一致")
+	}
+
+	if err = os.Rename(part, dst); err != nil {
+		return err
+	}
+	// Windows 下需要先关闭源文件才能删除；目标已完整写入，源文件删除失败不视为移动失败
+	in.Close()
+	os.Remove(src)
+	return nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,103 @@
+package filemover
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveRename(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "scratch", "a.flv")
+	dst := filepath.Join(dir, "out", "虎牙", "a.flv")
+	require.NoError(t, os.MkdirAll(filepath.Dir(src), 0755))
+	require.NoError(t, os.WriteFile(src, []byte("flv data"), 0644))
+
+	m := New()
+	require.NoError(t, m.Move(context.Background(), "live-1", src, dst))
+
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "flv data", string(data))
+	assert.NoFileExists(t, src)
+
+	jobs := m.List("live-1")
+	require.Len(t, jobs, 1)
+	assert.Equal(t, StateDone, jobs[0].State)
+	assert.Equal(t, MethodRename, jobs[0].Method)
+	assert.Equal(t, int64(8), jobs[0].Size)
+	assert.Empty(t, m.Pending("live-1"))
+}
+
+func TestCopyAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.flv")
+	dst := filepath.Join(dir, "b.flv")
+	require.NoError(t, os.WriteFile(src, []byte("segment"), 0644))
+
+	require.NoError(t, copyAndVerify(src, dst))
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "segment", string(data))
+	assert.NoFileExists(t, src)
+	assert.NoFileExists(t, dst+partSuffix)
+}
+
+func TestMoveRetriesUntilDestinationFree(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.flv")
+	dst := filepath.Join(dir, "out", "a.flv")
+	require.NoError(t, os.WriteFile(src, []byte("new"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Dir(dst), 0755))
+	require.NoError(t, os.WriteFile(dst, []byte("old"), 0644))
+
+	m := New()
+	retried := make(chan struct{})
+	m.retryDelay = func(attempt int) time.Duration {
+		if attempt == 1 {
+			close(retried)
+		}
+		return 10 * time.Millisecond
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- m.Move(context.Background(), "live-1", src, dst) }()
+
+	<-retried
+	require.Eventually(t, func() bool {
+		pending := m.Pending("live-1")
+		return len(pending) == 1 && pending[0].LastError != ""
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, os.Remove(dst))
+
+	require.NoError(t, <-done)
+	jobs := m.List("")
+	require.Len(t, jobs, 1)
+	assert.Equal(t, StateDone, jobs[0].State)
+	assert.GreaterOrEqual(t, jobs[0].Attempts, 2)
+}
+
+func TestMoveGivesUpOnCancel(t *testing.T) {
+	dir := t.TempDir()
+	m := New()
+	m.retryDelay = func(int) time.Duration { return time.Hour }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Move(ctx, "live-1", filepath.Join(dir, "missing.flv"), filepath.Join(dir, "out.flv"))
+	}()
+	require.Eventually(t, func() bool {
+		jobs := m.List("live-1")
+		return len(jobs) == 1 && jobs[0].State == StateRetrying
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+
+	assert.Error(t, <-done)
+	assert.Equal(t, StateFailed, m.List("live-1")[0].State)
+}
@@ -0,0 +1,274 @@
+package fmp4mux
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4/codecs"
+)
+
+// FLV 常量
+const (
+	TagAudio  uint8 = 8
+	TagVideo  uint8 = 9
+	TagScript uint8 = 18
+
+	codecIDAVC  uint8 = 7
+	codecIDHEVC uint8 = 12 // 非标准 FLV 扩展（国内平台常用）
+
+	soundFormatAAC uint8 = 10
+
+	frameTypeKey uint8 = 1
+
+	// 标准 FLV 的 AVCPacketType
+	avcPacketSeqHeader uint8 = 0
+	avcPacketNALU      uint8 = 1
+
+	// Enhanced FLV 的 PacketType
+	exPacketSequenceStart uint8 = 0
+	exPacketCodedFrames   uint8 = 1
+	exPacketCodedFramesX  uint8 = 3
+)
+
+var (
+	// ErrUnsupportedCodec 编码格式无法封装为 fMP4
+	ErrUnsupportedCodec = errors.New("不支持的编码格式")
+	// ErrInvalidConfig 解码配置（序列头）无法解析
+	ErrInvalidConfig = errors.New("无效的解码配置")
+)
+
+// videoTag 解析后的视频 tag
+type videoTag struct {
+	keyframe  bool
+	seqHeader bool
+	config    []byte // 序列头中的解码配置记录
+	hevc      bool
+	cts       int32
+	payload   []byte // AVCC 格式（长度前缀）的 NALU
+}
+
+// parseVideoTag 解析标准 FLV 和 Enhanced FLV 的 AVC/HEVC 视频 tag
+// 返回 nil 表示可以忽略的 tag（如命令帧、序列结束）
+func parseVideoTag(data []byte) (*videoTag, error) {
+	if len(data) < 5 {
+		return nil, nil
+	}
+	t := &videoTag{keyframe: (data[0]>>4)&0x07 == frameTypeKey}
+
+	if data[0]&0x80 != 0 {
+		// Enhanced FLV: FrameType(3 位) + PacketType(4 位) + FourCC
+		switch string(data[1:5]) {
+		case "hvc1":
+			t.hevc = true
+		case "avc1":
+		default:
+			return nil, ErrUnsupportedCodec
+		}
+		switch data[0] & 0x0f {
+		case exPacketSequenceStart:
+			t.seqHeader = true
+			t.config = data[5:]
+		case exPacketCodedFrames:
+			if len(data) < 8 {
+				return nil, nil
+			}
+			t.cts = int24(data[5:8])
+			t.payload = data[8:]
+		case exPacketCodedFramesX:
+			t.payload = data[5:]
+		default:
+			return nil, nil
+		}
+		return t, nil
+	}
+
+	switch data[0] & 0x0f {
+	case codecIDAVC:
+	case codecIDHEVC:
+		t.hevc = true
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+	switch data[1] {
+	case avcPacketSeqHeader:
+		t.seqHeader = true
+		t.config = data[5:]
+	case avcPacketNALU:
+		t.cts = int24(data[2:5])
+		t.payload = data[5:]
+	default:
+		return nil, nil
+	}
+	return t, nil
+}
+
+// int24 解析 24 位有符号整数（FLV CompositionTime）
+func int24(b []byte) int32 {
+	v := int32(b[0])<<16 | int32(b[1])<<8 | int32(b[2])
+	if v&0x800000 != 0 {
+		v -= 1 << 24
+	}
+	return v
+}
+
+// videoCodecFromConfig 从 AVC/HEVC 解码配置记录构造 fMP4 编码参数
+func videoCodecFromConfig(config []byte, hevc bool) (codecs.Codec, error) {
+	if hevc {
+		nalus, err := parseHEVCConfigRecord(config)
+		if err != nil {
+			return nil, err
+		}
+		c := &codecs.H265{}
+		for _, nalu := range nalus {
+			switch (nalu[0] >> 1) & 0x3f {
+			case 32:
+				c.VPS = nalu
+			case 33:
+				c.SPS = nalu
+			case 34:
+				c.PPS = nalu
+			}
+		}
+		if c.VPS == nil || c.SPS == nil || c.PPS == nil {
+			return nil, ErrInvalidConfig
+		}
+		return c, nil
+	}
+
+	sps, pps, err := parseAVCConfigRecord(config)
+	if err != nil {
+		return nil, err
+	}
+	return &codecs.H264{SPS: sps, PPS: pps}, nil
+}
+
+// parseAVCConfigRecord 从 AVCDecoderConfigurationRecord 中取出第一个 SPS 和 PPS
+func parseAVCConfigRecord(data []byte) (sps, pps []byte, err error) {
+	if len(data) < 7 {
+		return nil, nil, ErrInvalidConfig
+	}
+	// configurationVersion(1) + profile(1) + compatibility(1) + level(1) + lengthSizeMinusOne(1)
+	offset := 5
+	// readSets 读取 count 个参数集，返回第一个
+	readSets := func(count int) ([]byte, error) {
+		var first []byte
+		for i := 0; i < count; i++ {
+			if offset+2 > len(data) {
+				return nil, ErrInvalidConfig
+			}
+			n := int(binary.BigEndian.Uint16(data[offset:]))
+			offset += 2
+			if n == 0 || offset+n > len(data) {
+				return nil, ErrInvalidConfig
+			}
+			if first == nil {
+				first = append([]byte(nil), data[offset:offset+n]...)
+			}
+			offset += n
+		}
+		return first, nil
+	}
+
+	numSPS := int(data[offset] & 0x1f)
+	offset++
+	if sps, err = readSets(numSPS); err != nil {
+		return nil, nil, err
+	}
+	if offset >= len(data) {
+		return nil, nil, ErrInvalidConfig
+	}
+	numPPS := int(data[offset])
+	offset++
+	if pps, err = readSets(numPPS); err != nil {
+		return nil, nil, err
+	}
+	if sps == nil || pps == nil {
+		return nil, nil, ErrInvalidConfig
+	}
+	return sps, pps, nil
+}
+
+// parseHEVCConfigRecord 取出 HEVCDecoderConfigurationRecord 中的所有参数集 NALU
+func parseHEVCConfigRecord(data []byte) ([][]byte, error) {
+	if len(data) < 23 {
+		return nil, ErrInvalidConfig
+	}
+	numArrays := int(data[22])
+	offset := 23
+	var nalus [][]byte
+	for i := 0; i < numArrays; i++ {
+		if offset+3 > len(data) {
+			return nil, ErrInvalidConfig
+		}
+		numNalus := int(binary.BigEndian.Uint16(data[offset+1:]))
+		offset += 3
+		for j := 0; j < numNalus; j++ {
+			if offset+2 > len(data) {
+				return nil, ErrInvalidConfig
+			}
+			n := int(binary.BigEndian.Uint16(data[offset:]))
+			offset += 2
+			if n == 0 || offset+n > len(data) {
+				return nil, ErrInvalidConfig
+			}
+			nalus = append(nalus, append([]byte(nil), data[offset:offset+n]...))
+			offset += n
+		}
+	}
+	return nalus, nil
+}
+
+// audioTag 解析后的 AAC 音频 tag
+type audioTag struct {
+	seqHeader bool
+	config    []byte
+	payload   []byte
+}
+
+// parseAudioTag 解析 AAC 音频 tag，其他音频编码返回 ErrUnsupportedCodec
+func parseAudioTag(data []byte) (*audioTag, error) {
+	if len(data) < 2 {
+		return nil, nil
+	}
+	if data[0]>>4 != soundFormatAAC {
+		return nil, ErrUnsupportedCodec
+	}
+	if data[1] == 0 {
+		return &audioTag{seqHeader: true, config: data[2:]}, nil
+	}
+	return &audioTag{payload: data[2:]}, nil
+}
+
+// audioCodecFromConfig 从 AudioSpecificConfig 构造 fMP4 编码参数
+func audioCodecFromConfig(config []byte) (*codecs.MPEG4Audio, error) {
+	var asc mpeg4audio.AudioSpecificConfig
+	if err := asc.Unmarshal(config); err != nil {
+		return nil, ErrInvalidConfig
+	}
+	if asc.SampleRate <= 0 {
+		return nil, ErrInvalidConfig
+	}
+	return &codecs.MPEG4Audio{Config: asc}, nil
+}
+
+// aacFrameSamples 每个 AAC 帧包含的采样数
+func aacFrameSamples(c *codecs.MPEG4Audio) uint32 {
+	if c.Config.FrameLengthFlag {
+		return 960
+	}
+	return 1024
+}
+
+// IsSequenceHeader 判断 tag 是否为音视频序列头（解码配置）
+func IsSequenceHeader(tagType uint8, data []byte) bool {
+	switch tagType {
+	case TagVideo:
+		t, err := parseVideoTag(data)
+		return err == nil && t != nil && t.seqHeader
+	case TagAudio:
+		t, err := parseAudioTag(data)
+		return err == nil && t != nil && t.seqHeader
+	}
+	return false
+}
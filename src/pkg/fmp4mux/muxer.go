@@ -0,0 +1,414 @@
+// Package fmp4mux 将 FLV 中的 AVC/HEVC/AAC 数据无损封装为分片 MP4（fMP4）
+//
+// 输出由一个初始化段（ftyp + 带 mvex 的 moov）和若干分片（moof + mdat）组成：
+// 有视频时每个 GOP 一个分片，纯音频流按固定时长分片。
+// 编码参数变化时会重新输出初始化段，由调用方决定如何处理（新文件、HLS 不连续点等）。
+package fmp4mux
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4/codecs"
+)
+
+const (
+	// videoTimeScale 视频轨道时间刻度
+	videoTimeScale = 90000
+	// DefaultFragmentDuration 纯音频流的默认分片时长
+	DefaultFragmentDuration = 2 * time.Second
+	// audioOnlyProbeDuration 只收到音频超过该时长仍未出现视频序列头时，按纯音频流处理
+	audioOnlyProbeDuration = 3 * time.Second
+	// maxPendingBytes 关键帧迟迟不出现时，待输出数据超过该大小就强制输出分片，避免内存无限增长
+	maxPendingBytes = 64 * 1024 * 1024
+	// defaultVideoFrameMs 无法推算帧时长时使用的默认值（25fps）
+	defaultVideoFrameMs = 40
+)
+
+// Fragment 一个 fMP4 分片（moof + mdat）
+type Fragment struct {
+	Sequence uint32
+	Data     []byte
+	// Start 分片起始时间，相对于输出时间轴起点
+	Start    time.Duration
+	Duration time.Duration
+	// Keyframe 分片以关键帧开始，可以独立解码（纯音频分片始终为 true）
+	Keyframe bool
+}
+
+// Options 封装选项
+type Options struct {
+	// AudioOnly 忽略视频，只封装音频
+	AudioOnly bool
+	// FragmentDuration 纯音频流的分片时长，默认 DefaultFragmentDuration
+	FragmentDuration time.Duration
+	// OnInit 输出初始化段时调用，编码参数变化后会再次调用
+	OnInit func(init []byte) error
+	// OnFragment 输出分片时调用
+	OnFragment func(f *Fragment) error
+}
+
+type sample struct {
+	dts     int64 // FLV 时间戳（毫秒）
+	cts     int32 // 显示时间偏移（毫秒）
+	sync    bool
+	payload []byte
+}
+
+// Muxer FLV tag 到 fMP4 的封装器，非并发安全
+type Muxer struct {
+	opts Options
+
+	videoConfig []byte
+	videoCodec  codecs.Codec
+	audioConfig []byte
+	audioCodec  *codecs.MPEG4Audio
+
+	videoUnsupported bool
+	audioUnsupported bool
+
+	// 输出时间轴起点（FLV 毫秒时间戳），编码参数变化重新输出初始化段时保持不变
+	originSet bool
+	origin    int64
+
+	started    bool // 已输出当前编码参数的初始化段
+	videoTrack int  // 视频轨道 ID，0 表示无视频轨道
+	audioTrack int
+	seq        uint32
+
+	video        []*sample
+	audio        []*sample
+	pendingBytes int
+
+	lastVideoDuration int64 // 上一帧视频时长（毫秒）
+	audioNextBase     uint64
+	firstAudioDTS     int64
+	sawAudio          bool
+}
+
+// New 创建封装器
+func New(opts Options) *Muxer {
+	if opts.FragmentDuration <= 0 {
+		opts.FragmentDuration = DefaultFragmentDuration
+	}
+	return &Muxer{opts: opts}
+}
+
+// WriteTag 写入一个 FLV tag 的类型、时间戳和数据（不含 tag 头）
+// 返回 ErrUnsupportedCodec 时对应的音频或视频会被忽略，调用方记录日志后可以继续写入
+func (m *Muxer) WriteTag(tagType uint8, timestamp uint32, data []byte) error {
+	dts := int64(timestamp)
+	switch tagType {
+	case TagVideo:
+		if m.opts.AudioOnly || m.videoUnsupported {
+			return nil
+		}
+		t, err := parseVideoTag(data)
+		if err != nil {
+			m.videoUnsupported = true
+			return err
+		}
+		if t == nil {
+			return nil
+		}
+		if t.seqHeader {
+			return m.setVideoConfig(t.config, t.hevc)
+		}
+		return m.writeVideo(dts, t)
+
+	case TagAudio:
+		if m.audioUnsupported {
+			return nil
+		}
+		t, err := parseAudioTag(data)
+		if err != nil {
+			m.audioUnsupported = true
+			return err
+		}
+		if t == nil {
+			return nil
+		}
+		if t.seqHeader {
+			return m.setAudioConfig(t.config)
+		}
+		return m.writeAudio(dts, t.payload)
+	}
+	return nil
+}
+
+// Flush 输出所有待输出的样本，在流结束时调用
+func (m *Muxer) Flush() error {
+	return m.flush(-1)
+}
+
+// Reset 清空全部状态，用于开始封装一个新的 FLV 流
+func (m *Muxer) Reset() {
+	*m = Muxer{opts: m.opts}
+}
+
+// Restart 丢弃待输出的样本，保留编码参数，下一个关键帧处重新输出初始化段并从 0 开始计时
+func (m *Muxer) Restart() {
+	m.dropPending()
+	m.started = false
+	m.originSet = false
+	m.audioNextBase = 0
+	m.sawAudio = false
+}
+
+// HasVideo 当前初始化段是否包含视频轨道
+func (m *Muxer) HasVideo() bool {
+	return m.videoTrack != 0
+}
+
+func (m *Muxer) setVideoConfig(config []byte, hevc bool) error {
+	if m.videoCodec != nil && bytes.Equal(config, m.videoConfig) {
+		// 很多平台会周期性重复发送相同的序列头
+		return nil
+	}
+	c, err := videoCodecFromConfig(config, hevc)
+	if err != nil {
+		return err
+	}
+	if err := m.restartForNewConfig(); err != nil {
+		return err
+	}
+	m.videoConfig = append([]byte(nil), config...)
+	m.videoCodec = c
+	return nil
+}
+
+func (m *Muxer) setAudioConfig(config []byte) error {
+	if m.audioCodec != nil && bytes.Equal(config, m.audioConfig) {
+		return nil
+	}
+	c, err := audioCodecFromConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := m.restartForNewConfig(); err != nil {
+		return err
+	}
+	m.audioConfig = append([]byte(nil), config...)
+	m.audioCodec = c
+	return nil
+}
+
+// restartForNewConfig 编码参数变化：输出已有样本，下一个关键帧处重新输出初始化段
+func (m *Muxer) restartForNewConfig() error {
+	if !m.started {
+		return nil
+	}
+	err := m.flush(-1)
+	m.started = false
+	return err
+}
+
+func (m *Muxer) writeVideo(dts int64, t *videoTag) error {
+	if m.videoCodec == nil {
+		return nil
+	}
+	if !m.started {
+		if !t.keyframe {
+			return nil
+		}
+		if err := m.start(dts); err != nil {
+			return err
+		}
+	}
+	if len(m.video) > 0 && (t.keyframe || m.pendingBytes > maxPendingBytes) {
+		if err := m.flush(dts); err != nil {
+			return err
+		}
+	}
+	m.video = append(m.video, &sample{
+		dts:     dts,
+		cts:     t.cts,
+		sync:    t.keyframe,
+		payload: append([]byte(nil), t.payload...),
+	})
+	m.pendingBytes += len(t.payload)
+	return nil
+}
+
+func (m *Muxer) writeAudio(dts int64, payload []byte) error {
+	if m.audioCodec == nil {
+		return nil
+	}
+	if !m.started {
+		if !m.sawAudio {
+			m.sawAudio = true
+			m.firstAudioDTS = dts
+		}
+		audioOnly := m.opts.AudioOnly ||
+			(m.videoCodec == nil && time.Duration(dts-m.firstAudioDTS)*time.Millisecond >= audioOnlyProbeDuration)
+		if !audioOnly {
+			return nil
+		}
+		if err := m.start(dts); err != nil {
+			return err
+		}
+	}
+	if m.audioTrack == 0 {
+		return nil
+	}
+	m.audio = append(m.audio, &sample{
+		dts:     dts,
+		sync:    true,
+		payload: append([]byte(nil), payload...),
+	})
+	m.pendingBytes += len(payload)
+
+	if m.videoTrack == 0 {
+		frames := uint64(len(m.audio)) * uint64(aacFrameSamples(m.audioCodec))
+		if time.Duration(frames)*time.Second/time.Duration(m.audioCodec.Config.SampleRate) >= m.opts.FragmentDuration {
+			return m.flush(-1)
+		}
+	}
+	return nil
+}
+
+// start 输出初始化段
+func (m *Muxer) start(dts int64) error {
+	if !m.originSet {
+		m.originSet = true
+		m.origin = dts
+	}
+
+	init := fmp4.Init{}
+	m.videoTrack, m.audioTrack = 0, 0
+	if m.videoCodec != nil && !m.opts.AudioOnly {
+		m.videoTrack = len(init.Tracks) + 1
+		init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+			ID:        m.videoTrack,
+			TimeScale: videoTimeScale,
+			Codec:     m.videoCodec,
+		})
+	}
+	if m.audioCodec != nil {
+		m.audioTrack = len(init.Tracks) + 1
+		init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+			ID:        m.audioTrack,
+			TimeScale: uint32(m.audioCodec.Config.SampleRate),
+			Codec:     m.audioCodec,
+		})
+	}
+
+	var buf seekablebuffer.Buffer
+	if err := init.Marshal(&buf); err != nil {
+		return err
+	}
+	m.started = true
+	m.audioNextBase = 0
+	if m.opts.OnInit != nil {
+		return m.opts.OnInit(buf.Bytes())
+	}
+	return nil
+}
+
+// flush 将待输出样本封装为一个分片，nextVideoDTS 为下一帧视频的时间戳，用于计算最后一帧的时长，-1 表示未知
+func (m *Muxer) flush(nextVideoDTS int64) error {
+	if len(m.video) == 0 && len(m.audio) == 0 {
+		return nil
+	}
+	if !m.started {
+		m.dropPending()
+		return nil
+	}
+
+	part := fmp4.Part{SequenceNumber: m.seq + 1}
+	frag := &Fragment{Sequence: m.seq + 1, Keyframe: true}
+
+	if len(m.video) > 0 {
+		track := &fmp4.PartTrack{
+			ID:       m.videoTrack,
+			BaseTime: uint64(m.relative(m.video[0].dts)) * videoTimeScale / 1000,
+		}
+		var total int64
+		for i, s := range m.video {
+			var d int64
+			if i+1 < len(m.video) {
+				d = m.video[i+1].dts - s.dts
+			} else if nextVideoDTS >= 0 {
+				d = nextVideoDTS - s.dts
+			}
+			if d <= 0 || d > 10*1000 {
+				d = m.lastVideoDuration
+				if d <= 0 {
+					d = defaultVideoFrameMs
+				}
+			} else {
+				m.lastVideoDuration = d
+			}
+			total += d
+			track.Samples = append(track.Samples, &fmp4.Sample{
+				Duration:        uint32(d * videoTimeScale / 1000),
+				PTSOffset:       s.cts * videoTimeScale / 1000,
+				IsNonSyncSample: !s.sync,
+				Payload:         s.payload,
+			})
+		}
+		part.Tracks = append(part.Tracks, track)
+		frag.Start = time.Duration(m.relative(m.video[0].dts)) * time.Millisecond
+		frag.Duration = time.Duration(total) * time.Millisecond
+		frag.Keyframe = m.video[0].sync
+	}
+
+	if len(m.audio) > 0 {
+		rate := uint64(m.audioCodec.Config.SampleRate)
+		frameSamples := aacFrameSamples(m.audioCodec)
+		base := uint64(m.relative(m.audio[0].dts)) * rate / 1000
+		// 与上一个分片的结尾相差不到一帧时直接衔接，避免毫秒时间戳取整造成的细小空隙
+		if m.audioNextBase > 0 && absDiff(base, m.audioNextBase) <= uint64(frameSamples) {
+			base = m.audioNextBase
+		}
+		track := &fmp4.PartTrack{ID: m.audioTrack, BaseTime: base}
+		for _, s := range m.audio {
+			track.Samples = append(track.Samples, &fmp4.Sample{
+				Duration: frameSamples,
+				Payload:  s.payload,
+			})
+		}
+		m.audioNextBase = base + uint64(len(m.audio))*uint64(frameSamples)
+		part.Tracks = append(part.Tracks, track)
+		if len(m.video) == 0 {
+			frag.Start = time.Duration(base) * time.Second / time.Duration(rate)
+			frag.Duration = time.Duration(uint64(len(m.audio))*uint64(frameSamples)) * time.Second / time.Duration(rate)
+		}
+	}
+
+	m.dropPending()
+
+	var buf seekablebuffer.Buffer
+	if err := part.Marshal(&buf); err != nil {
+		return err
+	}
+	m.seq++
+	frag.Data = buf.Bytes()
+	if m.opts.OnFragment != nil {
+		return m.opts.OnFragment(frag)
+	}
+	return nil
+}
+
+func (m *Muxer) dropPending() {
+	m.video = nil
+	m.audio = nil
+	m.pendingBytes = 0
+}
+
+// relative 将 FLV 时间戳转换为相对于时间轴起点的毫秒数，早于起点的样本按 0 处理
+func (m *Muxer) relative(dts int64) int64 {
+	if d := dts - m.origin; d > 0 {
+		return d
+	}
+	return 0
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,150 @@
+package fmp4mux
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSPS = []byte{
+	0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+	0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+	0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9,
+	0x20,
+}
+
+var testPPS = []byte{0x08}
+
+// avcSeqHeader 构造 FLV AVC 序列头 tag 数据
+func avcSeqHeader() []byte {
+	data := []byte{0x17, 0, 0, 0, 0, 1, testSPS[1], testSPS[2], testSPS[3], 0xff, 0xe1}
+	data = append(data, byte(len(testSPS)>>8), byte(len(testSPS)))
+	data = append(data, testSPS...)
+	data = append(data, 1, byte(len(testPPS)>>8), byte(len(testPPS)))
+	return append(data, testPPS...)
+}
+
+func avcFrame(key bool, cts int, nalu byte) []byte {
+	head := byte(0x27)
+	if key {
+		head = 0x17
+	}
+	return []byte{head, 1, byte(cts >> 16), byte(cts >> 8), byte(cts), 0, 0, 0, 1, nalu}
+}
+
+func aacSeqHeader() []byte {
+	// AAC LC, 44100Hz, 双声道
+	return []byte{0xaf, 0, 0x12, 0x10}
+}
+
+func aacFrame(b byte) []byte {
+	return []byte{0xaf, 1, b}
+}
+
+func TestMuxerFragmentPerGOP(t *testing.T) {
+	var inits [][]byte
+	var frags []*Fragment
+	m := New(Options{
+		OnInit:     func(init []byte) error { inits = append(inits, init); return nil },
+		OnFragment: func(f *Fragment) error { frags = append(frags, f); return nil },
+	})
+
+	require.NoError(t, m.WriteTag(TagScript, 0, []byte{2}))
+	require.NoError(t, m.WriteTag(TagVideo, 0, avcSeqHeader()))
+	require.NoError(t, m.WriteTag(TagAudio, 0, aacSeqHeader()))
+	// 关键帧之前的非关键帧被丢弃
+	require.NoError(t, m.WriteTag(TagVideo, 960, avcFrame(false, 0, 0x41)))
+	require.NoError(t, m.WriteTag(TagVideo, 1000, avcFrame(true, 40, 0x65)))
+	require.NoError(t, m.WriteTag(TagAudio, 1010, aacFrame(1)))
+	require.NoError(t, m.WriteTag(TagVideo, 1040, avcFrame(false, 40, 0x41)))
+	require.NoError(t, m.WriteTag(TagAudio, 1033, aacFrame(2)))
+	require.NoError(t, m.WriteTag(TagVideo, 1080, avcFrame(true, 40, 0x65)))
+	require.NoError(t, m.WriteTag(TagVideo, 1120, avcFrame(false, 0, 0x41)))
+	require.NoError(t, m.Flush())
+
+	require.Len(t, inits, 1)
+	var init fmp4.Init
+	require.NoError(t, init.Unmarshal(bytes.NewReader(inits[0])))
+	require.Len(t, init.Tracks, 2)
+	assert.IsType(t, &codecs.H264{}, init.Tracks[0].Codec)
+	assert.IsType(t, &codecs.MPEG4Audio{}, init.Tracks[1].Codec)
+	assert.Equal(t, uint32(44100), init.Tracks[1].TimeScale)
+
+	require.Len(t, frags, 2)
+	assert.True(t, frags[0].Keyframe)
+	assert.Equal(t, time.Duration(0), frags[0].Start)
+	assert.Equal(t, 80*time.Millisecond, frags[0].Duration)
+	assert.Equal(t, 80*time.Millisecond, frags[1].Start)
+
+	var parts fmp4.Parts
+	require.NoError(t, parts.Unmarshal(frags[0].Data))
+	require.Len(t, parts, 1)
+	require.Len(t, parts[0].Tracks, 2)
+	video := parts[0].Tracks[0]
+	assert.Equal(t, uint64(0), video.BaseTime)
+	require.Len(t, video.Samples, 2)
+	assert.False(t, video.Samples[0].IsNonSyncSample)
+	assert.True(t, video.Samples[1].IsNonSyncSample)
+	assert.Equal(t, uint32(40*90), video.Samples[0].Duration)
+	assert.Equal(t, int32(40*90), video.Samples[0].PTSOffset)
+	assert.Equal(t, []byte{0, 0, 0, 1, 0x65}, video.Samples[0].Payload)
+	audio := parts[0].Tracks[1]
+	require.Len(t, audio.Samples, 2)
+	assert.Equal(t, uint32(1024), audio.Samples[0].Duration)
+
+	var next fmp4.Parts
+	require.NoError(t, next.Unmarshal(frags[1].Data))
+	assert.Equal(t, uint64(80*90), next[0].Tracks[0].BaseTime)
+}
+
+func TestMuxerAudioOnly(t *testing.T) {
+	var inits int
+	var frags []*Fragment
+	m := New(Options{
+		AudioOnly:        true,
+		FragmentDuration: 100 * time.Millisecond,
+		OnInit:           func([]byte) error { inits++; return nil },
+		OnFragment:       func(f *Fragment) error { frags = append(frags, f); return nil },
+	})
+	require.NoError(t, m.WriteTag(TagVideo, 0, avcSeqHeader()))
+	require.NoError(t, m.WriteTag(TagAudio, 0, aacSeqHeader()))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, m.WriteTag(TagVideo, uint32(i*23), avcFrame(true, 0, 0x65)))
+		require.NoError(t, m.WriteTag(TagAudio, uint32(i*23), aacFrame(byte(i))))
+	}
+	require.NoError(t, m.Flush())
+
+	assert.Equal(t, 1, inits)
+	assert.False(t, m.HasVideo())
+	require.Len(t, frags, 2)
+	// 5 帧 * 1024 / 44100 ≈ 116ms
+	assert.InDelta(t, 116, frags[0].Duration.Milliseconds(), 1)
+
+	var parts fmp4.Parts
+	require.NoError(t, parts.Unmarshal(frags[1].Data))
+	require.Len(t, parts[0].Tracks, 1)
+	// 分片之间按采样数衔接
+	assert.Equal(t, uint64(5*1024), parts[0].Tracks[0].BaseTime)
+}
+
+func TestMuxerConfigChangeEmitsNewInit(t *testing.T) {
+	var inits int
+	m := New(Options{OnInit: func([]byte) error { inits++; return nil }})
+	require.NoError(t, m.WriteTag(TagVideo, 0, avcSeqHeader()))
+	require.NoError(t, m.WriteTag(TagVideo, 0, avcFrame(true, 0, 0x65)))
+	// 重复的序列头不会重新输出初始化段
+	require.NoError(t, m.WriteTag(TagVideo, 40, avcSeqHeader()))
+	require.NoError(t, m.WriteTag(TagVideo, 40, avcFrame(true, 0, 0x65)))
+	assert.Equal(t, 1, inits)
+
+	require.NoError(t, m.WriteTag(TagAudio, 60, aacSeqHeader()))
+	require.NoError(t, m.WriteTag(TagVideo, 80, avcFrame(true, 0, 0x65)))
+	assert.Equal(t, 2, inits)
+
+	assert.ErrorIs(t, m.WriteTag(TagAudio, 100, []byte{0x2f, 0xff}), ErrUnsupportedCodec)
+}
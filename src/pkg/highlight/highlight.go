@@ -0,0 +1,214 @@
+// Package highlight 根据音频响度检测录像中的高光片段
+//
+// 响度数据来自 ffmpeg 的 ebur128 或 astats 滤镜（经 ametadata 输出），
+// 按固定窗口聚合后与之前一段时间的滚动基线比较，明显高于基线的窗口视为高光。
+package highlight
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SilenceFloor 响度下限（LUFS/dBFS），静音或 -inf 按此值计算
+const SilenceFloor = -70.0
+
+// Options 检测参数，时间单位均为秒，响度单位为 LU/dB
+type Options struct {
+	// Window 聚合窗口长度
+	Window float64 `json:"window"`
+	// BaselineWindow 滚动基线覆盖的时长（当前窗口之前）
+	BaselineWindow float64 `json:"baseline_window"`
+	// MinBaseline 基线至少需要的历史时长，开头不足时不检测
+	MinBaseline float64 `json:"min_baseline"`
+	// Threshold 窗口响度高出基线多少时视为高光
+	Threshold float64 `json:"threshold"`
+	// MinLoudness 窗口响度低于该值时不视为高光，避免安静片段中的相对峰值
+	MinLoudness float64 `json:"min_loudness"`
+	// MergeGap 相邻高光窗口间隔不超过该值时合并为一个片段
+	MergeGap float64 `json:"merge_gap"`
+	// PreRoll、PostRoll 片段在高光前后额外保留的时长
+	PreRoll  float64 `json:"pre_roll"`
+	PostRoll float64 `json:"post_roll"`
+	// MaxHighlights 最多返回的片段数（按得分保留），0 表示不限制
+	MaxHighlights int `json:"max_highlights"`
+}
+
+// DefaultOptions 返回默认检测参数
+func DefaultOptions() Options {
+	return Options{
+		Window:         1,
+		BaselineWindow: 300,
+		MinBaseline:    30,
+		Threshold:      8,
+		MinLoudness:    -40,
+		MergeGap:       10,
+		PreRoll:        15,
+		PostRoll:       15,
+		MaxHighlights:  50,
+	}
+}
+
+// Point 一个响度测量点
+type Point struct {
+	Time     float64 // 秒
+	Loudness float64 // LUFS 或 dBFS
+}
+
+// Highlight 一个高光片段
+type Highlight struct {
+	Start    float64 `json:"start"`    // 片段开始时间（含 PreRoll）
+	End      float64 `json:"end"`      // 片段结束时间（含 PostRoll）
+	Peak     float64 `json:"peak"`     // 得分最高的窗口的开始时间
+	Score    float64 `json:"score"`    // 峰值高出基线的幅度
+	Loudness float64 `json:"loudness"` // 峰值窗口的响度
+	Baseline float64 `json:"baseline"` // 峰值窗口的基线响度
+}
+
+// ParseMetadata 解析 ametadata=print 的输出，提取指定键的数值
+//
+// 输出格式为每帧一行 "frame:N pts:P pts_time:T"，随后是 "key=value" 行。
+func ParseMetadata(r io.Reader, key string) ([]Point, error) {
+	var points []Point
+	t := math.NaN()
+	prefix := key + "="
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "pts_time:"); i >= 0 {
+			fields := strings.Fields(line[i+len("pts_time:"):])
+			t = math.NaN()
+			if len(fields) > 0 {
+				if v, err := strconv.ParseFloat(fields[0], 64); err == nil {
+					t = v
+				}
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, prefix) || math.IsNaN(t) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimPrefix(line, prefix), 64)
+		if err != nil || math.IsNaN(v) || v < SilenceFloor {
+			// -inf 表示静音
+			v = SilenceFloor
+		}
+		points = append(points, Point{Time: t, Loudness: v})
+	}
+	return points, scanner.Err()
+}
+
+// aggregate 按窗口聚合响度（按能量平均），返回每个窗口的响度，没有测量点的窗口视为静音
+func aggregate(points []Point, window float64) []float64 {
+	if len(points) == 0 {
+		return nil
+	}
+	last := points[len(points)-1].Time
+	n := int(last/window) + 1
+	energy := make([]float64, n)
+	counts := make([]int, n)
+	for _, p := range points {
+		if p.Time < 0 {
+			continue
+		}
+		i := int(p.Time / window)
+		energy[i] += math.Pow(10, p.Loudness/10)
+		counts[i]++
+	}
+	windows := make([]float64, n)
+	for i := range windows {
+		if counts[i] == 0 {
+			windows[i] = SilenceFloor
+			continue
+		}
+		windows[i] = math.Max(10*math.Log10(energy[i]/float64(counts[i])), SilenceFloor)
+	}
+	return windows
+}
+
+// Detect 检测高光片段，结果按时间排序
+// duration 为录像时长，用于限制片段结束时间，未知时传 0
+func Detect(points []Point, duration float64, opts Options) []Highlight {
+	if opts.Window <= 0 {
+		opts.Window = DefaultOptions().Window
+	}
+	windows := aggregate(points, opts.Window)
+	baseN := int(math.Max(1, math.Round(opts.BaselineWindow/opts.Window)))
+	minN := int(math.Ceil(opts.MinBaseline / opts.Window))
+	if minN < 1 {
+		minN = 1
+	}
+
+	// 前缀和用于计算滚动基线（当前窗口之前 baseN 个窗口的平均响度）
+	prefix := make([]float64, len(windows)+1)
+	for i, v := range windows {
+		prefix[i+1] = prefix[i] + v
+	}
+
+	var result []Highlight
+	var cur *Highlight
+	var curLast float64
+	for i, v := range windows {
+		from := max(0, i-baseN)
+		if i-from < minN {
+			continue
+		}
+		baseline := (prefix[i] - prefix[from]) / float64(i-from)
+		score := v - baseline
+		if score < opts.Threshold || v < opts.MinLoudness {
+			continue
+		}
+		t := float64(i) * opts.Window
+		if cur != nil && t-curLast <= opts.MergeGap {
+			curLast = t + opts.Window
+			if score > cur.Score {
+				cur.Peak, cur.Score, cur.Loudness, cur.Baseline = t, score, v, baseline
+			}
+			continue
+		}
+		if cur != nil {
+			result = append(result, finish(*cur, curLast, duration, opts))
+		}
+		cur = &Highlight{Start: t, Peak: t, Score: score, Loudness: v, Baseline: baseline}
+		curLast = t + opts.Window
+	}
+	if cur != nil {
+		result = append(result, finish(*cur, curLast, duration, opts))
+	}
+
+	if opts.MaxHighlights > 0 && len(result) > opts.MaxHighlights {
+		result = TopN(result, opts.MaxHighlights)
+		sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	}
+	return result
+}
+
+// finish 加上前后保留时长并限制在录像范围内
+func finish(h Highlight, last, duration float64, opts Options) Highlight {
+	h.Start = math.Max(0, h.Start-opts.PreRoll)
+	h.End = last + opts.PostRoll
+	if duration > 0 && h.End > duration {
+		h.End = duration
+	}
+	h.Score = round2(h.Score)
+	h.Loudness = round2(h.Loudness)
+	h.Baseline = round2(h.Baseline)
+	return h
+}
+
+// TopN 返回得分最高的 n 个片段（按得分降序），不修改输入
+func TopN(highlights []Highlight, n int) []Highlight {
+	sorted := append([]Highlight(nil), highlights...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
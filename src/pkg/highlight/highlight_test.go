@@ -0,0 +1,128 @@
+package highlight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadata(t *testing.T) {
+	log := `frame:0    pts:0       pts_time:0
+lavfi.r128.M=-inf
+frame:1    pts:4800    pts_time:0.1
+lavfi.r128.M=-23.5
+lavfi.r128.S=-24.0
+frame:2    pts:9600    pts_time:0.2
+lavfi.r128.M=nan
+`
+	points, err := ParseMetadata(strings.NewReader(log), "lavfi.r128.M")
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: 0, Loudness: SilenceFloor},
+		{Time: 0.1, Loudness: -23.5},
+		{Time: 0.2, Loudness: SilenceFloor},
+	}, points)
+}
+
+// TestParseMetadataFixtures 解析 ffmpeg ametadata=mode=print 的输出样本
+// ebur128 按输入帧（AAC 每帧 1024 个采样）输出，astats 按 asetnsamples 分块输出
+func TestParseMetadataFixtures(t *testing.T) {
+	tests := []struct {
+		file  string
+		key   string
+		count int
+		want  map[int]Point
+	}{
+		{
+			file:  "ebur128.txt",
+			key:   "lavfi.r128.M",
+			count: 12,
+			want: map[int]Point{
+				0:  {Time: 0, Loudness: SilenceFloor}, // 测量窗口未满时为 -120.691
+				1:  {Time: 0.0213333, Loudness: SilenceFloor},
+				4:  {Time: 0.0853333, Loudness: -43.172},
+				10: {Time: 0.213333, Loudness: -19.984},
+				11: {Time: 0.234667, Loudness: SilenceFloor},
+			},
+		},
+		{
+			file:  "astats.txt",
+			key:   "lavfi.astats.Overall.RMS_level",
+			count: 6,
+			want: map[int]Point{
+				0: {Time: 0, Loudness: SilenceFloor},
+				2: {Time: 0.2, Loudness: -27.624133},
+				4: {Time: 0.4, Loudness: -17.90211},
+				5: {Time: 0.5, Loudness: -45.5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			require.NoError(t, err)
+			defer f.Close()
+			points, err := ParseMetadata(f, tt.key)
+			require.NoError(t, err)
+			require.Len(t, points, tt.count)
+			for i, want := range tt.want {
+				assert.InDelta(t, want.Time, points[i].Time, 1e-9, "point %d", i)
+				assert.InDelta(t, want.Loudness, points[i].Loudness, 1e-9, "point %d", i)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	// 10 分钟，平时 -30 LUFS，两段喊叫和一段安静中的相对峰值
+	var points []Point
+	for i := 0; i < 6000; i++ {
+		ts := float64(i) / 10
+		v := -30.0
+		switch {
+		case ts >= 120 && ts < 125, ts >= 131 && ts < 133:
+			v = -18
+		case ts >= 400 && ts < 403:
+			v = -14
+		case ts >= 500 && ts < 560:
+			v = -60
+		case ts >= 560 && ts < 562:
+			v = -45
+		}
+		points = append(points, Point{Time: ts, Loudness: v})
+	}
+
+	opts := DefaultOptions()
+	hs := Detect(points, 590, opts)
+	require.Len(t, hs, 2, fmt.Sprint(hs))
+
+	// 间隔 6 秒的两段合并为一个片段
+	assert.Equal(t, 105.0, hs[0].Start)
+	assert.Equal(t, 133.0+opts.PostRoll, hs[0].End)
+	assert.Equal(t, 120.0, hs[0].Peak)
+	assert.InDelta(t, 12, hs[0].Score, 0.01)
+	assert.Equal(t, 400.0, hs[1].Peak)
+	assert.Greater(t, hs[1].Score, hs[0].Score)
+
+	top := TopN(hs, 1)
+	require.Len(t, top, 1)
+	assert.Equal(t, 400.0, top[0].Peak)
+
+	// 片段数限制按得分保留，结果仍按时间排序
+	opts.MaxHighlights = 1
+	hs = Detect(points, 590, opts)
+	require.Len(t, hs, 1)
+	assert.Equal(t, 400.0, hs[0].Peak)
+
+	// 基线历史不足时不检测
+	opts = DefaultOptions()
+	opts.MinBaseline = 130
+	hs = Detect(points, 590, opts)
+	require.Len(t, hs, 2)
+	assert.Equal(t, 131.0, hs[0].Peak)
+}
@@ -0,0 +1,287 @@
+// Package integrity 为录制文件生成完整性校验附属文件，并在之后重新校验
+//
+// 文件落盘后在同目录生成两个附属文件：
+//   - <文件名>.sha256：与 sha256sum 兼容的校验和，可直接用 `sha256sum -c` 校验
+//   - <文件名>.manifest.json：大小、SHA-256、时长和编码等信息
+//
+// 磁盘或 NAS 故障后可按清单重新校验，找出丢失、被修改或被截断的录制文件。
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+const (
+	// ChecksumSuffix 校验和附属文件的后缀
+	ChecksumSuffix = ".sha256"
+	// ManifestSuffix 清单附属文件的后缀
+	ManifestSuffix = ".manifest.json"
+
+	manifestVersion = 1
+)
+
+var (
+	// ErrNoManifest 文件没有清单
+	ErrNoManifest = errors.New("没有完整性清单")
+	// ErrMismatch 文件与清单不一致
+	ErrMismatch = errors.New("文件与完整性清单不一致")
+)
+
+// Manifest 完整性清单
+type Manifest struct {
+	Version    int       `json:"version"`
+	File       string    `json:"file"` // 文件名（不含目录，文件随目录移动后清单仍然有效）
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	Duration   float64   `json:"duration,omitempty"` // 时长（秒），无法探测时为 0
+	VideoCodec string    `json:"video_codec,omitempty"`
+	AudioCodec string    `json:"audio_codec,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Status 校验结果
+type Status string
+
+const (
+	StatusOK         Status = "ok"          // 与清单一致
+	StatusMissing    Status = "missing"     // 清单存在但文件已丢失
+	StatusTruncated  Status = "truncated"   // 文件比清单记录的小
+	StatusChanged    Status = "changed"     // 大小变大或内容不一致
+	StatusNoManifest Status = "no_manifest" // 文件没有清单，无法校验
+	StatusError      Status = "error"       // 读取文件或清单失败
+)
+
+// Result 单个文件的校验结果
+type Result struct {
+	Path         string `json:"path"`
+	Status       Status `json:"status"`
+	ExpectedSize int64  `json:"expected_size,omitempty"`
+	ActualSize   int64  `json:"actual_size,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// IsProblem 结果是否表示文件已损坏或丢失
+func (r Result) IsProblem() bool {
+	switch r.Status {
+	case StatusMissing, StatusTruncated, StatusChanged, StatusError:
+		return true
+	}
+	return false
+}
+
+// ChecksumPath 返回文件的校验和附属文件路径
+func ChecksumPath(path string) string {
+	return path + ChecksumSuffix
+}
+
+// ManifestPath 返回文件的清单附属文件路径
+func ManifestPath(path string) string {
+	return path + ManifestSuffix
+}
+
+// Create 计算文件的 SHA-256 并写入附属文件，时长和编码通过探测文件获得
+func Create(ctx context.Context, path string) (*Manifest, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	sum, size, err := hashFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if size != fi.Size() {
+		return nil, fmt.Errorf("计算校验和期间文件大小发生变化: %s", path)
+	}
+
+	m := &Manifest{
+		Version:   manifestVersion,
+		File:      filepath.Base(path),
+		Size:      size,
+		SHA256:    sum,
+		CreatedAt: time.Now(),
+	}
+	if probed, err := streamprobe.ProbeFile(path); err == nil {
+		m.Duration = probed.Duration.Seconds()
+		if probed.StreamHeaderInfo != nil {
+			m.VideoCodec = probed.VideoCodec
+			m.AudioCodec = probed.AudioCodec
+		}
+	}
+
+	if err := writeFileAtomic(ChecksumPath(path), []byte(fmt.Sprintf("%s  %s\n", sum, m.File))); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(ManifestPath(path), data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Load 读取文件的清单，没有清单时返回 ErrNoManifest
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(ManifestPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("解析完整性清单失败: %w", err)
+	}
+	return &m, nil
+}
+
+// Verify 按清单重新校验文件
+// 大小不一致时不再计算校验和，可以快速发现被截断的文件
+func Verify(ctx context.Context, path string) Result {
+	result := Result{Path: path}
+	m, err := Load(path)
+	if errors.Is(err, ErrNoManifest) {
+		result.Status = StatusNoManifest
+		return result
+	}
+	if err != nil {
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+	result.ExpectedSize = m.Size
+
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		result.Status = StatusMissing
+		return result
+	}
+	if err != nil {
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+	result.ActualSize = fi.Size()
+	switch {
+	case fi.Size() < m.Size:
+		result.Status = StatusTruncated
+		return result
+	case fi.Size() > m.Size:
+		result.Status = StatusChanged
+		result.Message = "文件大小增加"
+		return result
+	}
+
+	sum, _, err := hashFile(ctx, path)
+	if err != nil {
+		result.Status = StatusError
+		result.Message = err.Error()
+		return result
+	}
+	if !strings.EqualFold(sum, m.SHA256) {
+		result.Status = StatusChanged
+		result.Message = "SHA-256 不一致"
+		return result
+	}
+	result.Status = StatusOK
+	return result
+}
+
+// Check 在上传、删除等操作前校验文件
+// 没有清单时返回 nil（无法校验，不阻止操作），文件与清单不一致时返回包装了 ErrMismatch 的错误
+func Check(ctx context.Context, path string) error {
+	result := Verify(ctx, path)
+	switch result.Status {
+	case StatusOK, StatusNoManifest:
+		return nil
+	case StatusError:
+		return fmt.Errorf("校验 %s 失败: %s", filepath.Base(path), result.Message)
+	}
+	return fmt.Errorf("%w: %s %s", ErrMismatch, filepath.Base(path), result.Status)
+}
+
+// Remove 删除文件的附属文件
+func Remove(path string) {
+	os.Remove(ChecksumPath(path))
+	os.Remove(ManifestPath(path))
+}
+
+// Rename 文件重命名后同步移动附属文件，并更新其中记录的文件名
+func Rename(oldPath, newPath string) error {
+	m, err := Load(oldPath)
+	if errors.Is(err, ErrNoManifest) {
+		os.Remove(ChecksumPath(oldPath))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m.File = filepath.Base(newPath)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ChecksumPath(newPath), []byte(fmt.Sprintf("%s  %s\n", m.SHA256, m.File))); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ManifestPath(newPath), data); err != nil {
+		return err
+	}
+	Remove(oldPath)
+	return nil
+}
+
+// hashFile 计算文件的 SHA-256，可通过 ctx 中断
+func hashFile(ctx context.Context, path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, &ctxReader{ctx: ctx, r: f})
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ctxReader 在每次读取前检查 ctx，避免校验大文件时无法取消
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免中断时留下不完整的附属文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestCreateAndVerify(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "record.flv")
+	data := []byte("not really a flv file")
+	writeTestFile(t, path, data)
+
+	m, err := Create(ctx, path)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(sum[:]), m.SHA256)
+	assert.Equal(t, int64(len(data)), m.Size)
+
+	checksum, err := os.ReadFile(ChecksumPath(path))
+	require.NoError(t, err)
+	assert.Equal(t, m.SHA256+"  record.flv\n", string(checksum))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, m.SHA256, loaded.SHA256)
+
+	assert.Equal(t, StatusOK, Verify(ctx, path).Status)
+	assert.NoError(t, Check(ctx, path))
+
+	// 大小不变但内容被修改
+	writeTestFile(t, path, []byte("NOT really a flv file"))
+	assert.Equal(t, StatusChanged, Verify(ctx, path).Status)
+	assert.True(t, errors.Is(Check(ctx, path), ErrMismatch))
+
+	writeTestFile(t, path, data[:5])
+	result := Verify(ctx, path)
+	assert.Equal(t, StatusTruncated, result.Status)
+	assert.Equal(t, int64(len(data)), result.ExpectedSize)
+	assert.Equal(t, int64(5), result.ActualSize)
+
+	require.NoError(t, os.Remove(path))
+	assert.Equal(t, StatusMissing, Verify(ctx, path).Status)
+
+	// 重命名后附属文件随之移动
+	writeTestFile(t, path, data)
+	renamed := filepath.Join(dir, "renamed.flv")
+	require.NoError(t, os.Rename(path, renamed))
+	require.NoError(t, Rename(path, renamed))
+	assert.Equal(t, StatusOK, Verify(ctx, renamed).Status)
+	assert.Equal(t, StatusNoManifest, Verify(ctx, path).Status)
+	checksum, err = os.ReadFile(ChecksumPath(renamed))
+	require.NoError(t, err)
+	assert.Equal(t, m.SHA256+"  renamed.flv\n", string(checksum))
+
+	Remove(renamed)
+	assert.Equal(t, StatusNoManifest, Verify(ctx, renamed).Status)
+	assert.NoError(t, Check(ctx, path), "没有清单时不阻止操作")
+}
+
+func TestVerifyTree(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "平台", "主播")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+
+	good := filepath.Join(sub, "good.flv")
+	lost := filepath.Join(sub, "lost.flv")
+	bare := filepath.Join(sub, "bare.mp4")
+	for _, p := range []string{good, lost, bare} {
+		writeTestFile(t, p, []byte(p))
+	}
+	for _, p := range []string{good, lost} {
+		_, err := Create(ctx, p)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.Remove(lost))
+
+	v := &Verifier{}
+	require.NoError(t, VerifyTree(ctx, []string{dir}, v.record))
+	status := v.Status()
+	assert.Equal(t, 3, status.Checked)
+	assert.Equal(t, 1, status.OK)
+	assert.Equal(t, 1, status.NoManifest)
+	require.Len(t, status.Problems, 1)
+	assert.Equal(t, lost, status.Problems[0].Path)
+	assert.Equal(t, StatusMissing, status.Problems[0].Status)
+}
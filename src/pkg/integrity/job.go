@@ -0,0 +1,134 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrJobRunning 已有校验任务在运行
+var ErrJobRunning = errors.New("完整性校验任务正在运行")
+
+// mediaExts 没有清单时也会出现在报告中的媒体文件扩展名
+var mediaExts = map[string]bool{
+	".flv": true,
+	".mp4": true,
+	".ts":  true,
+	".mkv": true,
+	".mov": true,
+	".m4a": true,
+	".aac": true,
+}
+
+// JobStatus 校验任务状态
+type JobStatus struct {
+	Running    bool      `json:"running"`
+	Roots      []string  `json:"roots,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Checked    int       `json:"checked"`     // 已校验的文件数（含没有清单的文件）
+	OK         int       `json:"ok"`          // 与清单一致的文件数
+	NoManifest int       `json:"no_manifest"` // 没有清单的媒体文件数
+	Problems   []Result  `json:"problems"`    // 丢失、被修改、被截断或无法读取的文件
+	Error      string    `json:"error,omitempty"`
+}
+
+// Verifier 在后台按目录批量校验文件
+type Verifier struct {
+	mu     sync.RWMutex
+	status JobStatus
+}
+
+var globalVerifier = &Verifier{}
+
+// GetGlobalVerifier 返回全局校验任务
+func GetGlobalVerifier() *Verifier {
+	return globalVerifier
+}
+
+// Status 返回最近一次校验任务的状态
+func (v *Verifier) Status() JobStatus {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	status := v.status
+	status.Problems = append([]Result(nil), v.status.Problems...)
+	return status
+}
+
+// Start 在后台校验指定目录，同一时间只允许一个任务
+func (v *Verifier) Start(ctx context.Context, roots []string) error {
+	v.mu.Lock()
+	if v.status.Running {
+		v.mu.Unlock()
+		return ErrJobRunning
+	}
+	v.status = JobStatus{Running: true, Roots: roots, StartedAt: time.Now()}
+	v.mu.Unlock()
+
+	go func() {
+		err := VerifyTree(ctx, roots, v.record)
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.status.Running = false
+		v.status.FinishedAt = time.Now()
+		if err != nil {
+			v.status.Error = err.Error()
+		}
+	}()
+	return nil
+}
+
+// record 累计一个文件的校验结果
+func (v *Verifier) record(result Result) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.status.Checked++
+	switch {
+	case result.Status == StatusOK:
+		v.status.OK++
+	case result.Status == StatusNoManifest:
+		v.status.NoManifest++
+	case result.IsProblem():
+		v.status.Problems = append(v.status.Problems, result)
+	}
+}
+
+// VerifyTree 校验目录下所有有清单的文件（包括文件已丢失的清单）和没有清单的媒体文件
+func VerifyTree(ctx context.Context, roots []string, onResult func(Result)) error {
+	for _, root := range roots {
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			onResult(Result{Path: root, Status: StatusError, Message: "目录不可用"})
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				// 单个目录无法访问时跳过，不中断整个校验
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			switch {
+			case strings.HasSuffix(path, ManifestSuffix):
+				onResult(Verify(ctx, strings.TrimSuffix(path, ManifestSuffix)))
+			case mediaExts[strings.ToLower(filepath.Ext(path))]:
+				// 有清单的文件由清单触发校验
+				if _, err := os.Stat(ManifestPath(path)); errors.Is(err, os.ErrNotExist) {
+					onResult(Result{Path: path, Status: StatusNoManifest})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+// Package livehls 将正在录制的 FLV 直播流实时封装为 fMP4 HLS，供不支持 flv.js 的浏览器观看
+//
+// 数据来自录制器探测代理转发的字节（与 HTTP-FLV 分发共用同一份数据），在内存中按关键帧切分为分段，
+// 直播播放列表只包含最近几个分段（滑动窗口），回看播放列表包含 DVR 窗口内的全部分段。
+// 为节省内存，只有在有人请求播放列表后才开始封装，无人观看一段时间后自动释放。
+package livehls
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/fmp4mux"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+const (
+	// DefaultTargetDuration 默认目标分段时长，实际在关键帧处切分
+	DefaultTargetDuration = 2 * time.Second
+	// DefaultLiveWindow 直播播放列表包含的分段数
+	DefaultLiveWindow = 6
+	// DefaultDVRWindow 可回看的时长
+	DefaultDVRWindow = 5 * time.Minute
+	// DefaultIdleTimeout 无人请求超过该时长后停止封装并释放分段
+	DefaultIdleTimeout = 2 * time.Minute
+)
+
+var (
+	// ErrClosed 录制已结束
+	ErrClosed = errors.New("直播流已关闭")
+	// ErrNotReady 还没有可播放的分段
+	ErrNotReady = errors.New("暂无可播放的分段")
+)
+
+// Options HLS 封装选项
+type Options struct {
+	TargetDuration time.Duration
+	LiveWindow     int
+	DVRWindow      time.Duration
+	IdleTimeout    time.Duration
+}
+
+func (o *Options) applyDefaults() {
+	if o.TargetDuration <= 0 {
+		o.TargetDuration = DefaultTargetDuration
+	}
+	if o.LiveWindow <= 0 {
+		o.LiveWindow = DefaultLiveWindow
+	}
+	if o.DVRWindow <= 0 {
+		o.DVRWindow = DefaultDVRWindow
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = DefaultIdleTimeout
+	}
+}
+
+// Segment 一个 HLS 分段（一个或多个完整的 GOP）
+type Segment struct {
+	Sequence      uint64
+	Data          []byte
+	Duration      time.Duration
+	InitVersion   int
+	Discontinuity bool // 与上一个分段之间存在不连续点（重连或编码参数变化）
+	ProgramTime   time.Time
+}
+
+// Stream 单个直播间的 HLS 封装器，实现 io.Writer，可作为 streamprobe.Config.Tee 使用
+type Stream struct {
+	mu   sync.Mutex
+	opts Options
+
+	splitter streamprobe.FLVTagSplitter
+	muxer    *fmp4mux.Muxer
+	tagErr   error // 最近一次不支持的编码错误，避免重复记录
+
+	active     bool
+	lastAccess time.Time
+	closed     bool
+
+	inits       map[int][]byte
+	initVersion int
+
+	segments        []*Segment
+	nextSequence    uint64
+	evictedDiscSeq  uint64 // 已移出窗口的不连续点数量
+	cur             *Segment
+	pendingDiscont  bool
+	sawFirstSegment bool
+}
+
+// New 创建 HLS 封装器
+func New(opts Options) *Stream {
+	opts.applyDefaults()
+	s := &Stream{
+		opts:  opts,
+		inits: make(map[int][]byte),
+	}
+	s.muxer = fmp4mux.New(fmp4mux.Options{
+		OnInit:     s.onInit,
+		OnFragment: s.onFragment,
+	})
+	s.splitter.OnTag = s.onTag
+	return s
+}
+
+// Write 接收探测代理转发给下载器的原始 FLV 字节，始终返回 len(p)
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return len(p), nil
+	}
+	if s.active && time.Since(s.lastAccess) > s.opts.IdleTimeout {
+		s.deactivateLocked()
+	}
+	return s.splitter.Write(p)
+}
+
+// Reset 在重新连接上游（新的 FLV 流）之前调用
+// 已有分段保留，新流的第一个分段前会插入不连续点
+func (s *Stream) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishSegmentLocked()
+	s.splitter.Reset()
+	s.muxer.Reset()
+}
+
+// Close 录制结束，释放全部分段
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.deactivateLocked()
+}
+
+// Touch 记录一次观看请求，未在封装时开始封装
+// 返回 false 表示录制已结束
+func (s *Stream) Touch() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.lastAccess = time.Now()
+	if !s.active {
+		s.active = true
+		// 从下一个关键帧开始输出
+		s.muxer.Restart()
+	}
+	return true
+}
+
+// deactivateLocked 停止封装并释放分段，保留解码配置以便再次激活时立即从关键帧开始
+func (s *Stream) deactivateLocked() {
+	s.active = false
+	s.muxer.Restart()
+	s.segments = nil
+	s.cur = nil
+	s.inits = make(map[int][]byte)
+	s.pendingDiscont = false
+	s.sawFirstSegment = false
+}
+
+func (s *Stream) onTag(tag []byte) {
+	tagType := streamprobe.TagType(tag)
+	if tagType != fmp4mux.TagAudio && tagType != fmp4mux.TagVideo {
+		return
+	}
+	data := streamprobe.TagData(tag)
+	if !s.active && !fmp4mux.IsSequenceHeader(tagType, data) {
+		// 未激活时只跟踪解码配置
+		return
+	}
+	if err := s.muxer.WriteTag(tagType, streamprobe.TagTimestamp(tag), data); err != nil {
+		s.tagErr = err
+	}
+}
+
+func (s *Stream) onInit(init []byte) error {
+	s.finishSegmentLocked()
+	s.initVersion++
+	s.inits[s.initVersion] = init
+	if s.sawFirstSegment {
+		s.pendingDiscont = true
+	}
+	return nil
+}
+
+func (s *Stream) onFragment(f *fmp4mux.Fragment) error {
+	if !s.active {
+		return nil
+	}
+	if s.cur == nil {
+		s.cur = &Segment{
+			InitVersion:   s.initVersion,
+			Discontinuity: s.pendingDiscont,
+			ProgramTime:   time.Now().Add(-f.Duration),
+		}
+		s.pendingDiscont = false
+	}
+	s.cur.Data = append(s.cur.Data, f.Data...)
+	s.cur.Duration += f.Duration
+	if s.cur.Duration >= s.opts.TargetDuration {
+		s.finishSegmentLocked()
+	}
+	return nil
+}
+
+// finishSegmentLocked 将正在构建的分段加入列表，并淘汰超出 DVR 窗口的旧分段
+func (s *Stream) finishSegmentLocked() {
+	if s.cur == nil {
+		return
+	}
+	seg := s.cur
+	s.cur = nil
+	seg.Sequence = s.nextSequence
+	s.nextSequence++
+	s.segments = append(s.segments, seg)
+	s.sawFirstSegment = true
+
+	var total time.Duration
+	for _, sg := range s.segments {
+		total += sg.Duration
+	}
+	for len(s.segments) > s.opts.LiveWindow && total-s.segments[0].Duration >= s.opts.DVRWindow {
+		old := s.segments[0]
+		total -= old.Duration
+		if old.Discontinuity {
+			s.evictedDiscSeq++
+		}
+		s.segments = s.segments[1:]
+	}
+	s.pruneInitsLocked()
+}
+
+// pruneInitsLocked 删除已没有分段引用的旧初始化段
+func (s *Stream) pruneInitsLocked() {
+	for v := range s.inits {
+		if v == s.initVersion {
+			continue
+		}
+		if len(s.segments) == 0 || v < s.segments[0].InitVersion {
+			delete(s.inits, v)
+		}
+	}
+}
+
+// Playlist 生成 m3u8 播放列表，dvr 为 true 时包含 DVR 窗口内的全部分段
+func (s *Stream) Playlist(dvr bool) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", ErrClosed
+	}
+	if len(s.segments) == 0 {
+		return "", ErrNotReady
+	}
+
+	first := 0
+	if !dvr && len(s.segments) > s.opts.LiveWindow {
+		first = len(s.segments) - s.opts.LiveWindow
+	}
+	segments := s.segments[first:]
+
+	// 第一个分段的不连续序号包含其之前（含自身）的全部不连续点
+	discSeq := s.evictedDiscSeq
+	for _, seg := range s.segments[:first+1] {
+		if seg.Discontinuity {
+			discSeq++
+		}
+	}
+
+	target := s.opts.TargetDuration
+	for _, seg := range segments {
+		if seg.Duration > target {
+			target = seg.Duration
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(target.Seconds())))
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", segments[0].Sequence)
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", discSeq)
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	initVersion := 0
+	for i, seg := range segments {
+		if seg.Discontinuity && i > 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if seg.InitVersion != initVersion {
+			initVersion = seg.InitVersion
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\"\n", InitName(initVersion))
+		}
+		fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", seg.ProgramTime.UTC().Format("2006-01-02T15:04:05.000Z"))
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", seg.Duration.Seconds())
+		b.WriteString(SegmentName(seg.Sequence) + "\n")
+	}
+	return b.String(), nil
+}
+
+// Init 返回指定版本的初始化段
+func (s *Stream) Init(version int) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.inits[version]
+	return data, ok
+}
+
+// Segment 返回指定序号的分段
+func (s *Stream) Segment(sequence uint64) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, seg := range s.segments {
+		if seg.Sequence == sequence {
+			return seg.Data, true
+		}
+	}
+	return nil, false
+}
+
+// LastError 返回最近一次封装失败的原因（例如不支持的编码格式）
+func (s *Stream) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tagErr
+}
+
+// InitName 初始化段文件名
+func InitName(version int) string {
+	return fmt.Sprintf("init-%d.mp4", version)
+}
+
+// SegmentName 分段文件名
+func SegmentName(sequence uint64) string {
+	return fmt.Sprintf("seg-%d.m4s", sequence)
+}
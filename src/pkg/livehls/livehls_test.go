@@ -0,0 +1,117 @@
+package livehls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSPS = []byte{
+	0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+	0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+	0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9,
+	0x20,
+}
+
+func flvHeader() []byte {
+	return []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+}
+
+func flvTag(tagType uint8, ts uint32, data []byte) []byte {
+	tag := []byte{tagType, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data)),
+		byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24), 0, 0, 0}
+	tag = append(tag, data...)
+	return binary.BigEndian.AppendUint32(tag, uint32(len(data)+11))
+}
+
+func avcSeqHeader() []byte {
+	data := []byte{0x17, 0, 0, 0, 0, 1, testSPS[1], testSPS[2], testSPS[3], 0xff, 0xe1}
+	data = append(data, byte(len(testSPS)>>8), byte(len(testSPS)))
+	data = append(data, testSPS...)
+	return append(data, 1, 0, 1, 0x08)
+}
+
+func avcFrame(key bool) []byte {
+	head := byte(0x27)
+	if key {
+		head = 0x17
+	}
+	return []byte{head, 1, 0, 0, 0, 0, 0, 0, 1, 0x65}
+}
+
+// writeGOPs 写入 n 个 1 秒的 GOP（每秒 25 帧）
+func writeGOPs(t *testing.T, s *Stream, start uint32, n int) uint32 {
+	ts := start
+	for i := 0; i < n; i++ {
+		for f := 0; f < 25; f++ {
+			_, err := s.Write(flvTag(9, ts, avcFrame(f == 0)))
+			require.NoError(t, err)
+			ts += 40
+		}
+	}
+	return ts
+}
+
+func TestStreamPlaylistAndSegments(t *testing.T) {
+	s := New(Options{TargetDuration: time.Second, LiveWindow: 2, DVRWindow: 3 * time.Second})
+	_, err := s.Write(flvHeader())
+	require.NoError(t, err)
+	_, err = s.Write(flvTag(9, 0, avcSeqHeader()))
+	require.NoError(t, err)
+
+	// 未被请求时不封装
+	writeGOPs(t, s, 0, 2)
+	_, err = s.Playlist(false)
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	require.True(t, s.Touch())
+	writeGOPs(t, s, 2000, 6)
+
+	live, err := s.Playlist(false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(live, "#EXTINF:"))
+	assert.Contains(t, live, "#EXT-X-MAP:URI=\"init-1.mp4\"")
+
+	dvr, err := s.Playlist(true)
+	require.NoError(t, err)
+	// 第 6 个 GOP 要等到下一个关键帧才结束，已完成的 5 个分段中 DVR 窗口保留最近 3 秒
+	assert.Equal(t, 3, strings.Count(dvr, "#EXTINF:"))
+	assert.Contains(t, dvr, "#EXT-X-MEDIA-SEQUENCE:2\n")
+
+	initData, ok := s.Init(1)
+	require.True(t, ok)
+	var init fmp4.Init
+	require.NoError(t, init.Unmarshal(bytes.NewReader(initData)))
+	require.Len(t, init.Tracks, 1)
+
+	_, ok = s.Segment(0)
+	assert.False(t, ok, "超出 DVR 窗口的分段应被淘汰")
+	seg, ok := s.Segment(4)
+	require.True(t, ok)
+	var parts fmp4.Parts
+	require.NoError(t, parts.Unmarshal(seg))
+	assert.Len(t, parts[0].Tracks[0].Samples, 25)
+
+	// 重连后输出新的初始化段并插入不连续点
+	s.Reset()
+	_, err = s.Write(flvHeader())
+	require.NoError(t, err)
+	_, err = s.Write(flvTag(9, 0, avcSeqHeader()))
+	require.NoError(t, err)
+	writeGOPs(t, s, 0, 2)
+
+	live, err = s.Playlist(false)
+	require.NoError(t, err)
+	assert.Contains(t, live, "#EXT-X-DISCONTINUITY\n#EXT-X-MAP:URI=\"init-2.mp4\"\n")
+
+	s.Close()
+	_, err = s.Playlist(false)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.False(t, s.Touch())
+}
@@ -0,0 +1,185 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+const (
+	// maxSilenceFillMs 时间戳空隙不超过该时长时用静音帧补齐，更大的跳变视为时间戳重置
+	maxSilenceFillMs = 10 * 1000
+	// maxTimestampJitterFrames 时间戳与按采样数推算的位置相差不超过该帧数时视为正常抖动
+	maxTimestampJitterFrames = 2
+)
+
+// ErrAudioNotAAC 只录音频模式下流中的音频不是 AAC，无法写入 ADTS
+var ErrAudioNotAAC = errors.New("只录音频模式仅支持 AAC 音频")
+
+// silentAACFrames AAC-LC 单声道和双声道的静音帧（原始数据块，不含 ADTS 头）
+var silentAACFrames = map[uint8][]byte{
+	1: {0x00, 0xc8, 0x00, 0x80, 0x23, 0x80},
+	2: {0x21, 0x00, 0x49, 0x90, 0x02, 0x19, 0x00, 0x23, 0x80},
+}
+
+// adtsWriter 从解析器输出的 FLV 字节流中提取 AAC 音频，写为 ADTS（.aac）
+//
+// ADTS 本身不带时间戳，播放时长完全由帧数决定。写入时按采样数推算每帧应有的时间，
+// 与 FLV 时间戳比较：上游丢帧造成的空隙用静音帧补齐，保证音频时长与直播时间一致。
+type adtsWriter struct {
+	out    io.Writer
+	logger logrus.FieldLogger
+
+	splitter streamprobe.FLVTagSplitter
+	config   []byte
+	asc      *mpeg4audio.AudioSpecificConfig
+	err      error
+
+	// 时间基准：origin 为第一帧的 FLV 时间戳，samples 为之后已写入的采样数
+	started bool
+	origin  int64
+	samples int64
+}
+
+func newADTSWriter(out io.Writer, logger logrus.FieldLogger) *adtsWriter {
+	w := &adtsWriter{out: out, logger: logger}
+	w.splitter.OnTag = w.onTag
+	return w
+}
+
+// Write 实现 io.Writer，写入文件失败或音频无法处理时返回错误使解析器停止
+func (w *adtsWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.splitter.Write(p)
+	if w.err == nil && w.splitter.Invalid() {
+		w.err = ErrNotFlvStream
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func (w *adtsWriter) onTag(tag []byte) {
+	if w.err != nil || streamprobe.TagType(tag) != audioTag {
+		return
+	}
+	data := streamprobe.TagData(tag)
+	if len(data) < 2 {
+		return
+	}
+	if SoundFormat(data[0]>>4) != AAC {
+		w.err = ErrAudioNotAAC
+		return
+	}
+	switch AACPacketType(data[1]) {
+	case AACSeqHeader:
+		w.setConfig(data[2:])
+	case AACRaw:
+		if w.asc == nil {
+			// 序列头之前的数据无法解码
+			return
+		}
+		w.writeFrame(int64(streamprobe.TagTimestamp(tag)), data[2:])
+	}
+}
+
+func (w *adtsWriter) setConfig(config []byte) {
+	if w.asc != nil && bytes.Equal(config, w.config) {
+		return
+	}
+	var asc mpeg4audio.AudioSpecificConfig
+	if err := asc.Unmarshal(config); err != nil || asc.SampleRate <= 0 {
+		w.logger.WithError(err).Warn("无法解析 AAC 序列头，忽略")
+		return
+	}
+	if w.asc != nil {
+		// ADTS 每帧都带有编码参数，可以直接继续写入，只需重新计算时间基准
+		w.logger.Infof("AAC 编码参数变化: %d Hz, 声道配置 %d", asc.SampleRate, asc.ChannelConfig)
+		w.started = false
+	}
+	w.config = append([]byte(nil), config...)
+	w.asc = &asc
+}
+
+func (w *adtsWriter) frameSamples() int64 {
+	if w.asc.FrameLengthFlag {
+		return 960
+	}
+	return 1024
+}
+
+func (w *adtsWriter) writeFrame(dts int64, au []byte) {
+	rate := int64(w.asc.SampleRate)
+	frame := w.frameSamples()
+	if !w.started {
+		w.started = true
+		w.origin = dts
+		w.samples = 0
+	}
+
+	gap := (dts-w.origin)*rate/1000 - w.samples
+	switch {
+	case gap > maxSilenceFillMs*rate/1000 || gap < -maxSilenceFillMs*rate/1000:
+		// 时间戳跳变（如上游重置时间戳），以当前帧为新的时间基准
+		w.logger.Warnf("音频时间戳跳变 %d ms，重新计算时间基准", gap*1000/rate)
+		w.origin = dts
+		w.samples = 0
+	case gap > maxTimestampJitterFrames*frame:
+		if silence := w.silentFrame(); silence != nil {
+			n := (gap + frame/2) / frame
+			w.logger.Debugf("音频时间戳空隙 %d ms，补齐 %d 个静音帧", gap*1000/rate, n)
+			for i := int64(0); i < n && w.err == nil; i++ {
+				w.write(silence)
+			}
+		} else {
+			// 无法生成静音帧时保留空隙，以当前帧为新的时间基准
+			w.logger.Warnf("音频时间戳空隙 %d ms，当前编码无法补齐静音", gap*1000/rate)
+			w.origin = dts
+			w.samples = 0
+		}
+	}
+	w.write(au)
+}
+
+// silentFrame 返回当前编码参数下的静音帧，不支持时返回 nil
+func (w *adtsWriter) silentFrame() []byte {
+	if w.asc.Type != mpeg4audio.ObjectTypeAACLC || w.asc.ExtensionType != 0 || w.asc.FrameLengthFlag {
+		return nil
+	}
+	return silentAACFrames[w.asc.ChannelConfig]
+}
+
+func (w *adtsWriter) write(au []byte) {
+	if w.err != nil {
+		return
+	}
+	objectType := w.asc.Type
+	if objectType == mpeg4audio.ObjectTypeSBR || objectType == mpeg4audio.ObjectTypePS {
+		// HE-AAC 在 ADTS 中按核心的 AAC-LC 描述，由解码器隐式识别 SBR/PS
+		objectType = mpeg4audio.ObjectTypeAACLC
+	}
+	pkts := mpeg4audio.ADTSPackets{{
+		Type:          objectType,
+		SampleRate:    w.asc.SampleRate,
+		ChannelConfig: w.asc.ChannelConfig,
+		AU:            au,
+	}}
+	b, err := pkts.Marshal()
+	if err != nil {
+		w.err = err
+		return
+	}
+	if _, err := w.out.Write(b); err != nil {
+		w.err = err
+		return
+	}
+	w.samples += w.frameSamples()
+}
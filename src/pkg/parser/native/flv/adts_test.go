@@ -0,0 +1,79 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
+	"github.com/bililive-go/bililive-go/src/pkg/reader"
+)
+
+func parseToADTS(t *testing.T, stream *flvStream) ([]byte, error) {
+	t.Helper()
+	var out bytes.Buffer
+	logger := livelogger.New(1024, nil)
+	p := &Parser{
+		logger:    logger,
+		i:         reader.New(stream),
+		o:         newADTSWriter(&out, logger),
+		stopCh:    make(chan struct{}),
+		closeOnce: new(sync.Once),
+		audioOnly: true,
+	}
+	err := p.doParse(context.Background())
+	if err == io.EOF {
+		err = nil
+	}
+	return out.Bytes(), err
+}
+
+func TestADTSOutputFillsTimestampGaps(t *testing.T) {
+	stream := newFLVStream()
+	stream.tag(scriptTag, 0, []byte{2, 0, 0})
+	// 序列头之前的音频帧无法解码，被丢弃
+	stream.tag(audioTag, 0, []byte{0xaf, 1, 0xee})
+	// AAC LC, 44100Hz, 双声道
+	stream.tag(audioTag, 0, []byte{0xaf, 0, 0x12, 0x10})
+	stream.tag(videoTag, 0, avcSeqHeader())
+	// 每帧 1024 / 44100 ≈ 23.2ms
+	stream.tag(audioTag, 1000, []byte{0xaf, 1, 1})
+	stream.tag(audioTag, 1023, []byte{0xaf, 1, 2})
+	stream.tag(audioTag, 1046, []byte{0xaf, 1, 3})
+	// 上游丢失约 10 帧
+	stream.tag(audioTag, 1046+11*23, []byte{0xaf, 1, 4})
+	// 解析器读到下一个 tag 头时上一个 tag 才完整写出
+	stream.tag(scriptTag, 1400, []byte{2, 0, 0})
+
+	data, err := parseToADTS(t, stream)
+	require.NoError(t, err)
+
+	var pkts mpeg4audio.ADTSPackets
+	require.NoError(t, pkts.Unmarshal(data))
+	require.Len(t, pkts, 14)
+	for _, pkt := range pkts {
+		assert.Equal(t, mpeg4audio.ObjectTypeAACLC, pkt.Type)
+		assert.Equal(t, 44100, pkt.SampleRate)
+		assert.Equal(t, uint8(2), pkt.ChannelConfig)
+	}
+	assert.Equal(t, []byte{1}, pkts[0].AU)
+	assert.Equal(t, []byte{3}, pkts[2].AU)
+	assert.Equal(t, silentAACFrames[2], pkts[3].AU)
+	assert.Equal(t, []byte{4}, pkts[13].AU)
+}
+
+func TestADTSOutputRejectsNonAAC(t *testing.T) {
+	stream := newFLVStream()
+	// MP3
+	stream.tag(audioTag, 0, []byte{0x2f, 0xff, 0xfb})
+	stream.tag(audioTag, 26, []byte{0x2f, 0xff, 0xfb})
+
+	_, err := parseToADTS(t, stream)
+	assert.ErrorIs(t, err, ErrAudioNotAAC)
+}
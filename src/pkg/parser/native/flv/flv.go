@@ -46,13 +46,18 @@ type builder struct{}
 
 func (b *builder) Build(cfg map[string]string, logger *livelogger.LiveLogger) (parser.Parser, error) {
 	audioOnly := cfg["audio_only"] == "true"
+	outputFormat := configs.NativeOutputFormat(cfg["output_format"])
+	if outputFormat == "" {
+		outputFormat = configs.NativeOutputFLV
+	}
 	return &Parser{
-		Metadata:  Metadata{},
-		hc:        &http.Client{},
-		stopCh:    make(chan struct{}),
-		closeOnce: new(sync.Once),
-		audioOnly: audioOnly,
-		logger:    logger,
+		Metadata:     Metadata{},
+		hc:           &http.Client{},
+		stopCh:       make(chan struct{}),
+		closeOnce:    new(sync.Once),
+		audioOnly:    audioOnly,
+		outputFormat: outputFormat,
+		logger:       logger,
 	}, nil
 }
 
@@ -68,11 +73,12 @@ type Parser struct {
 	avcHeaderCount uint8
 	tagCount       uint32
 
-	hc        *http.Client
-	stopCh    chan struct{}
-	closeOnce *sync.Once
-	audioOnly bool
-	logger    *livelogger.LiveLogger
+	hc           *http.Client
+	stopCh       chan struct{}
+	closeOnce    *sync.Once
+	audioOnly    bool
+	outputFormat configs.NativeOutputFormat
+	logger       *livelogger.LiveLogger
 }
 
 func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.StreamUrlInfo, live live.Live, file string) error {
@@ -85,8 +91,20 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 	}
 
 	url := streamUrlInfo.Url
+	// Stop 只在两个 tag 之间检查 stopCh，上游卡住时读取会一直阻塞，
+	// 因此停止时同时取消请求，让阻塞的读取立即返回
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopCh:
+			cancel()
+		case <-reqCtx.Done():
+		}
+	}()
+
 	// init input
-	req, err := http.NewRequest("GET", url.String(), nil)
+	req, err := http.NewRequestWithContext(reqCtx, "GET", url.String(), nil)
 	if err != nil {
 		return err
 	}
@@ -97,6 +115,9 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 	}
 	resp, err := p.hc.Do(req)
 	if err != nil {
+		if p.stopped() {
+			return nil
+		}
 		return err
 	}
 	defer resp.Body.Close()
@@ -108,11 +129,42 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 	if err != nil {
 		return err
 	}
-	p.o = f
 	defer f.Close()
 
-	// start parse
-	return p.doParse(ctx)
+	var parseErr error
+	switch {
+	case p.outputFormat == configs.NativeOutputFMP4:
+		// 边解析边封装为 fMP4（只录音频时为 m4a），结束时写出最后一个分片
+		w := newFMP4Writer(f, p.audioOnly, p.logger)
+		p.o = w
+		parseErr = p.doParse(ctx)
+		if err := w.Close(); err != nil && parseErr == nil {
+			parseErr = err
+		}
+	case p.audioOnly:
+		// 只录音频：提取 AAC 写为 ADTS
+		p.o = newADTSWriter(f, p.logger)
+		parseErr = p.doParse(ctx)
+	default:
+		p.o = f
+		// start parse
+		parseErr = p.doParse(ctx)
+	}
+	// 停止时取消请求导致的读取错误不是录制失败
+	if parseErr != nil && p.stopped() {
+		return nil
+	}
+	return parseErr
+}
+
+// stopped 是否已调用 Stop
+func (p *Parser) stopped() bool {
+	select {
+	case <-p.stopCh:
+		return true
+	default:
+		return false
+	}
 }
 
 func (p *Parser) Stop() error {
@@ -194,6 +246,7 @@ func (p *Parser) doWrite(ctx context.Context, b []byte) error {
 // Status 返回下载器的当前状态
 func (p *Parser) Status() (map[string]interface{}, error) {
 	return map[string]interface{}{
-		"parser": Name,
+		"parser":        Name,
+		"output_format": p.outputFormat,
 	}, nil
 }
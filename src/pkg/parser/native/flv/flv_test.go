@@ -0,0 +1,60 @@
+package flv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
+)
+
+// TestStopUnblocksStalledRead 上游发送 FLV 头后不再发送数据时，Stop 应让阻塞的读取返回
+func TestStopUnblocksStalledRead(t *testing.T) {
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0x46, 0x4c, 0x56, 0x01, 0x05, 0, 0, 0, 9, 0, 0, 0, 0})
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer server.Close()
+
+	p, err := new(builder).Build(map[string]string{}, livelogger.New(1024, nil))
+	require.NoError(t, err)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ParseLiveStream(context.Background(), &live.StreamUrlInfo{Url: u}, nil, filepath.Join(t.TempDir(), "out.flv"))
+	}()
+
+	// 等待解析器读完 FLV 头并阻塞在下一个 tag 上
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("ParseLiveStream returned before Stop: %v", err)
+	default:
+	}
+
+	require.NoError(t, p.Stop())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ParseLiveStream did not return after Stop")
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled")
+	}
+}
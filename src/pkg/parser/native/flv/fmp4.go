@@ -0,0 +1,105 @@
+package flv
+
+import (
+	"errors"
+	"io"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/pkg/fmp4mux"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+// ErrCodecChanged 编码参数在录制中途变化，当前 fMP4 文件无法继续写入
+// 解析器返回该错误后由录制器重新连接并写入新文件
+var ErrCodecChanged = errors.New("编码参数变化，需要切换到新文件")
+
+// fmp4Writer 将解析器输出的 FLV 字节流实时封装为分片 MP4 写入文件
+//
+// 文件开头是 ftyp + 带 mvex 的 moov，之后每个 GOP 一个 moof + mdat，
+// 每个分片完整写入后才写下一个，进程崩溃时最多丢失最后一个未写完的 GOP。
+type fmp4Writer struct {
+	out    io.Writer
+	logger logrus.FieldLogger
+
+	splitter  streamprobe.FLVTagSplitter
+	muxer     *fmp4mux.Muxer
+	wroteInit bool
+	err       error
+	tagErr    error
+}
+
+func newFMP4Writer(out io.Writer, audioOnly bool, logger logrus.FieldLogger) *fmp4Writer {
+	w := &fmp4Writer{out: out, logger: logger}
+	w.muxer = fmp4mux.New(fmp4mux.Options{
+		AudioOnly:  audioOnly,
+		OnInit:     w.onInit,
+		OnFragment: w.onFragment,
+	})
+	w.splitter.OnTag = w.onTag
+	return w
+}
+
+// Write 实现 io.Writer，写入文件失败或编码参数变化后返回错误使解析器停止
+func (w *fmp4Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.splitter.Write(p)
+	if w.err == nil && w.splitter.Invalid() {
+		w.err = ErrNotFlvStream
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+// Close 输出最后一个 GOP
+func (w *fmp4Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.muxer.Flush()
+}
+
+func (w *fmp4Writer) onTag(tag []byte) {
+	if w.err != nil {
+		return
+	}
+	err := w.muxer.WriteTag(streamprobe.TagType(tag), streamprobe.TagTimestamp(tag), streamprobe.TagData(tag))
+	if err == nil {
+		return
+	}
+	if errors.Is(err, fmp4mux.ErrUnsupportedCodec) || errors.Is(err, fmp4mux.ErrInvalidConfig) {
+		// 无法封装的音频或视频被忽略，每种错误只记录一次
+		if w.tagErr == nil || w.tagErr.Error() != err.Error() {
+			w.logger.WithError(err).Warn("fMP4 输出忽略无法封装的音视频数据")
+		}
+		w.tagErr = err
+		return
+	}
+	w.err = err
+}
+
+func (w *fmp4Writer) onInit(init []byte) error {
+	if w.wroteInit {
+		// 已输出的分片之后写入新的初始化段会得到无效文件
+		w.err = ErrCodecChanged
+		return w.err
+	}
+	w.wroteInit = true
+	return w.write(init)
+}
+
+func (w *fmp4Writer) onFragment(f *fmp4mux.Fragment) error {
+	return w.write(f.Data)
+}
+
+func (w *fmp4Writer) write(b []byte) error {
+	if _, err := w.out.Write(b); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
+	"github.com/bililive-go/bililive-go/src/pkg/reader"
+)
+
+var (
+	testSPS = []byte{
+		0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+		0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+		0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9,
+		0x20,
+	}
+	testPPS = []byte{0x08}
+)
+
+// flvStream 构造测试用的 FLV 字节流
+type flvStream struct {
+	bytes.Buffer
+}
+
+func newFLVStream() *flvStream {
+	s := &flvStream{}
+	s.Write([]byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0})
+	return s
+}
+
+func (s *flvStream) tag(tagType uint8, timestamp uint32, data []byte) {
+	head := []byte{tagType, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data)),
+		byte(timestamp >> 16), byte(timestamp >> 8), byte(timestamp), byte(timestamp >> 24), 0, 0, 0}
+	s.Write(head)
+	s.Write(data)
+	binary.Write(s, binary.BigEndian, uint32(len(head)+len(data)))
+}
+
+func avcSeqHeader() []byte {
+	data := []byte{0x17, 0, 0, 0, 0, 1, testSPS[1], testSPS[2], testSPS[3], 0xff, 0xe1}
+	data = append(data, byte(len(testSPS)>>8), byte(len(testSPS)))
+	data = append(data, testSPS...)
+	data = append(data, 1, byte(len(testPPS)>>8), byte(len(testPPS)))
+	return append(data, testPPS...)
+}
+
+func avcFrame(key bool) []byte {
+	head := byte(0x27)
+	if key {
+		head = 0x17
+	}
+	return []byte{head, 1, 0, 0, 0, 0, 0, 0, 1, 0x65}
+}
+
+func parseToFMP4(t *testing.T, stream *flvStream) ([]byte, error) {
+	t.Helper()
+	var out bytes.Buffer
+	logger := livelogger.New(1024, nil)
+	w := newFMP4Writer(&out, false, logger)
+	p := &Parser{
+		logger:    logger,
+		i:         reader.New(stream),
+		o:         w,
+		stopCh:    make(chan struct{}),
+		closeOnce: new(sync.Once),
+	}
+	err := p.doParse(context.Background())
+	if err == io.EOF {
+		err = nil
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return out.Bytes(), err
+}
+
+// topLevelBoxes 返回 MP4 顶层 box 的类型
+func topLevelBoxes(t *testing.T, data []byte) []string {
+	t.Helper()
+	var boxes []string
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 8)
+		size := int(binary.BigEndian.Uint32(data))
+		require.LessOrEqual(t, size, len(data))
+		boxes = append(boxes, string(data[4:8]))
+		data = data[size:]
+	}
+	return boxes
+}
+
+func TestFMP4OutputOneFragmentPerGOP(t *testing.T) {
+	stream := newFLVStream()
+	stream.tag(scriptTag, 0, []byte{2, 0, 0})
+	stream.tag(videoTag, 0, avcSeqHeader())
+	stream.tag(audioTag, 0, []byte{0xaf, 0, 0x12, 0x10})
+	for i := 0; i < 6; i++ {
+		ts := uint32(i * 40)
+		stream.tag(videoTag, ts, avcFrame(i%3 == 0))
+		stream.tag(audioTag, ts, []byte{0xaf, 1, byte(i)})
+	}
+
+	data, err := parseToFMP4(t, stream)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat"}, topLevelBoxes(t, data))
+	assert.True(t, bytes.Contains(data, []byte("mvex")))
+
+	var init fmp4.Init
+	require.NoError(t, init.Unmarshal(bytes.NewReader(data)))
+	assert.Len(t, init.Tracks, 2)
+}
+
+func TestFMP4OutputStopsOnCodecChange(t *testing.T) {
+	stream := newFLVStream()
+	stream.tag(videoTag, 0, avcSeqHeader())
+	stream.tag(audioTag, 0, []byte{0xaf, 0, 0x12, 0x10})
+	stream.tag(videoTag, 0, avcFrame(true))
+	stream.tag(videoTag, 40, avcFrame(false))
+	// 音频采样率从 44100Hz 变为 48000Hz
+	stream.tag(audioTag, 60, []byte{0xaf, 0, 0x11, 0x90})
+	stream.tag(videoTag, 80, avcFrame(true))
+	stream.tag(videoTag, 120, avcFrame(false))
+
+	data, err := parseToFMP4(t, stream)
+	assert.ErrorIs(t, err, ErrCodecChanged)
+	// 编码参数变化前的 GOP 已完整写入，文件仍然可以播放
+	assert.Equal(t, []string{"ftyp", "moov", "moof", "mdat"}, topLevelBoxes(t, data))
+}
@@ -0,0 +1,297 @@
+// Package rtmprelay 将正在录制的 FLV 直播流实时转推到自定义的 RTMP 服务器（如本地 nginx-rtmp）
+//
+// 每个转推目标由一个受监管的 ffmpeg 进程负责：数据来自录制器探测代理的 FLV 分发器
+// （与录制使用同一份上游数据，不会向平台额外拉流），通过 stdin 写入 ffmpeg 后以 -c copy 推送。
+// ffmpeg 退出后按退避间隔自动重启，直到录制结束。
+package rtmprelay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+// State 转推状态
+type State string
+
+const (
+	StateStarting State = "starting" // 正在启动 ffmpeg
+	StateRunning  State = "running"  // 正在推流
+	StateRetrying State = "retrying" // 上次推流中断，等待重试
+	StateStopped  State = "stopped"  // 录制结束，已停止
+)
+
+const (
+	// minRetryDelay 首次重试间隔
+	minRetryDelay = 2 * time.Second
+	// maxRetryDelay 重试间隔上限
+	maxRetryDelay = time.Minute
+	// stableDuration 推流持续超过该时长视为恢复正常，重试间隔从头计算
+	stableDuration = time.Minute
+	// stderrTailSize 保留 ffmpeg 错误输出的最大字节数
+	stderrTailSize = 2048
+)
+
+// Status 单个转推目标的状态
+type Status struct {
+	Target      string    `json:"target"` // 已隐藏推流密钥
+	State       State     `json:"state"`
+	Restarts    int       `json:"restarts"`
+	BytesSent   int64     `json:"bytes_sent"`
+	LastError   string    `json:"last_error,omitempty"`
+	StartedAt   time.Time `json:"started_at,omitempty"`
+	NextRetryAt time.Time `json:"next_retry_at,omitempty"`
+}
+
+// Relay 单个 RTMP 转推目标
+type Relay struct {
+	hub        *streamprobe.FanoutHub
+	ffmpegPath string
+	target     string
+	masked     string
+	logger     logrus.FieldLogger
+
+	mu     sync.Mutex
+	status Status
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// retryDelay 计算第 attempt 次中断后的重试间隔，测试时可替换
+	retryDelay func(attempt int) time.Duration
+	// newCommand 创建推流进程，测试时可替换
+	newCommand func(ctx context.Context) *exec.Cmd
+}
+
+// New 创建转推任务，调用 Start 后开始推流
+func New(hub *streamprobe.FanoutHub, ffmpegPath, target string, logger logrus.FieldLogger) *Relay {
+	r := &Relay{
+		hub:        hub,
+		ffmpegPath: ffmpegPath,
+		target:     target,
+		masked:     MaskTarget(target),
+		logger:     logger,
+		retryDelay: backoff,
+	}
+	r.status = Status{Target: r.masked, State: StateStopped}
+	r.newCommand = r.ffmpegCommand
+	return r
+}
+
+// backoff 指数退避：2s、4s、8s……最长 1 分钟
+func backoff(attempt int) time.Duration {
+	d := minRetryDelay
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// ffmpegCommand 从 stdin 读取 FLV 并原样推送到目标地址
+func (r *Relay) ffmpegCommand(ctx context.Context) *exec.Cmd {
+	return exec.CommandContext(ctx, r.ffmpegPath,
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "flv",
+		"-i", "pipe:0",
+		"-c", "copy",
+		"-f", "flv",
+		r.target,
+	)
+}
+
+// Start 在后台启动推流并持续监管，重复调用无效
+func (r *Relay) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+// Stop 停止推流并等待 ffmpeg 退出
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Status 返回当前状态的副本
+func (r *Relay) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *Relay) run(ctx context.Context) {
+	defer close(r.done)
+	defer r.updateStatus(func(s *Status) {
+		s.State = StateStopped
+		s.NextRetryAt = time.Time{}
+	})
+
+	attempt := 0
+	for {
+		started := time.Now()
+		err := r.runOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= stableDuration {
+			attempt = 0
+		}
+		attempt++
+		delay := r.retryDelay(attempt)
+		if err == nil {
+			err = errors.New("ffmpeg 意外退出")
+		}
+		r.logger.WithError(err).Warnf("RTMP 转推到 %s 中断，%s 后重试", r.masked, delay)
+		r.updateStatus(func(s *Status) {
+			s.State = StateRetrying
+			s.Restarts++
+			s.LastError = err.Error()
+			s.NextRetryAt = time.Now().Add(delay)
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runOnce 订阅分发器并运行一次 ffmpeg，直到 ffmpeg 退出或订阅被断开
+func (r *Relay) runOnce(ctx context.Context) error {
+	r.updateStatus(func(s *Status) {
+		s.State = StateStarting
+		s.NextRetryAt = time.Time{}
+	})
+
+	sub, err := r.hub.Subscribe()
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	cmdCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := r.newCommand(cmdCtx)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &tailBuffer{max: stderrTailSize}
+	cmd.Stderr = stderr
+	// 停止时 ffmpeg 被杀死后，不再等待可能仍占用输出管道的子进程
+	cmd.WaitDelay = 5 * time.Second
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动 ffmpeg 失败: %w", err)
+	}
+	r.logger.Infof("开始 RTMP 转推到 %s", r.masked)
+	r.updateStatus(func(s *Status) {
+		s.State = StateRunning
+		s.StartedAt = time.Now()
+	})
+
+	// 写入失败说明 ffmpeg 已退出；订阅被断开（客户端过慢或录制结束）时关闭 stdin 让 ffmpeg 退出
+	feedErr := make(chan error, 1)
+	go func() {
+		defer stdin.Close()
+		go func() {
+			// 停止转推时关闭订阅，使下面的循环退出
+			<-cmdCtx.Done()
+			sub.Close()
+		}()
+		for data := range sub.C() {
+			if _, err := stdin.Write(data); err != nil {
+				feedErr <- err
+				return
+			}
+			r.updateStatus(func(s *Status) { s.BytesSent += int64(len(data)) })
+		}
+		feedErr <- io.ErrUnexpectedEOF
+	}()
+
+	waitErr := cmd.Wait()
+	sub.Close()
+	feed := <-feedErr
+	if ctx.Err() != nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%v: %s", waitErr, msg)
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	if errors.Is(feed, io.ErrUnexpectedEOF) {
+		return errors.New("转推跟不上直播流，已断开")
+	}
+	return nil
+}
+
+func (r *Relay) updateStatus(fn func(s *Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.status)
+}
+
+// MaskTarget 隐藏推流地址中的推流密钥（最后一级路径和查询参数），用于日志和状态展示
+func MaskTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	masked := u.Scheme + "://" + u.Host
+	path := strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		masked += "/" + path[:i] + "/***"
+	} else if path != "" {
+		masked += "/***"
+	}
+	return masked
+}
+
+// tailBuffer 只保留最后 max 字节的输出
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
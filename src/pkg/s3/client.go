@@ -0,0 +1,404 @@
+// Package s3 实现上传录像所需的 S3 兼容对象存储客户端
+// 只覆盖简单上传、分片上传和对象校验用到的接口，请求使用 AWS Signature V4 签名，适用于 AWS S3、MinIO 等兼容服务
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash 空请求体的 SHA256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Config 连接配置
+type Config struct {
+	Endpoint  string // 例如 https://s3.amazonaws.com 或 http://127.0.0.1:9000，未指定协议时使用 https
+	Region    string // 为空时使用 us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle 使用 endpoint/bucket/key 形式的地址，MinIO 等自建服务通常需要开启；
+	// 关闭时使用 bucket.endpoint/key 形式
+	PathStyle bool
+}
+
+// Client S3 兼容存储客户端
+type Client struct {
+	cfg        Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewClient 创建客户端
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("s3: endpoint is required")
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3: bucket is required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3: access key and secret key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	raw := cfg.Endpoint
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	endpoint, err := url.Parse(raw)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+	return &Client{
+		cfg:      cfg,
+		endpoint: endpoint,
+		httpClient: &http.Client{
+			Timeout: 0, // 上传可能需要很长时间，由上下文控制
+		},
+		now: time.Now,
+	}, nil
+}
+
+// Error S3 返回的错误
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// ObjectInfo HEAD 对象得到的信息
+type ObjectInfo struct {
+	Size         int64
+	ETag         string // 去掉引号
+	StorageClass string
+}
+
+// PutObject 简单上传，返回对象的 ETag
+func (c *Client) PutObject(ctx context.Context, key string, body []byte, headers http.Header) (string, error) {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, headers, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return trimETag(resp.Header.Get("ETag")), nil
+}
+
+// HeadObject 获取对象信息
+func (c *Client) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return &ObjectInfo{
+		Size:         resp.ContentLength,
+		ETag:         trimETag(resp.Header.Get("ETag")),
+		StorageClass: resp.Header.Get("X-Amz-Storage-Class"),
+	}, nil
+}
+
+// CreateMultipartUpload 开始分片上传，返回 UploadId
+func (c *Client) CreateMultipartUpload(ctx context.Context, key string, headers http.Header) (string, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := c.doXML(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, headers, nil, &result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("s3: empty upload id")
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart 上传一个分片，返回分片的 ETag
+func (c *Client) UploadPart(ctx context.Context, key, uploadID string, partNumber int, body []byte, headers http.Header) (string, error) {
+	query := url.Values{
+		"partNumber": {fmt.Sprint(partNumber)},
+		"uploadId":   {uploadID},
+	}
+	resp, err := c.do(ctx, http.MethodPut, key, query, headers, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return trimETag(resp.Header.Get("ETag")), nil
+}
+
+// Part 已上传的分片
+type Part struct {
+	PartNumber int    `xml:"PartNumber" json:"part_number"`
+	ETag       string `xml:"ETag" json:"etag"`
+	Size       int64  `xml:"Size" json:"size"`
+	MD5        string `xml:"-" json:"md5,omitempty"` // 本地计算的分片 MD5，仅用于续传时核对
+}
+
+// ListParts 列出分片上传中已经上传的分片
+func (c *Client) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+	var parts []Part
+	marker := ""
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker != "" {
+			query.Set("part-number-marker", marker)
+		}
+		var result struct {
+			Parts                []Part `xml:"Part"`
+			IsTruncated          bool   `xml:"IsTruncated"`
+			NextPartNumberMarker string `xml:"NextPartNumberMarker"`
+		}
+		if err := c.doXML(ctx, http.MethodGet, key, query, nil, nil, &result); err != nil {
+			return nil, err
+		}
+		for _, p := range result.Parts {
+			p.ETag = trimETag(p.ETag)
+			parts = append(parts, p)
+		}
+		if !result.IsTruncated || result.NextPartNumberMarker == "" {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// CompleteMultipartUpload 合并分片，返回对象的 ETag
+func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) (string, error) {
+	type completePart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	request := struct {
+		XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+		Parts   []completePart `xml:"Part"`
+	}{}
+	for _, p := range parts {
+		request.Parts = append(request.Parts, completePart{PartNumber: p.PartNumber, ETag: `"` + p.ETag + `"`})
+	}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := c.doXML(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, body, &result); err != nil {
+		return "", err
+	}
+	// 合并失败时服务端可能返回 200 和错误文档
+	if result.XMLName.Local == "Error" {
+		return "", &Error{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message}
+	}
+	return trimETag(result.ETag), nil
+}
+
+// AbortMultipartUpload 放弃分片上传，释放已上传的分片
+func (c *Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// doXML 发送请求并解析 XML 响应
+func (c *Client) doXML(ctx context.Context, method, key string, query url.Values, headers http.Header, body []byte, out any) error {
+	resp, err := c.do(ctx, method, key, query, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("s3: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do 发送签名请求，非 2xx 响应转换为 *Error
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, headers http.Header, body []byte) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, key, query, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		s3Err := &Error{StatusCode: resp.StatusCode}
+		if method != http.MethodHead {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+			xml.Unmarshal(data, s3Err)
+		}
+		return nil, s3Err
+	}
+	return resp, nil
+}
+
+// newRequest 构建并签名请求
+func (c *Client) newRequest(ctx context.Context, method, key string, query url.Values, headers http.Header, body []byte) (*http.Request, error) {
+	host := c.endpoint.Host
+	path := strings.TrimSuffix(c.endpoint.Path, "/")
+	if c.cfg.PathStyle {
+		path += "/" + uriEncode(c.cfg.Bucket, false)
+	} else {
+		host = c.cfg.Bucket + "." + host
+	}
+	path += "/" + uriEncode(strings.TrimPrefix(key, "/"), true)
+
+	rawURL := c.endpoint.Scheme + "://" + host + path
+	if q := canonicalQuery(query); q != "" {
+		rawURL += "?" + q
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	for k, values := range headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	signRequest(req, path, query, payloadHash, c.cfg.AccessKey, c.cfg.SecretKey, c.cfg.Region, c.now().UTC())
+	return req, nil
+}
+
+// signRequest 按 AWS Signature V4 签名请求
+// 签名覆盖 host、content-md5、content-type 以及所有 x-amz-* 请求头
+func signRequest(req *http.Request, canonicalURI string, query url.Values, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	signed := map[string]string{"host": host}
+	for k, values := range req.Header {
+		lower := strings.ToLower(k)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" || lower == "content-type" || lower == "range" {
+			signed[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery 按键排序并编码查询参数
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按 SigV4 规则编码，只保留非保留字符，keepSlash 时保留路径分隔符
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
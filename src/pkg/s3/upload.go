@@ -0,0 +1,299 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// MinPartSize S3 要求除最后一个分片外每个分片至少 5 MiB
+	MinPartSize int64 = 5 << 20
+	// DefaultPartSize 默认分片大小
+	DefaultPartSize int64 = 16 << 20
+	// maxParts 单个对象最多的分片数
+	maxParts = 10000
+)
+
+// UploadOptions 上传选项
+type UploadOptions struct {
+	StorageClass string // 为空时使用存储桶的默认存储类型
+	ContentType  string
+	PartSize     int64 // 不超过该大小的文件使用简单上传，为 0 时使用 DefaultPartSize
+	// StatePath 分片上传的进度文件，上传中断后再次上传同一文件时从已完成的分片继续；为空时不续传
+	StatePath  string
+	OnProgress func(uploaded, total int64)
+}
+
+// UploadResult 上传结果
+type UploadResult struct {
+	Key          string
+	Size         int64
+	ETag         string
+	Parts        int // 分片数，简单上传为 0
+	ResumedParts int // 沿用上次上传的分片数
+}
+
+// uploadState 持久化的分片上传进度
+type uploadState struct {
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key"`
+	UploadID string `json:"upload_id"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"` // 纳秒，文件变化后不再续传
+	PartSize int64  `json:"part_size"`
+	Parts    []Part `json:"parts"`
+}
+
+// UploadFile 上传本地文件并校验结果
+// 每个请求都带 Content-MD5，由服务端校验传输内容；完成后核对对象大小和 ETag
+func (c *Client) UploadFile(ctx context.Context, localPath, key string, opts UploadOptions) (*UploadResult, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	// 分片数不能超过上限
+	if minSize := (stat.Size() + maxParts - 1) / maxParts; partSize < minSize {
+		partSize = minSize
+	}
+
+	if stat.Size() <= partSize {
+		return c.putFile(ctx, file, stat.Size(), key, opts)
+	}
+	return c.multipartUpload(ctx, file, stat, key, partSize, opts)
+}
+
+// putFile 简单上传
+func (c *Client) putFile(ctx context.Context, file *os.File, size int64, key string, opts UploadOptions) (*UploadResult, error) {
+	body := make([]byte, size)
+	if _, err := io.ReadFull(file, body); err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(body)
+	etag, err := c.PutObject(ctx, key, body, c.objectHeaders(opts, sum[:]))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkETag(etag, hex.EncodeToString(sum[:])); err != nil {
+		return nil, err
+	}
+	if opts.OnProgress != nil {
+		opts.OnProgress(size, size)
+	}
+	if err := c.VerifyObject(ctx, key, size, etag); err != nil {
+		return nil, err
+	}
+	return &UploadResult{Key: key, Size: size, ETag: etag}, nil
+}
+
+// multipartUpload 分片上传，支持从进度文件续传
+func (c *Client) multipartUpload(ctx context.Context, file *os.File, stat os.FileInfo, key string, partSize int64, opts UploadOptions) (*UploadResult, error) {
+	size := stat.Size()
+	partCount := int((size + partSize - 1) / partSize)
+	state := c.resumeState(ctx, opts.StatePath, key, stat, partSize)
+	resumed := len(state.Parts)
+	if state.UploadID == "" {
+		uploadID, err := c.CreateMultipartUpload(ctx, key, c.objectHeaders(opts, nil))
+		if err != nil {
+			return nil, err
+		}
+		state.UploadID = uploadID
+		saveState(opts.StatePath, state)
+	}
+
+	done := make(map[int]Part, len(state.Parts))
+	var uploaded int64
+	for _, p := range state.Parts {
+		done[p.PartNumber] = p
+		uploaded += p.Size
+	}
+	if opts.OnProgress != nil {
+		opts.OnProgress(uploaded, size)
+	}
+
+	buf := make([]byte, partSize)
+	sums := make([][]byte, partCount+1)
+	for n := 1; n <= partCount; n++ {
+		offset := int64(n-1) * partSize
+		length := min(partSize, size-offset)
+		body := buf[:length]
+		if _, err := file.ReadAt(body, offset); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		sum := md5.Sum(body)
+		sums[n] = sum[:]
+		md5Hex := hex.EncodeToString(sum[:])
+		if p, ok := done[n]; ok && p.Size == length && p.MD5 == md5Hex {
+			continue
+		}
+		if _, ok := done[n]; ok {
+			// 本地内容与记录不一致，重新上传该分片
+			uploaded -= done[n].Size
+			delete(done, n)
+		}
+
+		headers := http.Header{}
+		headers.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		etag, err := c.UploadPart(ctx, key, state.UploadID, n, body, headers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d: %w", n, err)
+		}
+		if err := checkETag(etag, md5Hex); err != nil {
+			return nil, fmt.Errorf("part %d: %w", n, err)
+		}
+		done[n] = Part{PartNumber: n, ETag: etag, Size: length, MD5: md5Hex}
+		state.Parts = append(state.Parts, done[n])
+		saveState(opts.StatePath, state)
+
+		uploaded += length
+		if opts.OnProgress != nil {
+			opts.OnProgress(uploaded, size)
+		}
+	}
+
+	parts := make([]Part, 0, partCount)
+	for n := 1; n <= partCount; n++ {
+		parts = append(parts, done[n])
+	}
+	etag, err := c.CompleteMultipartUpload(ctx, key, state.UploadID, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分片上传的 ETag 是各分片 MD5 拼接后再取 MD5，加上分片数
+	var joined []byte
+	for n := 1; n <= partCount; n++ {
+		joined = append(joined, sums[n]...)
+	}
+	joinedSum := md5.Sum(joined)
+	if err := checkETag(etag, fmt.Sprintf("%s-%d", hex.EncodeToString(joinedSum[:]), partCount)); err != nil {
+		return nil, err
+	}
+	if err := c.VerifyObject(ctx, key, size, etag); err != nil {
+		return nil, err
+	}
+	if opts.StatePath != "" {
+		os.Remove(opts.StatePath)
+	}
+	return &UploadResult{Key: key, Size: size, ETag: etag, Parts: partCount, ResumedParts: resumed}, nil
+}
+
+// resumeState 读取进度文件，并以服务端实际存在的分片为准
+// 文件或目标发生变化、上传已失效时返回新的空状态
+func (c *Client) resumeState(ctx context.Context, statePath, key string, stat os.FileInfo, partSize int64) *uploadState {
+	fresh := &uploadState{
+		Bucket:   c.cfg.Bucket,
+		Key:      key,
+		Size:     stat.Size(),
+		ModTime:  stat.ModTime().UnixNano(),
+		PartSize: partSize,
+	}
+	if statePath == "" {
+		return fresh
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return fresh
+	}
+	var saved uploadState
+	if json.Unmarshal(data, &saved) != nil || saved.UploadID == "" {
+		return fresh
+	}
+	if saved.Bucket != fresh.Bucket || saved.Key != fresh.Key || saved.Size != fresh.Size ||
+		saved.ModTime != fresh.ModTime || saved.PartSize != fresh.PartSize {
+		// 上次的分片已无法沿用，尽量释放
+		if saved.Bucket == fresh.Bucket {
+			c.AbortMultipartUpload(ctx, saved.Key, saved.UploadID)
+		}
+		return fresh
+	}
+
+	remote, err := c.ListParts(ctx, key, saved.UploadID)
+	if err != nil {
+		return fresh
+	}
+	server := make(map[int]Part, len(remote))
+	for _, p := range remote {
+		server[p.PartNumber] = p
+	}
+	fresh.UploadID = saved.UploadID
+	for _, p := range saved.Parts {
+		if sp, ok := server[p.PartNumber]; ok && sp.ETag == p.ETag && sp.Size == p.Size {
+			fresh.Parts = append(fresh.Parts, p)
+		}
+	}
+	return fresh
+}
+
+func saveState(statePath string, state *uploadState) {
+	if statePath == "" {
+		return
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	os.WriteFile(statePath, data, 0644)
+}
+
+// VerifyObject 核对远端对象的大小和 ETag
+func (c *Client) VerifyObject(ctx context.Context, key string, size int64, etag string) error {
+	info, err := c.HeadObject(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to verify object: %w", err)
+	}
+	if info.Size != size {
+		return fmt.Errorf("size mismatch after upload: local %d, remote %d", size, info.Size)
+	}
+	if etag != "" && info.ETag != "" && !strings.EqualFold(info.ETag, etag) {
+		return fmt.Errorf("etag mismatch after upload: expected %s, remote %s", etag, info.ETag)
+	}
+	return nil
+}
+
+// objectHeaders 创建对象时的请求头
+func (c *Client) objectHeaders(opts UploadOptions, md5Sum []byte) http.Header {
+	headers := http.Header{}
+	if opts.StorageClass != "" {
+		headers.Set("X-Amz-Storage-Class", opts.StorageClass)
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	headers.Set("Content-Type", contentType)
+	if md5Sum != nil {
+		headers.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum))
+	}
+	return headers
+}
+
+// checkETag 服务端返回 MD5 形式的 ETag 时与本地计算的值核对
+// 使用 SSE-KMS 等加密方式时 ETag 不是内容的 MD5，只要格式不同就不比较
+func checkETag(etag, expected string) error {
+	if etag == "" || len(etag) != len(expected) || strings.Count(etag, "-") != strings.Count(expected, "-") {
+		return nil
+	}
+	if !strings.EqualFold(etag, expected) {
+		return fmt.Errorf("checksum mismatch: expected etag %s, got %s", expected, etag)
+	}
+	return nil
+}
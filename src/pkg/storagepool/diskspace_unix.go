@@ -1,11 +1,11 @@
 //go:build !windows
 
-package notify
+package storagepool
 
 import "syscall"
 
-// getDiskFreeSpace 获取指定路径所在磁盘的剩余可用空间（字节）
-func getDiskFreeSpace(path string) (uint64, error) {
+// DiskFreeSpace 获取指定路径所在磁盘的剩余可用空间（字节）
+func DiskFreeSpace(path string) (uint64, error) {
 	var stat syscall.Statfs_t
 	if err := syscall.Statfs(path, &stat); err != nil {
 		return 0, err
@@ -0,0 +1,97 @@
+// Package storagepool 实现多磁盘存储池的根目录选择与路径归属判断
+package storagepool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+var (
+	// ErrNoAvailableRoot 存储池中没有任何可用的根目录
+	ErrNoAvailableRoot = errors.New("存储池中没有可用的根目录")
+	// ErrPinnedRootUnavailable pinned 策略指定的根目录不存在或不可用
+	ErrPinnedRootUnavailable = errors.New("固定的存储根目录不可用")
+)
+
+// roundRobinCounter 全局轮询计数器，所有直播间共享
+var roundRobinCounter atomic.Uint64
+
+// freeSpaceFunc 获取剩余空间的函数，测试时可替换
+var freeSpaceFunc = DiskFreeSpace
+
+// Select 根据放置策略从存储池中选择本次录制使用的根目录
+// 不存在或不是目录的根目录会被跳过。pinned 策略的根目录不可用时返回 ErrPinnedRootUnavailable，
+// 由调用方决定是否回退到其他策略。
+func Select(pool configs.StoragePool, placement configs.StoragePlacement) (configs.StorageRoot, error) {
+	if placement.Policy == configs.PlacementPinned {
+		root, ok := pool.GetRoot(placement.Root)
+		if !ok || !isAvailable(root.Path) {
+			return configs.StorageRoot{}, fmt.Errorf("%w: %s", ErrPinnedRootUnavailable, placement.Root)
+		}
+		return root, nil
+	}
+
+	available := make([]configs.StorageRoot, 0, len(pool.Roots))
+	for _, root := range pool.Roots {
+		if isAvailable(root.Path) {
+			available = append(available, root)
+		}
+	}
+	if len(available) == 0 {
+		return configs.StorageRoot{}, ErrNoAvailableRoot
+	}
+
+	if placement.Policy == configs.PlacementRoundRobin {
+		n := roundRobinCounter.Add(1) - 1
+		return available[n%uint64(len(available))], nil
+	}
+
+	// 默认 most_free：获取剩余空间失败的根目录视为 0
+	best := available[0]
+	var bestFree uint64
+	for _, root := range available {
+		free, err := freeSpaceFunc(root.Path)
+		if err != nil {
+			continue
+		}
+		if free > bestFree {
+			best, bestFree = root, free
+		}
+	}
+	return best, nil
+}
+
+// isAvailable 判断根目录当前是否可写入（存在且为目录）
+func isAvailable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// FindRoot 返回 path 所在的根目录以及相对于该根目录的路径
+// 多个根目录互相嵌套时取最长匹配；不属于任何根目录时 ok 为 false
+func FindRoot(roots []string, path string) (root, rel string, ok bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", false
+	}
+	for _, r := range roots {
+		absRoot, err := filepath.Abs(r)
+		if err != nil {
+			continue
+		}
+		relPath, err := filepath.Rel(absRoot, absPath)
+		if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !ok || len(absRoot) > len(root) {
+			root, rel, ok = absRoot, relPath, true
+		}
+	}
+	return root, rel, ok
+}
@@ -0,0 +1,83 @@
+package storagepool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+func newTestPool(t *testing.T) configs.StoragePool {
+	t.Helper()
+	return configs.StoragePool{
+		Roots: []configs.StorageRoot{
+			{Name: "a", Path: t.TempDir()},
+			{Name: "b", Path: t.TempDir()},
+			{Name: "offline", Path: filepath.Join(t.TempDir(), "not-mounted")},
+		},
+	}
+}
+
+func TestSelectMostFree(t *testing.T) {
+	pool := newTestPool(t)
+	free := map[string]uint64{pool.Roots[0].Path: 10, pool.Roots[1].Path: 20}
+	freeSpaceFunc = func(path string) (uint64, error) { return free[path], nil }
+	t.Cleanup(func() { freeSpaceFunc = DiskFreeSpace })
+
+	root, err := Select(pool, configs.StoragePlacement{})
+	require.NoError(t, err)
+	assert.Equal(t, "b", root.Name)
+
+	free[pool.Roots[0].Path] = 30
+	root, err = Select(pool, configs.StoragePlacement{Policy: configs.PlacementMostFree})
+	require.NoError(t, err)
+	assert.Equal(t, "a", root.Name)
+}
+
+func TestSelectRoundRobinSkipsUnavailable(t *testing.T) {
+	pool := newTestPool(t)
+	placement := configs.StoragePlacement{Policy: configs.PlacementRoundRobin}
+
+	first, err := Select(pool, placement)
+	require.NoError(t, err)
+	second, err := Select(pool, placement)
+	require.NoError(t, err)
+	third, err := Select(pool, placement)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.Name, second.Name)
+	assert.Equal(t, first.Name, third.Name)
+	assert.NotEqual(t, "offline", first.Name)
+	assert.NotEqual(t, "offline", second.Name)
+}
+
+func TestSelectPinned(t *testing.T) {
+	pool := newTestPool(t)
+
+	root, err := Select(pool, configs.StoragePlacement{Policy: configs.PlacementPinned, Root: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", root.Name)
+
+	_, err = Select(pool, configs.StoragePlacement{Policy: configs.PlacementPinned, Root: "offline"})
+	assert.True(t, errors.Is(err, ErrPinnedRootUnavailable))
+
+	_, err = Select(configs.StoragePool{Roots: pool.Roots[2:]}, configs.StoragePlacement{})
+	assert.True(t, errors.Is(err, ErrNoAvailableRoot))
+}
+
+func TestFindRoot(t *testing.T) {
+	base := t.TempDir()
+	roots := []string{base, filepath.Join(base, "disk2")}
+
+	root, rel, ok := FindRoot(roots, filepath.Join(base, "disk2", "虎牙", "a.flv"))
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(base, "disk2"), root)
+	assert.Equal(t, filepath.Join("虎牙", "a.flv"), rel)
+
+	_, _, ok = FindRoot(roots, filepath.Join(filepath.Dir(base), "other.flv"))
+	assert.False(t, ok)
+}
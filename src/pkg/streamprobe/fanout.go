@@ -0,0 +1,251 @@
+package streamprobe
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// maxCachedGOPSize 缓存 GOP 的最大字节数，关键帧间隔过长时放弃缓存，等待下一个关键帧
+	maxCachedGOPSize = 32 * 1024 * 1024
+	// fanoutSubscriberBuffer 每个观看者的发送队列长度（按 tag 计），队列满说明客户端过慢，直接断开
+	fanoutSubscriberBuffer = 1024
+)
+
+// ErrFanoutClosed 表示分发器已关闭
+var ErrFanoutClosed = errors.New("FLV 分发器已关闭")
+
+// FanoutHub 将录制器接收到的 FLV 字节流按 tag 分发给多个 HTTP-FLV 观看者
+//
+// 作为 Config.Tee 挂在 StreamProbe 上，按 tag 边界切分字节流，并缓存
+// FLV 头、onMetaData、音视频序列头以及最近一个 GOP，
+// 新加入的观看者会先收到这些缓存数据，从关键帧开始立即播放。
+// 慢速观看者的队列满时会被断开，不会阻塞录制。
+type FanoutHub struct {
+	mu sync.Mutex
+
+	splitter FLVTagSplitter
+
+	// 起播缓存
+	header   []byte // FLV 头 + PreviousTagSize0
+	metadata []byte
+	videoSeq []byte
+	audioSeq []byte
+	gop      [][]byte
+	gopSize  int
+
+	subscribers map[*FanoutSubscriber]struct{}
+	closed      bool
+}
+
+// FanoutSubscriber 一个 HTTP-FLV 观看者
+type FanoutSubscriber struct {
+	hub       *FanoutHub
+	ch        chan []byte
+	once      sync.Once
+	gotHeader bool // 是否已收到 FLV 头，由 hub.mu 保护
+}
+
+// NewFanoutHub 创建 FLV 分发器
+func NewFanoutHub() *FanoutHub {
+	h := &FanoutHub{
+		subscribers: make(map[*FanoutSubscriber]struct{}),
+	}
+	h.splitter.OnHeader = func(header []byte) {
+		h.header = append([]byte(nil), header...)
+		// 已连接的观看者已经收到过 FLV 头，只需补发给在流开始之前加入的观看者
+		for s := range h.subscribers {
+			if !s.gotHeader {
+				h.sendLocked(s, h.header)
+			}
+		}
+	}
+	h.splitter.OnTag = func(tag []byte) {
+		h.handleTagLocked(append([]byte(nil), tag...))
+	}
+	return h
+}
+
+// Reset 在重新连接上游（新的 FLV 流）之前调用，清空解析状态和起播缓存
+// 已连接的观看者保持连接，新流的序列头和后续 tag 会继续推送给它们
+func (h *FanoutHub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.splitter.Reset()
+	h.metadata = nil
+	h.videoSeq = nil
+	h.audioSeq = nil
+	h.resetGOPLocked()
+}
+
+// Write 实现 io.Writer，接收转发给下载器的原始字节
+// 始终返回 len(p)，解析失败只会停止分发，不影响录制
+func (h *FanoutHub) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return len(p), nil
+	}
+	return h.splitter.Write(p)
+}
+
+// handleTagLocked 更新起播缓存并分发一个完整的 tag（含末尾 PreviousTagSize）
+func (h *FanoutHub) handleTagLocked(tag []byte) {
+	data := TagData(tag)
+	switch TagType(tag) {
+	case flvTagScript:
+		h.metadata = tag
+	case flvTagVideo:
+		if len(data) == 0 {
+			break
+		}
+		isSeqHeader, isKeyframe := classifyVideoTag(data)
+		switch {
+		case isSeqHeader:
+			h.videoSeq = tag
+		case isKeyframe:
+			h.resetGOPLocked()
+			h.appendGOPLocked(tag)
+		case len(h.gop) > 0:
+			h.appendGOPLocked(tag)
+		}
+	case flvTagAudio:
+		if len(data) >= 2 && data[0]>>4 == audioCodecAAC && data[1] == 0 {
+			h.audioSeq = tag
+		} else if len(h.gop) > 0 {
+			h.appendGOPLocked(tag)
+		}
+	}
+	h.broadcastLocked(tag)
+}
+
+// classifyVideoTag 判断视频 tag 是否为序列头或关键帧，兼容 Enhanced FLV
+func classifyVideoTag(data []byte) (isSeqHeader, isKeyframe bool) {
+	frameType := (data[0] >> 4) & 0x07
+	isKeyframe = frameType == 1
+	if data[0]&0x80 != 0 {
+		// Enhanced FLV：低 4 位为 PacketType
+		return data[0]&0x0f == enhancedPacketTypeSequenceStart, isKeyframe
+	}
+	codecID := data[0] & 0x0f
+	if (codecID == codecAVC || codecID == codecHEVC) && len(data) >= 2 {
+		isSeqHeader = data[1] == avcSeqHeader
+	}
+	return isSeqHeader, isKeyframe
+}
+
+func (h *FanoutHub) appendGOPLocked(tag []byte) {
+	if h.gopSize+len(tag) > maxCachedGOPSize {
+		// GOP 过大，放弃缓存，新观看者等待下一个关键帧
+		h.resetGOPLocked()
+		return
+	}
+	h.gop = append(h.gop, tag)
+	h.gopSize += len(tag)
+}
+
+func (h *FanoutHub) resetGOPLocked() {
+	h.gop = nil
+	h.gopSize = 0
+}
+
+// broadcastLocked 将 tag 推送给所有已收到 FLV 头的观看者
+func (h *FanoutHub) broadcastLocked(tag []byte) {
+	for s := range h.subscribers {
+		if s.gotHeader {
+			h.sendLocked(s, tag)
+		}
+	}
+}
+
+// sendLocked 向观看者推送数据，队列已满的观看者会被断开
+func (h *FanoutHub) sendLocked(s *FanoutSubscriber, data []byte) {
+	select {
+	case s.ch <- data:
+		s.gotHeader = true
+	default:
+		h.removeLocked(s)
+	}
+}
+
+// Subscribe 添加一个观看者
+// 若已缓存到关键帧，返回的数据以 FLV 头、元数据、序列头和最近一个 GOP 开头；
+// 在流开始之前加入的观看者从 FLV 头开始接收
+func (h *FanoutHub) Subscribe() (*FanoutSubscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrFanoutClosed
+	}
+
+	s := &FanoutSubscriber{
+		hub: h,
+		ch:  make(chan []byte, fanoutSubscriberBuffer),
+	}
+	if h.header != nil {
+		s.ch <- h.startupDataLocked()
+		s.gotHeader = true
+	}
+	h.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+// startupDataLocked 拼接新观看者的起播数据
+func (h *FanoutHub) startupDataLocked() []byte {
+	size := len(h.header) + len(h.metadata) + len(h.videoSeq) + len(h.audioSeq) + h.gopSize
+	data := make([]byte, 0, size)
+	data = append(data, h.header...)
+	data = append(data, h.metadata...)
+	data = append(data, h.videoSeq...)
+	data = append(data, h.audioSeq...)
+	for _, tag := range h.gop {
+		data = append(data, tag...)
+	}
+	return data
+}
+
+// SubscriberCount 返回当前观看者数量
+func (h *FanoutHub) SubscriberCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// Close 关闭分发器并断开所有观看者
+func (h *FanoutHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for s := range h.subscribers {
+		h.removeLocked(s)
+	}
+	h.splitter.Reset()
+	h.header = nil
+	h.metadata = nil
+	h.videoSeq = nil
+	h.audioSeq = nil
+	h.resetGOPLocked()
+}
+
+func (h *FanoutHub) removeLocked(s *FanoutSubscriber) {
+	if _, ok := h.subscribers[s]; !ok {
+		return
+	}
+	delete(h.subscribers, s)
+	s.once.Do(func() { close(s.ch) })
+}
+
+// C 返回待发送数据的 channel，观看者被断开（过慢或分发器关闭）时 channel 被关闭
+func (s *FanoutSubscriber) C() <-chan []byte {
+	return s.ch
+}
+
+// Close 取消订阅
+func (s *FanoutSubscriber) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
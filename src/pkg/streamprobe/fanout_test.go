@@ -0,0 +1,82 @@
+package streamprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFLVHeader() []byte {
+	return []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+}
+
+func testFLVTag(tagType uint8, ts uint32, data []byte) []byte {
+	tag := make([]byte, flvTagHeaderSize, flvTagHeaderSize+len(data)+4)
+	tag[0] = tagType
+	tag[1], tag[2], tag[3] = byte(len(data)>>16), byte(len(data)>>8), byte(len(data))
+	tag[4], tag[5], tag[6], tag[7] = byte(ts>>16), byte(ts>>8), byte(ts), byte(ts>>24)
+	tag = append(tag, data...)
+	return binary.BigEndian.AppendUint32(tag, uint32(flvTagHeaderSize+len(data)))
+}
+
+func TestFanoutLateJoinerStartsAtLastGOP(t *testing.T) {
+	meta := testFLVTag(flvTagScript, 0, []byte{2, 0, 10})
+	videoSeq := testFLVTag(flvTagVideo, 0, []byte{0x17, 0, 0, 0, 0, 1})
+	audioSeq := testFLVTag(flvTagAudio, 0, []byte{0xaf, 0, 0x12, 0x10})
+	key1 := testFLVTag(flvTagVideo, 40, []byte{0x17, 1, 0, 0, 0, 0xa})
+	inter1 := testFLVTag(flvTagVideo, 80, []byte{0x27, 1, 0, 0, 0, 0xb})
+	key2 := testFLVTag(flvTagVideo, 120, []byte{0x17, 1, 0, 0, 0, 0xc})
+	audio := testFLVTag(flvTagAudio, 125, []byte{0xaf, 1, 0xde})
+
+	var stream []byte
+	for _, part := range [][]byte{testFLVHeader(), meta, videoSeq, audioSeq, key1, inter1, key2, audio} {
+		stream = append(stream, part...)
+	}
+
+	hub := NewFanoutHub()
+	defer hub.Close()
+	early, err := hub.Subscribe()
+	require.NoError(t, err)
+
+	// 按任意边界切分写入，模拟网络读取
+	for i := 0; i < len(stream); i += 7 {
+		end := min(i+7, len(stream))
+		n, err := hub.Write(stream[i:end])
+		require.NoError(t, err)
+		require.Equal(t, end-i, n)
+	}
+
+	late, err := hub.Subscribe()
+	require.NoError(t, err)
+	startup := <-late.C()
+	want := bytes.Join([][]byte{testFLVHeader(), meta, videoSeq, audioSeq, key2, audio}, nil)
+	assert.Equal(t, want, startup)
+
+	// 在流开始之前加入的观看者先收到 FLV 头，再按 tag 收到完整的流
+	var got []byte
+	for len(early.C()) > 0 {
+		got = append(got, <-early.C()...)
+	}
+	assert.Equal(t, stream, got)
+	assert.Equal(t, 2, hub.SubscriberCount())
+
+	hub.Close()
+	_, ok := <-late.C()
+	assert.False(t, ok)
+	_, err = hub.Subscribe()
+	assert.ErrorIs(t, err, ErrFanoutClosed)
+}
+
+func TestFanoutIgnoresNonFLV(t *testing.T) {
+	hub := NewFanoutHub()
+	defer hub.Close()
+	_, err := hub.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n"))
+	require.NoError(t, err)
+
+	sub, err := hub.Subscribe()
+	require.NoError(t, err)
+	assert.Empty(t, sub.C())
+}
@@ -0,0 +1,191 @@
+package streamprobe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxMoovSize 读取 moov box 的最大字节数，防止异常文件导致大量内存分配
+const maxMoovSize = 64 * 1024 * 1024
+
+// FileProbeResult 录制文件的探测结果
+type FileProbeResult struct {
+	*StreamHeaderInfo
+	// Duration 文件时长，无法确定时为 0
+	Duration time.Duration `json:"duration"`
+}
+
+// ProbeFile 探测已落盘的录制文件，解析编码、分辨率与时长
+// 目前支持 FLV、MP4/M4A/MOV 以及 TS 文件，TS 文件无法确定时长
+func ProbeFile(path string) (*FileProbeResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".flv":
+		return probeFLVFile(f)
+	case ".mp4", ".m4a", ".m4v", ".mov":
+		return probeMP4File(f)
+	case ".ts":
+		return probeTSFile(f)
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", filepath.Ext(path))
+	}
+}
+
+// probeFLVFile 解析 FLV 文件头部的 tag 获取流信息，
+// 再通过文件末尾的 PreviousTagSize 回溯到最后一个 tag 计算时长
+func probeFLVFile(f *os.File) (*FileProbeResult, error) {
+	info, buffered, err := parseFLVStreamInfo(bufio.NewReaderSize(f, 64*1024), 30)
+	if err != nil {
+		return nil, err
+	}
+	result := &FileProbeResult{StreamHeaderInfo: info}
+
+	// 第一个 tag 的时间戳（通常为 0，但分段续录的文件可能不是）
+	var firstTimestamp uint32
+	if len(buffered) >= flvHeaderSize+15 {
+		_, firstTimestamp = decodeTagTimestamp(buffered[flvHeaderSize+4 : flvHeaderSize+15])
+	}
+
+	if lastTimestamp, err := readLastFLVTimestamp(f); err == nil && lastTimestamp >= firstTimestamp {
+		result.Duration = time.Duration(lastTimestamp-firstTimestamp) * time.Millisecond
+	} else if d, ok := getNumberFromMeta(info.RawMetaData, "duration"); ok && d > 0 {
+		// 文件尾部被截断时退回使用 onMetaData 中的时长
+		result.Duration = time.Duration(d * float64(time.Second))
+	}
+	return result, nil
+}
+
+// readLastFLVTimestamp 读取 FLV 文件最后一个完整 tag 的时间戳
+func readLastFLVTimestamp(f *os.File) (uint32, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+	if size < flvHeaderSize+4+11 {
+		return 0, ErrTruncated
+	}
+
+	buf := make([]byte, 4)
+	if _, err := f.ReadAt(buf, size-4); err != nil {
+		return 0, err
+	}
+	prevTagSize := int64(binary.BigEndian.Uint32(buf))
+	tagStart := size - 4 - prevTagSize
+	if prevTagSize < 11 || tagStart < flvHeaderSize+4 {
+		return 0, ErrTruncated
+	}
+
+	header := make([]byte, 11)
+	if _, err := f.ReadAt(header, tagStart); err != nil {
+		return 0, err
+	}
+	tagType, timestamp := decodeTagTimestamp(header)
+	if tagType != flvTagAudio && tagType != flvTagVideo && tagType != flvTagScript {
+		return 0, ErrTruncated
+	}
+	return timestamp, nil
+}
+
+// decodeTagTimestamp 从 11 字节的 tag header 中解析 tag 类型和时间戳
+func decodeTagTimestamp(header []byte) (uint8, uint32) {
+	return header[0], uint32(header[4])<<16 | uint32(header[5])<<8 | uint32(header[6]) | uint32(header[7])<<24
+}
+
+// probeMP4File 遍历顶层 box 找到 moov，解析其中的 trak 和 mvhd
+func probeMP4File(f *os.File) (*FileProbeResult, error) {
+	var offset int64
+	header := make([]byte, 16)
+	for {
+		n, err := f.ReadAt(header, offset)
+		if n < 8 {
+			if err == nil || errors.Is(err, io.EOF) {
+				return nil, errors.New("未找到 moov box")
+			}
+			return nil, err
+		}
+		size, boxType, headerLen := readBoxHeader(header[:n])
+		if headerLen == 0 {
+			return nil, errors.New("未找到 moov box")
+		}
+		if size == uint64(n) && binary.BigEndian.Uint32(header[0:4]) == 0 {
+			// size 为 0 表示 box 延伸到文件末尾
+			fi, statErr := f.Stat()
+			if statErr != nil {
+				return nil, statErr
+			}
+			size = uint64(fi.Size() - offset)
+		}
+		if size < uint64(headerLen) {
+			return nil, errors.New("无效的 box 大小")
+		}
+
+		if boxType == "moov" {
+			if size > maxMoovSize {
+				return nil, fmt.Errorf("moov box 过大: %d", size)
+			}
+			data := make([]byte, size)
+			if _, err := f.ReadAt(data, offset); err != nil {
+				return nil, err
+			}
+			info, err := parseFMP4InitSegment(data)
+			if err != nil {
+				return nil, err
+			}
+			result := &FileProbeResult{StreamHeaderInfo: info}
+			result.Duration = parseMvhdDuration(findBox(data[headerLen:], "mvhd"))
+			return result, nil
+		}
+		offset += int64(size)
+	}
+}
+
+// parseMvhdDuration 解析 mvhd box 中的 timescale 和 duration
+func parseMvhdDuration(mvhd []byte) time.Duration {
+	if len(mvhd) < 20 {
+		return 0
+	}
+	var timescale uint32
+	var duration uint64
+	if mvhd[0] == 1 {
+		// version 1: 8 字节创建/修改时间，4 字节 timescale，8 字节 duration
+		if len(mvhd) < 32 {
+			return 0
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[20:24])
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(mvhd[12:16])
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// probeTSFile 读取 TS 文件开头的数据解析编码和分辨率
+func probeTSFile(f *os.File) (*FileProbeResult, error) {
+	data := make([]byte, 1024*1024)
+	n, err := io.ReadFull(f, data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	info, err := parseTSData(data[:n])
+	if err != nil {
+		return nil, err
+	}
+	return &FileProbeResult{StreamHeaderInfo: info}, nil
+}
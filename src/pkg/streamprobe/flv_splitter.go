@@ -0,0 +1,114 @@
+package streamprobe
+
+import "encoding/binary"
+
+const (
+	// flvTagHeaderSize FLV tag 头部大小（不含 PreviousTagSize）
+	flvTagHeaderSize = 11
+	// maxFLVTagSize 单个 tag 的最大长度，超过视为数据损坏
+	maxFLVTagSize = 16 * 1024 * 1024
+)
+
+// FLVTagSplitter 将按任意边界切分的 FLV 字节流还原为完整的 FLV 头和 tag
+// 用于在不影响录制的前提下旁路解析探测代理转发的数据
+type FLVTagSplitter struct {
+	// OnHeader 收到 FLV 头时调用，header 包含 9 字节头和 4 字节 PreviousTagSize0
+	OnHeader func(header []byte)
+	// OnTag 收到完整 tag 时调用，tag 包含 11 字节 tag 头、数据和末尾 4 字节 PreviousTagSize
+	// tag 仅在回调期间有效，需要保留时必须复制
+	OnTag func(tag []byte)
+
+	pending   []byte
+	gotHeader bool
+	invalid   bool
+}
+
+// Reset 清空解析状态，用于重新连接上游后解析新的 FLV 流
+func (s *FLVTagSplitter) Reset() {
+	s.pending = nil
+	s.gotHeader = false
+	s.invalid = false
+}
+
+// Invalid 数据不是 FLV 或已损坏时返回 true，此后写入的数据会被忽略
+func (s *FLVTagSplitter) Invalid() bool {
+	return s.invalid
+}
+
+// Write 实现 io.Writer，始终返回 len(p)
+func (s *FLVTagSplitter) Write(p []byte) (int, error) {
+	if s.invalid {
+		return len(p), nil
+	}
+	s.pending = append(s.pending, p...)
+
+	consumed := 0
+	for {
+		buf := s.pending[consumed:]
+		if !s.gotHeader {
+			if len(buf) < flvHeaderSize+4 {
+				break
+			}
+			if buf[0] != 'F' || buf[1] != 'L' || buf[2] != 'V' {
+				s.fail()
+				return len(p), nil
+			}
+			// DataOffset 通常为 9，后面紧跟 4 字节的 PreviousTagSize0
+			headerLen := int(binary.BigEndian.Uint32(buf[5:9])) + 4
+			if headerLen < flvHeaderSize+4 || headerLen > maxFLVTagSize {
+				s.fail()
+				return len(p), nil
+			}
+			if len(buf) < headerLen {
+				break
+			}
+			s.gotHeader = true
+			consumed += headerLen
+			if s.OnHeader != nil {
+				s.OnHeader(buf[:headerLen])
+			}
+			continue
+		}
+
+		if len(buf) < flvTagHeaderSize {
+			break
+		}
+		dataSize := int(buf[1])<<16 | int(buf[2])<<8 | int(buf[3])
+		tagLen := flvTagHeaderSize + dataSize + 4
+		if tagLen > maxFLVTagSize {
+			s.fail()
+			return len(p), nil
+		}
+		if len(buf) < tagLen {
+			break
+		}
+		consumed += tagLen
+		if s.OnTag != nil {
+			s.OnTag(buf[:tagLen])
+		}
+	}
+
+	// 保留未完整的数据，复制到新切片避免底层数组无限增长
+	s.pending = append([]byte(nil), s.pending[consumed:]...)
+	return len(p), nil
+}
+
+func (s *FLVTagSplitter) fail() {
+	s.invalid = true
+	s.pending = nil
+}
+
+// TagType 返回 tag 类型（音频/视频/脚本）
+func TagType(tag []byte) uint8 {
+	return tag[0] & 0x1f
+}
+
+// TagTimestamp 返回 tag 的时间戳（毫秒，含扩展字节）
+func TagTimestamp(tag []byte) uint32 {
+	return uint32(tag[4])<<16 | uint32(tag[5])<<8 | uint32(tag[6]) | uint32(tag[7])<<24
+}
+
+// TagData 返回 tag 的数据部分（不含 tag 头和 PreviousTagSize）
+func TagData(tag []byte) []byte {
+	return tag[flvTagHeaderSize : len(tag)-4]
+}
@@ -40,6 +40,10 @@ type Config struct {
 
 	// Logger 日志记录器
 	Logger *livelogger.LiveLogger
+
+	// Tee 可选，转发给下载器的全部字节会同时写入 Tee（例如 HTTP-FLV 分发器）
+	// Tee 的写入错误会被忽略，不影响录制
+	Tee io.Writer
 }
 
 // StreamProbe 直播流探测代理
@@ -321,6 +325,7 @@ func (p *StreamProbe) handleStreamRequest(w http.ResponseWriter, r *http.Request
 		if hasFlusher {
 			flusher.Flush()
 		}
+		p.tee(buffered)
 	}
 
 	// 2. 转发上游后续数据
@@ -346,6 +351,7 @@ func (p *StreamProbe) handleStreamRequest(w http.ResponseWriter, r *http.Request
 			if hasFlusher {
 				flusher.Flush()
 			}
+			p.tee(buf[:n])
 		}
 		if err != nil {
 			if !errors.Is(err, io.EOF) && !errors.Is(err, context.Canceled) {
@@ -356,6 +362,13 @@ func (p *StreamProbe) handleStreamRequest(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// tee 将已转发给下载器的数据写入 Tee
+func (p *StreamProbe) tee(data []byte) {
+	if p.config.Tee != nil {
+		p.config.Tee.Write(data)
+	}
+}
+
 // cleanup 清理资源
 func (p *StreamProbe) cleanup() {
 	if p.server != nil {
@@ -0,0 +1,376 @@
+package streamprobe
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// qualityGapThresholdMs 相邻同类 tag 的时间戳差超过该值（或回退）时视为时间戳跳变
+	qualityGapThresholdMs = 1000
+	// frameIntervalSmoothing 视频帧间隔滑动平均的权重，用于估算丢帧数
+	frameIntervalSmoothing = 0.1
+	// droppedFrameFactor 帧间隔超过平均间隔的倍数时视为中间有丢帧
+	droppedFrameFactor = 1.5
+)
+
+// QualitySample 一个采样周期内的流质量指标
+type QualitySample struct {
+	Time     time.Time `json:"time"`     // 采样时刻（周期结束时间）
+	Duration float64   `json:"duration"` // 采样周期长度（秒）
+	Bytes    int64     `json:"bytes"`    // 周期内收到的字节数
+
+	BitrateKbps      float64 `json:"bitrate_kbps"`       // 实际接收码率
+	VideoBitrateKbps float64 `json:"video_bitrate_kbps"` // 视频码率
+	AudioBitrateKbps float64 `json:"audio_bitrate_kbps"` // 音频码率
+	// FPS 按视频时间戳计算的帧率，反映流本身的帧率而非到达速度
+	FPS float64 `json:"fps"`
+	// KeyframeInterval 周期内关键帧的平均间隔（秒），不足两个关键帧时为 0
+	KeyframeInterval float64 `json:"keyframe_interval"`
+
+	TimestampGaps int   `json:"timestamp_gaps"` // 时间戳跳变次数（含回退）
+	MaxGapMs      int64 `json:"max_gap_ms"`     // 最大跳变幅度（毫秒，取绝对值）
+	// AVDriftMs 周期结束时最近的视频与音频时间戳之差（视频减音频，毫秒）
+	AVDriftMs    int64 `json:"av_drift_ms"`
+	MaxAVDriftMs int64 `json:"max_av_drift_ms"` // 周期内音画差的最大绝对值
+
+	DroppedFrames int `json:"dropped_frames"` // 根据视频帧间隔推算的丢帧数
+	CorruptTags   int `json:"corrupt_tags"`   // 长度不一致、类型未知或数据不完整的 tag 数
+	Reconnects    int `json:"reconnects"`     // 周期内重新连接上游的次数
+}
+
+// QualitySummary 整场录制的流质量汇总
+type QualitySummary struct {
+	Samples  int     `json:"samples"`  // 采样次数
+	Duration float64 `json:"duration"` // 采样覆盖的总时长（秒）
+	Bytes    int64   `json:"bytes"`    // 收到的总字节数
+
+	AvgBitrateKbps float64 `json:"avg_bitrate_kbps"`
+	MinBitrateKbps float64 `json:"min_bitrate_kbps"` // 有数据的采样周期中的最低码率
+	MaxBitrateKbps float64 `json:"max_bitrate_kbps"`
+	AvgFPS         float64 `json:"avg_fps"`
+
+	AvgKeyframeInterval float64 `json:"avg_keyframe_interval"`
+	MaxKeyframeInterval float64 `json:"max_keyframe_interval"`
+
+	TimestampGaps int   `json:"timestamp_gaps"`
+	MaxGapMs      int64 `json:"max_gap_ms"`
+	MaxAVDriftMs  int64 `json:"max_av_drift_ms"`
+	DroppedFrames int   `json:"dropped_frames"`
+	CorruptTags   int   `json:"corrupt_tags"`
+	Reconnects    int   `json:"reconnects"`
+}
+
+// qualityWindow 当前采样周期的累计值
+type qualityWindow struct {
+	start       time.Time
+	bytes       int64
+	videoBytes  int64
+	audioBytes  int64
+	videoFrames int
+	// 周期内第一个和最后一个视频帧的时间戳，用于计算帧率
+	firstVideoTS int64
+	lastVideoTS  int64
+
+	keyIntervalSumMs int64
+	keyIntervals     int
+	maxKeyIntervalMs int64
+
+	gaps       int
+	maxGapMs   int64
+	maxDriftMs int64
+	dropped    int
+	corrupt    int
+	reconnects int
+}
+
+// QualityMonitor 从探测代理转发的 FLV 字节流中持续统计流质量
+//
+// 作为 Config.Tee 的一部分挂在 StreamProbe 上，按 tag 统计码率、帧率、关键帧间隔、
+// 时间戳跳变、音画差以及损坏的 tag。调用方定期调用 Sample 取出一个周期的指标，
+// 录制结束时调用 Summary 获得整场汇总。可以安全地并发调用。
+type QualityMonitor struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	splitter FLVTagSplitter
+	win      qualityWindow
+	// streamInvalid 当前连接的数据已无法按 FLV 解析，避免重复计数
+	streamInvalid bool
+
+	// 跨周期的时间戳状态，重连后清空
+	haveVideo   bool
+	haveAudio   bool
+	lastVideoTS int64
+	lastAudioTS int64
+	haveKey     bool
+	lastKeyTS   int64
+	// frameIntervalMs 视频帧间隔的滑动平均（毫秒），跨重连保留
+	frameIntervalMs float64
+
+	summary QualitySummary
+	// 汇总中按时长加权的累计值
+	fpsWeighted      float64
+	fpsDuration      float64
+	keyIntervalSumMs int64
+	keyIntervals     int
+}
+
+// NewQualityMonitor 创建流质量监测器
+func NewQualityMonitor() *QualityMonitor {
+	return newQualityMonitor(time.Now)
+}
+
+func newQualityMonitor(now func() time.Time) *QualityMonitor {
+	m := &QualityMonitor{now: now}
+	m.win.start = now()
+	m.splitter.OnTag = m.onTag
+	return m
+}
+
+// Reset 重新连接上游时调用，新的连接从 FLV 头开始，时间戳也可能从零开始
+func (m *QualityMonitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.splitter.gotHeader || m.streamInvalid {
+		m.win.reconnects++
+	}
+	m.splitter.Reset()
+	m.streamInvalid = false
+	m.haveVideo = false
+	m.haveAudio = false
+	m.haveKey = false
+}
+
+// Write 实现 io.Writer，始终返回 len(p)，不会影响录制
+func (m *QualityMonitor) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.win.bytes += int64(len(p))
+	m.splitter.Write(p)
+	if m.splitter.Invalid() && !m.streamInvalid {
+		// 数据损坏导致无法继续切分，记为一次损坏，等待重连
+		m.streamInvalid = true
+		m.win.corrupt++
+	}
+	return len(p), nil
+}
+
+func (m *QualityMonitor) onTag(tag []byte) {
+	data := TagData(tag)
+	dataSize := len(data)
+	if prevSize := binary.BigEndian.Uint32(tag[len(tag)-4:]); int(prevSize) != flvTagHeaderSize+dataSize {
+		m.win.corrupt++
+		return
+	}
+	ts := int64(TagTimestamp(tag))
+	switch TagType(tag) {
+	case flvTagVideo:
+		if dataSize < 2 {
+			m.win.corrupt++
+			return
+		}
+		m.win.videoBytes += int64(dataSize)
+		m.onVideo(ts, data)
+	case flvTagAudio:
+		if dataSize < 1 {
+			m.win.corrupt++
+			return
+		}
+		m.win.audioBytes += int64(dataSize)
+		if data[0]>>4 == audioCodecAAC && (dataSize < 2 || data[1] == 0) {
+			// AAC 序列头不是音频帧，不参与时间戳统计
+			return
+		}
+		m.onAudio(ts)
+	case flvTagScript:
+	default:
+		m.win.corrupt++
+	}
+}
+
+func (m *QualityMonitor) onVideo(ts int64, data []byte) {
+	isSeqHeader, isKeyframe := classifyVideoTag(data)
+	if isSeqHeader || (data[0]>>4)&0x07 == frameTypeCommandFrame {
+		return
+	}
+
+	if m.haveVideo {
+		delta := ts - m.lastVideoTS
+		m.checkGap(delta)
+		m.estimateDropped(delta)
+	}
+	if m.win.videoFrames == 0 {
+		m.win.firstVideoTS = ts
+	}
+	m.win.videoFrames++
+	m.win.lastVideoTS = ts
+	m.haveVideo = true
+	m.lastVideoTS = ts
+
+	if isKeyframe {
+		if m.haveKey && ts > m.lastKeyTS {
+			interval := ts - m.lastKeyTS
+			m.win.keyIntervalSumMs += interval
+			m.win.keyIntervals++
+			if interval > m.win.maxKeyIntervalMs {
+				m.win.maxKeyIntervalMs = interval
+			}
+		}
+		m.haveKey = true
+		m.lastKeyTS = ts
+	}
+	m.updateDrift()
+}
+
+func (m *QualityMonitor) onAudio(ts int64) {
+	if m.haveAudio {
+		m.checkGap(ts - m.lastAudioTS)
+	}
+	m.haveAudio = true
+	m.lastAudioTS = ts
+	m.updateDrift()
+}
+
+// checkGap 统计同类 tag 之间的时间戳跳变
+func (m *QualityMonitor) checkGap(delta int64) {
+	if delta >= 0 && delta <= qualityGapThresholdMs {
+		return
+	}
+	m.win.gaps++
+	if abs := absInt64(delta); abs > m.win.maxGapMs {
+		m.win.maxGapMs = abs
+	}
+}
+
+// estimateDropped 根据视频帧间隔的滑动平均推算丢帧数
+func (m *QualityMonitor) estimateDropped(delta int64) {
+	if delta <= 0 {
+		return
+	}
+	interval := m.frameIntervalMs
+	if interval == 0 {
+		if delta <= qualityGapThresholdMs {
+			m.frameIntervalMs = float64(delta)
+		}
+		return
+	}
+	if float64(delta) > interval*droppedFrameFactor {
+		if delta <= qualityGapThresholdMs {
+			m.win.dropped += int(math.Round(float64(delta)/interval)) - 1
+		}
+		// 时间戳跳变不计入平均帧间隔，也不按帧间隔推算丢帧（可能是上游重置时间戳）
+		return
+	}
+	m.frameIntervalMs += (float64(delta) - interval) * frameIntervalSmoothing
+}
+
+func (m *QualityMonitor) updateDrift() {
+	if !m.haveVideo || !m.haveAudio {
+		return
+	}
+	if drift := absInt64(m.lastVideoTS - m.lastAudioTS); drift > m.win.maxDriftMs {
+		m.win.maxDriftMs = drift
+	}
+}
+
+// Sample 结束当前采样周期并返回该周期的指标
+func (m *QualityMonitor) Sample() QualitySample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	w := m.win
+	s := QualitySample{
+		Time:          now,
+		Duration:      now.Sub(w.start).Seconds(),
+		Bytes:         w.bytes,
+		TimestampGaps: w.gaps,
+		MaxGapMs:      w.maxGapMs,
+		MaxAVDriftMs:  w.maxDriftMs,
+		DroppedFrames: w.dropped,
+		CorruptTags:   w.corrupt,
+		Reconnects:    w.reconnects,
+	}
+	if s.Duration > 0 {
+		s.BitrateKbps = kbps(w.bytes, s.Duration)
+		s.VideoBitrateKbps = kbps(w.videoBytes, s.Duration)
+		s.AudioBitrateKbps = kbps(w.audioBytes, s.Duration)
+	}
+	var spanSec float64
+	if w.videoFrames > 1 && w.lastVideoTS > w.firstVideoTS {
+		spanSec = float64(w.lastVideoTS-w.firstVideoTS) / 1000
+		s.FPS = float64(w.videoFrames-1) / spanSec
+	}
+	if w.keyIntervals > 0 {
+		s.KeyframeInterval = float64(w.keyIntervalSumMs) / float64(w.keyIntervals) / 1000
+	}
+	if m.haveVideo && m.haveAudio {
+		s.AVDriftMs = m.lastVideoTS - m.lastAudioTS
+	}
+
+	m.addToSummary(s, spanSec, w)
+	m.win = qualityWindow{start: now}
+	return s
+}
+
+func (m *QualityMonitor) addToSummary(s QualitySample, spanSec float64, w qualityWindow) {
+	sum := &m.summary
+	sum.Samples++
+	sum.Duration += s.Duration
+	sum.Bytes += s.Bytes
+	if sum.Duration > 0 {
+		sum.AvgBitrateKbps = kbps(sum.Bytes, sum.Duration)
+	}
+	if s.Bytes > 0 {
+		if sum.MinBitrateKbps == 0 || s.BitrateKbps < sum.MinBitrateKbps {
+			sum.MinBitrateKbps = s.BitrateKbps
+		}
+		if s.BitrateKbps > sum.MaxBitrateKbps {
+			sum.MaxBitrateKbps = s.BitrateKbps
+		}
+	}
+	if spanSec > 0 {
+		m.fpsWeighted += s.FPS * spanSec
+		m.fpsDuration += spanSec
+		sum.AvgFPS = m.fpsWeighted / m.fpsDuration
+	}
+	if w.keyIntervals > 0 {
+		m.keyIntervalSumMs += w.keyIntervalSumMs
+		m.keyIntervals += w.keyIntervals
+		sum.AvgKeyframeInterval = float64(m.keyIntervalSumMs) / float64(m.keyIntervals) / 1000
+		if longest := float64(w.maxKeyIntervalMs) / 1000; longest > sum.MaxKeyframeInterval {
+			sum.MaxKeyframeInterval = longest
+		}
+	}
+	sum.TimestampGaps += s.TimestampGaps
+	if s.MaxGapMs > sum.MaxGapMs {
+		sum.MaxGapMs = s.MaxGapMs
+	}
+	if s.MaxAVDriftMs > sum.MaxAVDriftMs {
+		sum.MaxAVDriftMs = s.MaxAVDriftMs
+	}
+	sum.DroppedFrames += s.DroppedFrames
+	sum.CorruptTags += s.CorruptTags
+	sum.Reconnects += s.Reconnects
+}
+
+// Summary 返回已采样周期的汇总，不包含尚未调用 Sample 的当前周期
+func (m *QualityMonitor) Summary() QualitySummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.summary
+}
+
+func kbps(bytes int64, seconds float64) float64 {
+	return float64(bytes) * 8 / 1000 / seconds
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
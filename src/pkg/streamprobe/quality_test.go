@@ -0,0 +1,96 @@
+package streamprobe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualityMonitorSample(t *testing.T) {
+	now := time.Date(2024, 3, 5, 20, 0, 0, 0, time.UTC)
+	m := newQualityMonitor(func() time.Time { return now })
+
+	var stream []byte
+	stream = append(stream, testFLVHeader()...)
+	stream = append(stream, testFLVTag(flvTagVideo, 0, []byte{0x17, 0, 0, 0, 0, 1})...)
+	stream = append(stream, testFLVTag(flvTagAudio, 0, []byte{0xaf, 0, 0x12, 0x10})...)
+	// 25fps，每 50 帧（2 秒）一个关键帧，第 60~62 帧丢失
+	for i := 0; i < 100; i++ {
+		if i >= 60 && i < 63 {
+			continue
+		}
+		ts := uint32(i * 40)
+		data := []byte{0x27, 1, 0, 0, 0, 0xb}
+		if i%50 == 0 {
+			data[0] = 0x17
+		}
+		stream = append(stream, testFLVTag(flvTagVideo, ts, data)...)
+		stream = append(stream, testFLVTag(flvTagAudio, ts+10, []byte{0xaf, 1, 0xde})...)
+	}
+	// 音频时间戳回退
+	stream = append(stream, testFLVTag(flvTagAudio, 100, []byte{0xaf, 1, 0xde})...)
+	// PreviousTagSize 与数据长度不一致
+	bad := testFLVTag(flvTagVideo, 4000, []byte{0x27, 1, 0, 0, 0, 0xb})
+	bad[len(bad)-1]++
+	stream = append(stream, bad...)
+	stream = append(stream, testFLVTag(flvTagScript, 4000, []byte{2, 0, 0})...)
+
+	for i := 0; i < len(stream); i += 13 {
+		n, err := m.Write(stream[i:min(i+13, len(stream))])
+		require.NoError(t, err)
+		require.Equal(t, min(13, len(stream)-i), n)
+	}
+
+	now = now.Add(4 * time.Second)
+	s := m.Sample()
+	assert.Equal(t, 4.0, s.Duration)
+	assert.Equal(t, int64(len(stream)), s.Bytes)
+	assert.InDelta(t, float64(len(stream))*8/1000/4, s.BitrateKbps, 0.001)
+	// 97 帧分布在 3.96 秒内
+	assert.InDelta(t, 96/3.96, s.FPS, 0.01)
+	assert.Equal(t, 2.0, s.KeyframeInterval)
+	assert.Equal(t, 3, s.DroppedFrames)
+	assert.Equal(t, 1, s.TimestampGaps)
+	assert.Equal(t, int64(3870), s.MaxGapMs)
+	assert.Equal(t, int64(3860), s.AVDriftMs)
+	assert.Equal(t, int64(3860), s.MaxAVDriftMs)
+	assert.Equal(t, 1, s.CorruptTags)
+
+	// 重连后时间戳从零开始，不视为跳变
+	m.Reset()
+	var next []byte
+	next = append(next, testFLVHeader()...)
+	next = append(next, testFLVTag(flvTagVideo, 0, []byte{0x17, 1, 0, 0, 0, 0xa})...)
+	next = append(next, testFLVTag(flvTagVideo, 40, []byte{0x27, 1, 0, 0, 0, 0xb})...)
+	next = append(next, testFLVTag(flvTagScript, 40, []byte{2, 0, 0})...)
+	_, err := m.Write(next)
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Second)
+	s = m.Sample()
+	assert.Equal(t, 1, s.Reconnects)
+	assert.Zero(t, s.TimestampGaps)
+	assert.Zero(t, s.CorruptTags)
+	assert.InDelta(t, 25, s.FPS, 0.01)
+
+	sum := m.Summary()
+	assert.Equal(t, 2, sum.Samples)
+	assert.Equal(t, 6.0, sum.Duration)
+	assert.Equal(t, int64(len(stream)+len(next)), sum.Bytes)
+	assert.Equal(t, 3, sum.DroppedFrames)
+	assert.Equal(t, 1, sum.CorruptTags)
+	assert.Equal(t, 1, sum.Reconnects)
+	assert.Equal(t, 2.0, sum.MaxKeyframeInterval)
+	assert.Greater(t, sum.MaxBitrateKbps, sum.MinBitrateKbps)
+}
+
+func TestQualityMonitorInvalidStream(t *testing.T) {
+	m := NewQualityMonitor()
+	_, err := m.Write([]byte("<html>not a flv stream</html>"))
+	require.NoError(t, err)
+	_, err = m.Write([]byte("more"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.Sample().CorruptTags)
+}
@@ -0,0 +1,309 @@
+// Package webdav 实现上传录像所需的 WebDAV 客户端
+// 只覆盖创建目录、上传文件和查询文件属性用到的方法，使用 HTTP Basic 认证
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config 连接配置
+type Config struct {
+	URL      string // WebDAV 根地址，例如 https://nas.local:5006/records
+	Username string
+	Password string
+}
+
+// Client WebDAV 客户端
+type Client struct {
+	cfg        Config
+	base       *url.URL
+	httpClient *http.Client
+
+	mu   sync.Mutex
+	dirs map[string]bool // 已确认存在的目录
+}
+
+// NewClient 创建客户端
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webdav: url is required")
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("webdav: invalid url %q", cfg.URL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	base.RawPath = ""
+	return &Client{
+		cfg:  cfg,
+		base: base,
+		httpClient: &http.Client{
+			Timeout: 0, // 上传可能需要很长时间，由上下文控制
+		},
+		dirs: map[string]bool{"/": true},
+	}, nil
+}
+
+// Error WebDAV 服务返回的错误
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("webdav: %s %s: HTTP %d", e.Method, e.Path, e.StatusCode)
+}
+
+// IsNotFound 判断错误是否表示资源不存在
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// FileInfo PROPFIND 得到的文件属性
+type FileInfo struct {
+	Size  int64
+	ETag  string // 去掉引号和弱校验前缀
+	IsDir bool
+}
+
+// propfindBody 只查询上传校验用到的属性
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getetag/></D:prop></D:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				ETag          string `xml:"getetag"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// Stat 查询文件或目录的属性
+func (c *Client) Stat(ctx context.Context, remotePath string) (*FileInfo, error) {
+	headers := http.Header{}
+	headers.Set("Depth", "0")
+	headers.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := c.do(ctx, "PROPFIND", remotePath, headers, strings.NewReader(propfindBody), int64(len(propfindBody)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("webdav: failed to decode PROPFIND response: %w", err)
+	}
+	if len(ms.Responses) == 0 {
+		return nil, fmt.Errorf("webdav: empty PROPFIND response for %s", remotePath)
+	}
+	info := &FileInfo{}
+	for _, ps := range ms.Responses[0].Propstats {
+		// 服务端不支持的属性放在 404 的 propstat 中
+		if !strings.Contains(ps.Status, " 200") {
+			continue
+		}
+		if ps.Prop.ResourceType.Collection != nil {
+			info.IsDir = true
+		}
+		if ps.Prop.ContentLength != "" {
+			info.Size, _ = strconv.ParseInt(strings.TrimSpace(ps.Prop.ContentLength), 10, 64)
+		}
+		if ps.Prop.ETag != "" {
+			info.ETag = trimETag(ps.Prop.ETag)
+		}
+	}
+	return info, nil
+}
+
+// MkdirAll 按需创建目录及其上级目录，已确认存在的目录会被缓存
+func (c *Client) MkdirAll(ctx context.Context, dir string) error {
+	dir = cleanPath(dir)
+	c.mu.Lock()
+	exists := c.dirs[dir]
+	c.mu.Unlock()
+	if exists {
+		return nil
+	}
+
+	info, err := c.Stat(ctx, dir)
+	switch {
+	case err == nil && !info.IsDir:
+		return fmt.Errorf("webdav: %s exists and is not a directory", dir)
+	case err == nil:
+	case IsNotFound(err):
+		if err := c.MkdirAll(ctx, path.Dir(dir)); err != nil {
+			return err
+		}
+		resp, err := c.do(ctx, "MKCOL", dir, nil, nil, 0)
+		if err != nil {
+			// 405 表示目录已存在，可能是并发创建的
+			var e *Error
+			if !errors.As(err, &e) || e.StatusCode != http.StatusMethodNotAllowed {
+				return err
+			}
+		} else {
+			resp.Body.Close()
+		}
+	default:
+		return err
+	}
+
+	c.mu.Lock()
+	c.dirs[dir] = true
+	c.mu.Unlock()
+	return nil
+}
+
+// UploadOptions 上传选项
+type UploadOptions struct {
+	// Chunked 使用 Transfer-Encoding: chunked 发送，不预先声明 Content-Length；
+	// 部分服务（例如 nginx 的 dav 模块）不支持，默认关闭
+	Chunked    bool
+	OnProgress func(uploaded, total int64)
+}
+
+// UploadResult 上传结果
+type UploadResult struct {
+	Path string
+	Size int64
+	ETag string
+}
+
+// UploadFile 上传本地文件，上级目录不存在时自动创建，完成后核对大小和 ETag
+func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string, opts UploadOptions) (*UploadResult, error) {
+	remotePath = cleanPath(remotePath)
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+
+	if err := c.MkdirAll(ctx, path.Dir(remotePath)); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	var body io.Reader = file
+	if opts.OnProgress != nil {
+		body = &progressReader{r: file, total: size, onProgress: opts.OnProgress}
+	}
+	contentLength := size
+	if opts.Chunked {
+		contentLength = -1
+	}
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/octet-stream")
+	resp, err := c.do(ctx, http.MethodPut, remotePath, headers, body, contentLength)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	etag := trimETag(resp.Header.Get("ETag"))
+
+	if err := c.Verify(ctx, remotePath, size, etag); err != nil {
+		return nil, err
+	}
+	return &UploadResult{Path: remotePath, Size: size, ETag: etag}, nil
+}
+
+// Verify 核对远端文件的大小，etag 不为空且服务端返回了 ETag 时一并核对
+func (c *Client) Verify(ctx context.Context, remotePath string, size int64, etag string) error {
+	info, err := c.Stat(ctx, remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if info.IsDir {
+		return fmt.Errorf("failed to verify upload: %s is a directory", remotePath)
+	}
+	if info.Size != size {
+		return fmt.Errorf("size mismatch after upload: local %d, remote %d", size, info.Size)
+	}
+	if etag != "" && info.ETag != "" && info.ETag != etag {
+		return fmt.Errorf("etag mismatch after upload: expected %s, remote %s", etag, info.ETag)
+	}
+	return nil
+}
+
+// do 发送请求，非 2xx 响应转换为 *Error
+func (c *Client) do(ctx context.Context, method, remotePath string, headers http.Header, body io.Reader, contentLength int64) (*http.Response, error) {
+	u := *c.base
+	u.Path = c.base.Path + cleanPath(remotePath)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = contentLength
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	for k, values := range headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if c.cfg.Username != "" || c.cfg.Password != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		return nil, &Error{Method: method, Path: remotePath, StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// progressReader 读取时回调已发送的字节数
+type progressReader struct {
+	r          io.Reader
+	read       int64
+	total      int64
+	onProgress func(uploaded, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.onProgress(p.read, p.total)
+	}
+	return n, err
+}
+
+// cleanPath 规范化远端路径，始终以 / 开头
+func cleanPath(p string) string {
+	return path.Clean("/" + strings.ReplaceAll(p, "\\", "/"))
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(etag), "W/"), `"`)
+}
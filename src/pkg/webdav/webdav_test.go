@@ -0,0 +1,130 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// recordingServer 在 x/net/webdav 的内存实现外记录请求方法
+type recordingServer struct {
+	handler  http.Handler
+	mu       sync.Mutex
+	methods  []string
+	chunked  bool
+	username string
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.methods = append(s.methods, r.Method+" "+r.URL.Path)
+	if r.Method == http.MethodPut {
+		s.chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+	}
+	s.username, _, _ = r.BasicAuth()
+	s.mu.Unlock()
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *recordingServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, m := range s.methods {
+		if strings.HasPrefix(m, method+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestServer(t *testing.T) (*recordingServer, *Client) {
+	t.Helper()
+	rec := &recordingServer{handler: &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}}
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+	client, err := NewClient(Config{URL: server.URL + "/dav/", Username: "user", Password: "pass"})
+	require.NoError(t, err)
+	return rec, client
+}
+
+func writeTestFile(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "录播 1.flv")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644))
+	return path
+}
+
+func TestUploadFileCreatesDirectories(t *testing.T) {
+	rec, client := newTestServer(t)
+	local := writeTestFile(t, 3000)
+
+	var progress []int64
+	result, err := client.UploadFile(context.Background(), local, "/录播归档/哔哩哔哩/主播 A/录播 1.flv", UploadOptions{
+		OnProgress: func(uploaded, total int64) {
+			assert.Equal(t, int64(3000), total)
+			progress = append(progress, uploaded)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3000), result.Size)
+	assert.NotEmpty(t, result.ETag)
+	assert.Equal(t, int64(3000), progress[len(progress)-1])
+	assert.Equal(t, 3, rec.count("MKCOL"))
+	assert.False(t, rec.chunked)
+	assert.Equal(t, "user", rec.username)
+
+	info, err := client.Stat(context.Background(), "/录播归档/哔哩哔哩/主播 A")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir)
+
+	// 同一目录下的文件不再重复创建目录
+	_, err = client.UploadFile(context.Background(), local, "/录播归档/哔哩哔哩/主播 A/录播 2.flv", UploadOptions{Chunked: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, rec.count("MKCOL"))
+	assert.True(t, rec.chunked)
+}
+
+func TestMkdirAllExistingFile(t *testing.T) {
+	_, client := newTestServer(t)
+	local := writeTestFile(t, 10)
+	_, err := client.UploadFile(context.Background(), local, "/a", UploadOptions{})
+	require.NoError(t, err)
+
+	err = client.MkdirAll(context.Background(), "/a")
+	assert.ErrorContains(t, err, "not a directory")
+}
+
+func TestVerify(t *testing.T) {
+	_, client := newTestServer(t)
+	local := writeTestFile(t, 10)
+	result, err := client.UploadFile(context.Background(), local, "/v.flv", UploadOptions{})
+	require.NoError(t, err)
+
+	assert.NoError(t, client.Verify(context.Background(), "/v.flv", 10, result.ETag))
+	assert.ErrorContains(t, client.Verify(context.Background(), "/v.flv", 11, ""), "size mismatch")
+	assert.ErrorContains(t, client.Verify(context.Background(), "/v.flv", 10, "other"), "etag mismatch")
+
+	_, err = client.Stat(context.Background(), "/missing.flv")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestNewClientInvalidURL(t *testing.T) {
+	_, err := NewClient(Config{})
+	assert.Error(t, err)
+	_, err = NewClient(Config{URL: "ftp://nas/records"})
+	assert.Error(t, err)
+}
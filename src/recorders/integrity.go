@@ -0,0 +1,21 @@
+package recorders
+
+import (
+	"context"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+)
+
+// writeIntegritySidecars 为已落盘的分段生成完整性附属文件
+// 需要读取整个文件计算校验和，调用方应在后台执行
+func (r *recorder) writeIntegritySidecars(ctx context.Context, files ...string) {
+	if !configs.GetCurrentConfig().IntegritySidecar {
+		return
+	}
+	for _, f := range files {
+		if _, err := integrity.Create(ctx, f); err != nil {
+			r.getLogger().WithError(err).Warnf("生成完整性附属文件失败: %s", f)
+		}
+	}
+}
@@ -41,7 +41,9 @@ func SetOnRecordingEndFunc(fn OnRecordingEndFunc) {
 
 func NewManager(ctx context.Context) Manager {
 	rm := &manager{
+		ctx:          ctx,
 		savers:       make(map[types.LiveID]Recorder),
+		lives:        make(map[types.LiveID]live.Live),
 		statusStopCh: make(chan struct{}),
 	}
 	instance.GetInstance(ctx).RecorderManager = rm
@@ -63,6 +65,8 @@ type Manager interface {
 	GetRecorderStatus(ctx context.Context, liveId types.LiveID) (map[string]interface{}, error)
 	// GetActiveRecordingsCount 获取当前活跃的录制数量
 	GetActiveRecordingsCount() int
+	// GetRecordingQueue 获取因并发限制等待录制的直播间队列
+	GetRecordingQueue() []QueuedRecording
 }
 
 // for test
@@ -71,8 +75,12 @@ var (
 )
 
 type manager struct {
+	// ctx 用于从等待队列中启动录制，不随触发出队的请求结束而取消
+	ctx          context.Context
 	lock         sync.RWMutex
 	savers       map[types.LiveID]Recorder
+	lives        map[types.LiveID]live.Live // 正在录制的直播间，用于按优先级抢占
+	queue        []*queuedLive              // 因并发限制等待录制的直播间，按优先级排序
 	statusTicker *time.Ticker
 	statusStopCh chan struct{}
 	statusWg     sync.WaitGroup // 用于等待广播 goroutine 退出
@@ -105,6 +113,14 @@ func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 
 	removeEvtListener := events.NewEventListener(func(event *events.Event) {
 		live := event.Object.(live.Live)
+		// 仍在排队的直播间直接移出队列
+		m.lock.Lock()
+		queued := m.dequeueLocked(live.GetLiveId())
+		m.lock.Unlock()
+		if queued {
+			live.GetLogger().Info("直播间已结束或停止监控，移出录制等待队列")
+			return
+		}
 		if !m.HasRecorder(ctx, live.GetLiveId()) {
 			return
 		}
@@ -117,6 +133,7 @@ func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 }
 
 func (m *manager) Start(ctx context.Context) error {
+	m.ctx = ctx
 	inst := instance.GetInstance(ctx)
 	if cfg := configs.GetCurrentConfig(); (cfg != nil && cfg.RPC.Enable) || inst.Lives.Len() > 0 {
 		inst.WaitGroup.Add(1)
@@ -145,7 +162,9 @@ func (m *manager) Close(ctx context.Context) {
 	for id, recorder := range m.savers {
 		recorder.Close()
 		delete(m.savers, id)
+		delete(m.lives, id)
 	}
+	m.queue = nil
 	inst := instance.GetInstance(ctx)
 	inst.WaitGroup.Done()
 }
@@ -153,11 +172,24 @@ func (m *manager) Close(ctx context.Context) {
 func (m *manager) AddRecorder(ctx context.Context, live live.Live) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	return m.addRecorderLocked(ctx, live)
+	if _, ok := m.savers[live.GetLiveId()]; ok {
+		return ErrRecorderExist
+	}
+	// 已在队列中或因并发限制进入队列时，等待空位后再开始录制
+	for _, q := range m.queue {
+		if q.live.GetLiveId() == live.GetLiveId() {
+			return nil
+		}
+	}
+	if !m.admitLocked(live) {
+		return nil
+	}
+	return m.addRecorderLocked(ctx, live, nil)
 }
 
 // addRecorderLocked 是 AddRecorder 的内部实现，调用者必须已持有 m.lock
-func (m *manager) addRecorderLocked(ctx context.Context, live live.Live) error {
+// prev 不为 nil 时为分段重启，新录制器在启动前接管 prev 的实时输出
+func (m *manager) addRecorderLocked(ctx context.Context, live live.Live, prev Recorder) error {
 	if _, ok := m.savers[live.GetLiveId()]; ok {
 		return ErrRecorderExist
 	}
@@ -165,7 +197,11 @@ func (m *manager) addRecorderLocked(ctx context.Context, live live.Live) error {
 	if err != nil {
 		return err
 	}
+	if prev != nil {
+		inheritOutputs(recorder, prev)
+	}
 	m.savers[live.GetLiveId()] = recorder
+	m.lives[live.GetLiveId()] = live
 
 	cfg := configs.GetCurrentConfig()
 	if cfg != nil {
@@ -178,6 +214,7 @@ func (m *manager) addRecorderLocked(ctx context.Context, live live.Live) error {
 		// 使用异步 Close 避免在持锁时执行耗时操作（如等待 ffmpeg 进程退出），
 		// 防止长时间阻塞其他 manager 操作
 		delete(m.savers, live.GetLiveId())
+		delete(m.lives, live.GetLiveId())
 		bilisentry.Go(recorder.Close)
 		return err
 	}
@@ -215,7 +252,7 @@ func (m *manager) RestartRecorder(ctx context.Context, live live.Live) error {
 	}
 	// 从 map 中移除旧 recorder 并立即添加新 recorder，保持锁贯穿整个替换操作
 	delete(m.savers, live.GetLiveId())
-	if err := m.addRecorderLocked(ctx, live); err != nil {
+	if err := m.addRecorderLocked(ctx, live, oldRecorder); err != nil {
 		// 添加新 recorder 失败，恢复旧 recorder 避免僵尸状态
 		m.savers[live.GetLiveId()] = oldRecorder
 		m.lock.Unlock()
@@ -261,17 +298,31 @@ func (m *manager) RestartRecorder(ctx context.Context, live live.Live) error {
 func (m *manager) RemoveRecorder(ctx context.Context, liveId types.LiveID) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	return m.removeRecorderLocked(ctx, liveId)
+	if err := m.removeRecorderLocked(ctx, liveId); err != nil {
+		return err
+	}
+	// 空出的位置交给等待队列中优先级最高的直播间
+	m.drainQueueLocked(m.ctx)
+	return nil
 }
 
-// removeRecorderLocked 是 RemoveRecorder 的内部实现，调用者必须已持有 m.lock
-func (m *manager) removeRecorderLocked(ctx context.Context, liveId types.LiveID) error {
+// stopRecorderLocked 关闭录制器并从 map 中移除，调用者必须已持有 m.lock
+func (m *manager) stopRecorderLocked(liveId types.LiveID) error {
 	recorder, ok := m.savers[liveId]
 	if !ok {
 		return ErrRecorderNotExist
 	}
 	recorder.Close()
 	delete(m.savers, liveId)
+	delete(m.lives, liveId)
+	return nil
+}
+
+// removeRecorderLocked 是 RemoveRecorder 的内部实现，调用者必须已持有 m.lock
+func (m *manager) removeRecorderLocked(ctx context.Context, liveId types.LiveID) error {
+	if err := m.stopRecorderLocked(liveId); err != nil {
+		return err
+	}
 
 	// 录制结束后，检查是否有等待中的优雅更新
 	if onRecordingEndFunc != nil {
@@ -313,6 +364,8 @@ func (m *manager) startStatusBroadcaster(ctx context.Context) {
 			case <-m.statusStopCh:
 				return
 			case <-m.statusTicker.C:
+				// 最大同时录制数可能在运行中被调大，定期检查等待队列
+				m.drainQueue()
 				m.broadcastAllRecorderStatus(ctx)
 			}
 		}
@@ -336,6 +389,10 @@ func (m *manager) broadcastAllRecorderStatus(ctx context.Context) {
 			broadcastRecorderStatusFunc(liveId, status)
 		}
 	}
+	// 排队中的直播间也广播队列位置
+	for i, q := range m.queue {
+		broadcastRecorderStatusFunc(q.live.GetLiveId(), queuedStatus(q.snapshot(i+1)))
+	}
 }
 
 // GetAllParserPIDs 获取所有活动录制器的 parser PID 列表
@@ -357,6 +414,11 @@ func (m *manager) GetAllParserPIDs() []int {
 func (m *manager) GetRecorderStatus(ctx context.Context, liveId types.LiveID) (map[string]interface{}, error) {
 	recorder, err := m.GetRecorder(ctx, liveId)
 	if err != nil {
+		for _, q := range m.GetRecordingQueue() {
+			if q.LiveID == liveId {
+				return queuedStatus(q), nil
+			}
+		}
 		return nil, err
 	}
 	return recorder.GetStatus()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	gomock "go.uber.org/mock/gomock"
@@ -149,3 +150,81 @@ func TestRestartRecorderRaceWithLiveEnd(t *testing.T) {
 	assert.True(t, hasRecorderResult,
 		"HasRecorder 应在 RestartRecorder 完成后返回 true，说明锁正确阻止了中间状态暴露")
 }
+
+// TestManagerConcurrencyLimitAndPreemption 验证最大同时录制数：
+// 同优先级的直播间进入等待队列，更高优先级的直播间抢占最低优先级的录制，
+// 录制结束后按优先级从队列中补位。
+func TestManagerConcurrencyLimitAndPreemption(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := configs.NewConfig()
+	cfg.MaxConcurrentRecordings = 1
+	cfg.LiveRooms = []configs.LiveRoom{
+		{Url: "https://live.example.com/low"},
+		{Url: "https://live.example.com/mid"},
+		{Url: "https://live.example.com/high", Priority: 10},
+	}
+	configs.SetCurrentConfig(cfg)
+	defer configs.SetCurrentConfig(new(configs.Config))
+
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{})
+	m := NewManager(ctx)
+
+	backup := newRecorder
+	newRecorder = func(ctx context.Context, l live.Live) (Recorder, error) {
+		r := NewMockRecorder(ctrl)
+		r.EXPECT().Start(gomock.Any()).Return(nil)
+		r.EXPECT().StartTime().Return(time.Now()).AnyTimes()
+		r.EXPECT().Close().AnyTimes()
+		return r, nil
+	}
+	defer func() { newRecorder = backup }()
+
+	newLive := func(id string) live.Live {
+		l := livemock.NewMockLive(ctrl)
+		l.EXPECT().GetLiveId().Return(types.LiveID(id)).AnyTimes()
+		l.EXPECT().GetRawUrl().Return("https://live.example.com/" + id).AnyTimes()
+		l.EXPECT().GetLogger().Return(livelogger.New(0, nil)).AnyTimes()
+		return l
+	}
+	low, mid, high := newLive("low"), newLive("mid"), newLive("high")
+
+	assert.NoError(t, m.AddRecorder(ctx, low))
+	// 同优先级不抢占，进入队列
+	assert.NoError(t, m.AddRecorder(ctx, mid))
+	assert.True(t, m.HasRecorder(ctx, "low"))
+	assert.False(t, m.HasRecorder(ctx, "mid"))
+	queue := m.GetRecordingQueue()
+	assert.Len(t, queue, 1)
+	assert.Equal(t, types.LiveID("mid"), queue[0].LiveID)
+	assert.Equal(t, QueueReasonLimit, queue[0].Reason)
+
+	status, err := m.GetRecorderStatus(ctx, "mid")
+	assert.NoError(t, err)
+	assert.Equal(t, true, status["queued"])
+	assert.Equal(t, 1, status["queue_position"])
+
+	// 高优先级抢占 low，low 回到队列
+	assert.NoError(t, m.AddRecorder(ctx, high))
+	assert.True(t, m.HasRecorder(ctx, "high"))
+	assert.False(t, m.HasRecorder(ctx, "low"))
+	queue = m.GetRecordingQueue()
+	assert.Len(t, queue, 2)
+	assert.Equal(t, types.LiveID("mid"), queue[0].LiveID)
+	assert.Equal(t, types.LiveID("low"), queue[1].LiveID)
+	assert.Equal(t, QueueReasonPreempted, queue[1].Reason)
+	assert.Equal(t, 1, m.GetActiveRecordingsCount())
+
+	// 高优先级录制结束后，队首补位
+	assert.NoError(t, m.RemoveRecorder(ctx, "high"))
+	assert.True(t, m.HasRecorder(ctx, "mid"))
+	assert.Len(t, m.GetRecordingQueue(), 1)
+
+	// 排队中的直播间下播时移出队列
+	mgr := m.(*manager)
+	mgr.lock.Lock()
+	assert.True(t, mgr.dequeueLocked("low"))
+	mgr.lock.Unlock()
+	assert.Empty(t, m.GetRecordingQueue())
+}
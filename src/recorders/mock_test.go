@@ -3,7 +3,7 @@
 //
 // Generated by this command:
 //
-//	mockgen -package recorders -destination src/recorders/mock_test.go github.com/bililive-go/bililive-go/src/recorders Recorder,Manager
+//	mockgen -package recorders -destination mock_test.go github.com/bililive-go/bililive-go/src/recorders Recorder,Manager
 //
 
 // Package recorders is a generated GoMock package.
@@ -16,6 +16,8 @@ import (
 
 	live "github.com/bililive-go/bililive-go/src/live"
 	notify "github.com/bililive-go/bililive-go/src/notify"
+	livehls "github.com/bililive-go/bililive-go/src/pkg/livehls"
+	streamprobe "github.com/bililive-go/bililive-go/src/pkg/streamprobe"
 	types "github.com/bililive-go/bililive-go/src/types"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -70,6 +72,20 @@ func (mr *MockRecorderMockRecorder) CloseForRestart() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseForRestart", reflect.TypeOf((*MockRecorder)(nil).CloseForRestart))
 }
 
+// GetLiveHLS mocks base method.
+func (m *MockRecorder) GetLiveHLS() *livehls.Stream {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLiveHLS")
+	ret0, _ := ret[0].(*livehls.Stream)
+	return ret0
+}
+
+// GetLiveHLS indicates an expected call of GetLiveHLS.
+func (mr *MockRecorderMockRecorder) GetLiveHLS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLiveHLS", reflect.TypeOf((*MockRecorder)(nil).GetLiveHLS))
+}
+
 // GetParserPID mocks base method.
 func (m *MockRecorder) GetParserPID() int {
 	m.ctrl.T.Helper()
@@ -99,6 +115,20 @@ func (mr *MockRecorderMockRecorder) GetStatus() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatus", reflect.TypeOf((*MockRecorder)(nil).GetStatus))
 }
 
+// GetStreamFanout mocks base method.
+func (m *MockRecorder) GetStreamFanout() *streamprobe.FanoutHub {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStreamFanout")
+	ret0, _ := ret[0].(*streamprobe.FanoutHub)
+	return ret0
+}
+
+// GetStreamFanout indicates an expected call of GetStreamFanout.
+func (mr *MockRecorderMockRecorder) GetStreamFanout() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStreamFanout", reflect.TypeOf((*MockRecorder)(nil).GetStreamFanout))
+}
+
 // HasFlvProxy mocks base method.
 func (m *MockRecorder) HasFlvProxy() bool {
 	m.ctrl.T.Helper()
@@ -289,6 +319,20 @@ func (mr *MockManagerMockRecorder) GetRecorderStatus(ctx, liveId any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecorderStatus", reflect.TypeOf((*MockManager)(nil).GetRecorderStatus), ctx, liveId)
 }
 
+// GetRecordingQueue mocks base method.
+func (m *MockManager) GetRecordingQueue() []QueuedRecording {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecordingQueue")
+	ret0, _ := ret[0].([]QueuedRecording)
+	return ret0
+}
+
+// GetRecordingQueue indicates an expected call of GetRecordingQueue.
+func (mr *MockManagerMockRecorder) GetRecordingQueue() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecordingQueue", reflect.TypeOf((*MockManager)(nil).GetRecordingQueue))
+}
+
 // HasRecorder mocks base method.
 func (m *MockManager) HasRecorder(ctx context.Context, liveId types.LiveID) bool {
 	m.ctrl.T.Helper()
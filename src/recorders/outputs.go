@@ -0,0 +1,94 @@
+package recorders
+
+import (
+	"sync"
+
+	"github.com/bililive-go/bililive-go/src/pkg/livehls"
+	"github.com/bililive-go/bililive-go/src/pkg/rtmprelay"
+	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+// liveOutputs 直播间的实时输出：HTTP-FLV 分发、fMP4 HLS 和 RTMP 转推
+// 分段重启时由新的录制器继承，观看者、DVR 窗口和转推都不中断，只在录制真正结束时关闭
+type liveOutputs struct {
+	// fanout 将探测代理转发的 FLV 数据分发给 HTTP-FLV 观看者
+	fanout *streamprobe.FanoutHub
+	// hls 将探测代理转发的 FLV 数据封装为 fMP4 HLS
+	hls *livehls.Stream
+
+	// mu 保护 gen：写入持读锁，切换上游连接持写锁
+	mu  sync.RWMutex
+	gen uint64
+
+	// relays RTMP 转推任务，首次成功启动探测代理时按房间配置创建
+	relaysMu      sync.Mutex
+	relays        []*rtmprelay.Relay
+	relaysStarted bool
+}
+
+func newLiveOutputs() *liveOutputs {
+	return &liveOutputs{
+		fanout: streamprobe.NewFanoutHub(),
+		hls:    livehls.New(livehls.Options{}),
+	}
+}
+
+// begin 在连接新的上游之前调用，清空起播缓存并返回本次连接的写入端
+// 分段重启时新旧录制器可能短暂并存，旧连接此后写入的数据会被丢弃，不会混入新的流
+func (o *liveOutputs) begin() *outputsWriter {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gen++
+	o.fanout.Reset()
+	o.hls.Reset()
+	return &outputsWriter{o: o, gen: o.gen}
+}
+
+// close 断开所有观看者并停止转推
+// 转推的 ffmpeg 可能需要数秒才能退出，在后台等待，调用方可能持有管理器的锁
+func (o *liveOutputs) close() {
+	o.relaysMu.Lock()
+	o.relaysStarted = true
+	relays := o.relays
+	o.relaysMu.Unlock()
+	if len(relays) > 0 {
+		bilisentry.Go(func() {
+			for _, relay := range relays {
+				relay.Stop()
+			}
+		})
+	}
+	o.fanout.Close()
+	o.hls.Close()
+}
+
+// outputsWriter 一次上游连接写入 liveOutputs 的入口，作为探测代理的 Tee
+type outputsWriter struct {
+	o   *liveOutputs
+	gen uint64
+}
+
+// Write 始终返回 len(p)，分发失败不影响录制
+func (w *outputsWriter) Write(p []byte) (int, error) {
+	w.o.mu.RLock()
+	defer w.o.mu.RUnlock()
+	if w.gen != w.o.gen {
+		return len(p), nil
+	}
+	w.o.fanout.Write(p)
+	w.o.hls.Write(p)
+	return len(p), nil
+}
+
+// inheritOutputs 分段重启时让新的录制器在启动前接管旧录制器的实时输出
+// 其他 Recorder 实现（如测试中的 mock）没有实时输出，直接忽略
+func inheritOutputs(next, prev Recorder) {
+	n, ok := next.(*recorder)
+	if !ok {
+		return
+	}
+	if p, ok := prev.(*recorder); ok {
+		n.outputs = p.outputs
+	}
+}
@@ -0,0 +1,85 @@
+package recorders
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/pkg/rtmprelay"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+func TestLiveOutputsDropsStaleConnection(t *testing.T) {
+	o := newLiveOutputs()
+	defer o.close()
+	sub, err := o.fanout.Subscribe()
+	require.NoError(t, err)
+
+	header := []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}
+	old := o.begin()
+	cur := o.begin()
+
+	// 分段重启时旧录制器仍可能写入，数据不应混入新的流
+	n, err := old.Write(header)
+	require.NoError(t, err)
+	assert.Equal(t, len(header), n)
+	assert.Empty(t, sub.C())
+
+	_, err = cur.Write(header)
+	require.NoError(t, err)
+	require.Len(t, sub.C(), 1)
+	assert.Equal(t, header, <-sub.C())
+}
+
+func TestInheritOutputsKeepsViewersAcrossRestart(t *testing.T) {
+	prev := &recorder{outputs: newLiveOutputs()}
+	next := &recorder{outputs: newLiveOutputs()}
+	inheritOutputs(next, prev)
+	assert.Same(t, prev.outputs, next.outputs)
+
+	sub, err := next.outputs.fanout.Subscribe()
+	require.NoError(t, err)
+
+	// 真正停止录制时断开观看者
+	next.outputs.close()
+	_, ok := <-sub.C()
+	assert.False(t, ok)
+	_, err = prev.outputs.fanout.Subscribe()
+	assert.ErrorIs(t, err, streamprobe.ErrFanoutClosed)
+
+	// mock 录制器没有实时输出，忽略
+	inheritOutputs(NewMockRecorder(nil), prev)
+}
+
+func TestLiveOutputsCloseDoesNotWaitForRelays(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("需要 sh")
+	}
+	// 子进程持有输出管道，ffmpeg 被杀掉后 Stop 仍要等待 WaitDelay
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nsleep 7 &\nwait\n"), 0o755))
+
+	o := newLiveOutputs()
+	relay := rtmprelay.New(o.fanout, script, "rtmp://127.0.0.1/live/key", logrus.New())
+	relay.Start(context.Background())
+	o.relays = append(o.relays, relay)
+	require.Eventually(t, func() bool {
+		return relay.Status().State == rtmprelay.StateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// close 可能在持有管理器锁时调用，不能等待转推退出
+	start := time.Now()
+	o.close()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Eventually(t, func() bool {
+		return relay.Status().State == rtmprelay.StateStopped
+	}, 10*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,193 @@
+package recorders
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// 排队原因
+const (
+	QueueReasonLimit     = "limit"     // 开播时已达到最大同时录制数
+	QueueReasonPreempted = "preempted" // 录制被更高优先级的直播间抢占
+)
+
+// QueuedRecording 因并发限制等待录制的直播间
+type QueuedRecording struct {
+	LiveID   types.LiveID `json:"live_id"`
+	Priority int          `json:"priority"`
+	Position int          `json:"position"` // 队列位置，从 1 开始
+	Reason   string       `json:"reason"`
+	QueuedAt time.Time    `json:"queued_at"`
+}
+
+// queuedLive 队列中的直播间
+type queuedLive struct {
+	live     live.Live
+	priority int
+	reason   string
+	queuedAt time.Time
+}
+
+// snapshot 生成对外展示的队列项
+func (q *queuedLive) snapshot(position int) QueuedRecording {
+	return QueuedRecording{
+		LiveID:   q.live.GetLiveId(),
+		Priority: q.priority,
+		Position: position,
+		Reason:   q.reason,
+		QueuedAt: q.queuedAt,
+	}
+}
+
+// getRoomPriority 读取直播间配置的录制优先级，未找到配置时为 0
+func getRoomPriority(l live.Live) int {
+	cfg := configs.GetCurrentConfig()
+	if cfg == nil {
+		return 0
+	}
+	room, err := cfg.GetLiveRoomByUrl(l.GetRawUrl())
+	if err != nil {
+		return 0
+	}
+	return room.Priority
+}
+
+// getMaxConcurrentRecordings 读取最大同时录制数，0 表示不限制
+func getMaxConcurrentRecordings() int {
+	if cfg := configs.GetCurrentConfig(); cfg != nil {
+		return cfg.MaxConcurrentRecordings
+	}
+	return 0
+}
+
+// hasFreeSlotLocked 是否还能开始新的录制，调用者必须已持有 m.lock
+func (m *manager) hasFreeSlotLocked() bool {
+	limit := getMaxConcurrentRecordings()
+	return limit <= 0 || len(m.savers) < limit
+}
+
+// admitLocked 判断开播的直播间能否立即开始录制，调用者必须已持有 m.lock
+// 达到上限时，若新直播间优先级高于正在录制的最低优先级直播间，则抢占该录制并将其放回队列；
+// 否则新直播间进入队列，返回 false。
+func (m *manager) admitLocked(l live.Live) bool {
+	if m.hasFreeSlotLocked() {
+		return true
+	}
+
+	priority := getRoomPriority(l)
+	victimID, victimPriority, ok := m.lowestPriorityRecordingLocked()
+	if ok && victimPriority < priority {
+		victim := m.lives[victimID]
+		l.GetLogger().Infof("已达到最大同时录制数，抢占优先级更低的录制 %s (优先级 %d < %d)", victimID, victimPriority, priority)
+		if err := m.stopRecorderLocked(victimID); err == nil && victim != nil {
+			m.enqueueLocked(victim, victimPriority, QueueReasonPreempted)
+			victim.GetLogger().Infof("录制被优先级更高的直播间 %s 抢占，进入等待队列", l.GetLiveId())
+		}
+		if m.hasFreeSlotLocked() {
+			return true
+		}
+	}
+
+	m.enqueueLocked(l, priority, QueueReasonLimit)
+	l.GetLogger().Infof("已达到最大同时录制数 %d，进入等待队列 (优先级 %d)", getMaxConcurrentRecordings(), priority)
+	return false
+}
+
+// lowestPriorityRecordingLocked 找出正在录制的直播间中优先级最低的一个
+// 优先级相同时选择最晚开始录制的，尽量保留已录制较久的内容
+func (m *manager) lowestPriorityRecordingLocked() (types.LiveID, int, bool) {
+	var (
+		victimID       types.LiveID
+		victimPriority int
+		victimStart    time.Time
+		found          bool
+	)
+	for id, rec := range m.savers {
+		l, ok := m.lives[id]
+		if !ok {
+			continue
+		}
+		priority := getRoomPriority(l)
+		start := rec.StartTime()
+		if !found || priority < victimPriority || (priority == victimPriority && start.After(victimStart)) {
+			victimID, victimPriority, victimStart, found = id, priority, start, true
+		}
+	}
+	return victimID, victimPriority, found
+}
+
+// enqueueLocked 按优先级插入队列（优先级高的在前，同优先级先到先得），调用者必须已持有 m.lock
+func (m *manager) enqueueLocked(l live.Live, priority int, reason string) {
+	for _, q := range m.queue {
+		if q.live.GetLiveId() == l.GetLiveId() {
+			return
+		}
+	}
+	m.queue = append(m.queue, &queuedLive{
+		live:     l,
+		priority: priority,
+		reason:   reason,
+		queuedAt: time.Now(),
+	})
+	sort.SliceStable(m.queue, func(i, j int) bool {
+		return m.queue[i].priority > m.queue[j].priority
+	})
+}
+
+// dequeueLocked 将直播间移出队列，返回是否在队列中，调用者必须已持有 m.lock
+func (m *manager) dequeueLocked(liveId types.LiveID) bool {
+	for i, q := range m.queue {
+		if q.live.GetLiveId() == liveId {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// drainQueueLocked 有空位时按优先级依次开始排队中的录制，调用者必须已持有 m.lock
+func (m *manager) drainQueueLocked(ctx context.Context) {
+	for len(m.queue) > 0 && m.hasFreeSlotLocked() {
+		next := m.queue[0]
+		m.queue = m.queue[1:]
+		if err := m.addRecorderLocked(ctx, next.live, nil); err != nil {
+			next.live.GetLogger().Errorf("排队结束后开始录制失败, err: %v", err)
+			continue
+		}
+		next.live.GetLogger().Infof("排队结束，开始录制 (等待 %s)", time.Since(next.queuedAt).Round(time.Second))
+	}
+}
+
+// drainQueue 检查等待队列，用于直播间移除或配置变更（例如调大上限）之后
+func (m *manager) drainQueue() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.drainQueueLocked(m.ctx)
+}
+
+// GetRecordingQueue 获取等待录制的直播间队列
+func (m *manager) GetRecordingQueue() []QueuedRecording {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	queue := make([]QueuedRecording, len(m.queue))
+	for i, q := range m.queue {
+		queue[i] = q.snapshot(i + 1)
+	}
+	return queue
+}
+
+// queuedStatus 排队中直播间的录制器状态
+func queuedStatus(q QueuedRecording) map[string]interface{} {
+	return map[string]interface{}{
+		"queued":         true,
+		"queue_position": q.Position,
+		"queue_reason":   q.Reason,
+		"queued_at":      q.QueuedAt.Unix(),
+		"priority":       q.Priority,
+	}
+}
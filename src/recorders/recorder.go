@@ -4,7 +4,9 @@ package recorders
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
@@ -22,16 +24,20 @@ import (
 
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/library"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/notify"
 	"github.com/bililive-go/bililive-go/src/pipeline"
 	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/filemover"
+	"github.com/bililive-go/bililive-go/src/pkg/livehls"
 	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
 	"github.com/bililive-go/bililive-go/src/pkg/parser"
 	"github.com/bililive-go/bililive-go/src/pkg/parser/bililive_recorder"
 	"github.com/bililive-go/bililive-go/src/pkg/parser/ffmpeg"
 	"github.com/bililive-go/bililive-go/src/pkg/parser/native/flv"
 	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+	"github.com/bililive-go/bililive-go/src/pkg/storagepool"
 	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
 )
@@ -194,6 +200,11 @@ type Recorder interface {
 	CloseForRestart() []notify.RecordingFileDetail
 	// SetInitialRecordedFiles 设置初始录制文件列表（从上一个 recorder 继承）
 	SetInitialRecordedFiles(files []notify.RecordingFileDetail)
+	// GetStreamFanout 获取 HTTP-FLV 分发器
+	// 仅在当前录制的是经过探测代理的 FLV 流时返回非 nil
+	GetStreamFanout() *streamprobe.FanoutHub
+	// GetLiveHLS 获取 fMP4 HLS 封装器，条件与 GetStreamFanout 相同
+	GetLiveHLS() *livehls.Stream
 }
 
 type recorder struct {
@@ -210,6 +221,8 @@ type recorder struct {
 	// 当前录制文件信息
 	currentFileLock sync.RWMutex
 	currentFilePath string
+	// 当前录制写入的根目录（启用存储池时为被选中的根目录）
+	currentOutputRoot string
 
 	// 当前录制的流信息（来自平台 API）
 	currentStreamInfo *live.AvailableStreamInfo
@@ -221,6 +234,19 @@ type recorder struct {
 	// 实际流头部信息（来自 StreamProbe 探测）
 	actualStreamInfo atomic.Pointer[streamprobe.StreamHeaderInfo]
 
+	// outputs HTTP-FLV 分发、HLS 和 RTMP 转推，跨重连保持，分段重启时交给新的录制器
+	outputs *liveOutputs
+	// keepOutputs 为 true 时 Close 不关闭 outputs（分段重启场景）
+	keepOutputs bool
+	// fanoutActive 当前录制是否正在向 outputs 写入数据
+	fanoutActive atomic.Bool
+	// upstreamBytes 经过探测代理的上游字节数，停滞检测据此区分上游停滞和下载器停滞
+	upstreamBytes byteCounter
+	// stall 录制停滞检测记录
+	stall stallState
+	// telemetry 流质量遥测，统计经过探测代理的 FLV 数据
+	telemetry *streamTelemetry
+
 	// 累积的录制文件信息，待录制结束后统一推送摘要
 	// recordedFilesMu 保护 recordedFiles 的并发访问：
 	// run() goroutine 中的 accumulateRecordedFiles 和 RestartRecorder 中的
@@ -246,6 +272,8 @@ func NewRecorder(ctx context.Context, live live.Live) (Recorder, error) {
 		stop:       make(chan struct{}),
 		done:       make(chan struct{}),
 		parserLock: new(sync.RWMutex),
+		outputs:    newLiveOutputs(),
+		telemetry:  newStreamTelemetry(),
 	}, nil
 }
 
@@ -304,8 +332,9 @@ func (r *recorder) tryRecord(ctx context.Context) {
 	if err = tmpl.Execute(buf, info); err != nil {
 		panic(fmt.Sprintf("failed to render filename, err: %v", err))
 	}
-	// 使用层级配置的 OutPutPath
-	fileName := filepath.Join(resolvedConfig.OutPutPath, buf.String())
+	// 使用层级配置的 OutPutPath；启用存储池时模板相对于被选中的根目录渲染
+	outputRoot := r.selectOutputRoot(cfg, resolvedConfig)
+	fileName := filepath.Join(outputRoot, buf.String())
 	outputPath, _ := filepath.Split(fileName)
 
 	// TODO 根据配置选择最佳流
@@ -318,6 +347,7 @@ func (r *recorder) tryRecord(ctx context.Context) {
 
 	// 保存原始流 URL 和 Headers（供前端调试展示）
 	r.currentFileLock.Lock()
+	r.currentOutputRoot = outputRoot
 	r.currentStreamURL = url.String()
 	r.currentStreamHeaders = streamInfo.HeadersForDownloader
 	r.currentFileLock.Unlock()
@@ -326,20 +356,43 @@ func (r *recorder) tryRecord(ctx context.Context) {
 		fileName = fileName[:len(fileName)-4] + ".ts"
 	}
 
-	if info.AudioOnly {
+	// 使用层级配置的下载器类型
+	downloaderType := resolvedConfig.Feature.GetEffectiveDownloaderType()
+	isFLVStream := strings.Contains(url.Path, ".flv")
+	if info.AudioOnly && isFLVStream {
+		// 只录音频的 FLV 流由原生解析器直接提取 AAC，不依赖 ffmpeg
+		downloaderType = configs.DownloaderNative
+	}
+	// 原生解析器可直接输出分片 MP4（只录音频时为 .m4a），省去录制后的转封装
+	nativeFMP4 := resolvedConfig.Feature.NativeOutputFormat == configs.NativeOutputFMP4 &&
+		resolveParserName(downloaderType, isFLVStream, nil) == flv.Name
+
+	switch {
+	case info.AudioOnly && nativeFMP4:
+		fileName = fileName[:strings.LastIndex(fileName, ".")] + ".m4a"
+	case info.AudioOnly:
 		fileName = fileName[:strings.LastIndex(fileName, ".")] + ".aac"
+	case nativeFMP4:
+		fileName = fileName[:strings.LastIndex(fileName, ".")] + ".mp4"
 	}
 
 	if err = mkdir(outputPath); err != nil {
 		r.getLogger().WithError(err).Errorf("failed to create output path[%s]", outputPath)
 		return
 	}
+
+	// 配置了临时目录时，分段先写入临时目录，关闭后再移动到最终输出路径
+	finalFileName := fileName
+	if resolvedConfig.ScratchPath != "" {
+		fileName = r.scratchFileName(resolvedConfig.ScratchPath, outputRoot, finalFileName)
+	}
 	parserCfg := map[string]string{
 		"timeout_in_us": strconv.Itoa(resolvedConfig.TimeoutInUs),
 		"audio_only":    strconv.FormatBool(info.AudioOnly),
 	}
-	// 使用层级配置的下载器类型
-	downloaderType := resolvedConfig.Feature.GetEffectiveDownloaderType()
+	if nativeFMP4 {
+		parserCfg["output_format"] = string(configs.NativeOutputFMP4)
+	}
 
 	// 如果启用了 FLV 代理分段且使用 FFmpeg 下载器，传递配置
 	if resolvedConfig.Feature.EnableFlvProxySegment && downloaderType == configs.DownloaderFFmpeg {
@@ -353,6 +406,10 @@ func (r *recorder) tryRecord(ctx context.Context) {
 	// 但 newParser 内部通过 URL 路径判断是否为 FLV 流来选择下载器类型。
 	// 如果用代理 URL 判断，所有 FLV 流都会被误判为"非 FLV"，导致 Native/录播姬下载器回退到 ffmpeg。
 	originalURL := url
+	proxied := false
+	// 本次连接的 context，停滞检测重启时取消，使探测代理和下载器的上游请求一并中止
+	attemptCtx, cancelAttempt := context.WithCancel(ctx)
+	defer cancelAttempt()
 	isFLV := streamprobe.IsStreamFLV(url)
 	if isFLV {
 		// FLV 流：启动探测代理
@@ -377,10 +434,13 @@ func (r *recorder) tryRecord(ctx context.Context) {
 				})
 			},
 			Logger: r.getLogger(),
+			// 新的上游连接会带来新的 FLV 头和序列头，begin 清空上一次连接的起播缓存
+			Tee: io.MultiWriter(r.outputs.begin(), &r.upstreamBytes, r.telemetry.monitor),
 		}
 
+		r.telemetry.monitor.Reset()
 		probe := streamprobe.New(probeConfig)
-		if probeErr := probe.Start(ctx); probeErr != nil {
+		if probeErr := probe.Start(attemptCtx); probeErr != nil {
 			// 探测代理启动失败不应影响录制，回退到直连上游
 			r.getLogger().WithError(probeErr).Warn("流探测代理启动失败，将直接连接上游")
 			r.actualStreamInfo.Store(&streamprobe.StreamHeaderInfo{
@@ -390,6 +450,10 @@ func (r *recorder) tryRecord(ctx context.Context) {
 		} else {
 			// 代理启动成功，用代理 URL 替换原始 URL
 			defer probe.Stop()
+			proxied = true
+			r.fanoutActive.Store(true)
+			defer r.fanoutActive.Store(false)
+			r.startRelays(ctx, room.RelayTargets)
 			streamInfo = &live.StreamUrlInfo{
 				Url:                  probe.LocalURL(),
 				HeadersForDownloader: nil, // 本地代理不需要 headers
@@ -449,72 +513,105 @@ func (r *recorder) tryRecord(ctx context.Context) {
 	r.setCurrentFilePath(fileName)
 
 	r.getLogger().Debugln("Start ParseLiveStream(" + url.String() + ", " + fileName + ")")
-	err = r.parser.ParseLiveStream(ctx, streamInfo, r.Live, fileName)
+	watchCtx, stopWatch := context.WithCancel(attemptCtx)
+	bilisentry.Go(func() {
+		r.watchStall(watchCtx, resolvedConfig.StallWatchdog, fileName, proxied, cancelAttempt)
+	})
+	err = r.parser.ParseLiveStream(attemptCtx, streamInfo, r.Live, fileName)
+	stopWatch()
+	if err != nil && attemptCtx.Err() != nil && ctx.Err() == nil {
+		// 停滞检测主动中止的连接，已写入的内容照常后处理
+		r.getLogger().WithError(err).Debug("下载器因录制停滞被中止")
+		err = nil
+	}
 
 	// 清除当前录制文件路径
 	r.setCurrentFilePath("")
 
+	seg := &finishedSegment{
+		cfg:            cfg,
+		resolvedConfig: resolvedConfig,
+		info:           info,
+		downloaderType: downloaderType,
+		fileName:       finalFileName,
+		startTime:      r.startTime,
+		streamInfo:     r.actualStreamInfo.Load(),
+	}
+
 	if err != nil {
 		r.getLogger().WithError(err).Error("failed to parse live stream")
+		if fileName != finalFileName {
+			// 录制出错时不做后处理，但仍需把已写入的内容移出临时目录
+			removeEmptyFile(fileName)
+			appCtx := backgroundContext(ctx)
+			bilisentry.Go(func() {
+				r.moveFromScratch(appCtx, resolvedConfig.ScratchPath, outputRoot, scratchOutputFiles(fileName))
+			})
+		}
 		return
 	}
 	r.getLogger().Debugln("End ParseLiveStream(" + url.String() + ", " + fileName + ")")
 	removeEmptyFile(fileName)
+	r.beginSessionSegment(ctx, seg)
+
+	if fileName != finalFileName {
+		// 移动可能需要较长时间（例如复制到 NAS），在后台完成移动和后处理，不阻塞下一个分段的录制。
+		// 摘要所需的文件名和大小在移动前后一致，先行累积，避免 run() 退出时遗漏。
+		files := scratchOutputFiles(fileName)
+		r.accumulateRecordedFiles(files...)
+		seg.accumulated = true
+		appCtx := backgroundContext(ctx)
+		bilisentry.Go(func() {
+			if r.moveFromScratch(appCtx, resolvedConfig.ScratchPath, outputRoot, files) {
+				r.postProcess(appCtx, seg)
+			} else {
+				r.skipSessionSegment(appCtx, seg)
+			}
+		})
+		return
+	}
+	r.postProcess(ctx, seg)
+}
+
+// finishedSegment 一次 ParseLiveStream 结束后需要后处理的分段
+type finishedSegment struct {
+	cfg            *configs.Config
+	resolvedConfig configs.ResolvedConfig
+	info           *live.Info
+	downloaderType configs.DownloaderType
+	fileName       string // 最终输出路径下的文件名
+	startTime      time.Time
+	streamInfo     *streamprobe.StreamHeaderInfo
+	accumulated    bool  // 文件信息是否已累积到录制摘要
+	sessionMerge   bool  // 是否已登记为会话分段，文件就绪后交给会话合并任务
+	sessionSeq     int64 // 会话分段序号，决定分段在合并任务中的顺序
+}
+
+// postProcess 执行录制结束后的动作：custom_commandline 或 Pipeline 后处理
+func (r *recorder) postProcess(ctx context.Context, seg *finishedSegment) {
+	cfg, resolvedConfig, info, fileName := seg.cfg, seg.resolvedConfig, seg.info, seg.fileName
+	downloaderType := seg.downloaderType
 
 	// 使用层级配置的 OnRecordFinished
+	// 配置了自定义管道时 custom_commandline 不再生效
 	cmdStr := strings.Trim(resolvedConfig.OnRecordFinished.CustomCommandline, "")
-	if len(cmdStr) > 0 {
+	if len(cmdStr) > 0 && pipeline.IsLegacyConfig(&resolvedConfig.OnRecordFinished) {
 		// 累积录制文件信息（legacy 路径），待录制结束后统一推送摘要
-		r.accumulateRecordedFiles(fileName)
-
-		ffmpegPath, ffmpegErr := utils.GetFFmpegPathForLive(ctx, r.Live)
-		if ffmpegErr != nil {
-			r.getLogger().WithError(ffmpegErr).Error("failed to find ffmpeg")
-			return
-		}
-		customTmpl, errCmdTmpl := template.New("custom_commandline").Funcs(utils.GetFuncMap(cfg)).Parse(cmdStr)
-		if errCmdTmpl != nil {
-			r.getLogger().WithError(errCmdTmpl).Error("custom commandline parse failure")
-			return
+		if !seg.accumulated {
+			r.accumulateRecordedFiles(fileName)
 		}
-
-		buf := new(bytes.Buffer)
-		if execErr := customTmpl.Execute(buf, struct {
-			*live.Info
-			FileName string
-			Ffmpeg   string
-		}{
-			Info:     info,
-			FileName: fileName,
-			Ffmpeg:   ffmpegPath,
-		}); execErr != nil {
-			r.getLogger().WithError(execErr).Errorln("failed to render custom commandline")
+		r.indexRecordedFiles(ctx, seg, fileName)
+		if configs.GetCurrentConfig().IntegritySidecar {
+			// 计算校验和需要读取整个文件，与 Pipeline 路径一样在后台完成后再执行命令，
+			// 保证附属文件在命令修改文件之前生成，且不阻塞下一个分段的录制
+			appCtx := backgroundContext(ctx)
+			bilisentry.Go(func() {
+				r.writeIntegritySidecars(appCtx, fileName)
+				r.runCustomCommandline(appCtx, cfg, resolvedConfig, info, cmdStr, fileName)
+			})
 			return
 		}
-		bash := ""
-		args := []string{}
-		switch runtime.GOOS {
-		case "linux":
-			bash = "sh"
-			args = []string{"-c"}
-		case "windows":
-			bash = "cmd"
-			args = []string{"/C"}
-		default:
-			r.getLogger().Warnln("Unsupport system ", runtime.GOOS)
-		}
-		args = append(args, buf.String())
-		r.getLogger().Debugf("start executing custom_commandline: %s", args[1])
-		cmd := exec.Command(bash, args...)
-		// 跟随全局 Debug 开关输出
-		cmd.Stdout = utils.NewDebugControlledWriter(os.Stdout)
-		cmd.Stderr = utils.NewDebugControlledWriter(os.Stderr)
-		if err = cmd.Run(); err != nil {
-			r.getLogger().WithError(err).Debugf("custom commandline execute failure (%s %s)\n", bash, strings.Join(args, " "))
-		} else if resolvedConfig.OnRecordFinished.DeleteFlvAfterConvert {
-			os.Remove(fileName)
-		}
-		r.getLogger().Debugf("end executing custom_commandline: %s", args[1])
+		r.runCustomCommandline(ctx, cfg, resolvedConfig, info, cmdStr, fileName)
 	} else {
 		// 使用新的 Pipeline 系统处理后处理任务
 		inst := instance.GetInstance(ctx)
@@ -533,8 +630,9 @@ func (r *recorder) tryRecord(ctx context.Context) {
 
 				// 单文件重命名逻辑：
 				// 1. 只有一个分段文件（_PART000）
-				// 2. 未启用 FixFlvAtFirst（因为录播姬会在修复时自动分段，修复后的文件名已经是正确的）
-				if len(partFiles) == 1 && !resolvedConfig.OnRecordFinished.FixFlvAtFirst {
+				// 2. 后处理不修复 FLV（因为录播姬会在修复时自动分段，修复后的文件名已经是正确的）
+				fixFlv := pipeline.GetEffectivePipelineConfig(&resolvedConfig.OnRecordFinished).HasStage(pipeline.StageNameFixFlv)
+				if len(partFiles) == 1 && !fixFlv {
 					originalFileName := fileName // 原始期望的文件名，不带 _PART000
 					partFileName := partFiles[0] // 录播姬实际输出的文件名，带 _PART000
 
@@ -558,34 +656,105 @@ func (r *recorder) tryRecord(ctx context.Context) {
 
 		if len(outputFiles) == 0 {
 			r.getLogger().Warn("没有找到任何输出文件，跳过后处理")
+			r.skipSessionSegment(ctx, seg)
 			return
 		}
 
 		// 累积录制文件信息，待录制结束后统一推送摘要
-		r.accumulateRecordedFiles(outputFiles...)
-
-		// 获取 PipelineManager
-		pipelineManager := pipeline.GetManager(inst)
-		if pipelineManager == nil {
-			r.getLogger().Warn("pipeline manager not available, skipping post-processing")
-			return
+		if !seg.accumulated {
+			r.accumulateRecordedFiles(outputFiles...)
 		}
+		r.indexRecordedFiles(ctx, seg, outputFiles...)
+
+		// 计算校验和需要读取整个文件，在后台完成后再入队 Pipeline 任务，
+		// 保证后处理修改文件之前附属文件已经生成，且不阻塞下一个分段的录制
+		appCtx := backgroundContext(ctx)
+		bilisentry.Go(func() {
+			r.writeIntegritySidecars(appCtx, outputFiles...)
+			if seg.sessionMerge {
+				r.addSessionSegment(inst, seg, info, outputFiles)
+			} else {
+				r.enqueuePipeline(inst, resolvedConfig, info, outputFiles, seg.startTime)
+			}
+		})
+	}
+}
 
-		// 将旧配置转换为 Pipeline 配置
-		pipelineConfig := pipeline.GetEffectivePipelineConfig(&resolvedConfig.OnRecordFinished)
+// runCustomCommandline 执行 custom_commandline
+func (r *recorder) runCustomCommandline(ctx context.Context, cfg *configs.Config, resolvedConfig configs.ResolvedConfig, info *live.Info, cmdStr, fileName string) {
+	ffmpegPath, ffmpegErr := utils.GetFFmpegPathForLive(ctx, r.Live)
+	if ffmpegErr != nil {
+		r.getLogger().WithError(ffmpegErr).Error("failed to find ffmpeg")
+		return
+	}
+	customTmpl, errCmdTmpl := template.New("custom_commandline").Funcs(utils.GetFuncMap(cfg)).Parse(cmdStr)
+	if errCmdTmpl != nil {
+		r.getLogger().WithError(errCmdTmpl).Error("custom commandline parse failure")
+		return
+	}
 
-		// 如果没有配置任何处理阶段，跳过
-		if len(pipelineConfig.Stages) == 0 {
-			r.getLogger().Debug("no pipeline stages configured, skipping post-processing")
-			return
-		}
+	buf := new(bytes.Buffer)
+	if execErr := customTmpl.Execute(buf, struct {
+		*live.Info
+		FileName string
+		Ffmpeg   string
+	}{
+		Info:     info,
+		FileName: fileName,
+		Ffmpeg:   ffmpegPath,
+	}); execErr != nil {
+		r.getLogger().WithError(execErr).Errorln("failed to render custom commandline")
+		return
+	}
+	bash := ""
+	args := []string{}
+	switch runtime.GOOS {
+	case "linux":
+		bash = "sh"
+		args = []string{"-c"}
+	case "windows":
+		bash = "cmd"
+		args = []string{"/C"}
+	default:
+		r.getLogger().Warnln("Unsupport system ", runtime.GOOS)
+	}
+	args = append(args, buf.String())
+	r.getLogger().Debugf("start executing custom_commandline: %s", args[1])
+	cmd := exec.Command(bash, args...)
+	// 跟随全局 Debug 开关输出
+	cmd.Stdout = utils.NewDebugControlledWriter(os.Stdout)
+	cmd.Stderr = utils.NewDebugControlledWriter(os.Stderr)
+	if err := cmd.Run(); err != nil {
+		r.getLogger().WithError(err).Debugf("custom commandline execute failure (%s %s)\n", bash, strings.Join(args, " "))
+	} else if resolvedConfig.OnRecordFinished.DeleteFlvAfterConvert {
+		os.Remove(fileName)
+	}
+	r.getLogger().Debugf("end executing custom_commandline: %s", args[1])
+}
 
-		// 入队 Pipeline 任务
-		if err := pipelineManager.EnqueueRecordingTask(info, pipelineConfig, outputFiles); err != nil {
-			r.getLogger().WithError(err).Error("failed to enqueue pipeline task")
-		} else {
-			r.getLogger().Infof("pipeline task enqueued: %d files, %d stages", len(outputFiles), len(pipelineConfig.Stages))
-		}
+// enqueuePipeline 按层级配置将录制文件加入 Pipeline 后处理队列
+func (r *recorder) enqueuePipeline(inst *instance.Instance, resolvedConfig configs.ResolvedConfig, info *live.Info, outputFiles []string, startTime time.Time) {
+	// 获取 PipelineManager
+	pipelineManager := pipeline.GetManager(inst)
+	if pipelineManager == nil {
+		r.getLogger().Warn("pipeline manager not available, skipping post-processing")
+		return
+	}
+
+	// 使用自定义管道，未配置时将旧配置转换为 Pipeline 配置
+	pipelineConfig := pipeline.GetEffectivePipelineConfig(&resolvedConfig.OnRecordFinished)
+
+	// 如果没有配置任何处理阶段，跳过
+	if len(pipelineConfig.Stages) == 0 {
+		r.getLogger().Debug("no pipeline stages configured, skipping post-processing")
+		return
+	}
+
+	// 入队 Pipeline 任务
+	if err := pipelineManager.EnqueueRecordingTask(info, pipelineConfig, outputFiles, startTime); err != nil {
+		r.getLogger().WithError(err).Error("failed to enqueue pipeline task")
+	} else {
+		r.getLogger().Infof("pipeline task enqueued: %d files, %d stages", len(outputFiles), len(pipelineConfig.Stages))
 	}
 }
 
@@ -642,6 +811,8 @@ func (r *recorder) selectPreferredStream(streamInfos []*live.StreamUrlInfo) (ret
 func (r *recorder) run(ctx context.Context) {
 	defer close(r.done)
 	defer r.sendAccumulatedSummary()
+	defer r.endPipelineSession(ctx)
+	defer r.startTelemetry(ctx)()
 
 	const minRetryInterval = 5 * time.Second
 
@@ -687,6 +858,33 @@ func (r *recorder) accumulateRecordedFiles(files ...string) {
 	}
 }
 
+// indexRecordedFiles 将录制完成的文件写入录播资料库
+func (r *recorder) indexRecordedFiles(ctx context.Context, seg *finishedSegment, files ...string) {
+	libraryManager := library.GetManager(instance.GetInstance(ctx))
+	if libraryManager == nil {
+		return
+	}
+	info := seg.info
+	recordedFiles := make([]library.RecordedFile, 0, len(files))
+	for _, f := range files {
+		rf := library.RecordedFile{
+			Path:        f,
+			LiveID:      string(r.Live.GetLiveId()),
+			Platform:    r.Live.GetPlatformCNName(),
+			PlatformKey: configs.GetPlatformKeyFromUrl(r.Live.GetRawUrl()),
+			HostName:    info.HostName,
+			RoomName:    info.RoomName,
+			StreamInfo:  seg.streamInfo,
+		}
+		// 录播姬分段输出时无法得知每段的开始时间，交由资料库根据文件时长推算
+		if len(files) == 1 {
+			rf.StartTime = seg.startTime
+		}
+		recordedFiles = append(recordedFiles, rf)
+	}
+	libraryManager.AddRecordedFiles(recordedFiles...)
+}
+
 // sendAccumulatedSummary 录制结束后统一推送录制文件摘要通知
 // 在 run() 退出时通过 defer 调用，确保所有分段文件汇总为一条通知
 func (r *recorder) sendAccumulatedSummary() {
@@ -715,11 +913,37 @@ func (r *recorder) sendAccumulatedSummary() {
 		resolved := cfg.ResolveConfigForRoom(room, platformKey)
 		outputPath = resolved.OutPutPath
 	}
+	r.currentFileLock.RLock()
+	if r.currentOutputRoot != "" {
+		outputPath = r.currentOutputRoot
+	}
+	r.currentFileLock.RUnlock()
 
 	r.getLogger().Infof("推送录制摘要：%d 个文件", len(r.recordedFiles))
 	notify.SendRecordingSummary(r.getLogger(), info.HostName, r.Live.GetPlatformCNName(), r.recordedFiles, outputPath)
 }
 
+// selectOutputRoot 选择本次录制的输出根目录
+// 未配置存储池时使用层级配置的 OutPutPath；pinned 根目录不可用时回退到 most_free，
+// 存储池整体不可用时回退到 OutPutPath，保证录制不中断
+func (r *recorder) selectOutputRoot(cfg *configs.Config, resolvedConfig configs.ResolvedConfig) string {
+	if !cfg.StoragePool.Enabled() {
+		return resolvedConfig.OutPutPath
+	}
+	placement := resolvedConfig.StoragePlacement
+	root, err := storagepool.Select(cfg.StoragePool, placement)
+	if errors.Is(err, storagepool.ErrPinnedRootUnavailable) {
+		r.getLogger().WithError(err).Warn("固定的存储根目录不可用，改为选择剩余空间最多的根目录")
+		root, err = storagepool.Select(cfg.StoragePool, configs.StoragePlacement{Policy: configs.PlacementMostFree})
+	}
+	if err != nil {
+		r.getLogger().WithError(err).Warnf("存储池选择失败，使用输出路径 %s", resolvedConfig.OutPutPath)
+		return resolvedConfig.OutPutPath
+	}
+	r.getLogger().Infof("存储池选择根目录 %s (%s)，策略: %s", root.Name, root.Path, placement.Policy)
+	return root.Path
+}
+
 func (r *recorder) getParser() parser.Parser {
 	r.parserLock.RLock()
 	defer r.parserLock.RUnlock()
@@ -777,12 +1001,17 @@ func (r *recorder) Close() {
 			r.getLogger().WithError(err).Warn("failed to end recorder")
 		}
 	}
+	if !r.keepOutputs {
+		r.outputs.close()
+	}
 	r.getLogger().Info("Record End")
 	r.ed.DispatchEvent(events.NewEvent(RecorderStop, r.Live))
 }
 
 func (r *recorder) CloseForRestart() []notify.RecordingFileDetail {
 	r.suppressSummary = true
+	// outputs 已由新的录制器继承，观看者和转推不随本录制器关闭
+	r.keepOutputs = true
 	r.Close()
 	<-r.done // 等待 run() 完全退出，确保最后一个文件已累积
 	r.recordedFilesMu.Lock()
@@ -919,6 +1148,31 @@ func (r *recorder) GetStatus() (map[string]interface{}, error) {
 	}
 	r.currentFileLock.RUnlock()
 
+	// HTTP-FLV 观看者数量
+	if r.fanoutActive.Load() {
+		status["stream_viewers"] = r.outputs.fanout.SubscriberCount()
+	}
+
+	// RTMP 转推状态
+	if relays := r.relayStatuses(); len(relays) > 0 {
+		status["relays"] = relays
+	}
+
+	// 录制停滞检测记录
+	if stalls := r.stall.status(); stalls != nil {
+		status["stalls"] = stalls
+	}
+
+	// 流质量遥测最近一次采样
+	if telemetry := r.telemetry.status(); telemetry != nil {
+		status["telemetry"] = telemetry
+	}
+
+	// 临时目录中尚未移动到输出目录的分段
+	if pending := filemover.GetGlobalMover().Pending(string(r.Live.GetLiveId())); len(pending) > 0 {
+		status["scratch_moves"] = pending
+	}
+
 	return status, nil
 }
 
@@ -948,6 +1202,22 @@ func (r *recorder) RequestSegment() bool {
 	return false
 }
 
+// GetStreamFanout 获取 HTTP-FLV 分发器，当前不是经过探测代理的 FLV 录制时返回 nil
+func (r *recorder) GetStreamFanout() *streamprobe.FanoutHub {
+	if !r.fanoutActive.Load() {
+		return nil
+	}
+	return r.outputs.fanout
+}
+
+// GetLiveHLS 获取 fMP4 HLS 封装器，当前不是经过探测代理的 FLV 录制时返回 nil
+func (r *recorder) GetLiveHLS() *livehls.Stream {
+	if !r.fanoutActive.Load() {
+		return nil
+	}
+	return r.outputs.hls
+}
+
 // HasFlvProxy 检查当前是否使用 FLV 代理
 func (r *recorder) HasFlvProxy() bool {
 	p := r.getParser()
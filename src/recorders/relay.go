@@ -0,0 +1,54 @@
+package recorders
+
+import (
+	"context"
+
+	"github.com/bililive-go/bililive-go/src/pkg/rtmprelay"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// startRelays 启动房间配置的 RTMP 转推，同一个直播间只启动一次
+// 转推从 FLV 分发器读取数据，分发器跨重连和分段重启保持，因此转推期间无需重启
+func (r *recorder) startRelays(ctx context.Context, targets []string) {
+	if len(targets) == 0 {
+		return
+	}
+	o := r.outputs
+	o.relaysMu.Lock()
+	defer o.relaysMu.Unlock()
+	if o.relaysStarted {
+		return
+	}
+	o.relaysStarted = true
+
+	ffmpegPath, err := utils.GetFFmpegPathForLive(ctx, r.Live)
+	if err != nil {
+		r.getLogger().WithError(err).Error("未找到 FFmpeg，无法启动 RTMP 转推")
+		return
+	}
+	// 转推的生命周期跟随直播间的输出，而不是单次 tryRecord
+	appCtx := backgroundContext(ctx)
+	for _, target := range targets {
+		if target == "" {
+			continue
+		}
+		relay := rtmprelay.New(o.fanout, ffmpegPath, target, r.getLogger())
+		relay.Start(appCtx)
+		o.relays = append(o.relays, relay)
+	}
+}
+
+// relayStatuses 返回所有 RTMP 转推的状态
+func (r *recorder) relayStatuses() []rtmprelay.Status {
+	o := r.outputs
+	o.relaysMu.Lock()
+	defer o.relaysMu.Unlock()
+	if len(o.relays) == 0 {
+		return nil
+	}
+	statuses := make([]rtmprelay.Status, 0, len(o.relays))
+	for _, relay := range o.relays {
+		statuses = append(statuses, relay.Status())
+	}
+	return statuses
+}
@@ -0,0 +1,68 @@
+package recorders
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/filemover"
+)
+
+// scratchFileName 计算分段在临时目录中的路径：保持相对于输出根目录的目录结构不变
+// 临时目录不可用时回退为直接写入最终路径
+func (r *recorder) scratchFileName(scratchPath, outputRoot, finalFileName string) string {
+	rel, err := filepath.Rel(outputRoot, finalFileName)
+	if err != nil || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+		r.getLogger().Warnf("无法计算 %s 在临时目录中的路径，直接写入输出目录", finalFileName)
+		return finalFileName
+	}
+	scratchFile := filepath.Join(scratchPath, rel)
+	if err := mkdir(filepath.Dir(scratchFile)); err != nil {
+		r.getLogger().WithError(err).Warnf("创建临时目录 %s 失败，直接写入输出目录", filepath.Dir(scratchFile))
+		return finalFileName
+	}
+	return scratchFile
+}
+
+// scratchOutputFiles 返回临时目录中本次录制实际产生的文件（包括录播姬的分段文件）
+func scratchOutputFiles(scratchFile string) []string {
+	var files []string
+	if _, err := os.Stat(scratchFile); err == nil {
+		files = append(files, scratchFile)
+	}
+	return append(files, findBililiveRecorderOutputFiles(scratchFile)...)
+}
+
+// moveFromScratch 将临时目录中已关闭的分段逐个移动到输出根目录下的对应位置
+// 移动失败会持续重试，直到成功或程序退出；全部成功时返回 true
+func (r *recorder) moveFromScratch(ctx context.Context, scratchPath, outputRoot string, files []string) bool {
+	mover := filemover.GetGlobalMover()
+	liveID := string(r.Live.GetLiveId())
+	ok := true
+	for _, src := range files {
+		rel, err := filepath.Rel(scratchPath, src)
+		if err != nil {
+			r.getLogger().WithError(err).Errorf("无法计算 %s 的目标路径", src)
+			ok = false
+			continue
+		}
+		dst := filepath.Join(outputRoot, rel)
+		if err := mover.Move(ctx, liveID, src, dst); err != nil {
+			r.getLogger().WithError(err).Errorf("分段未能移出临时目录，文件保留在 %s", src)
+			ok = false
+			continue
+		}
+		r.getLogger().Infof("分段已移动到输出目录: %s", dst)
+	}
+	return ok
+}
+
+// backgroundContext 返回不随本次录制结束而取消的应用级 context，用于后台移动和后处理
+func backgroundContext(ctx context.Context) context.Context {
+	if inst := instance.GetInstance(ctx); inst != nil && inst.Ctx != nil {
+		return inst.Ctx
+	}
+	return context.WithoutCancel(ctx)
+}
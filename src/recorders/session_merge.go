@@ -0,0 +1,71 @@
+package recorders
+
+import (
+	"context"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+)
+
+// sessionMergeEnabled 层级配置是否启用会话合并
+// 自定义管道中包含 session_merge 阶段时启用；旧配置下 custom_commandline 不经过 Pipeline，配置了自定义命令时不参与合并
+func sessionMergeEnabled(resolvedConfig configs.ResolvedConfig) bool {
+	finished := &resolvedConfig.OnRecordFinished
+	if !pipeline.IsLegacyConfig(finished) {
+		return pipeline.GetEffectivePipelineConfig(finished).HasStage(pipeline.StageNameSessionMerge)
+	}
+	return finished.SessionMerge.Enable && finished.CustomCommandline == ""
+}
+
+// beginSessionSegment 分段录制结束时登记到会话合并任务
+// 必须在 run() 退出之前同步调用，保证关播时所有分段都已登记，会话任务会等待它们的文件就绪
+func (r *recorder) beginSessionSegment(ctx context.Context, seg *finishedSegment) {
+	if !sessionMergeEnabled(seg.resolvedConfig) {
+		return
+	}
+	pm := pipeline.GetManager(instance.GetInstance(ctx))
+	if pm == nil {
+		return
+	}
+	seg.sessionSeq = pm.BeginSessionSegment(r.Live.GetLiveId(), seg.startTime)
+	seg.sessionMerge = true
+}
+
+// skipSessionSegment 登记的分段最终没有可用文件
+func (r *recorder) skipSessionSegment(ctx context.Context, seg *finishedSegment) {
+	if !seg.sessionMerge {
+		return
+	}
+	if pm := pipeline.GetManager(instance.GetInstance(ctx)); pm != nil {
+		pm.SkipSessionSegment(r.Live.GetLiveId(), seg.sessionSeq)
+	}
+}
+
+// addSessionSegment 把分段文件加入会话合并任务，关播后统一处理
+func (r *recorder) addSessionSegment(inst *instance.Instance, seg *finishedSegment, info *live.Info, outputFiles []string) {
+	pm := pipeline.GetManager(inst)
+	if pm == nil {
+		return
+	}
+	pipelineConfig := pipeline.GetEffectivePipelineConfig(&seg.resolvedConfig.OnRecordFinished)
+	if err := pm.AddSessionSegment(seg.sessionSeq, info, pipelineConfig, outputFiles); err != nil {
+		r.getLogger().WithError(err).Error("failed to add segment to session pipeline task")
+		return
+	}
+	r.getLogger().Infof("分段已加入会话合并任务: %d 个文件，关播后统一处理", len(outputFiles))
+}
+
+// endPipelineSession 录制结束时通知会话合并任务，分段重启不视为关播
+func (r *recorder) endPipelineSession(ctx context.Context) {
+	r.recordedFilesMu.Lock()
+	suppressed := r.suppressSummary
+	r.recordedFilesMu.Unlock()
+	if suppressed {
+		return
+	}
+	if pm := pipeline.GetManager(instance.GetInstance(ctx)); pm != nil {
+		pm.EndSession(r.Live.GetLiveId())
+	}
+}
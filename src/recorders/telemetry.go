@@ -0,0 +1,125 @@
+package recorders
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/library"
+	"github.com/bililive-go/bililive-go/src/live"
+	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
+	"github.com/bililive-go/bililive-go/src/pkg/streamprobe"
+)
+
+// telemetrySampleInterval 流质量采样周期
+const telemetrySampleInterval = 10 * time.Second
+
+// streamTelemetry 录制器的流质量遥测状态
+type streamTelemetry struct {
+	// monitor 挂在探测代理的 Tee 上，跨重连保持，统计整场录制
+	monitor *streamprobe.QualityMonitor
+	// id 资料库中的遥测记录ID，收到第一份数据时创建
+	id   atomic.Int64
+	last atomic.Pointer[streamprobe.QualitySample]
+}
+
+func newStreamTelemetry() *streamTelemetry {
+	return &streamTelemetry{monitor: streamprobe.NewQualityMonitor()}
+}
+
+// status 返回最近一次采样，供状态接口展示
+func (t *streamTelemetry) status() map[string]interface{} {
+	last := t.last.Load()
+	if last == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":     t.id.Load(),
+		"latest": last,
+	}
+}
+
+// startTelemetry 启动流质量采样，返回的函数在录制结束时调用，写入最后一次采样和汇总
+func (r *recorder) startTelemetry(ctx context.Context) (stop func()) {
+	lm := library.GetManager(instance.GetInstance(ctx))
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	bilisentry.Go(func() {
+		defer wg.Done()
+		ticker := time.NewTicker(telemetrySampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.sampleTelemetry(ctx, lm)
+			case <-stopCh:
+				// 录制结束时 ctx 可能已取消，最后一次采样和汇总仍需写入
+				ctx := context.WithoutCancel(ctx)
+				r.sampleTelemetry(ctx, lm)
+				r.finishTelemetry(ctx, lm)
+				return
+			}
+		}
+	})
+	return func() {
+		close(stopCh)
+		wg.Wait()
+	}
+}
+
+// sampleTelemetry 取出一个周期的指标并写入资料库
+// 在收到第一份数据之前不创建记录，未经过探测代理的录制（如 HLS）不会产生遥测记录
+func (r *recorder) sampleTelemetry(ctx context.Context, lm *library.Manager) {
+	t := r.telemetry
+	sample := t.monitor.Sample()
+	if t.last.Load() == nil && sample.Bytes == 0 {
+		return
+	}
+	t.last.Store(&sample)
+	if lm == nil {
+		return
+	}
+	if t.id.Load() == 0 {
+		rec := &library.Telemetry{
+			LiveID:    string(r.Live.GetLiveId()),
+			Platform:  r.Live.GetPlatformCNName(),
+			StartTime: sample.Time.Add(-time.Duration(sample.Duration * float64(time.Second))),
+		}
+		if obj, err := r.cache.Get(r.Live); err == nil {
+			info := obj.(*live.Info)
+			rec.HostName = info.HostName
+			rec.RoomName = info.RoomName
+		}
+		if err := lm.StartTelemetry(ctx, rec); err != nil {
+			r.getLogger().WithError(err).Warn("创建流质量遥测记录失败")
+			return
+		}
+		t.id.Store(rec.ID)
+	}
+	if err := lm.AddTelemetrySample(ctx, t.id.Load(), sample); err != nil {
+		r.getLogger().WithError(err).Debug("写入流质量采样失败")
+	}
+}
+
+// finishTelemetry 录制结束时输出并保存整场汇总
+func (r *recorder) finishTelemetry(ctx context.Context, lm *library.Manager) {
+	t := r.telemetry
+	sum := t.monitor.Summary()
+	if sum.Bytes == 0 {
+		return
+	}
+	r.getLogger().Infof("流质量汇总: 平均码率 %.0f kbps (%.0f~%.0f), 平均帧率 %.1f, 关键帧间隔 %.1fs (最大 %.1fs), "+
+		"时间戳跳变 %d 次 (最大 %d ms), 最大音画差 %d ms, 丢帧 %d, 损坏 tag %d, 重连 %d 次",
+		sum.AvgBitrateKbps, sum.MinBitrateKbps, sum.MaxBitrateKbps, sum.AvgFPS,
+		sum.AvgKeyframeInterval, sum.MaxKeyframeInterval, sum.TimestampGaps, sum.MaxGapMs,
+		sum.MaxAVDriftMs, sum.DroppedFrames, sum.CorruptTags, sum.Reconnects)
+	if lm == nil || t.id.Load() == 0 {
+		return
+	}
+	if err := lm.FinishTelemetry(ctx, t.id.Load(), time.Now(), sum); err != nil {
+		r.getLogger().WithError(err).Warn("保存流质量汇总失败")
+	}
+}
@@ -0,0 +1,217 @@
+package recorders
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pkg/parser"
+)
+
+// StallReason 录制停滞原因分类
+type StallReason string
+
+const (
+	// StallNoData 本次连接开始后一直没有写入任何数据
+	StallNoData StallReason = "no_data"
+	// StallUpstream 探测代理也没有收到上游数据，通常是直播源或 CDN 卡住
+	StallUpstream StallReason = "upstream_stalled"
+	// StallDownloader 上游仍在发送数据，但下载器没有写出，通常是下载器卡住
+	StallDownloader StallReason = "downloader_stalled"
+	// StallOutput 写入停止，且没有经过探测代理，无法判断上游状态
+	StallOutput StallReason = "output_stalled"
+)
+
+const (
+	// maxStallCheckInterval 停滞检测的最大采样间隔
+	maxStallCheckInterval = 5 * time.Second
+)
+
+// StallEvent 一次录制停滞
+type StallEvent struct {
+	Reason   StallReason         `json:"reason"`
+	Action   configs.StallAction `json:"action"`
+	Duration time.Duration       `json:"duration"`
+	At       time.Time           `json:"at"`
+}
+
+// byteCounter 统计经过探测代理的上游字节数，用于区分上游停滞和下载器停滞
+type byteCounter struct {
+	n atomic.Int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// stallWatchdog 跟踪单次连接的写入进度
+type stallWatchdog struct {
+	timeout time.Duration
+	// written 返回当前已写入的字节数
+	written func() int64
+	// upstream 返回经过探测代理的上游字节数，没有经过探测代理时返回 false
+	upstream func() (int64, bool)
+
+	lastWritten  int64
+	lastUpstream int64
+	lastProgress time.Time
+	everWritten  bool
+	alerted      bool
+}
+
+func newStallWatchdog(timeout time.Duration, written func() int64, upstream func() (int64, bool), now time.Time) *stallWatchdog {
+	w := &stallWatchdog{
+		timeout:      timeout,
+		written:      written,
+		upstream:     upstream,
+		lastProgress: now,
+	}
+	w.lastWritten = written()
+	w.lastUpstream, _ = upstream()
+	return w
+}
+
+// check 采样一次写入进度，停滞超过超时时返回停滞原因（同一次停滞只返回一次）
+func (w *stallWatchdog) check(now time.Time) (StallReason, bool) {
+	written := w.written()
+	if written != w.lastWritten {
+		// 任何变化都视为有进度（ffmpeg 分段后写入量可能从头计算）
+		w.lastWritten = written
+		w.lastUpstream, _ = w.upstream()
+		w.lastProgress = now
+		w.everWritten = w.everWritten || written > 0
+		w.alerted = false
+		return "", false
+	}
+	if w.alerted || now.Sub(w.lastProgress) < w.timeout {
+		return "", false
+	}
+	w.alerted = true
+	return w.classify(), true
+}
+
+func (w *stallWatchdog) classify() StallReason {
+	if !w.everWritten && w.lastWritten == 0 {
+		return StallNoData
+	}
+	upstream, ok := w.upstream()
+	switch {
+	case !ok:
+		return StallOutput
+	case upstream > w.lastUpstream:
+		return StallDownloader
+	default:
+		return StallUpstream
+	}
+}
+
+// stallState 录制器的停滞检测状态，供状态接口展示
+type stallState struct {
+	mu    sync.Mutex
+	count int
+	last  *StallEvent
+}
+
+func (s *stallState) record(e StallEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	s.last = &e
+}
+
+func (s *stallState) status() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"count": s.count,
+		"last":  s.last,
+	}
+}
+
+// watchStall 在下载器运行期间检测写入停滞，ctx 结束（本次连接结束）时退出
+// 停滞时通过 cancelAttempt 中止本次连接的上游请求，再停止下载器
+func (r *recorder) watchStall(ctx context.Context, cfg configs.StallWatchdog, fileName string, proxied bool, cancelAttempt context.CancelFunc) {
+	if cfg.TimeoutSec <= 0 {
+		return
+	}
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+	action := cfg.Action
+	if action == "" {
+		action = configs.StallActionRestart
+	}
+	upstream := func() (int64, bool) {
+		if !proxied {
+			return 0, false
+		}
+		return r.upstreamBytes.n.Load(), true
+	}
+	w := newStallWatchdog(timeout, func() int64 { return r.writtenBytes(fileName) }, upstream, time.Now())
+
+	interval := timeout / 4
+	if interval > maxStallCheckInterval {
+		interval = maxStallCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reason, stalled := w.check(now)
+			if !stalled {
+				continue
+			}
+			r.stall.record(StallEvent{Reason: reason, Action: action, Duration: now.Sub(w.lastProgress), At: now})
+			if action != configs.StallActionRestart {
+				r.getLogger().Warnf("录制停滞: %s 内没有写入数据（原因: %s）", timeout, reason)
+				continue
+			}
+			r.getLogger().Warnf("录制停滞: %s 内没有写入数据（原因: %s），停止下载器并重新获取直播流地址", timeout, reason)
+			cancelAttempt()
+			if p := r.getParser(); p != nil {
+				if err := p.Stop(); err != nil {
+					r.getLogger().WithError(err).Warn("停止下载器失败")
+				}
+			}
+			return
+		}
+	}
+}
+
+// writtenBytes 返回本次连接已写入的字节数
+// 优先使用下载器上报的写入量，其次是当前文件大小，录播姬按分段文件合计
+func (r *recorder) writtenBytes(fileName string) int64 {
+	var n int64
+	if sp, ok := r.getParser().(parser.StatusParser); ok {
+		if status, err := sp.Status(); err == nil && status != nil {
+			if s, ok := status["total_size"].(string); ok {
+				n, _ = strconv.ParseInt(s, 10, 64)
+			}
+		}
+	}
+	if fi, err := os.Stat(fileName); err == nil {
+		if fi.Size() > n {
+			n = fi.Size()
+		}
+		return n
+	}
+	var parts int64
+	for _, f := range findBililiveRecorderOutputFiles(fileName) {
+		if fi, err := os.Stat(f); err == nil {
+			parts += fi.Size()
+		}
+	}
+	if parts > n {
+		n = parts
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package recorders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStallWatchdogClassify(t *testing.T) {
+	start := time.Now()
+	var written, upstream int64
+	proxied := true
+	w := newStallWatchdog(10*time.Second,
+		func() int64 { return written },
+		func() (int64, bool) { return upstream, proxied },
+		start)
+
+	// 一直没有数据
+	_, stalled := w.check(start.Add(9 * time.Second))
+	assert.False(t, stalled)
+	reason, stalled := w.check(start.Add(10 * time.Second))
+	assert.True(t, stalled)
+	assert.Equal(t, StallNoData, reason)
+	// 同一次停滞只报告一次
+	_, stalled = w.check(start.Add(20 * time.Second))
+	assert.False(t, stalled)
+
+	// 恢复写入后，上游也没有数据
+	written, upstream = 100, 100
+	_, stalled = w.check(start.Add(21 * time.Second))
+	assert.False(t, stalled)
+	reason, stalled = w.check(start.Add(31 * time.Second))
+	assert.True(t, stalled)
+	assert.Equal(t, StallUpstream, reason)
+
+	// 上游仍有数据，但下载器没有写出
+	written = 200
+	w.check(start.Add(32 * time.Second))
+	upstream = 500
+	reason, stalled = w.check(start.Add(42 * time.Second))
+	assert.True(t, stalled)
+	assert.Equal(t, StallDownloader, reason)
+
+	// 没有经过探测代理时无法判断上游状态
+	proxied = false
+	written = 300
+	w.check(start.Add(43 * time.Second))
+	reason, stalled = w.check(start.Add(53 * time.Second))
+	assert.True(t, stalled)
+	assert.Equal(t, StallOutput, reason)
+}
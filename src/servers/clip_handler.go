@@ -0,0 +1,135 @@
+package servers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/library"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pipeline/stages"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// clipRange 截取的时间范围，时间可以是秒数或 [HH:]MM:SS[.mmm] 字符串
+type clipRange struct {
+	Start any `json:"start"`
+	End   any `json:"end"`
+}
+
+// clipRequest 截取请求，start/end 与 ranges 二选一
+type clipRequest struct {
+	clipRange
+	Ranges       []clipRange `json:"ranges"`
+	Mode         string      `json:"mode"`          // copy（默认）或 reencode
+	NameTemplate string      `json:"name_template"` // 输出文件名模板（不含扩展名）
+	Concat       bool        `json:"concat"`        // 多个范围是否拼接为一个文件
+}
+
+// clipTimeString 把请求中的时间转换为 clip 阶段接受的字符串
+func clipTimeString(v any) (string, error) {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case string:
+		if t != "" {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid time: %v", v)
+}
+
+// makeClipFileHandler 从录像中截取片段，作为 Pipeline 任务在后台执行
+// POST /api/file/{path}/clip
+func makeClipFileHandler(pm *pipeline.Manager, lm *library.Manager) http.HandlerFunc {
+	return func(writer http.ResponseWriter, r *http.Request) {
+		path := mux.Vars(r)["path"]
+
+		var body clipRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "无效请求"})
+			return
+		}
+		ranges := body.Ranges
+		if len(ranges) == 0 {
+			ranges = []clipRange{body.clipRange}
+		}
+		rangeOpts := make([]string, 0, len(ranges))
+		for _, cr := range ranges {
+			start, err := clipTimeString(cr.Start)
+			if err != nil {
+				writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "无效的开始时间"})
+				return
+			}
+			end, err := clipTimeString(cr.End)
+			if err != nil {
+				writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "无效的结束时间"})
+				return
+			}
+			rangeOpts = append(rangeOpts, start+"-"+end)
+		}
+
+		cfg := configs.GetCurrentConfig()
+		storagePaths, err := resolveStoragePaths(cfg, path)
+		if err != nil {
+			writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "无效或越权路径"})
+			return
+		}
+		// 同一相对路径存在于多个根目录时，以靠前的根目录为准，与文件列表一致
+		matches := existingStoragePaths(storagePaths)
+		if len(matches) == 0 {
+			writeJSON(writer, commonResp{ErrNo: 404, ErrMsg: "文件不存在"})
+			return
+		}
+		src := matches[0].Abs
+		info, err := os.Stat(src)
+		if err != nil || info.IsDir() {
+			writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "只能截取文件"})
+			return
+		}
+
+		options := map[string]any{
+			pipeline.OptionRanges: rangeOpts,
+			pipeline.OptionConcat: body.Concat,
+		}
+		if body.Mode != "" {
+			options[pipeline.OptionClipMode] = body.Mode
+		}
+		if body.NameTemplate != "" {
+			options[pipeline.OptionNameTemplate] = body.NameTemplate
+		}
+		stageConfig := pipeline.StageConfig{Name: pipeline.StageNameClip, Options: options}
+		// 截取阶段的工厂会检查时间范围、截取方式和文件名模板
+		if _, err := stages.NewClipStage(stageConfig); err != nil {
+			writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: err.Error()})
+			return
+		}
+		pipelineConfig := &pipeline.PipelineConfig{Stages: []pipeline.StageConfig{stageConfig}}
+
+		// 资料库中有记录时带上直播间信息，供文件名模板和任务列表使用
+		recordInfo := pipeline.RecordInfo{StartTime: info.ModTime()}
+		if lm != nil {
+			if rec, err := lm.GetStore().GetRecordingByPath(r.Context(), src); err == nil {
+				recordInfo = pipeline.RecordInfo{
+					LiveID:    types.LiveID(rec.LiveID),
+					Platform:  rec.Platform,
+					HostName:  rec.HostName,
+					RoomName:  rec.RoomName,
+					StartTime: rec.StartTime,
+				}
+			}
+		}
+
+		task := pipeline.NewPipelineTask(recordInfo, pipelineConfig, []pipeline.FileInfo{pipeline.NewVideoFileInfo(src)})
+		if err := pm.EnqueueTask(task); err != nil {
+			writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: err.Error()})
+			return
+		}
+		writeJSON(writer, commonResp{Data: task})
+	}
+}
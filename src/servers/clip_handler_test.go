@@ -0,0 +1,93 @@
+package servers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pipeline"
+	"github.com/bililive-go/bililive-go/src/pipeline/stages"
+)
+
+func TestClipFileHandlerValidation(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "rec.flv"), []byte("flv"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0o755))
+	cfg := configs.NewConfig()
+	cfg.OutPutPath = root
+	backup := configs.GetCurrentConfig()
+	configs.SetCurrentConfig(cfg)
+	defer configs.SetCurrentConfig(backup)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pm := pipeline.NewManager(ctx, pipeline.NewMemoryStore(), nil, nil)
+	pm.RegisterStage(pipeline.StageNameClip, stages.NewClipStage)
+	handler := makeClipFileHandler(pm, nil)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		wantErr int
+		wantMsg string
+	}{
+		{name: "请求无效", path: "rec.flv", body: `{`, wantErr: 400, wantMsg: "无效请求"},
+		{name: "缺少开始时间", path: "rec.flv", body: `{"end": 10}`, wantErr: 400, wantMsg: "无效的开始时间"},
+		{name: "开始时间为空", path: "rec.flv", body: `{"start": "", "end": 10}`, wantErr: 400, wantMsg: "无效的开始时间"},
+		{name: "结束时间类型错误", path: "rec.flv", body: `{"start": 0, "end": true}`, wantErr: 400, wantMsg: "无效的结束时间"},
+		{name: "多个范围中有无效时间", path: "rec.flv", body: `{"ranges": [{"start": 0, "end": 10}, {"start": 20}]}`, wantErr: 400, wantMsg: "无效的结束时间"},
+		{name: "越权路径", path: "../rec.flv", body: `{"start": 0, "end": 10}`, wantErr: 400, wantMsg: "无效或越权路径"},
+		{name: "文件不存在", path: "missing.flv", body: `{"start": 0, "end": 10}`, wantErr: 404, wantMsg: "文件不存在"},
+		{name: "目录", path: "dir", body: `{"start": 0, "end": 10}`, wantErr: 400, wantMsg: "只能截取文件"},
+		{name: "结束早于开始", path: "rec.flv", body: `{"start": "00:01:00", "end": 30}`, wantErr: 400, wantMsg: "end must be after start"},
+		{name: "时间格式错误", path: "rec.flv", body: `{"start": "00:61", "end": "01:10"}`, wantErr: 400, wantMsg: "invalid time"},
+		{name: "未知截取方式", path: "rec.flv", body: `{"start": 0, "end": 10, "mode": "fast"}`, wantErr: 400, wantMsg: "unsupported mode"},
+		{name: "文件名模板无效", path: "rec.flv", body: `{"start": 0, "end": 10, "name_template": "{{ .FileName"}`, wantErr: 400, wantMsg: "invalid name template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/file/"+tt.path+"/clip", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"path": tt.path})
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			var resp commonResp
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+			assert.Equal(t, tt.wantErr, resp.ErrNo)
+			assert.Contains(t, resp.ErrMsg, tt.wantMsg)
+		})
+	}
+
+	t.Run("创建截取任务", func(t *testing.T) {
+		body := `{"ranges": [{"start": 10, "end": 20.5}, {"start": "00:01:00", "end": "00:01:30"}], "concat": true}`
+		req := httptest.NewRequest(http.MethodPost, "/api/file/rec.flv/clip", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"path": "rec.flv"})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		var resp struct {
+			ErrNo int                   `json:"err_no"`
+			Data  pipeline.PipelineTask `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+		require.Equal(t, 0, resp.ErrNo, rec.Body.String())
+		require.Len(t, resp.Data.PipelineConfig.Stages, 1)
+		stage := resp.Data.PipelineConfig.Stages[0]
+		assert.Equal(t, pipeline.StageNameClip, stage.Name)
+		assert.Equal(t, []string{"10-20.5", "00:01:00-00:01:30"}, stage.GetStringSliceOption(pipeline.OptionRanges))
+		assert.True(t, stage.GetBoolOption(pipeline.OptionConcat, false))
+		require.Len(t, resp.Data.InitialFiles, 1)
+		assert.Equal(t, filepath.Join(root, "rec.flv"), resp.Data.InitialFiles[0].Path)
+	})
+}
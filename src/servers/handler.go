@@ -31,6 +31,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/livestate"
 	applog "github.com/bililive-go/bililive-go/src/log"
+	"github.com/bililive-go/bililive-go/src/pipeline/stages"
 	"github.com/bililive-go/bililive-go/src/pkg/livelogger"
 	"github.com/bililive-go/bililive-go/src/pkg/memstats"
 	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
@@ -71,6 +72,8 @@ func parseInfo(ctx context.Context, l live.Live) *live.Info {
 	// 否则前一次调用的残留值会导致 recording=true + recording_preparing=true 同时返回
 	info.Recording = false
 	info.RecordingPreparing = false
+	info.RecordingQueued = false
+	info.QueuePosition = 0
 	recorderMgr := inst.RecorderManager.(recorders.Manager)
 	if recorderMgr.HasRecorder(ctx, l.GetLiveId()) {
 		if recorder, err := recorderMgr.GetRecorder(ctx, l.GetLiveId()); err == nil && recorder.IsRecording() {
@@ -79,6 +82,15 @@ func parseInfo(ctx context.Context, l live.Live) *live.Info {
 			// 有 recorder 但尚未真正开始录制（例如流 URL 404 导致不断重试）
 			info.RecordingPreparing = true
 		}
+	} else {
+		// 因最大同时录制数限制在等待队列中
+		for _, q := range recorderMgr.GetRecordingQueue() {
+			if q.LiveID == l.GetLiveId() {
+				info.RecordingQueued = true
+				info.QueuePosition = q.Position
+				break
+			}
+		}
 	}
 	if info.HostName == "" {
 		info.HostName = "获取失败"
@@ -230,12 +242,14 @@ func getLive(writer http.ResponseWriter, r *http.Request) {
 		"platform":            info.Live.GetPlatformCNName(),
 
 		// 有效配置信息
-		"platform_key":          platformKey,
-		"effective_interval":    resolvedConfig.Interval,
-		"effective_out_path":    resolvedConfig.OutPutPath,
-		"effective_ffmpeg_path": resolvedConfig.FfmpegPath,
-		"quality":               room.Quality,
-		"audio_only":            room.AudioOnly,
+		"platform_key":                platformKey,
+		"effective_interval":          resolvedConfig.Interval,
+		"effective_out_path":          resolvedConfig.OutPutPath,
+		"effective_storage_placement": resolvedConfig.StoragePlacement,
+		"effective_stall_watchdog":    resolvedConfig.StallWatchdog,
+		"effective_ffmpeg_path":       resolvedConfig.FfmpegPath,
+		"quality":                     room.Quality,
+		"audio_only":                  room.AudioOnly,
 
 		// 平台访问限制
 		"platform_rate_limit": cfg.GetPlatformMinAccessInterval(platformKey),
@@ -839,6 +853,13 @@ func putRawConfig(writer http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if err := stages.ValidateRecordFinishedConfig(newConfig); err != nil {
+		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+			ErrNo:  http.StatusBadRequest,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
 	oldConfig := configs.GetCurrentConfig()
 	oldConfig.RefreshLiveRoomIndexCache()
 	// 继承原配置的文件路径
@@ -1052,8 +1073,12 @@ func getPlatformStats(writer http.ResponseWriter, r *http.Request) {
 			"quality":      room.Quality,
 			"audio_only":   room.AudioOnly,
 			"nick_name":    room.NickName,
+			"priority":     room.Priority,
 			"live_id":      string(room.LiveId),
 		}
+		if len(room.RelayTargets) > 0 {
+			roomInfo["relay_targets"] = room.RelayTargets
+		}
 
 		// 从缓存获取直播间信息（不触发网络请求）
 		if liveInstance, ok := inst.Lives.Get(room.LiveId); ok {
@@ -1343,7 +1368,10 @@ func updateConfig(writer http.ResponseWriter, r *http.Request) {
 			return err
 		}
 		// 校验配置
-		return c.Verify()
+		if err := c.Verify(); err != nil {
+			return err
+		}
+		return stages.ValidateRecordFinishedConfig(c)
 	}, 3, 10*time.Millisecond)
 
 	if err != nil {
@@ -1390,6 +1418,9 @@ func applyConfigUpdates(c *configs.Config, updates map[string]interface{}) error
 	if timeoutSec, ok := updates["timeout_in_seconds"].(float64); ok {
 		c.TimeoutInUs = int(timeoutSec * 1000000)
 	}
+	if watchdog, ok := updates["stall_watchdog"].(map[string]interface{}); ok {
+		applyStallWatchdogUpdates(&c.StallWatchdog, watchdog)
+	}
 	if appDataPath, ok := updates["app_data_path"].(string); ok {
 		c.AppDataPath = appDataPath
 	}
@@ -1427,6 +1458,9 @@ func applyConfigUpdates(c *configs.Config, updates map[string]interface{}) error
 		if removeSymbolOther, ok := feature["remove_symbol_other_character"].(bool); ok {
 			c.Feature.RemoveSymbolOtherCharacter = removeSymbolOther
 		}
+		if format, ok := feature["native_output_format"].(string); ok {
+			c.Feature.NativeOutputFormat = configs.NativeOutputFormat(format)
+		}
 	}
 
 	// 处理视频分割策略
@@ -1723,6 +1757,12 @@ func updateRoomConfigById(writer http.ResponseWriter, r *http.Request) {
 		if nickName, ok := updates["nick_name"].(string); ok {
 			room.NickName = nickName
 		}
+		if priority, ok := updates["priority"].(float64); ok {
+			room.Priority = int(priority)
+		}
+		if targets, ok := parseStringList(updates["relay_targets"]); ok {
+			room.RelayTargets = targets
+		}
 
 		// 更新可覆盖配置
 		applyOverridableConfigUpdates(&room.OverridableConfig, updates)
@@ -1743,6 +1783,21 @@ func updateRoomConfigById(writer http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// parseStringList 解析 JSON 请求中的字符串数组，忽略空字符串
+func parseStringList(v interface{}) ([]string, bool) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if str, ok := item.(string); ok && strings.TrimSpace(str) != "" {
+			list = append(list, strings.TrimSpace(str))
+		}
+	}
+	return list, true
+}
+
 // applyOverridableConfigUpdates 统一处理可覆盖配置的更新
 func applyOverridableConfigUpdates(oc *configs.OverridableConfig, updates map[string]interface{}) {
 	if interval, ok := updates["interval"].(float64); ok {
@@ -1789,6 +1844,9 @@ func applyOverridableConfigUpdates(oc *configs.OverridableConfig, updates map[st
 		if enableFlvProxySegment, ok := feature["enable_flv_proxy_segment"].(bool); ok {
 			oc.Feature.EnableFlvProxySegment = enableFlvProxySegment
 		}
+		if format, ok := feature["native_output_format"].(string); ok && configs.NativeOutputFormat(format).IsValid() {
+			oc.Feature.NativeOutputFormat = configs.NativeOutputFormat(format)
+		}
 	}
 
 	// 也支持直接在顶层设置 downloader_type（简化前端逻辑）
@@ -1838,6 +1896,28 @@ func applyOverridableConfigUpdates(oc *configs.OverridableConfig, updates map[st
 			oc.StreamPreference = nil
 		}
 	}
+
+	// 处理录制停滞检测配置，null 表示继承上一级配置
+	if v, exists := updates["stall_watchdog"]; exists {
+		if watchdog, ok := v.(map[string]interface{}); ok {
+			if oc.StallWatchdog == nil {
+				oc.StallWatchdog = &configs.StallWatchdog{}
+			}
+			applyStallWatchdogUpdates(oc.StallWatchdog, watchdog)
+		} else if v == nil {
+			oc.StallWatchdog = nil
+		}
+	}
+}
+
+// applyStallWatchdogUpdates 应用录制停滞检测配置的更新
+func applyStallWatchdogUpdates(w *configs.StallWatchdog, updates map[string]interface{}) {
+	if timeoutSec, ok := updates["timeout_sec"].(float64); ok {
+		w.TimeoutSec = int(timeoutSec)
+	}
+	if action, ok := updates["action"].(string); ok {
+		w.Action = configs.StallAction(action)
+	}
 }
 
 // updateRoomConfig 更新直播间配置
@@ -1889,6 +1969,12 @@ func updateRoomConfig(writer http.ResponseWriter, r *http.Request) {
 		if nickName, ok := updates["nick_name"].(string); ok {
 			room.NickName = nickName
 		}
+		if priority, ok := updates["priority"].(float64); ok {
+			room.Priority = int(priority)
+		}
+		if targets, ok := parseStringList(updates["relay_targets"]); ok {
+			room.RelayTargets = targets
+		}
 		if interval, ok := updates["interval"].(float64); ok {
 			val := int(interval)
 			room.Interval = &val
@@ -1956,7 +2042,7 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 	path := vars["path"]
 
 	cfg := configs.GetCurrentConfig()
-	absPath, err := getSafePath(cfg.OutPutPath, path)
+	storagePaths, err := resolveStoragePaths(cfg, path)
 	if err != nil {
 		writeJSON(writer, commonResp{
 			ErrMsg: "无效或越权路径",
@@ -1964,40 +2050,62 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := os.ReadDir(absPath)
-	if err != nil {
+	type jsonFile struct {
+		IsFolder     bool   `json:"is_folder"`
+		Name         string `json:"name"`
+		LastModified int64  `json:"last_modified"`
+		Size         int64  `json:"size"`
+	}
+	// 合并所有存储根目录下同一相对路径的内容；同名文件夹合并显示，同名文件以靠前的根目录为准
+	merged := make(map[string]*jsonFile)
+	found := false
+	for _, sp := range storagePaths {
+		files, err := os.ReadDir(sp.Abs)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			if existing, ok := merged[file.Name()]; ok {
+				if existing.IsFolder && file.IsDir() && info.ModTime().Unix() > existing.LastModified {
+					existing.LastModified = info.ModTime().Unix()
+				}
+				continue
+			}
+			f := &jsonFile{
+				IsFolder:     file.IsDir(),
+				Name:         file.Name(),
+				LastModified: info.ModTime().Unix(),
+			}
+			if !file.IsDir() {
+				f.Size = info.Size()
+			}
+			merged[file.Name()] = f
+		}
+	}
+	if !found {
 		writeJSON(writer, commonResp{
 			ErrMsg: "获取目录失败",
 		})
 		return
 	}
 
-	type jsonFile struct {
-		IsFolder     bool   `json:"is_folder"`
-		Name         string `json:"name"`
-		LastModified int64  `json:"last_modified"`
-		Size         int64  `json:"size"`
+	jsonFiles := make([]jsonFile, 0, len(merged))
+	for _, f := range merged {
+		jsonFiles = append(jsonFiles, *f)
 	}
-	jsonFiles := make([]jsonFile, len(files))
+	sort.Slice(jsonFiles, func(i, j int) bool { return jsonFiles[i].Name < jsonFiles[j].Name })
 	json := struct {
 		Files []jsonFile `json:"files"`
 		Path  string     `json:"path"`
 	}{
-		Path: path,
-	}
-	for i, file := range files {
-		info, err := file.Info()
-		if err != nil {
-			continue
-		}
-		jsonFiles[i].IsFolder = file.IsDir()
-		jsonFiles[i].Name = file.Name()
-		jsonFiles[i].LastModified = info.ModTime().Unix()
-		if !file.IsDir() {
-			jsonFiles[i].Size = info.Size()
-		}
+		Files: jsonFiles,
+		Path:  path,
 	}
-	json.Files = jsonFiles
 
 	writeJSON(writer, json)
 }
@@ -2051,48 +2159,54 @@ func renameFile(writer http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg := configs.GetCurrentConfig()
-	oldAbsPath, err := getSafePath(cfg.OutPutPath, path)
+	storagePaths, err := resolveStoragePaths(cfg, path)
 	if err != nil {
 		writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "无效或越权路径"})
 		return
 	}
 
-	info, err := os.Stat(oldAbsPath)
-	if err != nil {
+	// 同一相对路径可能存在于多个存储根目录下，需要在每个根目录中分别重命名
+	matches := existingStoragePaths(storagePaths)
+	if len(matches) == 0 {
 		writeJSON(writer, commonResp{ErrNo: 404, ErrMsg: "文件不存在"})
 		return
 	}
 
-	var newAbsPath string
-	baseDir := filepath.Dir(oldAbsPath)
-	if info.IsDir() {
-		newAbsPath = filepath.Join(baseDir, body.NewName)
-	} else {
-		ext := filepath.Ext(oldAbsPath)
-		newAbsPath = filepath.Join(baseDir, body.NewName+ext)
-	}
+	newAbsPaths := make([]string, len(matches))
+	for i, match := range matches {
+		info, err := os.Stat(match.Abs)
+		if err != nil {
+			writeJSON(writer, commonResp{ErrNo: 404, ErrMsg: "文件不存在"})
+			return
+		}
 
-	// 重点：必须再次校验新路径是否安全，防止 body.NewName 包含 ../ 等逃逸字符
-	base, err := filepath.Abs(cfg.OutPutPath)
-	if err != nil {
-		writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "获取根目录绝对路径失败: " + err.Error()})
-		return
-	}
-	rel, err := filepath.Rel(base, newAbsPath)
-	if err != nil || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
-		writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "非法的新文件名：禁止越界路径"})
-		return
-	}
+		baseDir := filepath.Dir(match.Abs)
+		if info.IsDir() {
+			newAbsPaths[i] = filepath.Join(baseDir, body.NewName)
+		} else {
+			ext := filepath.Ext(match.Abs)
+			newAbsPaths[i] = filepath.Join(baseDir, body.NewName+ext)
+		}
 
-	// 检查目标文件名是否已存在
-	if _, err := os.Stat(newAbsPath); err == nil {
-		writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "重命名失败：目标文件名已存在"})
-		return
+		// 重点：必须再次校验新路径是否安全，防止 body.NewName 包含 ../ 等逃逸字符
+		if !isInsideBase(match.Base, newAbsPaths[i]) {
+			writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "非法的新文件名：禁止越界路径"})
+			return
+		}
+
+		// 检查目标文件名是否已存在
+		if _, err := os.Stat(newAbsPaths[i]); err == nil {
+			writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "重命名失败：目标文件名已存在"})
+			return
+		}
 	}
 
-	if err := os.Rename(oldAbsPath, newAbsPath); err != nil {
-		writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "重命名失败: " + translateOSError(err)})
-		return
+	for i, match := range matches {
+		if err := os.Rename(match.Abs, newAbsPaths[i]); err != nil {
+			writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "重命名失败: " + translateOSError(err)})
+			return
+		}
+		renameIntegritySidecars(match.Abs, newAbsPaths[i])
 	}
 
 	writeJSON(writer, commonResp{Data: "OK"})
@@ -2103,18 +2217,13 @@ func deleteFile(writer http.ResponseWriter, r *http.Request) {
 	path := vars["path"]
 
 	cfg := configs.GetCurrentConfig()
-	base, err := filepath.Abs(cfg.OutPutPath)
-	if err != nil {
-		writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "获取根目录绝对路径失败: " + err.Error()})
-		return
-	}
-	absPath, err := getSafePath(cfg.OutPutPath, path)
-	if err != nil || absPath == base {
+	storagePaths, err := resolveStoragePaths(cfg, path)
+	if err != nil || isStorageRootPath(storagePaths) {
 		writeJSON(writer, commonResp{ErrNo: 400, ErrMsg: "禁止删除根目录或无效/越权路径"})
 		return
 	}
 
-	if err := os.RemoveAll(absPath); err != nil {
+	if err := removeStoragePaths(storagePaths); err != nil {
 		writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "删除失败: " + translateOSError(err)})
 		return
 	}
@@ -2134,11 +2243,6 @@ func batchRenameFiles(writer http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg := configs.GetCurrentConfig()
-	base, err := filepath.Abs(cfg.OutPutPath)
-	if err != nil {
-		writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "获取根目录绝对路径失败: " + err.Error()})
-		return
-	}
 
 	type Result struct {
 		Path    string `json:"path"`
@@ -2148,19 +2252,25 @@ func batchRenameFiles(writer http.ResponseWriter, r *http.Request) {
 	results := make([]Result, 0, len(body.Paths))
 
 	for _, path := range body.Paths {
-		oldAbsPath, err := getSafePath(cfg.OutPutPath, path)
+		storagePaths, err := resolveStoragePaths(cfg, path)
 		if err != nil {
 			results = append(results, Result{Path: path, Success: false, Message: "无效或越权路径"})
 			continue
 		}
 
-		info, err := os.Stat(oldAbsPath)
+		matches := existingStoragePaths(storagePaths)
+		if len(matches) == 0 {
+			results = append(results, Result{Path: path, Success: false, Message: "文件不存在"})
+			continue
+		}
+
+		info, err := os.Stat(matches[0].Abs)
 		if err != nil {
 			results = append(results, Result{Path: path, Success: false, Message: "文件不存在"})
 			continue
 		}
 
-		oldName := filepath.Base(oldAbsPath)
+		oldName := filepath.Base(matches[0].Abs)
 		var newName string
 		if info.IsDir() {
 			newName = strings.ReplaceAll(oldName, body.Find, body.Replace)
@@ -2176,23 +2286,34 @@ func batchRenameFiles(writer http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		newAbsPath := filepath.Join(filepath.Dir(oldAbsPath), newName)
-
-		// 二次校验新路径
-		rel, err := filepath.Rel(base, newAbsPath)
-		if err != nil || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
-			results = append(results, Result{Path: path, Success: false, Message: "目标名越界"})
-			continue
+		// 二次校验新路径，并检查每个根目录下目标文件名是否已存在
+		message := ""
+		for _, match := range matches {
+			newAbsPath := filepath.Join(filepath.Dir(match.Abs), newName)
+			if !isInsideBase(match.Base, newAbsPath) {
+				message = "目标名越界"
+				break
+			}
+			if _, err := os.Stat(newAbsPath); err == nil {
+				message = "目标已存在"
+				break
+			}
 		}
-
-		// 检查目标文件名是否已存在
-		if _, err := os.Stat(newAbsPath); err == nil {
-			results = append(results, Result{Path: path, Success: false, Message: "目标已存在"})
+		if message != "" {
+			results = append(results, Result{Path: path, Success: false, Message: message})
 			continue
 		}
 
-		if err := os.Rename(oldAbsPath, newAbsPath); err != nil {
-			results = append(results, Result{Path: path, Success: false, Message: translateOSError(err)})
+		var renameErr error
+		for _, match := range matches {
+			newAbsPath := filepath.Join(filepath.Dir(match.Abs), newName)
+			if renameErr = os.Rename(match.Abs, newAbsPath); renameErr != nil {
+				break
+			}
+			renameIntegritySidecars(match.Abs, newAbsPath)
+		}
+		if renameErr != nil {
+			results = append(results, Result{Path: path, Success: false, Message: translateOSError(renameErr)})
 		} else {
 			results = append(results, Result{Path: path, Success: true, Message: "成功"})
 		}
@@ -2211,11 +2332,6 @@ func batchDeleteFiles(writer http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg := configs.GetCurrentConfig()
-	base, err := filepath.Abs(cfg.OutPutPath)
-	if err != nil {
-		writeJSON(writer, commonResp{ErrNo: 500, ErrMsg: "获取根目录绝对路径失败: " + err.Error()})
-		return
-	}
 
 	type Result struct {
 		Path    string `json:"path"`
@@ -2225,13 +2341,13 @@ func batchDeleteFiles(writer http.ResponseWriter, r *http.Request) {
 	results := make([]Result, 0, len(body.Paths))
 
 	for _, path := range body.Paths {
-		absPath, err := getSafePath(cfg.OutPutPath, path)
-		if err != nil || absPath == base {
+		storagePaths, err := resolveStoragePaths(cfg, path)
+		if err != nil || isStorageRootPath(storagePaths) {
 			results = append(results, Result{Path: path, Success: false, Message: "禁止操作根目录或越权路径"})
 			continue
 		}
 
-		if err := os.RemoveAll(absPath); err != nil {
+		if err := removeStoragePaths(storagePaths); err != nil {
 			results = append(results, Result{Path: path, Success: false, Message: translateOSError(err)})
 		} else {
 			results = append(results, Result{Path: path, Success: true, Message: "成功"})
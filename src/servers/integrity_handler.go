@@ -0,0 +1,44 @@
+package servers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+)
+
+// startIntegrityVerify 在后台按完整性清单重新校验所有输出目录中的录制文件
+// 校验范围固定为配置中的输出目录，不接受外部传入的路径
+func startIntegrityVerify(writer http.ResponseWriter, r *http.Request) {
+	verifier := integrity.GetGlobalVerifier()
+	roots := configs.GetCurrentConfig().GetAllOutputPaths()
+	if err := verifier.Start(instance.GetInstance(r.Context()).Ctx, roots); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, integrity.ErrJobRunning) {
+			code = http.StatusConflict
+		}
+		writeJsonWithStatusCode(writer, code, commonResp{ErrNo: code, ErrMsg: err.Error()})
+		return
+	}
+	writeJsonWithStatusCode(writer, http.StatusAccepted, verifier.Status())
+}
+
+// getIntegrityVerify 获取最近一次完整性校验的进度和结果
+func getIntegrityVerify(writer http.ResponseWriter, r *http.Request) {
+	writeJSON(writer, integrity.GetGlobalVerifier().Status())
+}
+
+// renameIntegritySidecars 文件重命名后同步移动完整性附属文件
+func renameIntegritySidecars(oldPath, newPath string) {
+	if fi, err := os.Stat(newPath); err != nil || fi.IsDir() {
+		return
+	}
+	if err := integrity.Rename(oldPath, newPath); err != nil {
+		logrus.WithError(err).Warnf("移动完整性附属文件失败: %s", oldPath)
+	}
+}
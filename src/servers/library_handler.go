@@ -0,0 +1,324 @@
+package servers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/library"
+	"github.com/bililive-go/bililive-go/src/pkg/storagepool"
+)
+
+// defaultLibraryPageSize 未指定 limit 时的默认分页大小
+const defaultLibraryPageSize = 100
+
+// RegisterLibraryHandlers 注册录播资料库相关的 HTTP 处理器
+// 注意：r 已经是 /api 前缀的子路由器
+func RegisterLibraryHandlers(r *mux.Router, lm *library.Manager) {
+	if lm == nil {
+		return
+	}
+
+	// 搜索录制记录
+	r.HandleFunc("/library/recordings", makeLibrarySearchHandler(lm)).Methods("GET")
+
+	// 获取单条录制记录
+	r.HandleFunc("/library/recordings/{id}", makeLibraryGetRecordingHandler(lm)).Methods("GET")
+
+	// 汇总统计
+	r.HandleFunc("/library/stats", makeLibraryStatsHandler(lm)).Methods("GET")
+
+	// 重新扫描输出目录
+	r.HandleFunc("/library/rescan", makeLibraryRescanHandler(lm)).Methods("POST")
+
+	// 获取扫描状态
+	r.HandleFunc("/library/rescan", makeLibraryScanStatusHandler(lm)).Methods("GET")
+
+	// 流质量遥测：按直播间或开播会话列出录制的遥测记录
+	r.HandleFunc("/library/telemetry", makeLibraryTelemetryListHandler(lm)).Methods("GET")
+
+	// 流质量遥测：单次录制的汇总和采样点时间序列
+	r.HandleFunc("/library/telemetry/{id}", makeLibraryTelemetryHandler(lm)).Methods("GET")
+}
+
+// makeLibrarySearchHandler 按条件搜索录制记录
+// 例如：?platform=huya&host=xxx&from=2024-03-01&to=2024-04-01&min_duration=7200&sort=start_time&order=desc
+func makeLibrarySearchHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseLibraryQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := lm.Search(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fillStorageLocation(result.Recordings...)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// parseLibraryQuery 解析搜索参数
+func parseLibraryQuery(values url.Values) (library.Query, error) {
+	q := library.Query{
+		Keyword:        values.Get("q"),
+		LiveID:         values.Get("live_id"),
+		Platform:       values.Get("platform"),
+		HostName:       values.Get("host"),
+		Ext:            values.Get("ext"),
+		VideoCodec:     values.Get("codec"),
+		PipelineStatus: values.Get("pipeline_status"),
+		UploadStatus:   values.Get("upload_status"),
+		SortBy:         values.Get("sort"),
+		SortDesc:       !strings.EqualFold(values.Get("order"), "asc"),
+		Limit:          defaultLibraryPageSize,
+	}
+
+	var err error
+	if v := values.Get("session_id"); v != "" {
+		if q.SessionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return q, fmt.Errorf("invalid session_id: %s", v)
+		}
+	}
+	if v := values.Get("from"); v != "" {
+		if q.StartFrom, err = parseLibraryTime(v); err != nil {
+			return q, fmt.Errorf("invalid from: %s", v)
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.StartTo, err = parseLibraryTime(v); err != nil {
+			return q, fmt.Errorf("invalid to: %s", v)
+		}
+	}
+	if v := values.Get("min_duration"); v != "" {
+		if q.MinDuration, err = strconv.ParseFloat(v, 64); err != nil {
+			return q, fmt.Errorf("invalid min_duration: %s", v)
+		}
+	}
+	if v := values.Get("max_duration"); v != "" {
+		if q.MaxDuration, err = strconv.ParseFloat(v, 64); err != nil {
+			return q, fmt.Errorf("invalid max_duration: %s", v)
+		}
+	}
+	if v := values.Get("min_size"); v != "" {
+		size, err := configs.ParseByteSize(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid min_size: %s", v)
+		}
+		q.MinSize = int64(size)
+	}
+	if v := values.Get("max_size"); v != "" {
+		size, err := configs.ParseByteSize(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid max_size: %s", v)
+		}
+		q.MaxSize = int64(size)
+	}
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid limit: %s", v)
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid offset: %s", v)
+		}
+	}
+	return q, nil
+}
+
+// parseLibraryTime 解析时间参数，支持日期、日期时间、RFC3339 和 Unix 时间戳
+func parseLibraryTime(v string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format: %s", v)
+}
+
+// fillStorageLocation 计算录制文件所在的存储根目录及相对路径
+// 文件不在文件浏览覆盖的根目录下时保持为空
+func fillStorageLocation(recs ...*library.Recording) {
+	roots := configs.GetCurrentConfig().GetStorageRoots()
+	for _, rec := range recs {
+		if root, rel, ok := storagepool.FindRoot(roots, rec.Path); ok {
+			rec.StorageRoot = root
+			rec.RelPath = filepath.ToSlash(rel)
+		}
+	}
+}
+
+// makeLibraryGetRecordingHandler 获取单条录制记录
+func makeLibraryGetRecordingHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "invalid recording id", http.StatusBadRequest)
+			return
+		}
+
+		rec, err := lm.GetRecording(r.Context(), id)
+		if errors.Is(err, library.ErrRecordingNotFound) {
+			http.Error(w, "recording not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fillStorageLocation(rec)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rec)
+	}
+}
+
+// makeLibraryStatsHandler 获取资料库汇总统计
+func makeLibraryStatsHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := lm.GetStats(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// makeLibraryRescanHandler 在后台重新扫描所有输出目录
+// 扫描范围固定为配置中的输出目录，不接受外部传入的路径
+func makeLibraryRescanHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roots := configs.GetCurrentConfig().GetAllOutputPaths()
+		if err := lm.StartRescan(roots); err != nil {
+			if errors.Is(err, library.ErrScanRunning) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(lm.GetScanStatus())
+	}
+}
+
+// makeLibraryScanStatusHandler 获取扫描状态
+func makeLibraryScanStatusHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lm.GetScanStatus())
+	}
+}
+
+// makeLibraryTelemetryListHandler 列出流质量遥测记录（不含采样点）
+// 例如：?live_id=xxx&session_id=12&limit=20
+func makeLibraryTelemetryListHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		q := library.TelemetryQuery{
+			LiveID: values.Get("live_id"),
+			Limit:  defaultLibraryPageSize,
+		}
+		var err error
+		if v := values.Get("session_id"); v != "" {
+			if q.SessionID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				http.Error(w, "invalid session_id: "+v, http.StatusBadRequest)
+				return
+			}
+		}
+		if v := values.Get("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid limit: "+v, http.StatusBadRequest)
+				return
+			}
+		}
+		if v := values.Get("offset"); v != "" {
+			if q.Offset, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid offset: "+v, http.StatusBadRequest)
+				return
+			}
+		}
+
+		list, err := lm.ListTelemetry(r.Context(), q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []*library.Telemetry{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// makeLibraryTelemetryHandler 获取单次录制的流质量遥测，samples 为按时间排序的采样点
+// 可用 from/to 限定时间范围，格式同资料库搜索
+func makeLibraryTelemetryHandler(lm *library.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			http.Error(w, "invalid telemetry id", http.StatusBadRequest)
+			return
+		}
+		var from, to time.Time
+		if v := r.URL.Query().Get("from"); v != "" {
+			if from, err = parseLibraryTime(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := r.URL.Query().Get("to"); v != "" {
+			if to, err = parseLibraryTime(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		telemetry, err := lm.GetTelemetry(r.Context(), id)
+		if errors.Is(err, library.ErrTelemetryNotFound) {
+			http.Error(w, "telemetry not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		samples, err := lm.ListTelemetrySamples(r.Context(), id, from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"telemetry": telemetry,
+			"samples":   samples,
+		})
+	}
+}
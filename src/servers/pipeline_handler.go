@@ -165,7 +165,9 @@ func makePipelineRetryTaskHandler(pm *pipeline.Manager) http.HandlerFunc {
 			return
 		}
 
-		if err := pm.RetryTask(id); err != nil {
+		// restart=true 时从头执行，否则从失败的阶段继续
+		fromStart := r.URL.Query().Get("restart") == "true"
+		if err := pm.RetryTask(id, fromStart); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
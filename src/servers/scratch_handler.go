@@ -0,0 +1,14 @@
+package servers
+
+import (
+	"net/http"
+
+	"github.com/bililive-go/bililive-go/src/pkg/filemover"
+)
+
+// getScratchMoves 获取临时目录分段的移动状态
+// 可通过 ?live_id=xxx 只查看某个直播间的移动任务
+func getScratchMoves(writer http.ResponseWriter, r *http.Request) {
+	jobs := filemover.GetGlobalMover().List(r.URL.Query().Get("live_id"))
+	writeJSON(writer, jobs)
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/library"
 	applog "github.com/bililive-go/bililive-go/src/log"
 	"github.com/bililive-go/bililive-go/src/pipeline"
 	bilisentry "github.com/bililive-go/bililive-go/src/pkg/sentry"
@@ -91,12 +92,17 @@ func initMux(ctx context.Context) *mux.Router {
 	apiRoute.HandleFunc("/lives/{id}/name-history", getLiveNameHistory).Methods("GET")   // 获取名称变更历史
 	apiRoute.HandleFunc("/lives/{id}/history", getLiveHistory).Methods("GET")            // 获取统一历史事件（支持分页筛选）
 	apiRoute.HandleFunc("/lives/{id}/switchStream", switchStreamHandler).Methods("POST") // 切换流设置（需要请求体，必须在通配符之前）
+	apiRoute.HandleFunc("/lives/{id}/stream.flv", getLiveStreamFLV).Methods("GET")       // HTTP-FLV 转发正在录制的直播流
+	apiRoute.HandleFunc("/lives/{id}/hls/{file}", getLiveHLSFile).Methods("GET")         // fMP4 HLS 直播与回看
 	apiRoute.HandleFunc("/lives/{id}/{action}", parseLiveAction).Methods("GET")          // 通配符路由必须放在最后
 	apiRoute.HandleFunc("/file/{path:.*}", getFileInfo).Methods("GET")
 	apiRoute.HandleFunc("/file/{path:.*}", renameFile).Methods("PUT")
 	apiRoute.HandleFunc("/file/{path:.*}", deleteFile).Methods("DELETE")
 	apiRoute.HandleFunc("/batch/file/rename", batchRenameFiles).Methods("PUT")
 	apiRoute.HandleFunc("/batch/file/delete", batchDeleteFiles).Methods("POST")
+	apiRoute.HandleFunc("/scratch/moves", getScratchMoves).Methods("GET")          // 临时目录分段移动状态
+	apiRoute.HandleFunc("/integrity/verify", startIntegrityVerify).Methods("POST") // 按完整性清单重新校验录制文件
+	apiRoute.HandleFunc("/integrity/verify", getIntegrityVerify).Methods("GET")    // 完整性校验进度和结果
 	apiRoute.HandleFunc("/cookies", getLiveHostCookie).Methods("GET")
 	apiRoute.HandleFunc("/cookies", putLiveHostCookie).Methods("PUT")
 
@@ -142,6 +148,13 @@ func initMux(ctx context.Context) *mux.Router {
 	inst := instance.GetInstance(ctx)
 	if pm := pipeline.GetManager(inst); pm != nil {
 		RegisterPipelineHandlers(apiRoute, pm)
+		// 截取录像片段，以 Pipeline 任务执行
+		apiRoute.HandleFunc("/file/{path:.*}/clip", makeClipFileHandler(pm, library.GetManager(inst))).Methods("POST")
+	}
+
+	// 录播资料库路由
+	if lm := library.GetManager(inst); lm != nil {
+		RegisterLibraryHandlers(apiRoute, lm)
 	}
 
 	// OSRP 开放直播录制协议路由
@@ -151,11 +164,8 @@ func initMux(ctx context.Context) *mux.Router {
 		CORSMiddleware(
 			http.StripPrefix(
 				"/files/",
-				http.FileServer(
-					http.Dir(
-						configs.GetCurrentConfig().OutPutPath,
-					),
-				),
+				// 在 out_put_path 与存储池的所有根目录中查找文件
+				http.FileServer(storagePoolFS{}),
 			),
 		),
 	)
@@ -0,0 +1,95 @@
+package servers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pkg/integrity"
+)
+
+// storagePath 文件浏览中的逻辑路径在某个存储根目录下的实际位置
+type storagePath struct {
+	Base string // 根目录绝对路径
+	Abs  string // 实际绝对路径
+}
+
+// resolveStoragePaths 将文件浏览中的逻辑路径映射到所有存储根目录下的安全路径（不检查是否存在）。
+// 文件浏览把 out_put_path 与存储池根目录合并成一个视图，同一相对路径可能同时存在于多个根目录下
+// （例如同一主播的目录分布在不同磁盘上）。
+func resolveStoragePaths(cfg *configs.Config, subPath string) ([]storagePath, error) {
+	roots := cfg.GetStorageRoots()
+	paths := make([]storagePath, 0, len(roots))
+	for _, root := range roots {
+		base, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		absPath, err := getSafePath(base, subPath)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, storagePath{Base: base, Abs: absPath})
+	}
+	return paths, nil
+}
+
+// existingStoragePaths 过滤出实际存在的路径
+func existingStoragePaths(paths []storagePath) []storagePath {
+	existing := make([]storagePath, 0, len(paths))
+	for _, p := range paths {
+		if _, err := os.Stat(p.Abs); err == nil {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
+
+// isStorageRootPath 判断逻辑路径是否指向某个存储根目录本身
+func isStorageRootPath(storagePaths []storagePath) bool {
+	for _, sp := range storagePaths {
+		if sp.Abs == sp.Base {
+			return true
+		}
+	}
+	return false
+}
+
+// removeStoragePaths 删除逻辑路径在所有存储根目录下的实际文件
+func removeStoragePaths(storagePaths []storagePath) error {
+	for _, sp := range existingStoragePaths(storagePaths) {
+		if err := os.RemoveAll(sp.Abs); err != nil {
+			return err
+		}
+		integrity.Remove(sp.Abs)
+	}
+	return nil
+}
+
+// isInsideBase 判断 path 是否位于 base 之内
+func isInsideBase(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	return err == nil && !strings.HasPrefix(rel, "..") && !filepath.IsAbs(rel)
+}
+
+// storagePoolFS 为 /files/ 提供的文件系统，按顺序在所有存储根目录中查找文件
+type storagePoolFS struct{}
+
+func (storagePoolFS) Open(name string) (http.File, error) {
+	var firstErr error
+	for _, root := range configs.GetCurrentConfig().GetStorageRoots() {
+		f, err := http.Dir(root).Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = os.ErrNotExist
+	}
+	return nil, firstErr
+}
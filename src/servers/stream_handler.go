@@ -0,0 +1,186 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/livehls"
+	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// getRecordingRecorder 获取正在录制的直播间的录制器，失败时已写入错误响应
+func getRecordingRecorder(writer http.ResponseWriter, r *http.Request) (recorders.Recorder, bool) {
+	inst := instance.GetInstance(r.Context())
+	liveID := types.LiveID(mux.Vars(r)["id"])
+
+	if _, ok := inst.Lives.Get(liveID); !ok {
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: fmt.Sprintf("live id: %s can not find", liveID),
+		})
+		return nil, false
+	}
+
+	recorderMgr, ok := inst.RecorderManager.(recorders.Manager)
+	if !ok {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: "录制管理器不可用",
+		})
+		return nil, false
+	}
+	recorder, err := recorderMgr.GetRecorder(r.Context(), liveID)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: "直播间未在录制中",
+		})
+		return nil, false
+	}
+	return recorder, true
+}
+
+// getLiveStreamFLV 以 HTTP-FLV 的形式转发正在录制的直播流
+// 数据来自录制器已接收的字节，不会向平台发起额外的拉流请求；
+// 新观看者先收到缓存的 FLV 头、序列头和最近一个 GOP，可以立即开始播放
+func getLiveStreamFLV(writer http.ResponseWriter, r *http.Request) {
+	recorder, ok := getRecordingRecorder(writer, r)
+	if !ok {
+		return
+	}
+	hub := recorder.GetStreamFanout()
+	if hub == nil {
+		writeJsonWithStatusCode(writer, http.StatusConflict, commonResp{
+			ErrNo:  http.StatusConflict,
+			ErrMsg: "当前录制不是 FLV 流，无法转发",
+		})
+		return
+	}
+
+	sub, err := hub.Subscribe()
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	defer sub.Close()
+
+	writer.Header().Set("Content-Type", "video/x-flv")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	writer.WriteHeader(http.StatusOK)
+	flusher, hasFlusher := writer.(http.Flusher)
+	if hasFlusher {
+		flusher.Flush()
+	}
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-sub.C():
+			if !ok {
+				// 录制结束或客户端过慢被断开
+				return
+			}
+			if _, err := writer.Write(data); err != nil {
+				return
+			}
+			if hasFlusher {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// hlsPlaylistWait 首次请求播放列表时等待第一个分段生成的最长时间
+const hlsPlaylistWait = 10 * time.Second
+
+// getLiveHLSFile 以 fMP4 HLS 的形式提供正在录制的直播流
+// index.m3u8 为直播滑动窗口，dvr.m3u8 包含可回看的全部分段；
+// 首次请求播放列表时才开始封装，会等待到下一个关键帧生成第一个分段
+func getLiveHLSFile(writer http.ResponseWriter, r *http.Request) {
+	recorder, ok := getRecordingRecorder(writer, r)
+	if !ok {
+		return
+	}
+	stream := recorder.GetLiveHLS()
+	if stream == nil || !stream.Touch() {
+		writeJsonWithStatusCode(writer, http.StatusConflict, commonResp{
+			ErrNo:  http.StatusConflict,
+			ErrMsg: "当前录制不是 FLV 流，无法转发",
+		})
+		return
+	}
+
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	file := mux.Vars(r)["file"]
+	switch {
+	case file == "index.m3u8" || file == "dvr.m3u8":
+		playlist, err := waitHLSPlaylist(r.Context(), stream, file == "dvr.m3u8")
+		if err != nil {
+			if lastErr := stream.LastError(); lastErr != nil {
+				err = fmt.Errorf("%w: %v", err, lastErr)
+			}
+			writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+				ErrNo:  http.StatusServiceUnavailable,
+				ErrMsg: err.Error(),
+			})
+			return
+		}
+		writer.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.Write([]byte(playlist))
+		return
+	case strings.HasPrefix(file, "init-") && strings.HasSuffix(file, ".mp4"):
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(file, "init-"), ".mp4"))
+		if err == nil {
+			if data, ok := stream.Init(version); ok {
+				writer.Header().Set("Content-Type", "video/mp4")
+				writer.Write(data)
+				return
+			}
+		}
+	case strings.HasPrefix(file, "seg-") && strings.HasSuffix(file, ".m4s"):
+		sequence, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(file, "seg-"), ".m4s"), 10, 64)
+		if err == nil {
+			if data, ok := stream.Segment(sequence); ok {
+				writer.Header().Set("Content-Type", "video/iso.segment")
+				writer.Write(data)
+				return
+			}
+		}
+	}
+	writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+		ErrNo:  http.StatusNotFound,
+		ErrMsg: "分段不存在或已过期",
+	})
+}
+
+// waitHLSPlaylist 等待第一个分段生成后返回播放列表
+func waitHLSPlaylist(ctx context.Context, stream *livehls.Stream, dvr bool) (string, error) {
+	deadline := time.Now().Add(hlsPlaylistWait)
+	for {
+		playlist, err := stream.Playlist(dvr)
+		if !errors.Is(err, livehls.ErrNotReady) || time.Now().After(deadline) {
+			return playlist, err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
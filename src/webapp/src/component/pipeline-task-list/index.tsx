@@ -18,7 +18,7 @@ const { Text, Paragraph } = Typography;
 const { Panel } = Collapse;
 
 // Pipeline 状态
-type PipelineStatus = 'pending' | 'running' | 'completed' | 'failed' | 'cancelled';
+type PipelineStatus = 'waiting' | 'pending' | 'running' | 'completed' | 'failed' | 'cancelled';
 
 // 阶段状态
 type StageStatus = 'pending' | 'running' | 'completed' | 'failed' | 'skipped';
@@ -41,8 +41,11 @@ interface RecordInfo {
 
 // 阶段配置
 interface StageConfig {
+  id?: string;
+  depends_on?: string[];
   name: string;
   enabled?: boolean;
+  when?: string;
   options?: Record<string, unknown>;
 }
 
@@ -58,6 +61,14 @@ interface StageResult {
   commands?: string[];
   logs?: string;
   error_message?: string;
+  attempts?: number;
+  // 并行组中因执行条件不满足而跳过的分支
+  sub_results?: StageResult[];
+  // DAG 管道中阶段的位置
+  stage_id?: string;
+  depends_on?: string[];
+  level?: number;
+  terminal?: boolean;
 }
 
 // Pipeline 任务
@@ -85,6 +96,7 @@ interface PipelineTask {
 interface PipelineStats {
   max_concurrent: number;
   running_count: number;
+  waiting_count: number;
   pending_count: number;
   completed_count: number;
   failed_count: number;
@@ -229,9 +241,11 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
     }
   };
 
-  handleRetry = async (taskId: number) => {
+  // 默认从失败的阶段继续，restart 为 true 时从头执行
+  handleRetry = async (taskId: number, restart = false) => {
     try {
-      const res = await fetch(`/api/pipeline/tasks/${taskId}/retry`, { method: 'POST' });
+      const query = restart ? '?restart=true' : '';
+      const res = await fetch(`/api/pipeline/tasks/${taskId}/retry${query}`, { method: 'POST' });
       if (res.ok) {
         message.success('任务已重新排队');
         this.loadData();
@@ -288,15 +302,27 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
     const labels: Record<string, string> = {
       'fix_flv': '修复FLV',
       'convert_mp4': '转换MP4',
+      'transcode': '转码',
+      'webhook': 'Webhook 通知',
+      's3_upload': 'S3 上传',
+      'webdav_upload': 'WebDAV 上传',
+      'metadata_sidecar': '媒体库元数据',
+      'danmaku_ass': '弹幕转字幕',
       'extract_cover': '提取封面',
       'cloud_upload': '云盘上传',
       'custom_command': '自定义命令',
+      'thumbnails': '时间轴缩略图',
+      'highlights': '高光检测',
+      'clip': '片段截取',
+      'session_merge': '会话合并',
     };
     return labels[stageName] || stageName;
   };
 
   getStatusTag = (status: PipelineStatus) => {
     switch (status) {
+      case 'waiting':
+        return <Tag icon={<ClockCircleOutlined />} color="default">等待关播</Tag>;
       case 'pending':
         return <Tag icon={<ClockCircleOutlined />} color="default">等待中</Tag>;
       case 'running':
@@ -458,6 +484,13 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
               <Space>
                 {this.getStageStatusIcon(result.status)}
                 <Text>{this.getStageLabel(result.stage_name)}</Text>
+                {result.stage_id && <Tag>{result.stage_id}</Tag>}
+                {result.depends_on && result.depends_on.length > 0 && (
+                  <Text type="secondary">依赖：{result.depends_on.join('、')}</Text>
+                )}
+                {result.attempts !== undefined && result.attempts > 1 && (
+                  <Text type="secondary">执行 {result.attempts} 次</Text>
+                )}
                 {result.status === 'failed' && (
                   <Text type="danger">失败</Text>
                 )}
@@ -467,6 +500,20 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
           >
             {this.renderFileList(result.input_files || [], '输入文件')}
             {this.renderFileList(result.output_files || [], '输出文件')}
+            {result.sub_results && result.sub_results.length > 0 && (
+              <div style={{ marginBottom: 8 }}>
+                <Text strong>跳过的并行分支：</Text>
+                {result.sub_results.map((sub, idx) => (
+                  <div key={idx} style={{ marginLeft: 16 }}>
+                    {this.getStageStatusIcon(sub.status)}
+                    <Text style={{ marginLeft: 4 }}>{this.getStageLabel(sub.stage_name)}</Text>
+                    {sub.logs && (
+                      <Text type="secondary" style={{ marginLeft: 8, fontSize: 12 }}>{sub.logs}</Text>
+                    )}
+                  </div>
+                ))}
+              </div>
+            )}
             {result.error_message && (
               <Alert
                 message="错误信息"
@@ -570,7 +617,12 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
             )}
             {(task.status === 'failed' || task.status === 'cancelled') && task.can_retry && (
               <Button icon={<PlayCircleOutlined />} onClick={() => this.handleRetry(task.id)}>
-                重新执行
+                继续执行
+              </Button>
+            )}
+            {(task.status === 'failed' || task.status === 'cancelled') && task.can_retry && (
+              <Button icon={<ReloadOutlined />} onClick={() => this.handleRetry(task.id, true)}>
+                从头执行
               </Button>
             )}
             {task.status !== 'running' && (
@@ -718,7 +770,7 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
               <Card size="small" onClick={() => this.setState({ statusFilter: 'all' })} style={{ cursor: 'pointer' }}>
                 <Statistic
                   title="全部"
-                  value={stats.running_count + stats.pending_count + stats.completed_count + stats.failed_count + stats.cancelled_count}
+                  value={stats.running_count + stats.waiting_count + stats.pending_count + stats.completed_count + stats.failed_count + stats.cancelled_count}
                 />
               </Card>
             </Col>
@@ -757,6 +809,7 @@ class PipelineTaskList extends Component<object, PipelineTaskListState> {
               options={[
                 { value: 'all', label: '全部' },
                 { value: 'running', label: '运行中' },
+                { value: 'waiting', label: '等待关播' },
                 { value: 'pending', label: '等待中' },
                 { value: 'completed', label: '已完成' },
                 { value: 'failed', label: '失败' },